@@ -3,6 +3,7 @@ module github.com/polarysfoundation/polarys-chain
 go 1.23.3
 
 require (
+	github.com/gorilla/websocket v1.5.3
 	github.com/polarysfoundation/pec-256 v0.1.1-beta
 	github.com/polarysfoundation/pm-256 v0.0.0-20250112065549-cb7b6eb92c94
 	github.com/polarysfoundation/polarys_db v1.0.0
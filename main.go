@@ -6,15 +6,20 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/polarysfoundation/polarys-chain/modules/accounts"
 	"github.com/polarysfoundation/polarys-chain/modules/common"
 	"github.com/polarysfoundation/polarys-chain/modules/core"
+	"github.com/polarysfoundation/polarys-chain/modules/core/consensus"
+	"github.com/polarysfoundation/polarys-chain/modules/core/consensus/poa"
+	"github.com/polarysfoundation/polarys-chain/modules/core/consensus/pos"
 	"github.com/polarysfoundation/polarys-chain/modules/core/consensus/pow"
 	"github.com/polarysfoundation/polarys-chain/modules/miner"
 	"github.com/polarysfoundation/polarys-chain/modules/node"
 	"github.com/polarysfoundation/polarys-chain/modules/params"
 	"github.com/polarysfoundation/polarys-chain/modules/prydb"
+	"github.com/polarysfoundation/polarys-chain/modules/rpc"
 	"github.com/sirupsen/logrus"
 )
 
@@ -29,18 +34,59 @@ func main() {
 
 	config := params.DefaultConfig
 	chainParams := params.Polarys
-	engine := pow.InitConsensus(chainParams.PowEngine.Epoch, chainParams.PowEngine.Difficulty, chainParams.PowEngine.Delay, chainParams.ChainID, []common.Address{addr})
+
+	var engine consensus.Engine
+	switch chainParams.Engine {
+	case params.PosEngineName:
+		// Until someone stakes, the bootstrap validators propose alone.
+		validators := chainParams.PosEngine.Validators
+		if len(validators) == 0 {
+			validators = []common.Address{addr}
+		}
+
+		engine = pos.InitConsensus(db, chainParams.PosEngine.Epoch, chainParams.PosEngine.Delay, chainParams.ChainID, chainParams.PosEngine.MinStake, validators)
+	case params.PoaEngineName:
+		// Without configured signers the local account signs alone.
+		signers := chainParams.PoaEngine.Signers
+		if len(signers) == 0 {
+			signers = []common.Address{addr}
+		}
+
+		engine = poa.InitConsensus(db, chainParams.PoaEngine.Epoch, chainParams.PoaEngine.Delay, chainParams.ChainID, signers)
+	default:
+		// Without a configured validator set the local account validates alone.
+		validators := chainParams.PowEngine.Validators
+		if len(validators) == 0 {
+			validators = []common.Address{addr}
+		}
+
+		engine = pow.InitConsensus(chainParams.PowEngine.Epoch, chainParams.PowEngine.Difficulty, chainParams.PowEngine.Delay, chainParams.ChainID, validators)
+	}
+	engine.Authorize(addr)
 
 	blockchain, _ := core.InitBlockchain(db, config, chainParams, engine, nil, logger)
-	engine.SelectValidator()
 
-	node, err := node.NewNode(db, logger, blockchain)
+	node, err := node.NewNode(db, logger, blockchain, engine)
 	if err != nil {
 		logger.Fatal(err)
 	}
 
+	if err := node.SetBootnodes(config.Bootnodes); err != nil {
+		logger.Fatal(err)
+	}
+	node.SetTargetPeers(config.TargetOutboundPeers)
+	node.SetBanDuration(time.Duration(config.PeerBanDuration) * time.Second)
+
 	go node.Run()
 
+	var rpcServer *rpc.Server
+	if config.RPCEnabled {
+		rpcServer = rpc.NewServer(blockchain, config.RPCAllowedOrigins, logger)
+		if err := rpcServer.Start(config.RPCAddr); err != nil {
+			logger.Fatal(err)
+		}
+	}
+
 	// Arrancamos los loops de blockchain y el worker de minería
 	blockchain.Start()
 	worker := miner.NewWorker(miner.NewMiner(addr, accounts), engine, blockchain, chainParams, logger)
@@ -53,6 +99,9 @@ func main() {
 	logger.Info("Shutting down node...")
 
 	// Paramos componentes en orden
+	if rpcServer != nil {
+		rpcServer.Stop()
+	}
 	worker.Stop()
 	blockchain.Stop()
 
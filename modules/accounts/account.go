@@ -132,7 +132,8 @@ func (a *Accounts) PubKey(account common.Address) (pec256.PubKey, error) {
 	return pec256.PubKey{}, fmt.Errorf("account not found")
 }
 
-func (a *Accounts) SignTX(account common.Address, tx *transaction.Transaction) (*transaction.Transaction, error) {
+// SignTX signs tx with account for the chain identified by chainID.
+func (a *Accounts) SignTX(account common.Address, tx *transaction.Transaction, chainID uint64) (*transaction.Transaction, error) {
 	a.mutex.RLock()
 	defer a.mutex.RUnlock()
 
@@ -145,7 +146,7 @@ func (a *Accounts) SignTX(account common.Address, tx *transaction.Transaction) (
 			return nil, fmt.Errorf("account is locked")
 		}
 
-		return wallet.SignTX(tx)
+		return wallet.SignTX(tx, chainID)
 	}
 
 	return nil, fmt.Errorf("account not found")
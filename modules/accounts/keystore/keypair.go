@@ -39,35 +39,22 @@ func (k *Keypair) address() common.Address {
 	return crypto.PubKeyToAddress(k.pub)
 }
 
-func (k *Keypair) signTX(tx *transaction.Transaction) (*transaction.Transaction, error) {
-	prefix := []byte{0xff}
-
-	b, err := common.Serialize([]interface{}{
-		prefix,
-		k.sharedKey(),
-		tx.Version(),
-		tx.To(),
-		tx.Nonce(),
-		tx.Data(),
-		tx.Value(),
-		tx.Payload(),
-	})
+func (k *Keypair) signTX(tx *transaction.Transaction, chainID uint64) (*transaction.Transaction, error) {
+	h, err := tx.SigningHash(chainID)
 	if err != nil {
 		return nil, err
 	}
 
-	h := crypto.Pm256(b)
-
 	signature := make([]byte, 64)
-	r, s, err := crypto.Sign(common.BytesToHash(h), k.priv)
+	r, s, err := crypto.Sign(h, k.priv)
 	if err != nil {
 		return nil, err
 	}
 
-	r.FillBytes(signature[32:])
-	s.FillBytes(signature[:32])
+	r.FillBytes(signature[:32])
+	s.FillBytes(signature[32:])
 
-	return tx.SignTransaction(signature), nil
+	return tx.SignTransaction(signature, k.pub), nil
 }
 
 func (k *Keypair) sign(data []byte) ([]byte, error) {
@@ -78,8 +65,8 @@ func (k *Keypair) sign(data []byte) ([]byte, error) {
 		return nil, err
 	}
 
-	r.FillBytes(signature[32:])
-	s.FillBytes(signature[:32])
+	r.FillBytes(signature[:32])
+	s.FillBytes(signature[32:])
 
 	return signature, nil
 }
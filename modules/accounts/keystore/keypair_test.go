@@ -0,0 +1,54 @@
+package keystore
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/polarysfoundation/polarys-chain/modules/common"
+	"github.com/polarysfoundation/polarys-chain/modules/core/transaction"
+	"github.com/polarysfoundation/polarys-chain/modules/crypto"
+)
+
+func newTestKeypair() *Keypair {
+	priv, pub := crypto.GenerateKey()
+
+	return &Keypair{pub: pub, priv: priv, timestamp: uint64(time.Now().Add(time.Hour).Unix())}
+}
+
+func TestKeypair_SignTX(t *testing.T) {
+	k := newTestKeypair()
+
+	to := common.BytesToAddress([]byte("receiver_addres"))
+	tx, err := transaction.NewTransaction(k.address(), to, big.NewInt(1000), nil, 0, 1, transaction.Legacy, nil, 1000000)
+	if err != nil {
+		t.Fatalf("NewTransaction() error = %v", err)
+	}
+
+	signed, err := k.signTX(tx, 7)
+	if err != nil {
+		t.Fatalf("signTX() error = %v", err)
+	}
+
+	if ok, err := signed.Verify(7); err != nil || !ok {
+		t.Errorf("Verify() = %v, %v, want true", ok, err)
+	}
+}
+
+func TestKeypair_Sign(t *testing.T) {
+	k := newTestKeypair()
+	data := []byte("message to sign")
+
+	signature, err := k.sign(data)
+	if err != nil {
+		t.Fatalf("sign() error = %v", err)
+	}
+
+	// r then s, big-endian.
+	r := new(big.Int).SetBytes(signature[:32])
+	s := new(big.Int).SetBytes(signature[32:])
+
+	if ok, err := crypto.Verify(common.BytesToHash(crypto.Pm256(data)), r, s, k.pub); err != nil || !ok {
+		t.Errorf("Verify() = %v, %v, want true", ok, err)
+	}
+}
@@ -65,12 +65,13 @@ func (w *Wallet) PubKey() pec256.PubKey {
 	return w.key.pub
 }
 
-func (w *Wallet) SignTX(tx *transaction.Transaction) (*transaction.Transaction, error) {
+// SignTX signs tx for the chain identified by chainID.
+func (w *Wallet) SignTX(tx *transaction.Transaction, chainID uint64) (*transaction.Transaction, error) {
 	if w.IsLocked() {
 		return nil, fmt.Errorf("wallet is locked")
 	}
 
-	return w.key.signTX(tx)
+	return w.key.signTX(tx, chainID)
 }
 
 func (w *Wallet) Sign(data []byte) ([]byte, error) {
@@ -11,6 +11,7 @@ import (
 type Block struct {
 	header       Header
 	transactions []transaction.Transaction
+	evidence     []Evidence
 	hash         common.Hash
 	sealHash     common.Hash
 	slotHash     common.Hash
@@ -34,17 +35,36 @@ func NewBlock(header Header, transactions []transaction.Transaction) *Block {
 	return blk
 }
 
+// FromHeader rebuilds a block received from the network. The header is
+// kept exactly as it was hashed by its producer.
+func FromHeader(header Header, transactions []transaction.Transaction) *Block {
+	blk := &Block{
+		header:       header,
+		transactions: transactions,
+	}
+
+	if blk.transactions == nil {
+		blk.transactions = make([]transaction.Transaction, 0)
+	}
+
+	blk.CalcHash()
+
+	return blk
+}
+
 func (b *Block) MarshalJSON() ([]byte, error) {
 	temp := struct {
-		Header       Header      `json:"header"`
-		Hash         common.Hash `json:"hash"`
-		Transactions uint64      `json:"transactions"`
-		SealHash     common.Hash `json:"seal_hash"`
-		SlotHash     common.Hash `json:"slot_hash"`
+		Header       Header                    `json:"header"`
+		Hash         common.Hash               `json:"hash"`
+		Transactions []transaction.Transaction `json:"transactions"`
+		Evidence     []Evidence                `json:"evidence,omitempty"`
+		SealHash     common.Hash               `json:"seal_hash"`
+		SlotHash     common.Hash               `json:"slot_hash"`
 	}{
 		Header:       b.header,
 		Hash:         b.hash,
-		Transactions: uint64(len(b.transactions)),
+		Transactions: b.transactions,
+		Evidence:     b.evidence,
 		SealHash:     b.sealHash,
 		SlotHash:     b.slotHash,
 	}
@@ -58,23 +78,18 @@ func (b *Block) MarshalJSON() ([]byte, error) {
 }
 
 func (b *Block) CalcHash() common.Hash {
-	data, err := b.header.marshal()
-	if err != nil {
-		panic(err)
-	}
-
-	h := crypto.Pm256(data)
-	b.hash = common.BytesToHash(h)
+	b.hash = b.header.Hash()
 	return b.hash
 }
 
 func (b *Block) UnmarshalJSON(data []byte) error {
 	temp := struct {
-		Header       Header      `json:"header"`
-		Hash         common.Hash `json:"hash"`
-		Transactions uint64      `json:"transactions"`
-		SealHash     common.Hash `json:"seal_hash"`
-		SlotHash     common.Hash `json:"slot_hash"`
+		Header       Header                    `json:"header"`
+		Hash         common.Hash               `json:"hash"`
+		Transactions []transaction.Transaction `json:"transactions"`
+		Evidence     []Evidence                `json:"evidence,omitempty"`
+		SealHash     common.Hash               `json:"seal_hash"`
+		SlotHash     common.Hash               `json:"slot_hash"`
 	}{}
 
 	err := json.Unmarshal(data, &temp)
@@ -86,7 +101,11 @@ func (b *Block) UnmarshalJSON(data []byte) error {
 	b.hash = temp.Hash
 	b.sealHash = temp.SealHash
 	b.slotHash = temp.SlotHash
-	b.transactions = make([]transaction.Transaction, temp.Transactions)
+	b.transactions = temp.Transactions
+	if b.transactions == nil {
+		b.transactions = make([]transaction.Transaction, 0)
+	}
+	b.evidence = temp.Evidence
 
 	return nil
 }
@@ -109,6 +128,61 @@ func (b *Block) AddTransaction(tx transaction.Transaction) {
 	b.transactions = append(b.transactions, tx)
 }
 
+// CalcTxRoot returns the Merkle root of the transaction hashes in block
+// order.
+func CalcTxRoot(txs []transaction.Transaction) common.Hash {
+	leaves := make([]common.Hash, len(txs))
+	for i := range txs {
+		leaves[i] = txs[i].Hash()
+	}
+
+	return crypto.MerkleRoot(leaves)
+}
+
+// VerifyTxRoot reports whether the header commits to the transactions the
+// block carries.
+func (b *Block) VerifyTxRoot() bool {
+	return CalcTxRoot(b.transactions) == b.header.TxRoot
+}
+
+// SetEvidence attaches the double-signing evidence committed to by the
+// header evidence root.
+func (b *Block) SetEvidence(evidence []Evidence) {
+	b.evidence = evidence
+}
+
+func (b *Block) Evidence() []Evidence {
+	return b.evidence
+}
+
+// VerifyEvidenceRoot reports whether the header commits to the evidence
+// the block carries.
+func (b *Block) VerifyEvidenceRoot() bool {
+	return CalcEvidenceRoot(b.evidence) == b.header.EvidenceRoot
+}
+
+func (b *Block) EvidenceRoot() common.Hash {
+	return b.header.EvidenceRoot
+}
+
+// MissingEvidence reports whether the header commits to evidence the block
+// does not carry, as with headers downloaded ahead of their body.
+func (b *Block) MissingEvidence() bool {
+	return b.header.EvidenceRoot != (common.Hash{}) && len(b.evidence) == 0
+}
+
+func (b *Block) Header() Header {
+	return b.header
+}
+
+func (b *Block) TxRoot() common.Hash {
+	return b.header.TxRoot
+}
+
+func (b *Block) StateRoot() common.Hash {
+	return b.header.StateRoot
+}
+
 func (b *Block) Timestamp() uint64 {
 	return b.header.Timestamp
 }
@@ -157,6 +231,11 @@ func (b *Block) GasUsed() uint64 {
 	return b.header.GasUsed
 }
 
+// BaseFee returns the fee per gas burned by every transaction of the block.
+func (b *Block) BaseFee() uint64 {
+	return b.header.BaseFee
+}
+
 func (b *Block) Difficulty() uint64 {
 	return b.header.Difficulty
 }
@@ -200,9 +279,16 @@ func copyBlock(b *Block) *Block {
 	transactions := make([]transaction.Transaction, len(b.transactions))
 	copy(transactions, b.transactions)
 
+	var evidence []Evidence
+	if len(b.evidence) > 0 {
+		evidence = make([]Evidence, len(b.evidence))
+		copy(evidence, b.evidence)
+	}
+
 	return &Block{
 		header:       b.header,
 		transactions: transactions,
+		evidence:     evidence,
 		hash:         b.hash,
 		sealHash:     b.sealHash,
 		slotHash:     b.slotHash,
@@ -2,6 +2,7 @@ package block
 
 import (
 	"encoding/json"
+	"math/big"
 	"reflect"
 	"testing"
 
@@ -196,4 +197,91 @@ func TestBlock_Hash_Sensitivity(t *testing.T) {
 		t.Logf("Header1: %+v", header1)
 		t.Logf("Header2: %+v", header2)
 	}
-}
\ No newline at end of file
+}
+
+func newTestTransactions(t *testing.T, n int) []transaction.Transaction {
+	t.Helper()
+
+	from := common.BytesToAddress([]byte("sender_address_"))
+	to := common.BytesToAddress([]byte("receiver_addres"))
+
+	txs := make([]transaction.Transaction, 0, n)
+	for i := 0; i < n; i++ {
+		tx, err := transaction.NewTransaction(from, to, big.NewInt(1000), nil, uint64(i), 0, transaction.Legacy, nil, 1000000)
+		if err != nil {
+			t.Fatalf("NewTransaction() error = %v", err)
+		}
+		txs = append(txs, *tx)
+	}
+
+	return txs
+}
+
+func TestBlock_TxRoot(t *testing.T) {
+	txs := newTestTransactions(t, 3)
+
+	header := newTestHeader()
+	header.TxRoot = CalcTxRoot(txs)
+	block := NewBlock(header, txs)
+
+	if !block.VerifyTxRoot() {
+		t.Errorf("block.VerifyTxRoot() = false, want true")
+	}
+
+	if root := CalcTxRoot(nil); root != (common.Hash{}) {
+		t.Errorf("CalcTxRoot(nil) = %v, want zero hash", root)
+	}
+
+	reordered := []transaction.Transaction{txs[1], txs[0], txs[2]}
+	if CalcTxRoot(reordered) == header.TxRoot {
+		t.Errorf("CalcTxRoot() does not depend on transaction order")
+	}
+
+	if NewBlock(header, txs[:2]).VerifyTxRoot() {
+		t.Errorf("block.VerifyTxRoot() = true for a block missing a transaction")
+	}
+
+	other := newTestHeader()
+	other.TxRoot = CalcTxRoot(txs[:2])
+	if NewBlock(header, txs).CalcHash() == NewBlock(other, txs).CalcHash() {
+		t.Errorf("block hash does not cover the transactions root")
+	}
+
+	other = newTestHeader()
+	other.TxRoot = header.TxRoot
+	other.StateRoot = common.BytesToHash([]byte("state"))
+	if NewBlock(header, txs).CalcHash() == NewBlock(other, txs).CalcHash() {
+		t.Errorf("block hash does not cover the state root")
+	}
+}
+
+func TestBlock_JSONKeepsTransactions(t *testing.T) {
+	txs := newTestTransactions(t, 2)
+
+	header := newTestHeader()
+	header.TxRoot = CalcTxRoot(txs)
+	block := NewBlock(header, txs)
+	block.CalcHash()
+
+	b, err := json.Marshal(block)
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+
+	var decoded Block
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		t.Fatalf("json.Unmarshal() error = %v", err)
+	}
+
+	if len(decoded.Transactions()) != len(txs) {
+		t.Fatalf("decoded block has %d transactions, want %d", len(decoded.Transactions()), len(txs))
+	}
+
+	if !decoded.VerifyTxRoot() {
+		t.Errorf("decoded block.VerifyTxRoot() = false, want true")
+	}
+
+	if decoded.Hash() != block.Hash() {
+		t.Errorf("decoded block.Hash() = %v, want %v", decoded.Hash(), block.Hash())
+	}
+}
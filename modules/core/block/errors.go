@@ -0,0 +1,9 @@
+package block
+
+import "errors"
+
+var (
+	ErrInvalidSignature = errors.New("invalid block signature")
+	ErrNoConflict       = errors.New("evidence headers do not conflict")
+	ErrEvidenceSigner   = errors.New("evidence header not signed by its validator")
+)
@@ -0,0 +1,83 @@
+package block
+
+import (
+	"bytes"
+
+	"github.com/polarysfoundation/polarys-chain/modules/common"
+	"github.com/polarysfoundation/polarys-chain/modules/crypto"
+)
+
+// Evidence proves that a validator signed two different blocks at the
+// same height. It holds the two signed headers and nothing else, so any
+// node can check it without having seen the blocks.
+type Evidence struct {
+	First  Header `json:"first"`
+	Second Header `json:"second"`
+}
+
+// NewEvidence orders the two headers by hash, so the same offence always
+// gives the same evidence.
+func NewEvidence(a, b Header) Evidence {
+	hashA, hashB := a.Hash(), b.Hash()
+	if bytes.Compare(hashA.Bytes(), hashB.Bytes()) > 0 {
+		a, b = b, a
+	}
+
+	return Evidence{First: a, Second: b}
+}
+
+// Offender returns the validator that signed both headers.
+func (e *Evidence) Offender() common.Address {
+	return e.First.Validator
+}
+
+func (e *Evidence) Height() uint64 {
+	return e.First.Height
+}
+
+// Hash identifies the evidence by the hashes of its headers, whatever
+// their order.
+func (e *Evidence) Hash() common.Hash {
+	first, second := e.First.Hash(), e.Second.Hash()
+	if bytes.Compare(first.Bytes(), second.Bytes()) > 0 {
+		first, second = second, first
+	}
+
+	return common.BytesToHash(crypto.Pm256(append(first.Bytes(), second.Bytes()...)))
+}
+
+// Verify checks that the headers are two different blocks at the same
+// height, both signed for chainID by the key of the same validator.
+func (e *Evidence) Verify(chainID uint64) error {
+	if e.First.Height != e.Second.Height || e.First.Validator != e.Second.Validator {
+		return ErrNoConflict
+	}
+
+	if e.First.Hash() == e.Second.Hash() {
+		return ErrNoConflict
+	}
+
+	for _, header := range []Header{e.First, e.Second} {
+		signer, err := FromHeader(header, nil).Signer(chainID)
+		if err != nil {
+			return err
+		}
+
+		if signer != header.Validator {
+			return ErrEvidenceSigner
+		}
+	}
+
+	return nil
+}
+
+// CalcEvidenceRoot returns the Merkle root of the evidence hashes in block
+// order.
+func CalcEvidenceRoot(evidence []Evidence) common.Hash {
+	leaves := make([]common.Hash, len(evidence))
+	for i := range evidence {
+		leaves[i] = evidence[i].Hash()
+	}
+
+	return crypto.MerkleRoot(leaves)
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 
 	"github.com/polarysfoundation/polarys-chain/modules/common"
+	"github.com/polarysfoundation/polarys-chain/modules/crypto"
 )
 
 var (
@@ -20,6 +21,7 @@ type Header struct {
 	GasTarget       uint64         `json:"gas_target"`
 	GasTip          uint64         `json:"gas_tip"`
 	GasUsed         uint64         `json:"gas_used"`
+	BaseFee         uint64         `json:"base_fee"`
 	Difficulty      uint64         `json:"difficulty"`
 	TotalDifficulty uint64         `json:"total_difficulty"`
 	Data            []byte         `json:"data"`
@@ -27,14 +29,17 @@ type Header struct {
 	ConsensusProof  []byte         `json:"consensus_proof"`
 	Signature       []byte         `json:"signature"`
 	Validator       common.Address `json:"validator"`
+	TxRoot          common.Hash    `json:"tx_root"`
+	StateRoot       common.Hash    `json:"state_root"`
+	EvidenceRoot    common.Hash    `json:"evidence_root"`
 	Size            uint64         `json:"size"`
 }
 
 func (h *Header) CalculateSize() uint64 {
 	size := uint64(0)
 
-	// Adding 9 fields with uint64 value
-	size += uint64(9 * uint64Size)
+	// Adding 10 fields with uint64 value
+	size += uint64(10 * uint64Size)
 
 	// Adding prev(hash)
 	size += uint64(hashSize)
@@ -42,6 +47,9 @@ func (h *Header) CalculateSize() uint64 {
 	// Adding address size
 	size += uint64(addressSize)
 
+	// Adding tx root, state root and evidence root
+	size += uint64(3 * hashSize)
+
 	// Adding validator proof size
 	size += calcSliceSize(h.ValidatorProof)
 
@@ -59,6 +67,17 @@ func (h *Header) CalculateSize() uint64 {
 	return size
 }
 
+// Hash returns the hash of the block the header belongs to. The signature
+// is not covered.
+func (h *Header) Hash() common.Hash {
+	data, err := h.marshal()
+	if err != nil {
+		panic(err)
+	}
+
+	return common.BytesToHash(crypto.Pm256(data))
+}
+
 func calcSliceSize(b []byte) uint64 {
 	return uint64(len(b))
 }
@@ -72,12 +91,16 @@ func (h *Header) unmarshal(data []byte) error {
 		GasTarget       uint64         `json:"gas_target"`
 		GasTip          uint64         `json:"gas_tip"`
 		GasUsed         uint64         `json:"gas_used"`
+		BaseFee         uint64         `json:"base_fee"`
 		Difficulty      uint64         `json:"difficulty"`
 		TotalDifficulty uint64         `json:"total_difficulty"`
 		Data            []byte         `json:"data"`
 		ValidatorProof  []byte         `json:"validator_proof"`
 		ConsensusProof  []byte         `json:"consensus_proof"`
 		Validator       common.Address `json:"validator"`
+		TxRoot          common.Hash    `json:"tx_root"`
+		StateRoot       common.Hash    `json:"state_root"`
+		EvidenceRoot    common.Hash    `json:"evidence_root"`
 		Size            uint64         `json:"size"`
 	}{}
 
@@ -93,12 +116,16 @@ func (h *Header) unmarshal(data []byte) error {
 	h.GasTarget = temp.GasTarget
 	h.GasTip = temp.GasTip
 	h.GasUsed = temp.GasUsed
+	h.BaseFee = temp.BaseFee
 	h.Difficulty = temp.Difficulty
 	h.TotalDifficulty = temp.TotalDifficulty
 	h.Data = temp.Data
 	h.ValidatorProof = temp.ValidatorProof
 	h.ConsensusProof = temp.ConsensusProof
 	h.Validator = temp.Validator
+	h.TxRoot = temp.TxRoot
+	h.StateRoot = temp.StateRoot
+	h.EvidenceRoot = temp.EvidenceRoot
 	h.Size = temp.Size
 
 	return nil
@@ -113,12 +140,16 @@ func (h *Header) marshal() ([]byte, error) {
 		GasTarget       uint64         `json:"gas_target"`
 		GasTip          uint64         `json:"gas_tip"`
 		GasUsed         uint64         `json:"gas_used"`
+		BaseFee         uint64         `json:"base_fee"`
 		Difficulty      uint64         `json:"difficulty"`
 		TotalDifficulty uint64         `json:"total_difficulty"`
 		Data            []byte         `json:"data"`
 		ValidatorProof  []byte         `json:"validator_proof"`
 		ConsensusProof  []byte         `json:"consensus_proof"`
 		Validator       common.Address `json:"validator"`
+		TxRoot          common.Hash    `json:"tx_root"`
+		StateRoot       common.Hash    `json:"state_root"`
+		EvidenceRoot    common.Hash    `json:"evidence_root"`
 		Size            uint64         `json:"size"`
 	}{
 		Height:          h.Height,
@@ -128,12 +159,16 @@ func (h *Header) marshal() ([]byte, error) {
 		GasTarget:       h.GasTarget,
 		GasTip:          h.GasTip,
 		GasUsed:         h.GasUsed,
+		BaseFee:         h.BaseFee,
 		Difficulty:      h.Difficulty,
 		TotalDifficulty: h.TotalDifficulty,
 		Data:            h.Data,
 		ValidatorProof:  h.ValidatorProof,
 		ConsensusProof:  h.ConsensusProof,
 		Validator:       h.Validator,
+		TxRoot:          h.TxRoot,
+		StateRoot:       h.StateRoot,
+		EvidenceRoot:    h.EvidenceRoot,
 		Size:            h.Size,
 	}
 
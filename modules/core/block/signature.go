@@ -0,0 +1,53 @@
+package block
+
+import (
+	"math/big"
+
+	pec256 "github.com/polarysfoundation/pec-256"
+	"github.com/polarysfoundation/polarys-chain/modules/common"
+	"github.com/polarysfoundation/polarys-chain/modules/crypto"
+)
+
+// A block signature is the r and s values signed by the validator followed
+// by its public key. PEC-256 signatures do not allow to recover the key, so
+// it travels with the block and the signer is the address of that key.
+const (
+	signatureValuesLen = 64
+	SignatureLen       = signatureValuesLen + 32
+)
+
+var blockSignaturePrefix = []byte{0xfb}
+
+// SigningData returns what the validator signs for the block on chainID.
+// It commits to the block hash, so to every header field but the
+// signature.
+func (b *Block) SigningData(chainID uint64) []byte {
+	hash := b.header.Hash()
+
+	data := make([]byte, 0, len(blockSignaturePrefix)+8+common.HashLen)
+	data = append(data, blockSignaturePrefix...)
+	data = append(data, common.Uint64ToBytes(chainID)...)
+	data = append(data, hash.Bytes()...)
+
+	return data
+}
+
+// Signer checks the block signature and returns the address of the key
+// that produced it.
+func (b *Block) Signer(chainID uint64) (common.Address, error) {
+	signature := b.header.Signature
+	if len(signature) != SignatureLen {
+		return common.Address{}, ErrInvalidSignature
+	}
+
+	r := new(big.Int).SetBytes(signature[:32])
+	s := new(big.Int).SetBytes(signature[32:signatureValuesLen])
+	pub := pec256.BytesToPubKey(signature[signatureValuesLen:])
+
+	digest := common.BytesToHash(crypto.Pm256(b.SigningData(chainID)))
+	if ok, err := crypto.Verify(digest, r, s, pub); err != nil || !ok {
+		return common.Address{}, ErrInvalidSignature
+	}
+
+	return crypto.PubKeyToAddress(pub), nil
+}
@@ -2,6 +2,7 @@ package core
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
@@ -11,6 +12,7 @@ import (
 	"github.com/polarysfoundation/polarys-chain/modules/core/blockpool"
 	"github.com/polarysfoundation/polarys-chain/modules/core/consensus"
 	"github.com/polarysfoundation/polarys-chain/modules/core/gaspool"
+	"github.com/polarysfoundation/polarys-chain/modules/core/state"
 	"github.com/polarysfoundation/polarys-chain/modules/core/transaction"
 	"github.com/polarysfoundation/polarys-chain/modules/core/txpool"
 	"github.com/polarysfoundation/polarys-chain/modules/params"
@@ -37,6 +39,14 @@ type Blockchain struct {
 	wg              sync.WaitGroup
 	cancel          context.CancelFunc
 	gaspool         *gaspool.GasPool
+	processor       *StateProcessor
+	stateCache      *state.Cache
+	attestations    map[common.Hash]map[common.Address]*consensus.Attestation // By checkpoint hash
+	finalized       *prydb.FinalityRecord
+
+	signedHeaders    map[uint64]map[common.Address]block.Header // By height and validator
+	evidencePool     map[common.Hash]block.Evidence
+	includedEvidence map[common.Hash]uint64 // Offence height by evidence hash
 
 	logs *logrus.Logger
 	db   *prydb.Database
@@ -52,8 +62,8 @@ func InitBlockchain(db *prydb.Database, config *params.Config, chainParams *para
 
 	bc := &Blockchain{
 		chainID:         chainParams.ChainID,
-		epoch:           chainParams.PowEngine.Epoch,
-		delay:           chainParams.PowEngine.Delay,
+		epoch:           chainParams.Epoch(),
+		delay:           chainParams.Delay(),
 		chainConfig:     config,
 		db:              db,
 		localBlocks:     make([]*block.Block, 0),
@@ -61,8 +71,13 @@ func InitBlockchain(db *prydb.Database, config *params.Config, chainParams *para
 		totalDifficulty: 0,
 		logs:            logs,
 		gasTarget:       1000000,
+		attestations:    make(map[common.Hash]map[common.Address]*consensus.Attestation),
 		ctx:             ctx,
 		cancel:          cancel,
+
+		signedHeaders:    make(map[uint64]map[common.Address]block.Header),
+		evidencePool:     make(map[common.Hash]block.Evidence),
+		includedEvidence: make(map[common.Hash]uint64),
 	}
 
 	if !hasGenesisBlock(db) {
@@ -145,7 +160,7 @@ func InitBlockchain(db *prydb.Database, config *params.Config, chainParams *para
 			Timestamp:       uint64(time.Now().Unix()),
 			GasTarget:       bc.gasTarget,
 			Difficulty:      bc.difficulty,
-			TotalDifficulty: bc.totalDifficulty,
+			TotalDifficulty: bc.genesis.TotalDifficulty() + bc.difficulty,
 			Validator:       common.Address{},
 			ValidatorProof:  []byte{},
 			ConsensusProof:  bc.consensusProof,
@@ -153,6 +168,7 @@ func InitBlockchain(db *prydb.Database, config *params.Config, chainParams *para
 			Signature:       []byte{},
 			GasTip:          0,
 			GasUsed:         0,
+			BaseFee:         gaspool.CalcBaseFee(bc.genesis.GasTarget(), bc.genesis.GasUsed(), bc.genesis.BaseFee()),
 		}
 
 		header.CalculateSize()
@@ -161,13 +177,16 @@ func InitBlockchain(db *prydb.Database, config *params.Config, chainParams *para
 
 		latestBlock = blk
 
-		bc.latestBlock = blk
-		bc.totalDifficulty += blk.Difficulty()
-
 		bc.db.CommitBlock(latestBlock)
 	}
 
 	bc.latestBlock = latestBlock
+	bc.totalDifficulty = latestBlock.TotalDifficulty()
+
+	if err := bc.loadFinalized(); err != nil {
+		bc.logs.WithError(err).Error("Failed to load finalized checkpoint")
+		return nil, err
+	}
 
 	bc.logs.WithFields(logrus.Fields{
 		"latest_height":    latestBlock.Height(),
@@ -182,11 +201,14 @@ func InitBlockchain(db *prydb.Database, config *params.Config, chainParams *para
 	}
 
 	bc.gaspool = gaspool.InitGasPool()
+	bc.gaspool.SetHead(latestBlock.GasTarget(), latestBlock.GasUsed(), latestBlock.BaseFee())
 
 	bc.consensus = engine
 	bc.consensusProof = consensusProof
+	bc.processor = NewStateProcessor(engine, bc.chainID)
+	bc.stateCache = state.NewCache()
 
-	txPool, err := txpool.InitTxPool(db, common.Address{}, uint64(config.MinimalGasTip), consensusProof, bc.gaspool, bc.latestBlock)
+	txPool, err := txpool.InitTxPool(db, common.Address{}, txpool.NewConfig(config), consensusProof, bc.gaspool, bc.latestBlock, bc.chainID)
 	if err != nil {
 		bc.logs.WithError(err).Error("Failed to initialize transaction pool")
 		return nil, err
@@ -222,6 +244,102 @@ func (bc *Blockchain) GetTransactions() []transaction.Transaction {
 	return bc.txPool.GetTransactions()
 }
 
+// SubmitTransaction validates a signed transaction submitted to this node
+// against the latest state and inserts it into the transaction pool. The
+// pool journals it so it survives a restart.
+func (bc *Blockchain) SubmitTransaction(tx *transaction.Transaction) (common.Hash, error) {
+	if err := bc.checkTransaction(tx); err != nil {
+		return common.Hash{}, err
+	}
+
+	if err := bc.txPool.AddLocal(*tx); err != nil {
+		if !errors.Is(err, txpool.ErrJournal) {
+			bc.logs.WithFields(logrus.Fields{
+				"hash": tx.Hash().String(),
+				"from": tx.From().String(),
+			}).WithError(err).Debug("Transaction rejected")
+			return common.Hash{}, err
+		}
+
+		bc.logs.WithField("hash", tx.Hash().String()).WithError(err).Warn("Failed to journal local transaction")
+	}
+
+	bc.logs.WithFields(logrus.Fields{
+		"hash":  tx.Hash().String(),
+		"from":  tx.From().String(),
+		"nonce": tx.Nonce(),
+	}).Info("Transaction submitted")
+
+	return tx.Hash(), nil
+}
+
+// AddRemoteTransaction validates a transaction received from a peer and
+// inserts it into the transaction pool.
+func (bc *Blockchain) AddRemoteTransaction(tx *transaction.Transaction) error {
+	if err := bc.checkTransaction(tx); err != nil {
+		return err
+	}
+
+	return bc.txPool.AddTransaction(*tx)
+}
+
+// checkTransaction runs the checks that do not depend on the pool or the
+// account state, so a malformed submission gets its own error.
+func (bc *Blockchain) checkTransaction(tx *transaction.Transaction) error {
+	if tx == nil {
+		return ErrNilTransaction
+	}
+
+	if bc.chainConfig.MaxTxSize > 0 && len(tx.Bytes()) > int(bc.chainConfig.MaxTxSize) {
+		return ErrOversizedTransaction
+	}
+
+	if tx.Value() == nil || tx.Value().Sign() < 0 || !tx.Value().IsUint64() {
+		return ErrInvalidTransactionValue
+	}
+
+	// A wrong sender or an unsupported version says more than a bad
+	// signature.
+	ok, err := tx.Verify(bc.chainID)
+	if err != nil && !errors.Is(err, transaction.ErrInvalidSignature) {
+		return err
+	}
+
+	if err != nil || !ok {
+		return ErrInvalidTransactionSignature
+	}
+
+	expected, err := tx.CalcGas(gaspool.GasLimit(bc.gasTarget))
+	if err != nil {
+		return err
+	}
+
+	if expected.Gas() != tx.Gas() {
+		return ErrInvalidGas
+	}
+
+	return nil
+}
+
+// HasTransaction reports whether the transaction is already in the pool or
+// confirmed in a block.
+func (bc *Blockchain) HasTransaction(hash common.Hash) bool {
+	if bc.txPool.Has(hash) {
+		return true
+	}
+
+	tx, err := bc.db.GetTransactionByHash(hash)
+	return err == nil && tx != nil
+}
+
+func (bc *Blockchain) GetPoolTransaction(hash common.Hash) (*transaction.Transaction, bool) {
+	return bc.txPool.Get(hash)
+}
+
+func (bc *Blockchain) PendingTransactions() []transaction.Transaction {
+	return bc.txPool.Pending()
+}
+
 func (bc *Blockchain) ChainID() uint64 {
 	return bc.chainID
 }
@@ -252,18 +370,88 @@ func (bc *Blockchain) AddRemoteBlock(block *block.Block) error {
 		return ErrBlockExists
 	}
 
-	if block.Height() <= bc.latestBlock.Height() {
-		return ErrBlockHeight
+	return bc.importBlock(block)
+}
+
+// State returns the account state at the latest committed block.
+func (bc *Blockchain) State() (*state.StateDB, error) {
+	bc.lock.RLock()
+	defer bc.lock.RUnlock()
+
+	latest, err := bc.db.LatestBlock()
+	if err != nil {
+		return nil, err
+	}
+
+	return state.NewWithCache(bc.db, latest, bc.stateCache), nil
+}
+
+// Process applies blk on top of the latest committed block and returns the
+// resulting state without persisting it. Block producers use it to fill in
+// the state root.
+func (bc *Blockchain) Process(blk *block.Block) (*state.StateDB, error) {
+	bc.lock.RLock()
+	defer bc.lock.RUnlock()
+
+	parent, err := bc.db.LatestBlock()
+	if err != nil {
+		return nil, err
+	}
+
+	if blk.Prev() != parent.Hash() {
+		return nil, ErrUnknownParent
+	}
+
+	return bc.processor.Process(blk, state.NewWithCache(bc.db, parent, bc.stateCache))
+}
+
+// insertBlock runs the block transactions on top of the latest committed
+// block and persists the block, the resulting accounts and the transaction
+// indexes. It must be called with the chain lock held.
+func (bc *Blockchain) insertBlock(blk *block.Block) error {
+	parent, err := bc.db.LatestBlock()
+	if err != nil {
+		return err
+	}
+
+	if blk.Prev() != parent.Hash() {
+		return ErrUnknownParent
 	}
 
-	err := bc.db.CommitBlock(block)
+	newState, err := bc.processor.Process(blk, state.NewWithCache(bc.db, parent, bc.stateCache))
 	if err != nil {
 		return err
 	}
 
-	bc.latestBlock = block
+	root, err := newState.Root()
+	if err != nil {
+		return err
+	}
 
-	return nil
+	if root != blk.StateRoot() {
+		return ErrInvalidStateRoot
+	}
+
+	if err := bc.db.CommitBlock(blk); err != nil {
+		return err
+	}
+
+	if err := newState.Commit(blk); err != nil {
+		return err
+	}
+
+	txs := blk.Transactions()
+	for i := range txs {
+		if err := bc.db.CommitTransaction(&txs[i], blk); err != nil {
+			return err
+		}
+	}
+
+	bc.totalDifficulty = blk.TotalDifficulty()
+	bc.latestBlock = blk
+	bc.markEvidence(blk)
+
+	return bc.txPool.Update(blk)
 }
 
 func (bc *Blockchain) HasBlock(hash common.Hash) bool {
@@ -283,9 +471,34 @@ func (bc *Blockchain) hasBlock(hash common.Hash) bool {
 }
 
 func (bc *Blockchain) Start() {
-	bc.wg.Add(2)
+	bc.wg.Add(3)
 	go bc.processLocalBlocksLoop()
 	go bc.processBlocksLoop()
+	go bc.rejournalLoop()
+}
+
+// rejournalLoop periodically rewrites the journal of local transactions so
+// it only keeps the ones still waiting in the pool.
+func (bc *Blockchain) rejournalLoop() {
+	defer bc.wg.Done()
+
+	if bc.txPool.Config().Journal == "" {
+		return
+	}
+
+	ticker := time.NewTicker(bc.txPool.Config().Rejournal)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-bc.ctx.Done():
+			return
+		case <-ticker.C:
+			if err := bc.txPool.Rejournal(); err != nil {
+				bc.logs.WithError(err).Warn("Failed to rotate the transaction journal")
+			}
+		}
+	}
 }
 
 func (bc *Blockchain) processLocalBlocksLoop() {
@@ -299,6 +512,8 @@ func (bc *Blockchain) processLocalBlocksLoop() {
 			bc.logs.Info("Stopping local-blocks loop")
 			return
 		case <-ticker.C:
+			bc.txPool.ProcessTransaction()
+
 			bc.lock.Lock()
 			blocks := bc.localBlocks
 			bc.lock.Unlock()
@@ -348,29 +563,34 @@ func (bc *Blockchain) processBlocksLoop() {
 			}).Info("Processing proposed block")
 
 			// sólo guardamos si es la siguiente altura
+			bc.lock.Lock()
 			if blk.Height() == bc.latestBlock.Height() {
 				latestBlock, err := bc.db.LatestBlock()
 				if err != nil {
+					bc.lock.Unlock()
 					bc.logs.WithError(err).Error("Failed to get latest block")
 					continue
 				}
 
-				if err := bc.db.CommitBlock(blk); err != nil {
-					bc.logs.WithError(err).Error("Failed to save new block")
+				if err := bc.importBlock(blk); err != nil {
+					bc.lock.Unlock()
+					bc.logs.WithFields(logrus.Fields{
+						"height": blk.Height(),
+						"hash":   blk.Hash().String(),
+					}).WithError(err).Error("Failed to import new block")
 					continue
 				}
 
-				bc.totalDifficulty += blk.Difficulty()
-				bc.latestBlock = blk
-
 				timeElapsed := time.Since(time.Unix(int64(latestBlock.Timestamp()), 0))
 
 				bc.logs.WithFields(logrus.Fields{
 					"height":           blk.Height(),
+					"transactions":     len(blk.Transactions()),
 					"total_difficulty": bc.totalDifficulty,
 					"delay":            fmt.Sprintf("%.2fs", float64(timeElapsed.Seconds())),
 				}).Info("Committed new block")
 			}
+			bc.lock.Unlock()
 
 			if err := bc.blockPool.SyncBlockPool(blk.Height() + 1); err != nil {
 				bc.logs.WithError(err).Error("Failed to sync block pool")
@@ -382,6 +602,10 @@ func (bc *Blockchain) processBlocksLoop() {
 func (bc *Blockchain) Stop() {
 	bc.cancel()
 	bc.wg.Wait()
+
+	if err := bc.txPool.Close(); err != nil {
+		bc.logs.WithError(err).Warn("Failed to close the transaction journal")
+	}
 	bc.logs.Info("Blockchain processing stopped")
 }
 
@@ -433,7 +657,7 @@ func getBlockByHashAndHeight(db *prydb.Database, hash common.Hash, height uint64
 	if ok, err := db.BlockHasTransactions(blk); err != nil {
 		return nil, err
 	} else if ok {
-		txs, _ := db.GetTransactionsByBlockHeight(height)
+		txs, _ := db.GetTransactionsByBlockHash(blk.Hash())
 
 		if len(txs) > 0 {
 			for _, tx := range txs {
@@ -446,6 +670,51 @@ func getBlockByHashAndHeight(db *prydb.Database, hash common.Hash, height uint64
 	return blk, nil
 }
 
+func (bc *Blockchain) GetTransactionByHash(hash common.Hash) (*transaction.Transaction, error) {
+	bc.lock.RLock()
+	defer bc.lock.RUnlock()
+
+	return bc.db.GetTransactionByHash(hash)
+}
+
+func (bc *Blockchain) BalanceAt(address common.Address, blk *block.Block) (uint64, error) {
+	bc.lock.RLock()
+	defer bc.lock.RUnlock()
+
+	balance, err := bc.db.BalanceAt(address, blk)
+	if err != nil {
+		if errors.Is(err, prydb.ErrAccountNotFound) {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	return balance, nil
+}
+
+// NonceAt returns the nonce the next transaction of address must carry
+// after blk.
+func (bc *Blockchain) NonceAt(address common.Address, blk *block.Block) (uint64, error) {
+	bc.lock.RLock()
+	defer bc.lock.RUnlock()
+
+	nonce, err := bc.db.NonceAt(address, blk)
+	if err != nil {
+		if errors.Is(err, prydb.ErrAccountNotFound) {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	return nonce, nil
+}
+
+// PendingNonceAt returns the next nonce of address counting the
+// transactions it already has in the pool.
+func (bc *Blockchain) PendingNonceAt(address common.Address) (uint64, error) {
+	return bc.txPool.Nonce(address)
+}
+
 func (bc *Blockchain) GasTarget() uint64 {
 	return bc.gasTarget
 }
@@ -0,0 +1,547 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"math/big"
+	"testing"
+
+	pec256 "github.com/polarysfoundation/pec-256"
+	"github.com/polarysfoundation/polarys-chain/modules/common"
+	"github.com/polarysfoundation/polarys-chain/modules/core/block"
+	"github.com/polarysfoundation/polarys-chain/modules/core/consensus"
+	"github.com/polarysfoundation/polarys-chain/modules/core/consensus/pow"
+	"github.com/polarysfoundation/polarys-chain/modules/core/gaspool"
+	"github.com/polarysfoundation/polarys-chain/modules/core/state"
+	"github.com/polarysfoundation/polarys-chain/modules/core/transaction"
+	"github.com/polarysfoundation/polarys-chain/modules/core/txpool"
+	"github.com/polarysfoundation/polarys-chain/modules/crypto"
+	"github.com/polarysfoundation/polarys-chain/modules/params"
+	"github.com/sirupsen/logrus"
+)
+
+// newTestTx returns a transfer from one of the test keys, signed and
+// priced for the pool of bc.
+func newTestTx(t *testing.T, bc *Blockchain, from, to common.Address, nonce uint64) *transaction.Transaction {
+	t.Helper()
+
+	return newPricedTestTx(t, bc, from, to, nonce, bc.gaspool.BaseFee())
+}
+
+func newPricedTestTx(t *testing.T, bc *Blockchain, from, to common.Address, nonce, price uint64) *transaction.Transaction {
+	t.Helper()
+
+	// Pm256 ignores most of inputs whose length is 120 to 127 modulo 128,
+	// such transactions would all share the same hash: pad the data until
+	// the encoding avoids those lengths.
+	var tx *transaction.Transaction
+	for data := []byte{}; ; data = append(data, 0) {
+		var err error
+		tx, err = transaction.NewTransaction(from, to, big.NewInt(1), data, nonce, price, transaction.Legacy, nil, bc.gaspool.MaxGasTarget())
+		if err != nil {
+			t.Fatalf("NewTransaction() error = %v", err)
+		}
+
+		if len(tx.Bytes())%128 < 120 {
+			break
+		}
+	}
+
+	return signTestTx(t, tx, bc.chainID)
+}
+
+// signTestTx signs tx for chainID with the key of its sender.
+func signTestTx(t *testing.T, tx *transaction.Transaction, chainID uint64) *transaction.Transaction {
+	t.Helper()
+
+	key, ok := testKeys[tx.From()]
+	if !ok {
+		t.Fatalf("no key for account %v", tx.From())
+	}
+
+	h, err := tx.SigningHash(chainID)
+	if err != nil {
+		t.Fatalf("SigningHash() error = %v", err)
+	}
+
+	r, s, err := crypto.Sign(h, pec256.BytesToPrivKey(key[0]))
+	if err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+
+	signature := make([]byte, 64)
+	r.FillBytes(signature[:32])
+	s.FillBytes(signature[32:])
+
+	return tx.SignTransaction(signature, pec256.BytesToPubKey(key[1]))
+}
+
+func TestBlockchain_PendingNonce(t *testing.T) {
+	bc, db := newTestBlockchain(t)
+
+	genesis, err := bc.GetLatestBlock()
+	if err != nil {
+		t.Fatalf("GetLatestBlock() error = %v", err)
+	}
+
+	// The block reward funds validatorA.
+	a2, _ := newTestBlock(t, bc, genesis, state.New(db, genesis), validatorA, genesis.Timestamp()+2)
+	if err := bc.AddRemoteBlock(a2); err != nil {
+		t.Fatalf("AddRemoteBlock(a2) error = %v", err)
+	}
+
+	assertNonce := func(want uint64) {
+		t.Helper()
+
+		nonce, err := bc.PendingNonceAt(validatorA)
+		if err != nil {
+			t.Fatalf("PendingNonceAt() error = %v", err)
+		}
+		if nonce != want {
+			t.Errorf("PendingNonceAt() = %d, want %d", nonce, want)
+		}
+	}
+
+	tx0 := newTestTx(t, bc, validatorA, validatorZ, 0)
+	tx1 := newTestTx(t, bc, validatorA, validatorZ, 1)
+	tx2 := newTestTx(t, bc, validatorA, validatorZ, 2)
+
+	// A nonce above the account nonce waits in the queue.
+	if _, err := bc.SubmitTransaction(tx1); err != nil {
+		t.Fatalf("SubmitTransaction(tx1) error = %v", err)
+	}
+	if !bc.HasTransaction(tx1.Hash()) {
+		t.Errorf("queued transaction not found in the pool")
+	}
+	if len(bc.PendingTransactions()) != 0 {
+		t.Errorf("PendingTransactions() = %d transactions, want 0", len(bc.PendingTransactions()))
+	}
+	assertNonce(0)
+
+	// Filling the gap promotes the queued transaction.
+	if _, err := bc.SubmitTransaction(tx0); err != nil {
+		t.Fatalf("SubmitTransaction(tx0) error = %v", err)
+	}
+	if len(bc.PendingTransactions()) != 2 {
+		t.Errorf("PendingTransactions() = %d transactions, want 2", len(bc.PendingTransactions()))
+	}
+	assertNonce(2)
+
+	// A second transaction for a pending nonce must bid more.
+	replacement := newTestTx(t, bc, validatorA, validatorB, 1)
+	if _, err := bc.SubmitTransaction(replacement); !errors.Is(err, txpool.ErrReplaceUnderpriced) {
+		t.Errorf("SubmitTransaction(nonce 1) error = %v, want %v", err, txpool.ErrReplaceUnderpriced)
+	}
+
+	if _, err := bc.SubmitTransaction(tx2); err != nil {
+		t.Fatalf("SubmitTransaction(tx2) error = %v", err)
+	}
+	assertNonce(3)
+
+	// The confirmed nonce only moves when a block executes the transactions.
+	nonce, err := bc.NonceAt(validatorA, nil)
+	if err != nil {
+		t.Fatalf("NonceAt() error = %v", err)
+	}
+	if nonce != 0 {
+		t.Errorf("NonceAt() = %d, want 0", nonce)
+	}
+}
+
+func TestBlockchain_ReplayProtection(t *testing.T) {
+	bc, db := newTestBlockchain(t)
+
+	genesis, err := bc.GetLatestBlock()
+	if err != nil {
+		t.Fatalf("GetLatestBlock() error = %v", err)
+	}
+
+	a2, _ := newTestBlock(t, bc, genesis, state.New(db, genesis), validatorA, genesis.Timestamp()+2)
+	if err := bc.AddRemoteBlock(a2); err != nil {
+		t.Fatalf("AddRemoteBlock(a2) error = %v", err)
+	}
+
+	tx := newTestTx(t, bc, validatorA, validatorZ, 0)
+
+	// The same transaction signed for another chain.
+	replayed := signTestTx(t, tx, bc.chainID+1)
+	if _, err := bc.SubmitTransaction(replayed); err != ErrInvalidTransactionSignature {
+		t.Errorf("SubmitTransaction(other chain) error = %v, want %v", err, ErrInvalidTransactionSignature)
+	}
+
+	// The signature with a key that is not the sender's.
+	wrongKey := tx.SignTransaction(tx.Signature(), pec256.BytesToPubKey(testKeys[validatorB][1]))
+	if _, err := bc.SubmitTransaction(wrongKey); err != transaction.ErrInvalidSender {
+		t.Errorf("SubmitTransaction(wrong key) error = %v, want %v", err, transaction.ErrInvalidSender)
+	}
+
+	st := state.New(db, a2)
+	if _, err := ApplyTransaction(st, replayed, bc.chainID, bc.gaspool.BaseFee(), gaspool.GasLimit(bc.GasTarget())); err != ErrInvalidTransactionSignature {
+		t.Errorf("ApplyTransaction(other chain) error = %v, want %v", err, ErrInvalidTransactionSignature)
+	}
+
+	if _, err := bc.SubmitTransaction(tx); err != nil {
+		t.Errorf("SubmitTransaction() error = %v", err)
+	}
+}
+
+func TestBlockchain_PoolReplacementAndEviction(t *testing.T) {
+	config := *params.DefaultConfig
+	config.TxPoolGlobalSlots = 2
+	config.TxPoolPriceBump = 10
+
+	bc, db := newTestBlockchainWithConfig(t, &config)
+
+	genesis, err := bc.GetLatestBlock()
+	if err != nil {
+		t.Fatalf("GetLatestBlock() error = %v", err)
+	}
+
+	// Block rewards fund both validators.
+	a2, a2State := newTestBlock(t, bc, genesis, state.New(db, genesis), validatorA, genesis.Timestamp()+2)
+	b3, _ := newTestBlock(t, bc, a2, a2State, validatorB, a2.Timestamp()+2)
+	for _, blk := range []*block.Block{a2, b3} {
+		if err := bc.AddRemoteBlock(blk); err != nil {
+			t.Fatalf("AddRemoteBlock(%d) error = %v", blk.Height(), err)
+		}
+	}
+
+	a0 := newPricedTestTx(t, bc, validatorA, validatorZ, 0, 10000)
+	b0 := newPricedTestTx(t, bc, validatorB, validatorZ, 0, 30000)
+	for _, tx := range []*transaction.Transaction{a0, b0} {
+		if _, err := bc.SubmitTransaction(tx); err != nil {
+			t.Fatalf("SubmitTransaction() error = %v", err)
+		}
+	}
+
+	// The pool is full: a transaction must outbid the cheapest one.
+	if _, err := bc.SubmitTransaction(newPricedTestTx(t, bc, validatorB, validatorZ, 1, 5000)); !errors.Is(err, txpool.ErrUnderpriced) {
+		t.Errorf("SubmitTransaction(cheap) error = %v, want %v", err, txpool.ErrUnderpriced)
+	}
+
+	b1 := newPricedTestTx(t, bc, validatorB, validatorZ, 1, 20000)
+	if _, err := bc.SubmitTransaction(b1); err != nil {
+		t.Fatalf("SubmitTransaction(b1) error = %v", err)
+	}
+	if bc.HasTransaction(a0.Hash()) {
+		t.Errorf("cheapest transaction was not evicted")
+	}
+
+	// Replacing b1 requires a 10% higher gas price.
+	if _, err := bc.SubmitTransaction(newPricedTestTx(t, bc, validatorB, validatorA, 1, 21000)); !errors.Is(err, txpool.ErrReplaceUnderpriced) {
+		t.Errorf("SubmitTransaction(small bump) error = %v, want %v", err, txpool.ErrReplaceUnderpriced)
+	}
+
+	replacement := newPricedTestTx(t, bc, validatorB, validatorA, 1, 22000)
+	if _, err := bc.SubmitTransaction(replacement); err != nil {
+		t.Fatalf("SubmitTransaction(replacement) error = %v", err)
+	}
+	if bc.HasTransaction(b1.Hash()) || !bc.HasTransaction(replacement.Hash()) {
+		t.Errorf("transaction was not replaced")
+	}
+
+	// Sealed transactions come out in nonce order for each sender.
+	bc.txPool.ProcessTransaction()
+	txs := bc.GetTransactions()
+	if len(txs) != 2 || txs[0].Hash() != b0.Hash() || txs[1].Hash() != replacement.Hash() {
+		t.Errorf("GetTransactions() = %d transactions, want b0 then its replacement", len(txs))
+	}
+}
+
+func TestBlockchain_PoolUpdate(t *testing.T) {
+	bc, db := newTestBlockchain(t)
+
+	genesis, err := bc.GetLatestBlock()
+	if err != nil {
+		t.Fatalf("GetLatestBlock() error = %v", err)
+	}
+
+	a2, a2State := newTestBlock(t, bc, genesis, state.New(db, genesis), validatorA, genesis.Timestamp()+2)
+	if err := bc.AddRemoteBlock(a2); err != nil {
+		t.Fatalf("AddRemoteBlock(a2) error = %v", err)
+	}
+
+	tx0 := newTestTx(t, bc, validatorA, validatorZ, 0)
+	tx1 := newTestTx(t, bc, validatorA, validatorZ, 1)
+	for _, tx := range []*transaction.Transaction{tx0, tx1} {
+		if _, err := bc.SubmitTransaction(tx); err != nil {
+			t.Fatalf("SubmitTransaction() error = %v", err)
+		}
+	}
+	bc.txPool.ProcessTransaction()
+
+	assertPool := func(want ...*transaction.Transaction) {
+		t.Helper()
+
+		pending := bc.PendingTransactions()
+		if len(pending) != len(want) {
+			t.Fatalf("PendingTransactions() = %d transactions, want %d", len(pending), len(want))
+		}
+
+		for _, tx := range want {
+			if !bc.txPool.Has(tx.Hash()) {
+				t.Errorf("transaction with nonce %d not in the pool", tx.Nonce())
+			}
+		}
+	}
+
+	// The block including tx0 removes it from the pool.
+	a3, _ := newTestBlockWithTxs(t, bc, a2, a2State, validatorA, a2.Timestamp()+2, []transaction.Transaction{*tx0})
+	if err := bc.AddRemoteBlock(a3); err != nil {
+		t.Fatalf("AddRemoteBlock(a3) error = %v", err)
+	}
+	assertPool(tx1)
+
+	for _, tx := range bc.GetTransactions() {
+		if tx.Hash() == tx0.Hash() {
+			t.Errorf("GetTransactions() returned a confirmed transaction")
+		}
+	}
+
+	// Reverting a3 brings tx0 back in front of tx1.
+	b3, b3State := newTestBlock(t, bc, a2, a2State, validatorB, a2.Timestamp()+3)
+	b4, _ := newTestBlock(t, bc, b3, b3State, validatorB, b3.Timestamp()+2)
+	for _, blk := range []*block.Block{b3, b4} {
+		if err := bc.AddRemoteBlock(blk); err != nil {
+			t.Fatalf("AddRemoteBlock(%d) error = %v", blk.Height(), err)
+		}
+	}
+	assertHead(t, bc, b4)
+	assertPool(tx0, tx1)
+
+	nonce, err := bc.PendingNonceAt(validatorA)
+	if err != nil {
+		t.Fatalf("PendingNonceAt() error = %v", err)
+	}
+	if nonce != 2 {
+		t.Errorf("PendingNonceAt() = %d, want 2", nonce)
+	}
+}
+
+func TestBlockchain_TxJournal(t *testing.T) {
+	bc, db := newTestBlockchain(t)
+
+	genesis, err := bc.GetLatestBlock()
+	if err != nil {
+		t.Fatalf("GetLatestBlock() error = %v", err)
+	}
+
+	a2, _ := newTestBlock(t, bc, genesis, state.New(db, genesis), validatorA, genesis.Timestamp()+2)
+	if err := bc.AddRemoteBlock(a2); err != nil {
+		t.Fatalf("AddRemoteBlock(a2) error = %v", err)
+	}
+
+	local := newTestTx(t, bc, validatorA, validatorZ, 0)
+	if _, err := bc.SubmitTransaction(local); err != nil {
+		t.Fatalf("SubmitTransaction() error = %v", err)
+	}
+
+	remote := newTestTx(t, bc, validatorA, validatorZ, 1)
+	if err := bc.AddRemoteTransaction(remote); err != nil {
+		t.Fatalf("AddRemoteTransaction() error = %v", err)
+	}
+
+	if err := bc.txPool.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	// A restarted node replays the local transactions only.
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	restarted, err := InitBlockchain(db, params.DefaultConfig, params.Polarys, bc.consensus, nil, log)
+	if err != nil {
+		t.Fatalf("InitBlockchain() error = %v", err)
+	}
+
+	if !restarted.HasTransaction(local.Hash()) {
+		t.Errorf("local transaction lost on restart")
+	}
+
+	if restarted.HasTransaction(remote.Hash()) {
+		t.Errorf("remote transaction journaled")
+	}
+}
+
+func TestBlockchain_BaseFee(t *testing.T) {
+	bc, db := newTestBlockchain(t)
+	reward := pow.BlockReward.Uint64()
+
+	genesis, err := bc.GetLatestBlock()
+	if err != nil {
+		t.Fatalf("GetLatestBlock() error = %v", err)
+	}
+
+	a2, a2State := newTestBlock(t, bc, genesis, state.New(db, genesis), validatorA, genesis.Timestamp()+2)
+	if err := bc.AddRemoteBlock(a2); err != nil {
+		t.Fatalf("AddRemoteBlock(a2) error = %v", err)
+	}
+
+	// An empty block lowers the base fee of the next one.
+	baseFee := gaspool.CalcBaseFee(a2.GasTarget(), a2.GasUsed(), a2.BaseFee())
+	if baseFee >= a2.BaseFee() {
+		t.Fatalf("base fee after an empty block = %d, want below %d", baseFee, a2.BaseFee())
+	}
+	if got := bc.gaspool.BaseFee(); got != baseFee {
+		t.Errorf("pool base fee = %d, want %d", got, baseFee)
+	}
+
+	// A block with another base fee is rejected.
+	header := block.Header{
+		Height:    a2.Height() + 1,
+		Prev:      a2.Hash(),
+		Timestamp: a2.Timestamp() + 2,
+		Validator: validatorB,
+		Data:      []byte{},
+		BaseFee:   baseFee + 1,
+	}
+	setTestDifficulty(bc, &header, a2)
+	invalid := block.NewBlock(header, nil)
+	invalid.CalcHash()
+
+	if err := bc.AddRemoteBlock(signTestBlock(t, invalid)); !errors.Is(err, consensus.ErrInvalidBaseFee) {
+		t.Errorf("AddRemoteBlock(wrong base fee) error = %v, want %v", err, consensus.ErrInvalidBaseFee)
+	}
+
+	// The base fee is burned and the validator keeps the tip.
+	const tip = 4
+	tx := newPricedTestTx(t, bc, validatorA, validatorZ, 0, baseFee+tip)
+
+	b3, _ := newTestBlockWithTxs(t, bc, a2, a2State, validatorB, a2.Timestamp()+2, []transaction.Transaction{*tx})
+	if err := bc.AddRemoteBlock(b3); err != nil {
+		t.Fatalf("AddRemoteBlock(b3) error = %v", err)
+	}
+
+	if b3.GasTip() != tx.Gas()*tip {
+		t.Errorf("block gas tip = %d, want %d", b3.GasTip(), tx.Gas()*tip)
+	}
+
+	assertBalance(t, bc, validatorA, reward-tx.Value().Uint64()-tx.Gas()*(baseFee+tip))
+	assertBalance(t, bc, validatorB, reward+tx.Gas()*tip)
+	assertBalance(t, bc, validatorZ, tx.Value().Uint64())
+}
+
+func TestBlockchain_InvalidTransactionGas(t *testing.T) {
+	bc, db := newTestBlockchain(t)
+
+	genesis, err := bc.GetLatestBlock()
+	if err != nil {
+		t.Fatalf("GetLatestBlock() error = %v", err)
+	}
+
+	a2, _ := newTestBlock(t, bc, genesis, state.New(db, genesis), validatorA, genesis.Timestamp()+2)
+	if err := bc.AddRemoteBlock(a2); err != nil {
+		t.Fatalf("AddRemoteBlock(a2) error = %v", err)
+	}
+
+	// A transaction declaring no gas, signed by its sender, pays no fee.
+	encoded, err := json.Marshal(newTestTx(t, bc, validatorA, validatorZ, 0))
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+
+	var fields map[string]any
+	if err := json.Unmarshal(encoded, &fields); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	fields["tx_data"].(map[string]any)["gas"] = 0
+
+	if encoded, err = json.Marshal(fields); err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+
+	free := &transaction.Transaction{}
+	if err := json.Unmarshal(encoded, free); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	free.CalcHash()
+	free = signTestTx(t, free, bc.chainID)
+
+	if _, err := bc.SubmitTransaction(free); err != ErrInvalidGas {
+		t.Errorf("SubmitTransaction(zero gas transaction) error = %v, want %v", err, ErrInvalidGas)
+	}
+
+	txs := []transaction.Transaction{*free}
+	header := block.Header{
+		Height:    a2.Height() + 1,
+		Prev:      a2.Hash(),
+		Timestamp: a2.Timestamp() + 2,
+		Validator: validatorB,
+		Data:      []byte{},
+		TxRoot:    block.CalcTxRoot(txs),
+		GasTarget: bc.GasTarget(),
+		BaseFee:   gaspool.CalcBaseFee(a2.GasTarget(), a2.GasUsed(), a2.BaseFee()),
+	}
+	setTestDifficulty(bc, &header, a2)
+	invalid := block.NewBlock(header, txs)
+	invalid = sealTestBlock(t, bc, invalid)
+
+	if err := bc.AddRemoteBlock(signTestBlock(t, invalid)); !errors.Is(err, ErrInvalidGas) {
+		t.Errorf("AddRemoteBlock(zero gas transaction) error = %v, want %v", err, ErrInvalidGas)
+	}
+
+	assertHead(t, bc, a2)
+}
+
+func TestBlockchain_FeeHistory(t *testing.T) {
+	bc, db := newTestBlockchain(t)
+
+	genesis, err := bc.GetLatestBlock()
+	if err != nil {
+		t.Fatalf("GetLatestBlock() error = %v", err)
+	}
+
+	a2, a2State := newTestBlock(t, bc, genesis, state.New(db, genesis), validatorA, genesis.Timestamp()+2)
+	if err := bc.AddRemoteBlock(a2); err != nil {
+		t.Fatalf("AddRemoteBlock(a2) error = %v", err)
+	}
+
+	// Without transactions to sample the pool minimum is suggested.
+	tip, err := bc.SuggestGasTip()
+	if err != nil {
+		t.Fatalf("SuggestGasTip() error = %v", err)
+	}
+	if tip != uint64(gaspool.MinTipPerGas) {
+		t.Errorf("SuggestGasTip() = %d, want %d", tip, gaspool.MinTipPerGas)
+	}
+
+	baseFee := bc.gaspool.BaseFee()
+	low := newPricedTestTx(t, bc, validatorA, validatorZ, 0, baseFee+6)
+	high := newPricedTestTx(t, bc, validatorA, validatorZ, 1, baseFee+12)
+
+	b3, _ := newTestBlockWithTxs(t, bc, a2, a2State, validatorB, a2.Timestamp()+2, []transaction.Transaction{*low, *high})
+	if err := bc.AddRemoteBlock(b3); err != nil {
+		t.Fatalf("AddRemoteBlock(b3) error = %v", err)
+	}
+
+	tip, err = bc.SuggestGasTip()
+	if err != nil {
+		t.Fatalf("SuggestGasTip() error = %v", err)
+	}
+	if want := b3.GasTip() / b3.GasUsed(); tip != want {
+		t.Errorf("SuggestGasTip() = %d, want %d", tip, want)
+	}
+
+	history, err := bc.FeeHistory(2, b3, []float64{0, 100})
+	if err != nil {
+		t.Fatalf("FeeHistory() error = %v", err)
+	}
+
+	if history.OldestBlock != a2.Height() {
+		t.Errorf("OldestBlock = %d, want %d", history.OldestBlock, a2.Height())
+	}
+
+	next := gaspool.CalcBaseFee(b3.GasTarget(), b3.GasUsed(), b3.BaseFee())
+	if len(history.BaseFees) != 3 || history.BaseFees[1] != b3.BaseFee() || history.BaseFees[2] != next {
+		t.Errorf("BaseFees = %v, want [%d %d %d]", history.BaseFees, a2.BaseFee(), b3.BaseFee(), next)
+	}
+
+	if len(history.Rewards) != 2 || history.Rewards[1][0] != 6 || history.Rewards[1][1] != 12 {
+		t.Errorf("Rewards = %v, want [0 0] then [6 12]", history.Rewards)
+	}
+
+	if _, err := bc.FeeHistory(2, b3, []float64{50, 10}); !errors.Is(err, gaspool.ErrInvalidPercentile) {
+		t.Errorf("FeeHistory(descending) error = %v, want %v", err, gaspool.ErrInvalidPercentile)
+	}
+}
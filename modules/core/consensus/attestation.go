@@ -0,0 +1,71 @@
+package consensus
+
+import (
+	"math/big"
+
+	pec256 "github.com/polarysfoundation/pec-256"
+	"github.com/polarysfoundation/polarys-chain/modules/common"
+	"github.com/polarysfoundation/polarys-chain/modules/crypto"
+)
+
+// An attestation signature is laid out like a block signature: r, s and
+// the public key of the validator.
+const AttestationSignatureLen = 96
+
+var attestationPrefix = []byte{0xfa}
+
+// Attestation is the vote of a validator for the checkpoint block at
+// Height. Once validators holding 2/3 of the power attested the same
+// checkpoint it is final.
+type Attestation struct {
+	Height    uint64         `json:"height"`
+	Hash      common.Hash    `json:"hash"`
+	Validator common.Address `json:"validator"`
+	Signature []byte         `json:"signature"`
+}
+
+// ID identifies the attestation of a validator for a checkpoint,
+// whatever its signature.
+func (a *Attestation) ID() common.Hash {
+	data := make([]byte, 0, 8+common.HashLen+common.AddrLen)
+	data = append(data, common.Uint64ToBytes(a.Height)...)
+	data = append(data, a.Hash.Bytes()...)
+	data = append(data, a.Validator.Bytes()...)
+
+	return common.BytesToHash(crypto.Pm256(data))
+}
+
+// SigningData returns what the validator signs for the attestation on
+// chainID.
+func (a *Attestation) SigningData(chainID uint64) []byte {
+	data := make([]byte, 0, len(attestationPrefix)+16+common.HashLen)
+	data = append(data, attestationPrefix...)
+	data = append(data, common.Uint64ToBytes(chainID)...)
+	data = append(data, common.Uint64ToBytes(a.Height)...)
+	data = append(data, a.Hash.Bytes()...)
+
+	return data
+}
+
+// Verify checks that the attestation was signed, for chainID, by the key
+// of its validator.
+func (a *Attestation) Verify(chainID uint64) error {
+	if len(a.Signature) != AttestationSignatureLen {
+		return ErrInvalidAttestation
+	}
+
+	r := new(big.Int).SetBytes(a.Signature[:32])
+	s := new(big.Int).SetBytes(a.Signature[32:64])
+	pub := pec256.BytesToPubKey(a.Signature[64:])
+
+	digest := common.BytesToHash(crypto.Pm256(a.SigningData(chainID)))
+	if ok, err := crypto.Verify(digest, r, s, pub); err != nil || !ok {
+		return ErrInvalidAttestation
+	}
+
+	if crypto.PubKeyToAddress(pub) != a.Validator {
+		return ErrAttesterMismatch
+	}
+
+	return nil
+}
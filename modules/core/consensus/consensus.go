@@ -1,6 +1,9 @@
 package consensus
 
 import (
+	"context"
+	"math/big"
+
 	"github.com/polarysfoundation/polarys-chain/modules/common"
 	"github.com/polarysfoundation/polarys-chain/modules/core/block"
 )
@@ -16,12 +19,20 @@ type Engine interface {
 	ValidatorProof() ([]byte, error)
 	ValidatorExists(validator common.Address) bool
 	VerifyBlock(chain Chain, block *block.Block) (bool, error)
+	VerifyHeader(parent *block.Block, header *block.Block) error
 	DifficultyValidator(block *block.Block, prevBlock *block.Block) (bool, error)
-	SealBlock(block *block.Block) (*block.Block, error)
+	SealBlock(ctx context.Context, block *block.Block) (*block.Block, error)
+	VerifySeal(block *block.Block) error
+	VerifySignature(header *block.Block) error
 	AdjustDifficulty(block *block.Block, prevBlock *block.Block) uint64
-	SelectValidator() common.Address
+	Authorize(validator common.Address)
+	SelectValidator(height uint64) common.Address
+	ProposalTime(parent *block.Block, validator common.Address) (uint64, error)
+	VerifyProposer(parent *block.Block, header *block.Block) error
 	Validator() common.Address
 	VerifyChain(chain Chain) (bool, error)
+	BlockReward(height uint64) *big.Int
+	Validators(blk *block.Block) ([]*Validator, error)
 }
 
 type Chain interface {
@@ -30,3 +41,23 @@ type Chain interface {
 	GetBlockByHeightAndHash(height uint64, hash common.Hash) (*block.Block, error)
 	GetLatestBlock() (*block.Block, error)
 }
+
+// Finalizer is implemented by engines that change the state at the end of
+// a block, on top of the block reward.
+type Finalizer interface {
+	Finalize(blk *block.Block, st State) error
+}
+
+// Jailer is implemented by engines that drop the validators caught double
+// signing from the active set once the block carrying the evidence is
+// processed. The chain only collects and accepts evidence under them.
+type Jailer interface {
+	JailsOffenders()
+}
+
+// State is the part of the account state a Finalizer may change.
+type State interface {
+	GetBalance(address common.Address) (uint64, error)
+	AddBalance(address common.Address, amount uint64) error
+	SubBalance(address common.Address, amount uint64) error
+}
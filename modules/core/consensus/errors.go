@@ -0,0 +1,14 @@
+package consensus
+
+import "errors"
+
+var (
+	ErrInvalidAttestation = errors.New("invalid attestation signature")
+	ErrAttesterMismatch   = errors.New("signer is not the attesting validator")
+
+	ErrInvalidGasTarget = errors.New("gas target above the maximum")
+	ErrGasLimitExceeded = errors.New("gas used above the block gas limit")
+	ErrInvalidBaseFee   = errors.New("base fee does not follow from the parent block")
+
+	ErrMissingBody = errors.New("ancestor body not imported")
+)
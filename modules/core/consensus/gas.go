@@ -0,0 +1,25 @@
+package consensus
+
+import (
+	"github.com/polarysfoundation/polarys-chain/modules/core/block"
+	"github.com/polarysfoundation/polarys-chain/modules/core/gaspool"
+)
+
+// VerifyGas checks the gas fields of header against its parent: the gas
+// target stays within the allowed maximum, the gas used within the limit
+// of the block, and the base fee is the one the parent determines.
+func VerifyGas(parent *block.Block, header *block.Block) error {
+	if header.GasTarget() > uint64(gaspool.DefaultMaxGasTarget) {
+		return ErrInvalidGasTarget
+	}
+
+	if header.GasUsed() > gaspool.GasLimit(header.GasTarget()) {
+		return ErrGasLimitExceeded
+	}
+
+	if header.BaseFee() != gaspool.CalcBaseFee(parent.GasTarget(), parent.GasUsed(), parent.BaseFee()) {
+		return ErrInvalidBaseFee
+	}
+
+	return nil
+}
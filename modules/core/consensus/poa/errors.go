@@ -0,0 +1,28 @@
+package poa
+
+import "errors"
+
+// Define error variables
+var (
+	ErrInvalidBlockHash      = errors.New("invalid block hash")
+	ErrInvalidConsensusProof = errors.New("invalid consensus proof")
+	ErrInvalidValidatorProof = errors.New("invalid validator proof")
+	ErrInvalidBlockHeight    = errors.New("invalid block height")
+	ErrInvalidEpoch          = errors.New("invalid epoch")
+	ErrDuplicatedBlock       = errors.New("duplicated block")
+	ErrInvalidDifficulty     = errors.New("invalid difficulty")
+	ErrNilBlock              = errors.New("block is nil")
+	ErrNilPreviousBlock      = errors.New("previous block is nil")
+	ErrInvalidBlockTimestamp = errors.New("invalid block timestamp")
+	ErrInvalidProtocolHash   = errors.New("invalid protocol hash")
+	ErrInvalidChainID        = errors.New("invalid chain ID")
+	ErrInvalidTxRoot         = errors.New("invalid transactions root")
+	ErrSealAborted           = errors.New("seal aborted")
+	ErrOutOfTurn             = errors.New("validator proposed before its turn")
+	ErrUnknownAncestor       = errors.New("unknown ancestor")
+	ErrUnauthorizedSigner    = errors.New("unauthorized signer")
+	ErrRecentlySigned        = errors.New("signer signed recently")
+	ErrSignerMismatch        = errors.New("signer is not the block validator")
+	ErrInvalidVote           = errors.New("invalid vote")
+	ErrInvalidCheckpoint     = errors.New("invalid checkpoint signers")
+)
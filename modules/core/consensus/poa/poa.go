@@ -0,0 +1,648 @@
+package poa
+
+import (
+	"bytes"
+	"context"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/polarysfoundation/polarys-chain/modules/common"
+	"github.com/polarysfoundation/polarys-chain/modules/core/block"
+	"github.com/polarysfoundation/polarys-chain/modules/core/consensus"
+	"github.com/polarysfoundation/polarys-chain/modules/crypto"
+	"github.com/polarysfoundation/polarys-chain/modules/prydb"
+)
+
+var (
+	DefaultEpoch = uint64(1000)
+
+	// The in-turn signer weighs more, so the fork choice prefers the chain
+	// that followed the schedule.
+	DiffInTurn = uint64(2)
+	DiffNoTurn = uint64(1)
+)
+
+var (
+	// Authorities are not paid for signing, they only keep the tips.
+	BlockReward = big.NewInt(0)
+)
+
+const (
+	allowedFutureBlockTime = 15 * time.Second
+
+	snapshotsKept = 256
+)
+
+// Consensus is a proof-of-authority engine. Blocks are signed by a set of
+// authorised signers, stored in the header Data of every checkpoint, the
+// first block of an epoch. In between, signers vote others in and out and
+// each of them signs at most one block out of len(signers)/2+1.
+//
+// Blocks 0 and 1 are created locally by the blockchain, the signers of the
+// configuration are authorised from there until the first checkpoint.
+type Consensus struct {
+	db               *prydb.Database
+	epoch            uint64
+	delay            uint64
+	chainID          uint64
+	protocolHash     common.Hash
+	signers          []common.Address
+	currentValidator common.Address
+
+	lock      sync.Mutex
+	snapshots map[common.Hash]*Snapshot
+	proposals map[common.Address]bool // Votes this node casts when it signs
+}
+
+func InitConsensus(db *prydb.Database, epoch, delay, chainID uint64, signers []common.Address) *Consensus {
+	buff := common.Decode("PoaEngine")
+	protocolHash := crypto.Pm256(buff)
+
+	if epoch < 2 {
+		epoch = DefaultEpoch
+	}
+
+	return &Consensus{
+		db:           db,
+		epoch:        epoch,
+		delay:        delay,
+		chainID:      chainID,
+		protocolHash: common.BytesToHash(protocolHash),
+		signers:      signers,
+		snapshots:    make(map[common.Hash]*Snapshot),
+		proposals:    make(map[common.Address]bool),
+	}
+}
+
+func (c *Consensus) Validator() common.Address {
+	return c.currentValidator
+}
+
+func (c *Consensus) ProtocolHash() common.Hash {
+	return c.protocolHash
+}
+
+// Authorize sets the signer this node proposes blocks as.
+func (c *Consensus) Authorize(validator common.Address) {
+	c.currentValidator = validator
+}
+
+// Propose makes this node vote for authorising or dropping address in the
+// blocks it signs, until the vote passes or is discarded.
+func (c *Consensus) Propose(address common.Address, authorize bool) {
+	c.lock.Lock()
+	c.proposals[address] = authorize
+	c.lock.Unlock()
+}
+
+// Discard stops voting for address.
+func (c *Consensus) Discard(address common.Address) {
+	c.lock.Lock()
+	delete(c.proposals, address)
+	c.lock.Unlock()
+}
+
+// ConsensusProof commits to the chain, the parent height and the epoch of
+// the block built on top of it.
+func (c *Consensus) ConsensusProof(crrBlockNumber uint64) ([]byte, error) {
+	consensusProof := make([]byte, 64)
+	copy(consensusProof[:8], common.Uint64ToBytes(c.chainID))
+	copy(consensusProof[8:16], common.Uint64ToBytes(crrBlockNumber))
+	copy(consensusProof[16:24], common.Uint64ToBytes(c.epoch))
+	copy(consensusProof[24:32], common.Uint64ToBytes((crrBlockNumber+1)/c.epoch))
+	copy(consensusProof[32:], c.protocolHash.Bytes())
+
+	return consensusProof, nil
+}
+
+func (c *Consensus) ValidatorProof() ([]byte, error) {
+	validatorProof := make([]byte, 64)
+
+	copy(validatorProof[:8], common.Uint64ToBytes(c.chainID))
+	copy(validatorProof[8:16], common.Uint64ToBytes(c.epoch))
+	copy(validatorProof[16:31], c.currentValidator.Bytes())
+	copy(validatorProof[32:64], c.protocolHash.Bytes())
+
+	return validatorProof, nil
+}
+
+// ValidatorExists reports whether address is a signer after the chain
+// head.
+func (c *Consensus) ValidatorExists(address common.Address) bool {
+	head, err := c.db.LatestBlock()
+	if err != nil {
+		return false
+	}
+
+	snap, err := c.Snapshot(head)
+	if err != nil {
+		return false
+	}
+
+	_, ok := snap.Signers[address]
+	return ok
+}
+
+func (c *Consensus) VerifyBlock(chain consensus.Chain, block *block.Block) (bool, error) {
+	if block == nil {
+		return false, ErrNilBlock
+	}
+
+	prevBlock, err := chain.GetBlockByHeight(block.Height() - 1)
+	if err != nil {
+		return false, err
+	}
+
+	if prevBlock == nil {
+		return false, ErrNilPreviousBlock
+	}
+
+	if prevBlock.Hash() != block.Prev() {
+		return false, ErrInvalidBlockHash
+	}
+
+	if !block.VerifyTxRoot() {
+		return false, ErrInvalidTxRoot
+	}
+
+	if err := c.verifyConsensusProof(block, prevBlock); err != nil {
+		return false, err
+	}
+
+	if !c.verifyValidatorProof(block) {
+		return false, ErrInvalidValidatorProof
+	}
+
+	// Recovers the signer from the signature and checks it was allowed to
+	// sign.
+	if err := c.VerifyHeader(prevBlock, block); err != nil {
+		return false, err
+	}
+
+	latestBlock, err := chain.GetLatestBlock()
+	if err != nil {
+		return false, err
+	}
+
+	if block.Height() <= latestBlock.Height() {
+		return false, ErrInvalidBlockHeight
+	}
+
+	if block.Prev() != latestBlock.Hash() {
+		return false, ErrInvalidBlockHash
+	}
+
+	tmpBlk, err := chain.GetBlockByHeight(block.Height())
+	if err == nil && tmpBlk != nil {
+		return false, ErrDuplicatedBlock
+	}
+
+	return true, nil
+}
+
+// VerifyHeader checks a header against its parent and the signers
+// authorised after it.
+func (c *Consensus) VerifyHeader(parent *block.Block, header *block.Block) error {
+	if parent == nil || header == nil {
+		return ErrNilBlock
+	}
+
+	if header.Height() != parent.Height()+1 {
+		return ErrInvalidBlockHeight
+	}
+
+	if header.Prev() != parent.Hash() {
+		return ErrInvalidBlockHash
+	}
+
+	if header.Timestamp() < parent.Timestamp() {
+		return ErrInvalidBlockTimestamp
+	}
+
+	if header.Timestamp() > uint64(time.Now().Add(allowedFutureBlockTime).Unix()) {
+		return ErrInvalidBlockTimestamp
+	}
+
+	if header.TotalDifficulty() != parent.TotalDifficulty()+header.Difficulty() {
+		return ErrInvalidDifficulty
+	}
+
+	if err := consensus.VerifyGas(parent, header); err != nil {
+		return err
+	}
+
+	if err := c.VerifySeal(header); err != nil {
+		return err
+	}
+
+	return c.VerifyProposer(parent, header)
+}
+
+func (c *Consensus) VerifyChain(chain consensus.Chain) (bool, error) {
+	latestBlock, err := chain.GetLatestBlock()
+	if err != nil {
+		return false, err
+	}
+
+	for i := uint64(2); i <= latestBlock.Height(); i++ {
+		currentBlock, err := chain.GetBlockByHeight(i)
+		if err != nil {
+			return false, err
+		}
+
+		prevBlock, err := chain.GetBlockByHeight(i - 1)
+		if err != nil {
+			return false, err
+		}
+
+		if currentBlock.CalcHash() != currentBlock.Hash() {
+			return false, ErrInvalidBlockHash
+		}
+
+		if prevBlock.Hash() != currentBlock.Prev() {
+			return false, ErrInvalidBlockHash
+		}
+
+		if ok, err := c.DifficultyValidator(currentBlock, prevBlock); err != nil || !ok {
+			return false, ErrInvalidDifficulty
+		}
+	}
+
+	return true, nil
+}
+
+func (c *Consensus) verifyConsensusProof(block *block.Block, prevBlock *block.Block) error {
+	consensusProof := block.ConsensusProof()
+	if len(consensusProof) != 64 {
+		return ErrInvalidConsensusProof
+	}
+
+	chainID := common.BytesToUint64(consensusProof[:8])
+	crrBlockNumber := common.BytesToUint64(consensusProof[8:16])
+	epochLength := common.BytesToUint64(consensusProof[16:24])
+	epoch := common.BytesToUint64(consensusProof[24:32])
+	protocolHash := common.BytesToHash(consensusProof[32:])
+
+	if chainID != c.chainID {
+		return ErrInvalidChainID
+	}
+
+	if crrBlockNumber != prevBlock.Height() {
+		return ErrInvalidBlockHeight
+	}
+
+	if epochLength != c.epoch || epoch != block.Height()/c.epoch {
+		return ErrInvalidEpoch
+	}
+
+	if protocolHash != c.protocolHash {
+		return ErrInvalidProtocolHash
+	}
+
+	return nil
+}
+
+func (c *Consensus) verifyValidatorProof(block *block.Block) bool {
+	validatorProof := block.ValidatorProof()
+	if len(validatorProof) != 64 {
+		return false
+	}
+
+	chainID := common.BytesToUint64(validatorProof[:8])
+	epoch := common.BytesToUint64(validatorProof[8:16])
+	validator := common.BytesToAddress(validatorProof[16:31])
+	protocolHash := common.BytesToHash(validatorProof[32:])
+
+	return chainID == c.chainID && epoch == c.epoch && protocolHash == c.protocolHash && validator == block.Validator()
+}
+
+// BlockReward returns the amount minted for the signer of the block at the
+// given height.
+func (c *Consensus) BlockReward(height uint64) *big.Int {
+	return new(big.Int).Set(BlockReward)
+}
+
+// AdjustDifficulty returns the difficulty of block, which only depends on
+// whether its signer is in turn.
+func (c *Consensus) AdjustDifficulty(block *block.Block, prevBlock *block.Block) uint64 {
+	if block == nil || prevBlock == nil {
+		return DiffNoTurn
+	}
+
+	snap, err := c.Snapshot(prevBlock)
+	if err != nil {
+		return DiffNoTurn
+	}
+
+	if turn, ok := snap.turn(block.Validator(), block.Height()); ok && turn == 0 {
+		return DiffInTurn
+	}
+
+	return DiffNoTurn
+}
+
+func (c *Consensus) DifficultyValidator(block *block.Block, prevBlock *block.Block) (bool, error) {
+	if block == nil || prevBlock == nil {
+		return false, ErrNilBlock
+	}
+
+	return block.Difficulty() == c.AdjustDifficulty(block, prevBlock), nil
+}
+
+// SealBlock fills in the authorisation fields of the header: the signer
+// list on checkpoints, one of the pending proposals otherwise. The block
+// still has to be signed by its validator.
+func (c *Consensus) SealBlock(ctx context.Context, blk *block.Block) (*block.Block, error) {
+	if blk == nil {
+		return nil, ErrNilBlock
+	}
+
+	if ctx.Err() != nil {
+		return nil, ErrSealAborted
+	}
+
+	parent, err := c.db.GetBlockByHash(blk.Prev())
+	if err != nil {
+		return nil, ErrUnknownAncestor
+	}
+
+	snap, err := c.Snapshot(parent)
+	if err != nil {
+		return nil, err
+	}
+
+	header := blk.Header()
+	header.Nonce = NonceDropVote
+	header.Data = []byte{}
+
+	if header.Height%c.epoch == 0 {
+		header.Data = checkpointData(snap.SignerList())
+	} else {
+		c.lock.Lock()
+		addresses := make([]common.Address, 0, len(c.proposals))
+		for address, authorize := range c.proposals {
+			if snap.validVote(address, authorize) {
+				addresses = append(addresses, address)
+			}
+		}
+
+		if len(addresses) > 0 {
+			sort.Slice(addresses, func(i, j int) bool {
+				return bytes.Compare(addresses[i].Bytes(), addresses[j].Bytes()) < 0
+			})
+
+			header.Data = addresses[0].Bytes()
+			if c.proposals[addresses[0]] {
+				header.Nonce = NonceAuthVote
+			}
+		}
+		c.lock.Unlock()
+	}
+
+	sealed := block.NewBlock(header, blk.Transactions())
+	sealed.CalcHash()
+	sealed.SetEvidence(blk.Evidence())
+	sealed.Seal(sealed.Hash())
+
+	return sealed, nil
+}
+
+// VerifySeal checks the block hash and the fields that do not depend on
+// the parent: the difficulty and the encoding of the vote or of the
+// checkpoint signers.
+func (c *Consensus) VerifySeal(blk *block.Block) error {
+	if blk == nil {
+		return ErrNilBlock
+	}
+
+	hash := blk.Hash()
+	if blk.CalcHash() != hash {
+		return ErrInvalidBlockHash
+	}
+
+	if blk.Difficulty() != DiffInTurn && blk.Difficulty() != DiffNoTurn {
+		return ErrInvalidDifficulty
+	}
+
+	if blk.Height()%c.epoch == 0 {
+		if blk.Nonce() != NonceDropVote {
+			return ErrInvalidVote
+		}
+
+		_, err := parseCheckpoint(blk.Data())
+		return err
+	}
+
+	_, _, _, err := vote(blk)
+	return err
+}
+
+// SelectValidator returns the in-turn signer at height on the canonical
+// chain.
+func (c *Consensus) SelectValidator(height uint64) common.Address {
+	if height == 0 {
+		return common.Address{}
+	}
+
+	parent, err := c.db.GetBlockByHeight(height - 1)
+	if err != nil {
+		return common.Address{}
+	}
+
+	snap, err := c.Snapshot(parent)
+	if err != nil || len(snap.Signers) == 0 {
+		return common.Address{}
+	}
+
+	signers := snap.SignerList()
+	return signers[height%uint64(len(signers))]
+}
+
+// ProposalTime returns the earliest timestamp at which validator may sign
+// on top of parent, one turn timeout for every signer in turn before it.
+func (c *Consensus) ProposalTime(parent *block.Block, validator common.Address) (uint64, error) {
+	if parent == nil {
+		return 0, ErrNilPreviousBlock
+	}
+
+	snap, err := c.Snapshot(parent)
+	if err != nil {
+		return 0, err
+	}
+
+	turn, ok := snap.turn(validator, parent.Height()+1)
+	if !ok {
+		return 0, ErrUnauthorizedSigner
+	}
+
+	if snap.signedRecently(validator, parent.Height()+1) {
+		return 0, ErrRecentlySigned
+	}
+
+	return parent.Timestamp() + turn*c.turnTimeout(), nil
+}
+
+// VerifyProposer recovers the signer of header and checks that it was
+// allowed to sign on top of parent at the header timestamp, with the
+// difficulty and the checkpoint signers it claims.
+func (c *Consensus) VerifyProposer(parent *block.Block, header *block.Block) error {
+	if header == nil {
+		return ErrNilBlock
+	}
+
+	if err := c.VerifySignature(header); err != nil {
+		return err
+	}
+
+	earliest, err := c.ProposalTime(parent, header.Validator())
+	if err != nil {
+		return err
+	}
+
+	if header.Timestamp() < earliest {
+		return ErrOutOfTurn
+	}
+
+	if header.Difficulty() != c.AdjustDifficulty(header, parent) {
+		return ErrInvalidDifficulty
+	}
+
+	if header.Height()%c.epoch == 0 {
+		snap, err := c.Snapshot(parent)
+		if err != nil {
+			return err
+		}
+
+		if !bytes.Equal(header.Data(), checkpointData(snap.SignerList())) {
+			return ErrInvalidCheckpoint
+		}
+	}
+
+	return nil
+}
+
+// VerifySignature checks that header was signed, for this chain, by the
+// key of its validator, the signer of the block.
+func (c *Consensus) VerifySignature(header *block.Block) error {
+	if header == nil {
+		return ErrNilBlock
+	}
+
+	signer, err := header.Signer(c.chainID)
+	if err != nil {
+		return err
+	}
+
+	if signer != header.Validator() {
+		return ErrSignerMismatch
+	}
+
+	return nil
+}
+
+// JailsOffenders marks the engine as a consensus.Jailer: offenders are
+// dropped from the signers without a vote.
+func (c *Consensus) JailsOffenders() {}
+
+// Validators returns the signers authorised after blk, each with a power
+// of one.
+func (c *Consensus) Validators(blk *block.Block) ([]*consensus.Validator, error) {
+	snap, err := c.Snapshot(blk)
+	if err != nil {
+		return nil, err
+	}
+
+	signers := snap.SignerList()
+	validators := make([]*consensus.Validator, 0, len(signers))
+	for _, signer := range signers {
+		validators = append(validators, &consensus.Validator{
+			Address:     signer,
+			TotalReward: big.NewInt(0),
+			Staked:      big.NewInt(0),
+			Power:       big.NewInt(1),
+			NextReward:  big.NewInt(0),
+		})
+	}
+
+	return validators, nil
+}
+
+// Snapshot returns the authorisation state after blk.
+func (c *Consensus) Snapshot(blk *block.Block) (*Snapshot, error) {
+	if blk == nil {
+		return nil, ErrNilBlock
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	var (
+		snap    *Snapshot
+		headers []*block.Block
+		current = blk
+	)
+
+	for snap == nil {
+		if cached, ok := c.snapshots[current.Hash()]; ok {
+			snap = cached
+			break
+		}
+
+		// The block hash does not cover the evidence, so a snapshot built
+		// without it would be cached and reused once the body arrives.
+		if current.MissingEvidence() {
+			return nil, consensus.ErrMissingBody
+		}
+
+		switch {
+		case current.Height() <= 1:
+			snap = newSnapshot(current.Height(), current.Hash(), c.signers)
+		case current.Height()%c.epoch == 0:
+			signers, err := parseCheckpoint(current.Data())
+			if err != nil {
+				return nil, err
+			}
+
+			// The checkpoint lists the signers before its own evidence.
+			snap = newSnapshot(current.Height(), current.Hash(), signers)
+			snap.Recents[current.Height()] = current.Validator()
+			snap.punish(current)
+		default:
+			headers = append(headers, current)
+
+			parent, err := c.db.GetBlockByHash(current.Prev())
+			if err != nil {
+				return nil, ErrUnknownAncestor
+			}
+			current = parent
+		}
+	}
+
+	c.snapshots[snap.Hash] = snap
+
+	for i := len(headers) - 1; i >= 0; i-- {
+		next, err := snap.apply(headers[i], c.epoch)
+		if err != nil {
+			return nil, err
+		}
+
+		c.snapshots[next.Hash] = next
+		snap = next
+	}
+
+	for hash, cached := range c.snapshots {
+		if cached.Height+snapshotsKept < snap.Height {
+			delete(c.snapshots, hash)
+		}
+	}
+
+	return snap, nil
+}
+
+// turnTimeout is the time, in seconds, a signer waits for each signer in
+// turn before it.
+func (c *Consensus) turnTimeout() uint64 {
+	return max(c.delay, 1)
+}
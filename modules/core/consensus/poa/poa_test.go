@@ -0,0 +1,210 @@
+package poa
+
+import (
+	"errors"
+	"testing"
+
+	pec256 "github.com/polarysfoundation/pec-256"
+	"github.com/polarysfoundation/polarys-chain/modules/common"
+	"github.com/polarysfoundation/polarys-chain/modules/core/block"
+	"github.com/polarysfoundation/polarys-chain/modules/core/consensus"
+	"github.com/polarysfoundation/polarys-chain/modules/crypto"
+	"github.com/polarysfoundation/polarys-chain/modules/prydb"
+)
+
+var (
+	signerA   = common.BytesToAddress([]byte("signer_aaaaaaaa"))
+	signerB   = common.BytesToAddress([]byte("signer_bbbbbbbb"))
+	signerC   = common.BytesToAddress([]byte("signer_cccccccc"))
+	candidate = common.BytesToAddress([]byte("candidate_ddddd"))
+)
+
+func voteHeader(parent *Snapshot, signer common.Address, data []byte, nonce uint64) *block.Block {
+	blk := block.NewBlock(block.Header{
+		Height:    parent.Height + 1,
+		Prev:      parent.Hash,
+		Validator: signer,
+		Data:      data,
+		Nonce:     nonce,
+	}, nil)
+	blk.CalcHash()
+
+	return blk
+}
+
+func TestSnapshot_Apply(t *testing.T) {
+	snap := newSnapshot(1, common.Hash{1}, []common.Address{signerA, signerB, signerC})
+
+	steps := []struct {
+		signer  common.Address
+		data    []byte
+		nonce   uint64
+		signers int
+		err     error
+	}{
+		{signerA, candidate.Bytes(), NonceAuthVote, 3, nil},
+		{signerA, candidate.Bytes(), NonceAuthVote, 0, ErrRecentlySigned}, // one block out of two
+		{signerB, candidate.Bytes(), NonceAuthVote, 4, nil},               // majority reached
+		{signerC, signerA.Bytes(), NonceDropVote, 4, nil},
+		{signerA, []byte{1, 2}, NonceDropVote, 0, ErrInvalidVote},
+	}
+
+	for i, step := range steps {
+		next, err := snap.apply(voteHeader(snap, step.signer, step.data, step.nonce), 1000)
+		if !errors.Is(err, step.err) {
+			t.Fatalf("step %d: apply() error = %v, want %v", i, err, step.err)
+		}
+
+		if err != nil {
+			continue
+		}
+
+		if len(next.Signers) != step.signers {
+			t.Fatalf("step %d: %d signers, want %d", i, len(next.Signers), step.signers)
+		}
+		snap = next
+	}
+
+	if _, ok := snap.Signers[candidate]; !ok {
+		t.Errorf("candidate not authorised")
+	}
+
+	if tally := snap.Tally[signerA]; tally.Authorize || tally.Votes != 1 {
+		t.Errorf("Tally[signerA] = %+v, want one drop vote", tally)
+	}
+}
+
+func signBlock(t *testing.T, blk *block.Block, priv pec256.PrivKey, pub pec256.PubKey, chainID uint64) *block.Block {
+	t.Helper()
+
+	r, s, err := crypto.Sign(common.BytesToHash(crypto.Pm256(blk.SigningData(chainID))), priv)
+	if err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+
+	signature := make([]byte, 64, block.SignatureLen)
+	r.FillBytes(signature[:32])
+	s.FillBytes(signature[32:])
+
+	signed, err := blk.SignBlock(append(signature, pub.Bytes()...))
+	if err != nil {
+		t.Fatalf("SignBlock() error = %v", err)
+	}
+
+	return signed
+}
+
+func TestConsensus_VerifyProposer(t *testing.T) {
+	// The database lives under the home directory.
+	t.Setenv("HOME", t.TempDir())
+
+	db, err := prydb.InitDB()
+	if err != nil {
+		t.Fatalf("InitDB() error = %v", err)
+	}
+
+	priv, pub := crypto.GenerateKey()
+	signer := crypto.PubKeyToAddress(pub)
+	otherPriv, otherPub := crypto.GenerateKey()
+
+	c := InitConsensus(db, 1000, 1, 0, []common.Address{signer})
+
+	var parent *block.Block
+	for height := uint64(0); height < 2; height++ {
+		header := block.Header{Height: height, Data: []byte{}}
+		if parent != nil {
+			header.Prev = parent.Hash()
+		}
+
+		blk := block.NewBlock(header, nil)
+		blk.CalcHash()
+		if err := db.CommitBlock(blk); err != nil {
+			t.Fatalf("CommitBlock() error = %v", err)
+		}
+		parent = blk
+	}
+
+	blk := block.NewBlock(block.Header{
+		Height:     2,
+		Prev:       parent.Hash(),
+		Timestamp:  parent.Timestamp(),
+		Difficulty: DiffInTurn,
+		Validator:  signer,
+		Data:       []byte{},
+	}, nil)
+	blk.CalcHash()
+
+	tests := []struct {
+		name string
+		blk  *block.Block
+		want error
+	}{
+		{"signed", signBlock(t, blk, priv, pub, 0), nil},
+		{"unsigned", blk, block.ErrInvalidSignature},
+		{"other chain", signBlock(t, blk, priv, pub, 1), block.ErrInvalidSignature},
+		{"forged", signBlock(t, blk, otherPriv, otherPub, 0), ErrSignerMismatch},
+	}
+
+	for _, tt := range tests {
+		if err := c.VerifyProposer(parent, tt.blk); !errors.Is(err, tt.want) {
+			t.Errorf("%s: VerifyProposer() error = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestConsensus_SnapshotMissingEvidence(t *testing.T) {
+	// The database lives under the home directory.
+	t.Setenv("HOME", t.TempDir())
+
+	db, err := prydb.InitDB()
+	if err != nil {
+		t.Fatalf("InitDB() error = %v", err)
+	}
+
+	c := InitConsensus(db, 1000, 1, 0, []common.Address{signerA, signerB, signerC})
+
+	var parent *block.Block
+	for height := uint64(0); height < 2; height++ {
+		header := block.Header{Height: height, Data: []byte{}}
+		if parent != nil {
+			header.Prev = parent.Hash()
+		}
+
+		blk := block.NewBlock(header, nil)
+		blk.CalcHash()
+		if err := db.CommitBlock(blk); err != nil {
+			t.Fatalf("CommitBlock() error = %v", err)
+		}
+		parent = blk
+	}
+
+	evidence := []block.Evidence{block.NewEvidence(
+		block.Header{Height: 1, Validator: signerC, Data: []byte{1}},
+		block.Header{Height: 1, Validator: signerC, Data: []byte{2}},
+	)}
+
+	header := block.Header{
+		Height:       2,
+		Prev:         parent.Hash(),
+		Validator:    signerA,
+		Data:         []byte{},
+		EvidenceRoot: block.CalcEvidenceRoot(evidence),
+	}
+
+	// Downloaded ahead of its body, the block hides the offence.
+	if _, err := c.Snapshot(block.FromHeader(header, nil)); !errors.Is(err, consensus.ErrMissingBody) {
+		t.Fatalf("Snapshot(header only) error = %v, want %v", err, consensus.ErrMissingBody)
+	}
+
+	full := block.FromHeader(header, nil)
+	full.SetEvidence(evidence)
+
+	snap, err := c.Snapshot(full)
+	if err != nil {
+		t.Fatalf("Snapshot() error = %v", err)
+	}
+
+	if _, ok := snap.Signers[signerC]; ok || len(snap.Signers) != 2 {
+		t.Errorf("signers = %v, want the offender dropped", snap.SignerList())
+	}
+}
@@ -0,0 +1,326 @@
+package poa
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/polarysfoundation/polarys-chain/modules/common"
+	"github.com/polarysfoundation/polarys-chain/modules/core/block"
+)
+
+// Votes are carried by the headers that are not checkpoints: Data holds
+// the address of the candidate and Nonce whether to authorise or drop it.
+var (
+	NonceAuthVote = uint64(0xffffffffffffffff)
+	NonceDropVote = uint64(0)
+)
+
+// Vote is a vote of a signer for authorising or dropping an address.
+type Vote struct {
+	Signer    common.Address `json:"signer"`
+	Height    uint64         `json:"height"`
+	Address   common.Address `json:"address"`
+	Authorize bool           `json:"authorize"`
+}
+
+// Tally is the count of the votes for an address.
+type Tally struct {
+	Authorize bool `json:"authorize"`
+	Votes     int  `json:"votes"`
+}
+
+// Snapshot is the authorisation state after a block: the signers, the
+// ones that signed recently and the pending votes.
+type Snapshot struct {
+	Height  uint64                      `json:"height"`
+	Hash    common.Hash                 `json:"hash"`
+	Signers map[common.Address]struct{} `json:"signers"`
+	Recents map[uint64]common.Address   `json:"recents"`
+	Votes   []*Vote                     `json:"votes"`
+	Tally   map[common.Address]Tally    `json:"tally"`
+}
+
+func newSnapshot(height uint64, hash common.Hash, signers []common.Address) *Snapshot {
+	snap := &Snapshot{
+		Height:  height,
+		Hash:    hash,
+		Signers: make(map[common.Address]struct{}, len(signers)),
+		Recents: make(map[uint64]common.Address),
+		Votes:   make([]*Vote, 0),
+		Tally:   make(map[common.Address]Tally),
+	}
+
+	for _, signer := range signers {
+		snap.Signers[signer] = struct{}{}
+	}
+
+	return snap
+}
+
+func (s *Snapshot) copy() *Snapshot {
+	cpy := &Snapshot{
+		Height:  s.Height,
+		Hash:    s.Hash,
+		Signers: make(map[common.Address]struct{}, len(s.Signers)),
+		Recents: make(map[uint64]common.Address, len(s.Recents)),
+		Votes:   make([]*Vote, len(s.Votes)),
+		Tally:   make(map[common.Address]Tally, len(s.Tally)),
+	}
+
+	for signer := range s.Signers {
+		cpy.Signers[signer] = struct{}{}
+	}
+
+	for height, signer := range s.Recents {
+		cpy.Recents[height] = signer
+	}
+
+	for address, tally := range s.Tally {
+		cpy.Tally[address] = tally
+	}
+
+	copy(cpy.Votes, s.Votes)
+
+	return cpy
+}
+
+// SignerList returns the signers sorted by address.
+func (s *Snapshot) SignerList() []common.Address {
+	signers := make([]common.Address, 0, len(s.Signers))
+	for signer := range s.Signers {
+		signers = append(signers, signer)
+	}
+
+	sort.Slice(signers, func(i, j int) bool {
+		return bytes.Compare(signers[i].Bytes(), signers[j].Bytes()) < 0
+	})
+
+	return signers
+}
+
+// recentLimit is the number of consecutive blocks in which a signer may
+// only sign once.
+func (s *Snapshot) recentLimit() uint64 {
+	return uint64(len(s.Signers)/2 + 1)
+}
+
+// signedRecently reports whether signer is not allowed to sign at height
+// yet.
+func (s *Snapshot) signedRecently(signer common.Address, height uint64) bool {
+	limit := s.recentLimit()
+	for seen, recent := range s.Recents {
+		if recent == signer && (height < limit || seen > height-limit) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// turn returns how many turns signer is away from signing at height, 0 for
+// the in-turn signer.
+func (s *Snapshot) turn(signer common.Address, height uint64) (uint64, bool) {
+	signers := s.SignerList()
+	for i, address := range signers {
+		if address == signer {
+			size := uint64(len(signers))
+			return (uint64(i) + size - height%size) % size, true
+		}
+	}
+
+	return 0, false
+}
+
+// validVote reports whether the vote would change the signer set.
+func (s *Snapshot) validVote(address common.Address, authorize bool) bool {
+	_, signer := s.Signers[address]
+	return (signer && !authorize) || (!signer && authorize)
+}
+
+func (s *Snapshot) cast(address common.Address, authorize bool) bool {
+	if !s.validVote(address, authorize) {
+		return false
+	}
+
+	tally, ok := s.Tally[address]
+	if ok && tally.Authorize != authorize {
+		return false
+	}
+
+	tally.Authorize = authorize
+	tally.Votes++
+	s.Tally[address] = tally
+
+	return true
+}
+
+func (s *Snapshot) uncast(address common.Address, authorize bool) {
+	tally, ok := s.Tally[address]
+	if !ok || tally.Authorize != authorize {
+		return
+	}
+
+	if tally.Votes > 1 {
+		tally.Votes--
+		s.Tally[address] = tally
+	} else {
+		delete(s.Tally, address)
+	}
+}
+
+// vote decodes the vote carried by a header that is not a checkpoint.
+func vote(header *block.Block) (common.Address, bool, bool, error) {
+	if header.Nonce() != NonceAuthVote && header.Nonce() != NonceDropVote {
+		return common.Address{}, false, false, ErrInvalidVote
+	}
+
+	switch len(header.Data()) {
+	case 0:
+		if header.Nonce() != NonceDropVote {
+			return common.Address{}, false, false, ErrInvalidVote
+		}
+
+		return common.Address{}, false, false, nil
+	case common.AddrLen:
+		return common.BytesToAddress(header.Data()), header.Nonce() == NonceAuthVote, true, nil
+	default:
+		return common.Address{}, false, false, ErrInvalidVote
+	}
+}
+
+// apply returns the snapshot after header, whose signature was checked
+// when it was imported.
+func (s *Snapshot) apply(header *block.Block, epoch uint64) (*Snapshot, error) {
+	if header.Height() != s.Height+1 || header.Prev() != s.Hash {
+		return nil, ErrUnknownAncestor
+	}
+
+	snap := s.copy()
+	snap.Height = header.Height()
+	snap.Hash = header.Hash()
+
+	if header.Height()%epoch == 0 {
+		snap.Votes = make([]*Vote, 0)
+		snap.Tally = make(map[common.Address]Tally)
+	}
+
+	if limit := snap.recentLimit(); header.Height() >= limit {
+		delete(snap.Recents, header.Height()-limit)
+	}
+
+	signer := header.Validator()
+	if _, ok := snap.Signers[signer]; !ok {
+		return nil, ErrUnauthorizedSigner
+	}
+
+	for _, recent := range snap.Recents {
+		if recent == signer {
+			return nil, ErrRecentlySigned
+		}
+	}
+	snap.Recents[header.Height()] = signer
+
+	snap.punish(header)
+
+	if header.Height()%epoch == 0 {
+		return snap, nil
+	}
+
+	address, authorize, ok, err := vote(header)
+	if err != nil {
+		return nil, err
+	}
+
+	if !ok {
+		return snap, nil
+	}
+
+	// A new vote of the signer for the same address replaces the old one.
+	for i, v := range snap.Votes {
+		if v.Signer == signer && v.Address == address {
+			snap.uncast(v.Address, v.Authorize)
+			snap.Votes = append(snap.Votes[:i], snap.Votes[i+1:]...)
+			break
+		}
+	}
+
+	if snap.cast(address, authorize) {
+		snap.Votes = append(snap.Votes, &Vote{
+			Signer:    signer,
+			Height:    header.Height(),
+			Address:   address,
+			Authorize: authorize,
+		})
+	}
+
+	// A strict majority of the signers changes the set.
+	if tally := snap.Tally[address]; tally.Votes > len(snap.Signers)/2 {
+		if tally.Authorize {
+			snap.Signers[address] = struct{}{}
+		} else {
+			snap.drop(address, header.Height())
+		}
+
+		for i := 0; i < len(snap.Votes); i++ {
+			if snap.Votes[i].Address == address {
+				snap.Votes = append(snap.Votes[:i], snap.Votes[i+1:]...)
+				i--
+			}
+		}
+		delete(snap.Tally, address)
+	}
+
+	return snap, nil
+}
+
+// punish drops the signers caught double signing by the evidence of
+// header, without a vote. The last signer is kept, the chain would stop
+// otherwise.
+func (s *Snapshot) punish(header *block.Block) {
+	for _, evidence := range header.Evidence() {
+		if _, ok := s.Signers[evidence.Offender()]; ok && len(s.Signers) > 1 {
+			s.drop(evidence.Offender(), header.Height())
+		}
+	}
+}
+
+// drop removes address from the signers along with its votes.
+func (s *Snapshot) drop(address common.Address, height uint64) {
+	delete(s.Signers, address)
+
+	// The window shrinks with the set.
+	if limit := s.recentLimit(); height >= limit {
+		delete(s.Recents, height-limit)
+	}
+
+	for i := 0; i < len(s.Votes); i++ {
+		if s.Votes[i].Signer == address {
+			s.uncast(s.Votes[i].Address, s.Votes[i].Authorize)
+			s.Votes = append(s.Votes[:i], s.Votes[i+1:]...)
+			i--
+		}
+	}
+}
+
+// checkpointData encodes the signers stored in checkpoint headers.
+func checkpointData(signers []common.Address) []byte {
+	data := make([]byte, 0, len(signers)*common.AddrLen)
+	for _, signer := range signers {
+		data = append(data, signer.Bytes()...)
+	}
+
+	return data
+}
+
+func parseCheckpoint(data []byte) ([]common.Address, error) {
+	if len(data) == 0 || len(data)%common.AddrLen != 0 {
+		return nil, ErrInvalidCheckpoint
+	}
+
+	signers := make([]common.Address, 0, len(data)/common.AddrLen)
+	for i := 0; i < len(data); i += common.AddrLen {
+		signers = append(signers, common.BytesToAddress(data[i:i+common.AddrLen]))
+	}
+
+	return signers, nil
+}
@@ -0,0 +1,27 @@
+package pos
+
+import "errors"
+
+// Define error variables
+var (
+	ErrInvalidBlockHash      = errors.New("invalid block hash")
+	ErrInvalidConsensusProof = errors.New("invalid consensus proof")
+	ErrInvalidValidatorProof = errors.New("invalid validator proof")
+	ErrInvalidValidator      = errors.New("invalid validator")
+	ErrInvalidBlockHeight    = errors.New("invalid block height")
+	ErrInvalidEpoch          = errors.New("invalid epoch")
+	ErrDuplicatedBlock       = errors.New("duplicated block")
+	ErrInvalidDifficulty     = errors.New("invalid difficulty")
+	ErrNilBlock              = errors.New("block is nil")
+	ErrNilPreviousBlock      = errors.New("previous block is nil")
+	ErrInvalidBlockTimestamp = errors.New("invalid block timestamp")
+	ErrInvalidProtocolHash   = errors.New("invalid protocol hash")
+	ErrInvalidChainID        = errors.New("invalid chain ID")
+	ErrInvalidTxRoot         = errors.New("invalid transactions root")
+	ErrSealAborted           = errors.New("seal aborted")
+	ErrOutOfTurn             = errors.New("validator proposed before its turn")
+	ErrSignerMismatch        = errors.New("signer is not the block validator")
+	ErrNoValidators          = errors.New("no validators")
+	ErrUnknownAncestor       = errors.New("unknown ancestor")
+	ErrStakeOverflow         = errors.New("stake overflow")
+)
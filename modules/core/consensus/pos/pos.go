@@ -0,0 +1,806 @@
+package pos
+
+import (
+	"context"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/polarysfoundation/polarys-chain/modules/common"
+	"github.com/polarysfoundation/polarys-chain/modules/core/block"
+	"github.com/polarysfoundation/polarys-chain/modules/core/consensus"
+	"github.com/polarysfoundation/polarys-chain/modules/crypto"
+	"github.com/polarysfoundation/polarys-chain/modules/prydb"
+)
+
+var (
+	DefaultEpoch = uint64(1000)
+
+	// The in-turn proposer weighs more, so the fork choice prefers the
+	// chain that followed the schedule.
+	DiffInTurn = uint64(2)
+	DiffNoTurn = uint64(1)
+)
+
+var (
+	BlockReward = big.NewInt(10000000000000000)
+)
+
+const (
+	allowedFutureBlockTime = 15 * time.Second
+
+	snapshotsKept = 16 // epochs of snapshots kept in memory
+)
+
+// Consensus is a proof-of-stake engine. Validators join by staking to
+// consensus.SystemAddress, and the validator set of an epoch is the one
+// staked at the end of the previous epoch. Snapshots are rebuilt from the
+// blocks after a restart.
+//
+// The engine reads the chain straight from the database, because it is
+// called by the blockchain while the chain lock is held.
+type Consensus struct {
+	db               *prydb.Database
+	epoch            uint64
+	delay            uint64
+	chainID          uint64
+	protocolHash     common.Hash
+	genesis          *Snapshot
+	currentValidator common.Address
+
+	lock        sync.Mutex
+	snapshots   map[common.Hash]*Snapshot                   // By checkpoint hash
+	checkpoints map[common.Hash]common.Hash                 // Block hash to the checkpoint of the next block
+	offences    map[common.Hash]map[common.Address]struct{} // Block hash to the offenders caught in its epoch so far
+}
+
+func InitConsensus(db *prydb.Database, epoch, delay, chainID, minStake uint64, validators []common.Address) *Consensus {
+	buff := common.Decode("PosEngine")
+	protocolHash := crypto.Pm256(buff)
+
+	if epoch == 0 {
+		epoch = DefaultEpoch
+	}
+
+	return &Consensus{
+		db:           db,
+		epoch:        epoch,
+		delay:        delay,
+		chainID:      chainID,
+		protocolHash: common.BytesToHash(protocolHash),
+		genesis:      newSnapshot(minStake, validators),
+		snapshots:    make(map[common.Hash]*Snapshot),
+		checkpoints:  make(map[common.Hash]common.Hash),
+		offences:     make(map[common.Hash]map[common.Address]struct{}),
+	}
+}
+
+func (c *Consensus) Validator() common.Address {
+	return c.currentValidator
+}
+
+func (c *Consensus) ProtocolHash() common.Hash {
+	return c.protocolHash
+}
+
+// Authorize sets the validator this node proposes blocks as.
+func (c *Consensus) Authorize(validator common.Address) {
+	c.currentValidator = validator
+}
+
+// ConsensusProof commits to the chain, the parent height and the epoch of
+// the block built on top of it.
+func (c *Consensus) ConsensusProof(crrBlockNumber uint64) ([]byte, error) {
+	consensusProof := make([]byte, 64)
+	copy(consensusProof[:8], common.Uint64ToBytes(c.chainID))
+	copy(consensusProof[8:16], common.Uint64ToBytes(crrBlockNumber))
+	copy(consensusProof[16:24], common.Uint64ToBytes(c.epoch))
+	copy(consensusProof[24:32], common.Uint64ToBytes(c.epochOf(crrBlockNumber+1)))
+	copy(consensusProof[32:], c.protocolHash.Bytes())
+
+	return consensusProof, nil
+}
+
+func (c *Consensus) ValidatorProof() ([]byte, error) {
+	validatorProof := make([]byte, 64)
+
+	copy(validatorProof[:8], common.Uint64ToBytes(c.chainID))
+	copy(validatorProof[8:16], common.Uint64ToBytes(c.epoch))
+	copy(validatorProof[16:31], c.currentValidator.Bytes())
+	copy(validatorProof[32:64], c.protocolHash.Bytes())
+
+	return validatorProof, nil
+}
+
+// ValidatorExists reports whether address is a validator of the epoch
+// following the chain head.
+func (c *Consensus) ValidatorExists(address common.Address) bool {
+	head, err := c.db.LatestBlock()
+	if err != nil {
+		return false
+	}
+
+	snap, err := c.Snapshot(head)
+	if err != nil {
+		return false
+	}
+
+	for _, validator := range snap.Validators() {
+		if validator.Address == address {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (c *Consensus) VerifyBlock(chain consensus.Chain, block *block.Block) (bool, error) {
+	if block == nil {
+		return false, ErrNilBlock
+	}
+
+	prevBlock, err := chain.GetBlockByHeight(block.Height() - 1)
+	if err != nil {
+		return false, err
+	}
+
+	if prevBlock == nil {
+		return false, ErrNilPreviousBlock
+	}
+
+	if prevBlock.Hash() != block.Prev() {
+		return false, ErrInvalidBlockHash
+	}
+
+	if !block.VerifyTxRoot() {
+		return false, ErrInvalidTxRoot
+	}
+
+	if err := c.verifyConsensusProof(block, prevBlock); err != nil {
+		return false, err
+	}
+
+	if !c.verifyValidatorProof(block) {
+		return false, ErrInvalidValidatorProof
+	}
+
+	if err := c.VerifyHeader(prevBlock, block); err != nil {
+		return false, err
+	}
+
+	latestBlock, err := chain.GetLatestBlock()
+	if err != nil {
+		return false, err
+	}
+
+	if block.Height() <= latestBlock.Height() {
+		return false, ErrInvalidBlockHeight
+	}
+
+	if block.Prev() != latestBlock.Hash() {
+		return false, ErrInvalidBlockHash
+	}
+
+	tmpBlk, err := chain.GetBlockByHeight(block.Height())
+	if err == nil && tmpBlk != nil {
+		return false, ErrDuplicatedBlock
+	}
+
+	return true, nil
+}
+
+// VerifyHeader checks a header against its parent and the validator set
+// of its epoch.
+func (c *Consensus) VerifyHeader(parent *block.Block, header *block.Block) error {
+	if parent == nil || header == nil {
+		return ErrNilBlock
+	}
+
+	if header.Height() != parent.Height()+1 {
+		return ErrInvalidBlockHeight
+	}
+
+	if header.Prev() != parent.Hash() {
+		return ErrInvalidBlockHash
+	}
+
+	if header.Timestamp() < parent.Timestamp() {
+		return ErrInvalidBlockTimestamp
+	}
+
+	if header.Timestamp() > uint64(time.Now().Add(allowedFutureBlockTime).Unix()) {
+		return ErrInvalidBlockTimestamp
+	}
+
+	if header.TotalDifficulty() != parent.TotalDifficulty()+header.Difficulty() {
+		return ErrInvalidDifficulty
+	}
+
+	if err := consensus.VerifyGas(parent, header); err != nil {
+		return err
+	}
+
+	if err := c.VerifySignature(header); err != nil {
+		return err
+	}
+
+	if err := c.VerifyProposer(parent, header); err != nil {
+		return err
+	}
+
+	if ok, err := c.DifficultyValidator(header, parent); err != nil || !ok {
+		return ErrInvalidDifficulty
+	}
+
+	return c.VerifySeal(header)
+}
+
+// VerifySignature checks that header was signed, for this chain, by the
+// key of its validator.
+func (c *Consensus) VerifySignature(header *block.Block) error {
+	if header == nil {
+		return ErrNilBlock
+	}
+
+	signer, err := header.Signer(c.chainID)
+	if err != nil {
+		return err
+	}
+
+	if signer != header.Validator() {
+		return ErrSignerMismatch
+	}
+
+	return nil
+}
+
+func (c *Consensus) VerifyChain(chain consensus.Chain) (bool, error) {
+	latestBlock, err := chain.GetLatestBlock()
+	if err != nil {
+		return false, err
+	}
+
+	for i := uint64(2); i <= latestBlock.Height(); i++ {
+		currentBlock, err := chain.GetBlockByHeight(i)
+		if err != nil {
+			return false, err
+		}
+
+		prevBlock, err := chain.GetBlockByHeight(i - 1)
+		if err != nil {
+			return false, err
+		}
+
+		if currentBlock.CalcHash() != currentBlock.Hash() {
+			return false, ErrInvalidBlockHash
+		}
+
+		if prevBlock.Hash() != currentBlock.Prev() {
+			return false, ErrInvalidBlockHash
+		}
+
+		if ok, err := c.DifficultyValidator(currentBlock, prevBlock); err != nil || !ok {
+			return false, ErrInvalidDifficulty
+		}
+	}
+
+	return true, nil
+}
+
+func (c *Consensus) verifyConsensusProof(block *block.Block, prevBlock *block.Block) error {
+	consensusProof := block.ConsensusProof()
+	if len(consensusProof) != 64 {
+		return ErrInvalidConsensusProof
+	}
+
+	chainID := common.BytesToUint64(consensusProof[:8])
+	crrBlockNumber := common.BytesToUint64(consensusProof[8:16])
+	epochLength := common.BytesToUint64(consensusProof[16:24])
+	epoch := common.BytesToUint64(consensusProof[24:32])
+	protocolHash := common.BytesToHash(consensusProof[32:])
+
+	if chainID != c.chainID {
+		return ErrInvalidChainID
+	}
+
+	if crrBlockNumber != prevBlock.Height() {
+		return ErrInvalidBlockHeight
+	}
+
+	if epochLength != c.epoch || epoch != c.epochOf(block.Height()) {
+		return ErrInvalidEpoch
+	}
+
+	if protocolHash != c.protocolHash {
+		return ErrInvalidProtocolHash
+	}
+
+	return nil
+}
+
+func (c *Consensus) verifyValidatorProof(block *block.Block) bool {
+	validatorProof := block.ValidatorProof()
+	if len(validatorProof) != 64 {
+		return false
+	}
+
+	chainID := common.BytesToUint64(validatorProof[:8])
+	epoch := common.BytesToUint64(validatorProof[8:16])
+	validator := common.BytesToAddress(validatorProof[16:31])
+	protocolHash := common.BytesToHash(validatorProof[32:])
+
+	return chainID == c.chainID && epoch == c.epoch && protocolHash == c.protocolHash && validator == block.Validator()
+}
+
+// BlockReward returns the amount minted for the proposer of the block at
+// the given height.
+func (c *Consensus) BlockReward(height uint64) *big.Int {
+	return new(big.Int).Set(BlockReward)
+}
+
+// AdjustDifficulty returns the difficulty of block, which only depends on
+// whether its validator is in turn.
+func (c *Consensus) AdjustDifficulty(block *block.Block, prevBlock *block.Block) uint64 {
+	if block == nil || prevBlock == nil {
+		return DiffNoTurn
+	}
+
+	snap, err := c.Snapshot(prevBlock)
+	if err != nil {
+		return DiffNoTurn
+	}
+
+	if turn, ok := snap.turn(block.Validator(), block.Height()); ok && turn == 0 {
+		return DiffInTurn
+	}
+
+	return DiffNoTurn
+}
+
+func (c *Consensus) DifficultyValidator(block *block.Block, prevBlock *block.Block) (bool, error) {
+	if block == nil || prevBlock == nil {
+		return false, ErrNilBlock
+	}
+
+	return block.Difficulty() == c.AdjustDifficulty(block, prevBlock), nil
+}
+
+// SealBlock seals the block with its hash. Stake takes the place of work,
+// so there is nothing to search for.
+func (c *Consensus) SealBlock(ctx context.Context, blk *block.Block) (*block.Block, error) {
+	if blk == nil {
+		return nil, ErrNilBlock
+	}
+
+	if ctx.Err() != nil {
+		return nil, ErrSealAborted
+	}
+
+	sealed := block.FromHeader(blk.Header(), blk.Transactions())
+	sealed.SetEvidence(blk.Evidence())
+	sealed.Seal(sealed.Hash())
+
+	return sealed, nil
+}
+
+// VerifySeal checks the block hash and that the difficulty is one a
+// proposer may claim.
+func (c *Consensus) VerifySeal(blk *block.Block) error {
+	if blk == nil {
+		return ErrNilBlock
+	}
+
+	hash := blk.Hash()
+	if blk.CalcHash() != hash {
+		return ErrInvalidBlockHash
+	}
+
+	if blk.Difficulty() != DiffInTurn && blk.Difficulty() != DiffNoTurn {
+		return ErrInvalidDifficulty
+	}
+
+	return nil
+}
+
+// SelectValidator returns the in-turn validator at height on the canonical
+// chain.
+func (c *Consensus) SelectValidator(height uint64) common.Address {
+	if height == 0 {
+		return common.Address{}
+	}
+
+	parent, err := c.db.GetBlockByHeight(height - 1)
+	if err != nil {
+		return common.Address{}
+	}
+
+	snap, err := c.active(parent)
+	if err != nil {
+		return common.Address{}
+	}
+
+	order := snap.order(height)
+	if len(order) == 0 {
+		return common.Address{}
+	}
+
+	return order[0]
+}
+
+// ProposalTime returns the earliest timestamp at which validator may
+// propose on top of parent, one turn timeout for every validator drawn
+// before it.
+func (c *Consensus) ProposalTime(parent *block.Block, validator common.Address) (uint64, error) {
+	if parent == nil {
+		return 0, ErrNilPreviousBlock
+	}
+
+	snap, err := c.active(parent)
+	if err != nil {
+		return 0, err
+	}
+
+	turn, ok := snap.turn(validator, parent.Height()+1)
+	if !ok {
+		return 0, ErrInvalidValidator
+	}
+
+	return parent.Timestamp() + turn*c.turnTimeout(), nil
+}
+
+// VerifyProposer checks that the validator of header was allowed to
+// propose on top of parent at the header timestamp, and claims the
+// difficulty of its turn.
+func (c *Consensus) VerifyProposer(parent *block.Block, header *block.Block) error {
+	if header == nil {
+		return ErrNilBlock
+	}
+
+	earliest, err := c.ProposalTime(parent, header.Validator())
+	if err != nil {
+		return err
+	}
+
+	if header.Timestamp() < earliest {
+		return ErrOutOfTurn
+	}
+
+	if header.Difficulty() != c.AdjustDifficulty(header, parent) {
+		return ErrInvalidDifficulty
+	}
+
+	return nil
+}
+
+// Finalize refunds, in the first block of an epoch, the stakes withdrawn
+// during the previous one, and burns the stakes of the validators caught
+// double signing in it.
+func (c *Consensus) Finalize(blk *block.Block, st consensus.State) error {
+	if blk.Height() == 0 || blk.Height()%c.epoch != 0 {
+		return nil
+	}
+
+	parent, err := c.db.GetBlockByHash(blk.Prev())
+	if err != nil {
+		return ErrUnknownAncestor
+	}
+
+	snap, err := c.Snapshot(parent)
+	if err != nil {
+		return err
+	}
+
+	addresses := make([]common.Address, 0, len(snap.Withdrawals))
+	for address := range snap.Withdrawals {
+		addresses = append(addresses, address)
+	}
+
+	sort.Slice(addresses, func(i, j int) bool {
+		return addresses[i].CXID() < addresses[j].CXID()
+	})
+
+	for _, address := range addresses {
+		amount := snap.Withdrawals[address]
+		if !amount.IsUint64() {
+			return ErrStakeOverflow
+		}
+
+		if err := st.SubBalance(consensus.SystemAddress, amount.Uint64()); err != nil {
+			return err
+		}
+
+		if err := st.AddBalance(address, amount.Uint64()); err != nil {
+			return err
+		}
+	}
+
+	offenders := make([]common.Address, 0, len(snap.Slashed))
+	for address := range snap.Slashed {
+		offenders = append(offenders, address)
+	}
+
+	sort.Slice(offenders, func(i, j int) bool {
+		return offenders[i].CXID() < offenders[j].CXID()
+	})
+
+	for _, address := range offenders {
+		amount := snap.Slashed[address]
+		if !amount.IsUint64() {
+			return ErrStakeOverflow
+		}
+
+		if amount.Sign() == 0 {
+			continue
+		}
+
+		if err := st.SubBalance(consensus.SystemAddress, amount.Uint64()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// JailsOffenders marks the engine as a consensus.Jailer: offenders leave
+// the active set at once and lose their stake at the end of the epoch.
+func (c *Consensus) JailsOffenders() {}
+
+// Validators returns the validators allowed to propose the block following
+// blk, with their power.
+func (c *Consensus) Validators(blk *block.Block) ([]*consensus.Validator, error) {
+	snap, err := c.active(blk)
+	if err != nil {
+		return nil, err
+	}
+
+	return snap.Validators(), nil
+}
+
+// Snapshot returns the validator set of the epoch of the block following
+// parent.
+func (c *Consensus) Snapshot(parent *block.Block) (*Snapshot, error) {
+	if parent == nil {
+		return nil, ErrNilPreviousBlock
+	}
+
+	epoch := c.epochOf(parent.Height() + 1)
+	if epoch == 0 {
+		return c.genesis, nil
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	checkpoint, err := c.checkpoint(parent, epoch*c.epoch-1)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.snapshot(checkpoint)
+}
+
+// active returns the validator set of the block following parent: the
+// snapshot of its epoch without the validators caught double signing since
+// the epoch started. Their stake is only burned at the end of the epoch.
+func (c *Consensus) active(parent *block.Block) (*Snapshot, error) {
+	snap, err := c.Snapshot(parent)
+	if err != nil {
+		return nil, err
+	}
+
+	// The snapshot of a new epoch already jails them.
+	if c.epochOf(parent.Height()+1) != c.epochOf(parent.Height()) {
+		return snap, nil
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	offenders, err := c.offenders(parent)
+	if err != nil {
+		return nil, err
+	}
+
+	return snap.jail(offenders), nil
+}
+
+// offenders returns the validators punished by the evidence included from
+// the start of the epoch of blk up to blk. The lock must be held.
+func (c *Consensus) offenders(blk *block.Block) (map[common.Address]struct{}, error) {
+	start := c.epochOf(blk.Height()) * c.epoch
+
+	var (
+		base    map[common.Address]struct{}
+		visited []*block.Block
+		current = blk
+	)
+
+	for {
+		if cached, ok := c.offences[current.Hash()]; ok {
+			base = cached
+			break
+		}
+
+		if current.MissingEvidence() {
+			return nil, consensus.ErrMissingBody
+		}
+
+		visited = append(visited, current)
+		if current.Height() == start {
+			base = make(map[common.Address]struct{})
+			break
+		}
+
+		// Headers being synchronised are not in the database yet, but
+		// their parent was resolved when it was verified.
+		if cached, ok := c.offences[current.Prev()]; ok {
+			base = cached
+			break
+		}
+
+		parent, err := c.db.GetBlockByHash(current.Prev())
+		if err != nil {
+			return nil, ErrUnknownAncestor
+		}
+		current = parent
+	}
+
+	if len(c.offences)+len(visited) > int(4*c.epoch) {
+		c.offences = make(map[common.Hash]map[common.Address]struct{})
+	}
+
+	// Blocks without evidence share the set of their parent.
+	for i := len(visited) - 1; i >= 0; i-- {
+		if evidence := visited[i].Evidence(); len(evidence) > 0 {
+			set := make(map[common.Address]struct{}, len(base)+len(evidence))
+			for address := range base {
+				set[address] = struct{}{}
+			}
+
+			for j := range evidence {
+				set[evidence[j].Offender()] = struct{}{}
+			}
+			base = set
+		}
+
+		c.offences[visited[i].Hash()] = base
+	}
+
+	return base, nil
+}
+
+// checkpoint returns the hash of the ancestor of blk at height, the last
+// block of an epoch. The lock must be held.
+func (c *Consensus) checkpoint(blk *block.Block, height uint64) (common.Hash, error) {
+	visited := make([]common.Hash, 0)
+	current := blk
+	hash := current.Hash()
+
+	for current.Height() > height {
+		if cached, ok := c.checkpoints[current.Hash()]; ok {
+			hash = cached
+			break
+		}
+
+		visited = append(visited, current.Hash())
+
+		// Headers being synchronised are not in the database yet, so
+		// resolve the parent from its hash or from the cache filled when
+		// it was verified.
+		if current.Height()-1 == height {
+			hash = current.Prev()
+			break
+		}
+
+		if cached, ok := c.checkpoints[current.Prev()]; ok {
+			hash = cached
+			break
+		}
+
+		parent, err := c.db.GetBlockByHash(current.Prev())
+		if err != nil {
+			return common.Hash{}, ErrUnknownAncestor
+		}
+
+		current = parent
+		hash = current.Hash()
+	}
+
+	// The cache only serves the current epochs, drop it when it outgrows
+	// them.
+	if len(c.checkpoints)+len(visited) > int(4*c.epoch) {
+		c.checkpoints = make(map[common.Hash]common.Hash)
+	}
+
+	for _, visitedHash := range visited {
+		c.checkpoints[visitedHash] = hash
+	}
+
+	return hash, nil
+}
+
+// snapshot returns the snapshot of the epoch following checkpoint,
+// applying the epochs missing since the last known snapshot. The lock must
+// be held.
+func (c *Consensus) snapshot(checkpoint common.Hash) (*Snapshot, error) {
+	var (
+		base    = c.genesis
+		epochs  [][]epochBlock
+		hashes  []common.Hash
+		current = checkpoint
+	)
+
+	for {
+		if snap, ok := c.snapshots[current]; ok {
+			base = snap
+			break
+		}
+
+		// The staking transactions of an epoch are only known once its
+		// bodies are imported.
+		blk, err := c.db.GetBlockByHash(current)
+		if err != nil {
+			return nil, consensus.ErrMissingBody
+		}
+
+		// Walk the epoch that ends with blk back to its first block.
+		start := blk.Height() + 1 - c.epoch
+		blocks := make([]epochBlock, 0, c.epoch)
+
+		for {
+			blocks = append(blocks, newEpochBlock(blk))
+			if blk.Height() == start {
+				break
+			}
+
+			blk, err = c.db.GetBlockByHash(blk.Prev())
+			if err != nil {
+				return nil, consensus.ErrMissingBody
+			}
+		}
+
+		epochs = append(epochs, blocks)
+		hashes = append(hashes, current)
+
+		if start == 0 {
+			break
+		}
+
+		current = blk.Prev()
+	}
+
+	// Apply the epochs oldest first, each in chain order.
+	for i := len(epochs) - 1; i >= 0; i-- {
+		blocks := epochs[i]
+		for l, r := 0, len(blocks)-1; l < r; l, r = l+1, r-1 {
+			blocks[l], blocks[r] = blocks[r], blocks[l]
+		}
+
+		snap, err := base.apply(blocks, hashes[i])
+		if err != nil {
+			return nil, err
+		}
+
+		c.snapshots[hashes[i]] = snap
+		base = snap
+	}
+
+	for hash, snap := range c.snapshots {
+		if snap.Epoch+snapshotsKept < base.Epoch {
+			delete(c.snapshots, hash)
+		}
+	}
+
+	return base, nil
+}
+
+func (c *Consensus) epochOf(height uint64) uint64 {
+	return height / c.epoch
+}
+
+// turnTimeout is the time, in seconds, a validator waits for each
+// validator in turn before it.
+func (c *Consensus) turnTimeout() uint64 {
+	return max(c.delay, 1)
+}
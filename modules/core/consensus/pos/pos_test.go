@@ -0,0 +1,289 @@
+package pos
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/polarysfoundation/polarys-chain/modules/common"
+	"github.com/polarysfoundation/polarys-chain/modules/core/block"
+	"github.com/polarysfoundation/polarys-chain/modules/core/consensus"
+	"github.com/polarysfoundation/polarys-chain/modules/core/transaction"
+	"github.com/polarysfoundation/polarys-chain/modules/prydb"
+)
+
+var (
+	bootstrap = common.BytesToAddress([]byte("bootstrap_aaaaa"))
+	stakerA   = common.BytesToAddress([]byte("staker_aaaaaaaa"))
+	stakerB   = common.BytesToAddress([]byte("staker_bbbbbbbb"))
+)
+
+func stakeTx(t *testing.T, from common.Address, value int64, data []byte) transaction.Transaction {
+	t.Helper()
+
+	tx, err := transaction.NewTransaction(from, consensus.SystemAddress, big.NewInt(value), data, 0, 0, transaction.Legacy, nil, 1000000)
+	if err != nil {
+		t.Fatalf("NewTransaction() error = %v", err)
+	}
+
+	return *tx
+}
+
+func TestSnapshot_Apply(t *testing.T) {
+	genesis := newSnapshot(100, []common.Address{bootstrap})
+
+	validators := genesis.Validators()
+	if len(validators) != 1 || validators[0].Address != bootstrap {
+		t.Fatalf("Validators() = %v, want the bootstrap validator", validators)
+	}
+
+	snap, err := genesis.apply([]epochBlock{
+		{validator: bootstrap, txs: []transaction.Transaction{stakeTx(t, stakerA, 250, nil)}},
+		{validator: bootstrap, txs: []transaction.Transaction{stakeTx(t, stakerB, 50, nil)}},
+	}, common.Hash{1})
+	if err != nil {
+		t.Fatalf("apply() error = %v", err)
+	}
+
+	// Below the minimum stake stakerB has no power, the bootstrap validator
+	// leaves as soon as someone staked enough.
+	validators = snap.Validators()
+	if len(validators) != 1 || validators[0].Address != stakerA {
+		t.Fatalf("Validators() = %v, want stakerA only", validators)
+	}
+
+	if validators[0].Power.Int64() != 2 || validators[0].Staked.Int64() != 250 {
+		t.Errorf("stakerA power = %v, staked = %v, want 2 and 250", validators[0].Power, validators[0].Staked)
+	}
+
+	next, err := snap.apply([]epochBlock{
+		{validator: stakerA, txs: []transaction.Transaction{stakeTx(t, stakerB, 50, nil)}},
+		{validator: stakerA, txs: []transaction.Transaction{stakeTx(t, stakerA, 0, UnstakeData)}},
+	}, common.Hash{2})
+	if err != nil {
+		t.Fatalf("apply() error = %v", err)
+	}
+
+	validators = next.Validators()
+	if len(validators) != 1 || validators[0].Address != stakerB {
+		t.Fatalf("Validators() = %v, want stakerB only", validators)
+	}
+
+	if got := next.Withdrawals[stakerA]; got == nil || got.Int64() != 250 {
+		t.Errorf("Withdrawals[stakerA] = %v, want 250", got)
+	}
+
+	// The previous snapshot is left untouched.
+	if snap.Stakers[stakerA].Staked.Int64() != 250 {
+		t.Errorf("apply() changed the previous snapshot")
+	}
+}
+
+func TestSnapshot_Slash(t *testing.T) {
+	genesis := newSnapshot(100, []common.Address{bootstrap})
+
+	snap, err := genesis.apply([]epochBlock{
+		{validator: bootstrap, txs: []transaction.Transaction{stakeTx(t, stakerA, 300, nil), stakeTx(t, stakerB, 200, nil)}},
+	}, common.Hash{1})
+	if err != nil {
+		t.Fatalf("apply() error = %v", err)
+	}
+
+	// stakerA tries to leave before the evidence is included.
+	next, err := snap.apply([]epochBlock{
+		{validator: stakerB, txs: []transaction.Transaction{stakeTx(t, stakerA, 0, UnstakeData)}},
+		{validator: stakerB, offenders: []common.Address{stakerA}},
+		{validator: stakerB, offenders: []common.Address{stakerA}},
+	}, common.Hash{2})
+	if err != nil {
+		t.Fatalf("apply() error = %v", err)
+	}
+
+	if got := next.Slashed[stakerA]; got == nil || got.Int64() != 300 {
+		t.Errorf("Slashed[stakerA] = %v, want 300", got)
+	}
+
+	if _, ok := next.Withdrawals[stakerA]; ok {
+		t.Errorf("slashed stake still withdrawn")
+	}
+
+	validators := next.Validators()
+	if len(validators) != 1 || validators[0].Address != stakerB {
+		t.Fatalf("Validators() = %v, want stakerB only", validators)
+	}
+
+	// Staking again does not bring the offender back.
+	last, err := next.apply([]epochBlock{
+		{validator: stakerB, txs: []transaction.Transaction{stakeTx(t, stakerA, 500, nil)}},
+	}, common.Hash{3})
+	if err != nil {
+		t.Fatalf("apply() error = %v", err)
+	}
+
+	if _, ok := last.Jailed[stakerA]; !ok || len(last.Validators()) != 1 {
+		t.Errorf("Validators() = %v, want stakerA jailed", last.Validators())
+	}
+}
+
+func TestSnapshot_Order(t *testing.T) {
+	snap := newSnapshot(1, nil)
+	snap.Stakers[stakerA] = newValidator(stakerA)
+	snap.Stakers[stakerA].Power = big.NewInt(9)
+	snap.Stakers[stakerB] = newValidator(stakerB)
+	snap.Stakers[stakerB].Power = big.NewInt(1)
+
+	inTurn := 0
+	for height := uint64(0); height < 1000; height++ {
+		order := snap.order(height)
+		if len(order) != 2 || order[0] == order[1] {
+			t.Fatalf("order(%d) = %v, want both validators once", height, order)
+		}
+
+		if order[0] == stakerA {
+			inTurn++
+		}
+	}
+
+	// stakerA holds 90% of the power.
+	if inTurn < 850 || inTurn > 950 {
+		t.Errorf("stakerA in turn %d times out of 1000, want about 900", inTurn)
+	}
+}
+
+type testState map[common.Address]uint64
+
+func (s testState) GetBalance(address common.Address) (uint64, error) {
+	return s[address], nil
+}
+
+func (s testState) AddBalance(address common.Address, amount uint64) error {
+	s[address] += amount
+	return nil
+}
+
+func (s testState) SubBalance(address common.Address, amount uint64) error {
+	s[address] -= amount
+	return nil
+}
+
+func TestConsensus_Epochs(t *testing.T) {
+	// The database lives under the home directory.
+	t.Setenv("HOME", t.TempDir())
+
+	db, err := prydb.InitDB()
+	if err != nil {
+		t.Fatalf("InitDB() error = %v", err)
+	}
+
+	c := InitConsensus(db, 2, 1, 0, 100, []common.Address{bootstrap})
+
+	txs := [][]transaction.Transaction{
+		nil,
+		{stakeTx(t, stakerA, 100, nil)},
+		{stakeTx(t, stakerA, 0, UnstakeData)},
+		nil,
+	}
+
+	var parent *block.Block
+	blocks := make([]*block.Block, 0, len(txs))
+	for height, blockTxs := range txs {
+		header := block.Header{Height: uint64(height), Validator: bootstrap, Data: []byte{}}
+		if parent != nil {
+			header.Prev = parent.Hash()
+		}
+
+		blk := block.NewBlock(header, blockTxs)
+		blk.CalcHash()
+		if err := db.CommitBlock(blk); err != nil {
+			t.Fatalf("CommitBlock() error = %v", err)
+		}
+
+		blocks = append(blocks, blk)
+		parent = blk
+	}
+
+	tests := []struct {
+		parent *block.Block
+		want   common.Address
+	}{
+		{blocks[0], bootstrap}, // epoch 0
+		{blocks[1], stakerA},   // epoch 1, staked in epoch 0
+		{blocks[2], stakerA},
+		{blocks[3], bootstrap}, // epoch 2, unstaked in epoch 1
+	}
+
+	for _, tt := range tests {
+		snap, err := c.Snapshot(tt.parent)
+		if err != nil {
+			t.Fatalf("Snapshot(%d) error = %v", tt.parent.Height(), err)
+		}
+
+		validators := snap.Validators()
+		if len(validators) != 1 || validators[0].Address != tt.want {
+			t.Errorf("Snapshot(%d) validators = %v, want %v", tt.parent.Height(), validators, tt.want)
+		}
+	}
+
+	// The stake comes back in the first block of epoch 2.
+	st := testState{consensus.SystemAddress: 100}
+	next := block.NewBlock(block.Header{Height: 4, Prev: blocks[3].Hash()}, nil)
+	if err := c.Finalize(next, st); err != nil {
+		t.Fatalf("Finalize() error = %v", err)
+	}
+
+	if st[stakerA] != 100 || st[consensus.SystemAddress] != 0 {
+		t.Errorf("balances after Finalize() = %v, want the stake refunded", st)
+	}
+}
+
+func TestConsensus_VerifyProposer(t *testing.T) {
+	// The database lives under the home directory.
+	t.Setenv("HOME", t.TempDir())
+
+	db, err := prydb.InitDB()
+	if err != nil {
+		t.Fatalf("InitDB() error = %v", err)
+	}
+
+	c := InitConsensus(db, 1000, 1, 0, 100, []common.Address{stakerA, stakerB})
+
+	genesis := block.NewBlock(block.Header{Data: []byte{}}, nil)
+	genesis.CalcHash()
+	if err := db.CommitBlock(genesis); err != nil {
+		t.Fatalf("CommitBlock() error = %v", err)
+	}
+
+	inTurn := c.SelectValidator(1)
+	outOfTurn := stakerA
+	if inTurn == stakerA {
+		outOfTurn = stakerB
+	}
+
+	tests := []struct {
+		name       string
+		validator  common.Address
+		timestamp  uint64
+		difficulty uint64
+		want       error
+	}{
+		{"in turn", inTurn, 0, DiffInTurn, nil},
+		{"in turn, claims less", inTurn, 0, DiffNoTurn, ErrInvalidDifficulty},
+		{"out of turn", outOfTurn, c.turnTimeout(), DiffNoTurn, nil},
+		{"out of turn, claims more", outOfTurn, c.turnTimeout(), DiffInTurn, ErrInvalidDifficulty},
+		{"out of turn, early", outOfTurn, 0, DiffNoTurn, ErrOutOfTurn},
+	}
+
+	for _, tt := range tests {
+		header := block.NewBlock(block.Header{
+			Height:     1,
+			Prev:       genesis.Hash(),
+			Timestamp:  genesis.Timestamp() + tt.timestamp,
+			Difficulty: tt.difficulty,
+			Validator:  tt.validator,
+			Data:       []byte{},
+		}, nil)
+
+		if err := c.VerifyProposer(genesis, header); err != tt.want {
+			t.Errorf("%s: VerifyProposer() error = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,280 @@
+package pos
+
+import (
+	"bytes"
+	"math/big"
+	"sort"
+
+	"github.com/polarysfoundation/polarys-chain/modules/common"
+	"github.com/polarysfoundation/polarys-chain/modules/core/block"
+	"github.com/polarysfoundation/polarys-chain/modules/core/consensus"
+	"github.com/polarysfoundation/polarys-chain/modules/core/transaction"
+	"github.com/polarysfoundation/polarys-chain/modules/crypto"
+)
+
+// UnstakeData marks a transaction to consensus.SystemAddress that withdraws
+// the whole stake of its sender. Any other transaction to the system
+// address stakes its value.
+var UnstakeData = []byte("unstake")
+
+// Snapshot is the validator set of an epoch. It is derived from the one of
+// the previous epoch and the staking transactions included in it, so it
+// only depends on the chain up to the checkpoint, the last block of the
+// previous epoch.
+type Snapshot struct {
+	Epoch       uint64
+	Checkpoint  common.Hash
+	Stakers     map[common.Address]*consensus.Validator
+	Withdrawals map[common.Address]*big.Int // Refunded by the first block of the epoch
+	Slashed     map[common.Address]*big.Int // Burned by the first block of the epoch
+	Jailed      map[common.Address]struct{} // Caught double signing, never validate again
+
+	minStake  *big.Int
+	bootstrap []common.Address
+}
+
+// epochBlock keeps what a snapshot needs from a block of the epoch.
+type epochBlock struct {
+	validator common.Address
+	txs       []transaction.Transaction
+	offenders []common.Address
+}
+
+func newEpochBlock(blk *block.Block) epochBlock {
+	eb := epochBlock{validator: blk.Validator()}
+	for _, tx := range blk.Transactions() {
+		if tx.To() == consensus.SystemAddress {
+			eb.txs = append(eb.txs, tx)
+		}
+	}
+
+	for _, evidence := range blk.Evidence() {
+		eb.offenders = append(eb.offenders, evidence.Offender())
+	}
+
+	return eb
+}
+
+func newSnapshot(minStake uint64, bootstrap []common.Address) *Snapshot {
+	return &Snapshot{
+		Stakers:     make(map[common.Address]*consensus.Validator),
+		Withdrawals: make(map[common.Address]*big.Int),
+		Slashed:     make(map[common.Address]*big.Int),
+		Jailed:      make(map[common.Address]struct{}),
+		minStake:    new(big.Int).SetUint64(max(minStake, 1)),
+		bootstrap:   bootstrap,
+	}
+}
+
+// apply returns the snapshot of the next epoch, the blocks being the ones
+// of the current epoch in chain order.
+func (s *Snapshot) apply(blocks []epochBlock, checkpoint common.Hash) (*Snapshot, error) {
+	next := newSnapshot(s.minStake.Uint64(), s.bootstrap)
+	next.Epoch = s.Epoch + 1
+	next.Checkpoint = checkpoint
+
+	for address, staker := range s.Stakers {
+		next.Stakers[address] = copyValidator(staker)
+	}
+
+	for address := range s.Jailed {
+		next.Jailed[address] = struct{}{}
+	}
+
+	for _, blk := range blocks {
+		if staker, ok := next.Stakers[blk.validator]; ok {
+			staker.TotalReward.Add(staker.TotalReward, BlockReward)
+			staker.LastEpoch = s.Epoch
+		}
+
+		for _, tx := range blk.txs {
+			staker, ok := next.Stakers[tx.From()]
+			if !ok {
+				staker = newValidator(tx.From())
+				next.Stakers[tx.From()] = staker
+			}
+
+			if tx.Value() != nil {
+				staker.Staked.Add(staker.Staked, tx.Value())
+			}
+
+			if !staker.Staked.IsUint64() {
+				return nil, ErrStakeOverflow
+			}
+
+			if bytes.Equal(tx.Data(), UnstakeData) {
+				withdrawal, ok := next.Withdrawals[tx.From()]
+				if !ok {
+					withdrawal = new(big.Int)
+					next.Withdrawals[tx.From()] = withdrawal
+				}
+
+				withdrawal.Add(withdrawal, staker.Staked)
+				staker.Staked = new(big.Int)
+			}
+		}
+
+		// The stake of an offender is burned, the part being withdrawn
+		// included.
+		for _, offender := range blk.offenders {
+			slashed, ok := next.Slashed[offender]
+			if !ok {
+				slashed = new(big.Int)
+				next.Slashed[offender] = slashed
+			}
+
+			if staker, ok := next.Stakers[offender]; ok {
+				slashed.Add(slashed, staker.Staked)
+				staker.Staked = new(big.Int)
+			}
+
+			if withdrawal, ok := next.Withdrawals[offender]; ok {
+				slashed.Add(slashed, withdrawal)
+				delete(next.Withdrawals, offender)
+			}
+
+			next.Jailed[offender] = struct{}{}
+		}
+	}
+
+	for address, staker := range next.Stakers {
+		if staker.Staked.Sign() == 0 {
+			delete(next.Stakers, address)
+			continue
+		}
+
+		staker.Power = new(big.Int).Div(staker.Staked, next.minStake)
+		staker.NextReward = new(big.Int).Set(BlockReward)
+		staker.CurrentEpoch = next.Epoch
+	}
+
+	return next, nil
+}
+
+// Validators returns the validators of the epoch sorted by address. The
+// bootstrap validators take part, with one unit of power each, as long as
+// nobody staked enough.
+func (s *Snapshot) Validators() []*consensus.Validator {
+	validators := make([]*consensus.Validator, 0, len(s.Stakers))
+	for _, staker := range s.Stakers {
+		if _, jailed := s.Jailed[staker.Address]; jailed {
+			continue
+		}
+
+		if staker.Power != nil && staker.Power.Sign() > 0 {
+			validators = append(validators, copyValidator(staker))
+		}
+	}
+
+	if len(validators) == 0 {
+		for _, address := range s.bootstrap {
+			if _, jailed := s.Jailed[address]; jailed {
+				continue
+			}
+
+			validator := newValidator(address)
+			validator.Power = big.NewInt(1)
+			validator.NextReward = new(big.Int).Set(BlockReward)
+			validator.CurrentEpoch = s.Epoch
+			validators = append(validators, validator)
+		}
+	}
+
+	sort.Slice(validators, func(i, j int) bool {
+		return bytes.Compare(validators[i].Address.Bytes(), validators[j].Address.Bytes()) < 0
+	})
+
+	return validators
+}
+
+// jail returns the snapshot with offenders left out of the validators. It
+// shares the stakers with s.
+func (s *Snapshot) jail(offenders map[common.Address]struct{}) *Snapshot {
+	if len(offenders) == 0 {
+		return s
+	}
+
+	cpy := *s
+	cpy.Jailed = make(map[common.Address]struct{}, len(s.Jailed)+len(offenders))
+	for address := range s.Jailed {
+		cpy.Jailed[address] = struct{}{}
+	}
+
+	for address := range offenders {
+		cpy.Jailed[address] = struct{}{}
+	}
+
+	return &cpy
+}
+
+// order returns the validators in the order they may propose at height.
+// Each position is drawn among the remaining validators with a chance
+// proportional to their power, from a seed every node knows.
+func (s *Snapshot) order(height uint64) []common.Address {
+	validators := s.Validators()
+	order := make([]common.Address, 0, len(validators))
+
+	total := new(big.Int)
+	for _, validator := range validators {
+		total.Add(total, validator.Power)
+	}
+
+	for len(validators) > 0 {
+		seed := make([]byte, 0, common.HashLen+16)
+		seed = append(seed, s.Checkpoint.Bytes()...)
+		seed = append(seed, common.Uint64ToBytes(height)...)
+		seed = append(seed, common.Uint64ToBytes(uint64(len(order)))...)
+
+		draw := new(big.Int).SetBytes(crypto.Pm256(seed))
+		draw.Mod(draw, total)
+
+		index := len(validators) - 1
+		for i, validator := range validators {
+			if draw.Cmp(validator.Power) < 0 {
+				index = i
+				break
+			}
+			draw.Sub(draw, validator.Power)
+		}
+
+		order = append(order, validators[index].Address)
+		total.Sub(total, validators[index].Power)
+		validators = append(validators[:index], validators[index+1:]...)
+	}
+
+	return order
+}
+
+// turn returns how many turns validator is away from proposing at height,
+// 0 for the in-turn validator.
+func (s *Snapshot) turn(validator common.Address, height uint64) (uint64, bool) {
+	for i, address := range s.order(height) {
+		if address == validator {
+			return uint64(i), true
+		}
+	}
+
+	return 0, false
+}
+
+func newValidator(address common.Address) *consensus.Validator {
+	return &consensus.Validator{
+		Address:     address,
+		TotalReward: new(big.Int),
+		Staked:      new(big.Int),
+		Power:       new(big.Int),
+		NextReward:  new(big.Int),
+	}
+}
+
+func copyValidator(v *consensus.Validator) *consensus.Validator {
+	return &consensus.Validator{
+		Address:      v.Address,
+		TotalReward:  new(big.Int).Set(v.TotalReward),
+		Staked:       new(big.Int).Set(v.Staked),
+		Power:        new(big.Int).Set(v.Power),
+		NextReward:   new(big.Int).Set(v.NextReward),
+		CurrentEpoch: v.CurrentEpoch,
+		LastEpoch:    v.LastEpoch,
+	}
+}
@@ -21,4 +21,8 @@ var (
 	ErrInvalidValidatorCount = errors.New("invalid validator count")
 	ErrInvalidProtocolHash   = errors.New("invalid protocol hash")
 	ErrInvalidChainID        = errors.New("invalid chain ID")
+	ErrInvalidTxRoot         = errors.New("invalid transactions root")
+	ErrSealAborted           = errors.New("seal aborted")
+	ErrOutOfTurn             = errors.New("validator proposed before its turn")
+	ErrSignerMismatch        = errors.New("signer is not the block validator")
 )
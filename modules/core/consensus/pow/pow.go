@@ -6,6 +6,7 @@ import (
 	"math/big"
 
 	"slices"
+	"time"
 
 	"github.com/polarysfoundation/polarys-chain/modules/common"
 	"github.com/polarysfoundation/polarys-chain/modules/core/block"
@@ -25,6 +26,12 @@ var (
 	BlockReward = big.NewInt(10000000000000000)
 )
 
+const allowedFutureBlockTime = 15 * time.Second
+
+// Consensus is a proof-of-work engine taking turns over a fixed list of
+// validators. The list never changes, so double signing cannot be punished:
+// the engine is not a consensus.Jailer and the chain rejects evidence under
+// it.
 type Consensus struct {
 	epoch            uint64
 	difficulty       uint64
@@ -88,34 +95,6 @@ func (c *Consensus) ValidatorProof() ([]byte, error) {
 	return validatorProof, nil
 }
 
-func (c *Consensus) SealBlock(block *block.Block) (*block.Block, error) {
-	if block == nil {
-		return nil, ErrNilBlock
-	}
-
-	consensusProof, err := c.ConsensusProof(block.Height())
-	if err != nil {
-		return nil, err
-	}
-
-	validatorProof, err := c.ValidatorProof()
-	if err != nil {
-		return nil, err
-	}
-
-	h1 := crypto.Pm256(consensusProof)
-	h2 := crypto.Pm256(validatorProof)
-
-	buff := make([]byte, 64)
-	copy(buff[:32], h1)
-	copy(buff[32:], h2)
-
-	sealHash := crypto.Pm256(buff)
-	block.Seal(common.BytesToHash(sealHash))
-
-	return block, nil
-}
-
 func (c *Consensus) VerifyBlock(chain consensus.Chain, block *block.Block) (bool, error) {
 	if block == nil {
 		return false, ErrNilBlock
@@ -134,6 +113,14 @@ func (c *Consensus) VerifyBlock(chain consensus.Chain, block *block.Block) (bool
 		return false, ErrInvalidBlockHash
 	}
 
+	if !block.VerifyTxRoot() {
+		return false, ErrInvalidTxRoot
+	}
+
+	if err := consensus.VerifyGas(prevBlock, block); err != nil {
+		return false, err
+	}
+
 	if ok, err := c.verifyConsensusProof(block, prevBlock); err != nil || !ok {
 		return false, err
 	}
@@ -142,10 +129,18 @@ func (c *Consensus) VerifyBlock(chain consensus.Chain, block *block.Block) (bool
 		return false, ErrInvalidValidatorProof
 	}
 
+	if err := c.VerifySignature(block); err != nil {
+		return false, err
+	}
+
 	if !c.ValidatorExists(block.Validator()) {
 		return false, ErrInvalidValidator
 	}
 
+	if err := c.VerifyProposer(prevBlock, block); err != nil {
+		return false, err
+	}
+
 	latestBlock, err := chain.GetLatestBlock()
 	if err != nil {
 		return false, err
@@ -164,13 +159,83 @@ func (c *Consensus) VerifyBlock(chain consensus.Chain, block *block.Block) (bool
 		return false, ErrDuplicatedBlock
 	}
 
-	if !block.SealHash().IsValid() {
-		return false, ErrInvalidSealHash
+	if err := c.VerifySeal(block); err != nil {
+		return false, err
 	}
 
 	return true, nil
 }
 
+// VerifyHeader checks a header against its parent only, so it can be used
+// on headers that are not connected to the local chain yet.
+func (c *Consensus) VerifyHeader(parent *block.Block, header *block.Block) error {
+	if parent == nil || header == nil {
+		return ErrNilBlock
+	}
+
+	if header.Height() != parent.Height()+1 {
+		return ErrInvalidBlockHeight
+	}
+
+	if header.Prev() != parent.Hash() {
+		return ErrInvalidBlockHash
+	}
+
+	hash := header.Hash()
+	if header.CalcHash() != hash {
+		return ErrInvalidBlockHash
+	}
+
+	if header.Timestamp() < parent.Timestamp() {
+		return ErrInvalidBlockTimestamp
+	}
+
+	if header.Timestamp() > uint64(time.Now().Add(allowedFutureBlockTime).Unix()) {
+		return ErrInvalidBlockTimestamp
+	}
+
+	if header.TotalDifficulty() != parent.TotalDifficulty()+header.Difficulty() {
+		return ErrInvalidDifficulty
+	}
+
+	if err := consensus.VerifyGas(parent, header); err != nil {
+		return err
+	}
+
+	if err := c.VerifySignature(header); err != nil {
+		return err
+	}
+
+	if err := c.VerifyProposer(parent, header); err != nil {
+		return err
+	}
+
+	if ok, err := c.DifficultyValidator(header, parent); err != nil || !ok {
+		return ErrInvalidDifficulty
+	}
+
+	return c.VerifySeal(header)
+}
+
+// VerifySignature checks that header was signed, for this chain, by the
+// key of its validator.
+func (c *Consensus) VerifySignature(header *block.Block) error {
+	if header == nil {
+		return ErrNilBlock
+	}
+
+	signer, err := header.Signer(c.chainID)
+	if err != nil {
+		return err
+	}
+
+	if signer != header.Validator() {
+		return ErrSignerMismatch
+	}
+
+	return nil
+}
+
 func (c *Consensus) VerifyChain(chain consensus.Chain) (bool, error) {
 	latestBlock, err := chain.GetLatestBlock()
 	if err != nil {
@@ -290,6 +355,28 @@ func (c *Consensus) ValidatorExists(address common.Address) bool {
 	return slices.Contains(c.validators, address)
 }
 
+// Validators returns the configured validators, each with a power of one.
+func (c *Consensus) Validators(blk *block.Block) ([]*consensus.Validator, error) {
+	validators := make([]*consensus.Validator, 0, len(c.validators))
+	for _, address := range c.validators {
+		validators = append(validators, &consensus.Validator{
+			Address:     address,
+			TotalReward: big.NewInt(0),
+			Staked:      big.NewInt(0),
+			Power:       big.NewInt(1),
+			NextReward:  new(big.Int).Set(BlockReward),
+		})
+	}
+
+	return validators, nil
+}
+
+// BlockReward returns the amount minted for the validator of the block at
+// the given height.
+func (c *Consensus) BlockReward(height uint64) *big.Int {
+	return new(big.Int).Set(BlockReward)
+}
+
 func (c *Consensus) AdjustDifficulty(block *block.Block, prevBlock *block.Block) uint64 {
 	// Siempre recalculamos en cada bloque, para que builder y validator
 	// hablen el mismo idioma:
@@ -327,15 +414,58 @@ func (c *Consensus) DifficultyValidator(block *block.Block, prevBlock *block.Blo
 	return blockDifficulty >= minDiff && blockDifficulty <= maxDiff, nil
 }
 
-func (c *Consensus) SelectValidator() common.Address {
-	if len(c.validators) == 0 {
-		return common.Address{}
+// Authorize sets the validator this node proposes blocks as.
+func (c *Consensus) Authorize(validator common.Address) {
+	c.currentValidator = validator
+}
+
+// SelectValidator returns the in-turn validator at height.
+func (c *Consensus) SelectValidator(height uint64) common.Address {
+	validator, _ := consensus.Proposer(c.validators, height, c.epoch)
+	c.latestValidator = validator
+	return validator
+}
+
+// ProposalTime returns the earliest timestamp at which validator may
+// propose on top of parent. The in-turn validator may propose right away,
+// the others wait one turn timeout for every turn they are away, so a
+// missing proposer only slows the chain down.
+func (c *Consensus) ProposalTime(parent *block.Block, validator common.Address) (uint64, error) {
+	if parent == nil {
+		return 0, ErrNilPreviousBlock
+	}
+
+	turn, ok := consensus.Turn(c.validators, validator, parent.Height()+1, c.epoch)
+	if !ok {
+		return 0, ErrInvalidValidator
+	}
+
+	return parent.Timestamp() + turn*c.turnTimeout(), nil
+}
+
+// VerifyProposer checks that the validator of header was allowed to
+// propose on top of parent at the header timestamp.
+func (c *Consensus) VerifyProposer(parent *block.Block, header *block.Block) error {
+	if header == nil {
+		return ErrNilBlock
+	}
+
+	earliest, err := c.ProposalTime(parent, header.Validator())
+	if err != nil {
+		return err
+	}
+
+	if header.Timestamp() < earliest {
+		return ErrOutOfTurn
 	}
 
-	nextVal := c.validators[0]
-	c.currentValidator = nextVal
-	c.latestValidator = nextVal
-	return nextVal
+	return nil
+}
+
+// turnTimeout is the time, in seconds, a validator waits for each
+// validator in turn before it.
+func (c *Consensus) turnTimeout() uint64 {
+	return max(c.delay, 1)
 }
 
 func (c *Consensus) calcDifficulty(block *block.Block, prevBlock *block.Block) uint64 {
@@ -0,0 +1,127 @@
+package pow
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"math/big"
+	"runtime"
+	"sync"
+
+	"github.com/polarysfoundation/polarys-chain/modules/common"
+	"github.com/polarysfoundation/polarys-chain/modules/core/block"
+)
+
+// sealCheckInterval is the number of nonces tried between two checks of
+// the cancellation of a seal.
+const sealCheckInterval = 1024
+
+var maxTarget = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 256), big.NewInt(1))
+
+// Target returns the highest block hash that satisfies difficulty. Every
+// hash has a 1/difficulty chance to be at or below it.
+func Target(difficulty uint64) (*big.Int, error) {
+	if difficulty == 0 {
+		return nil, ErrInvalidDifficulty
+	}
+
+	return new(big.Int).Div(maxTarget, new(big.Int).SetUint64(difficulty)), nil
+}
+
+func meetsTarget(hash common.Hash, target *big.Int) bool {
+	return new(big.Int).SetBytes(hash.Bytes()).Cmp(target) <= 0
+}
+
+// SealBlock searches the header nonce until the block hash is at or below
+// the target of the block difficulty. The search runs on every CPU and
+// stops with ErrSealAborted when ctx is cancelled, for example because a
+// competing block at the same height was imported. The signature is not
+// covered by the hash, so the block can be signed once sealed.
+func (c *Consensus) SealBlock(ctx context.Context, blk *block.Block) (*block.Block, error) {
+	if blk == nil {
+		return nil, ErrNilBlock
+	}
+
+	target, err := Target(blk.Difficulty())
+	if err != nil {
+		return nil, err
+	}
+
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return nil, err
+	}
+	seed := binary.LittleEndian.Uint64(b[:])
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	threads := runtime.NumCPU()
+	found := make(chan block.Header, threads)
+
+	var wg sync.WaitGroup
+	for i := 0; i < threads; i++ {
+		wg.Add(1)
+		go func(nonce uint64) {
+			defer wg.Done()
+
+			// Each thread tries the nonces congruent to its own offset.
+			header := blk.Header()
+			for attempts := 0; ; attempts++ {
+				if attempts%sealCheckInterval == 0 && ctx.Err() != nil {
+					return
+				}
+
+				header.Nonce = nonce
+				if meetsTarget(header.Hash(), target) {
+					found <- header
+					return
+				}
+
+				nonce += uint64(threads)
+			}
+		}(seed + uint64(i))
+	}
+
+	var sealed *block.Block
+	select {
+	case <-ctx.Done():
+	case header := <-found:
+		sealed = block.FromHeader(header, blk.Transactions())
+		sealed.SetEvidence(blk.Evidence())
+		sealed.Seal(sealed.Hash())
+	}
+
+	cancel()
+	wg.Wait()
+
+	if sealed == nil {
+		return nil, ErrSealAborted
+	}
+
+	return sealed, nil
+}
+
+// VerifySeal checks that the block hash is at or below the target of the
+// block difficulty.
+func (c *Consensus) VerifySeal(blk *block.Block) error {
+	if blk == nil {
+		return ErrNilBlock
+	}
+
+	target, err := Target(blk.Difficulty())
+	if err != nil {
+		return err
+	}
+
+	hash := blk.Hash()
+	if blk.CalcHash() != hash {
+		return ErrInvalidBlockHash
+	}
+
+	if !meetsTarget(hash, target) {
+		return ErrInvalidSealHash
+	}
+
+	return nil
+}
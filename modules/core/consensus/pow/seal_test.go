@@ -0,0 +1,74 @@
+package pow
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/polarysfoundation/polarys-chain/modules/common"
+	"github.com/polarysfoundation/polarys-chain/modules/core/block"
+)
+
+func newSealTestBlock(difficulty uint64) *block.Block {
+	return block.NewBlock(block.Header{
+		Height:     2,
+		Prev:       common.BytesToHash([]byte("parent")),
+		Timestamp:  uint64(time.Now().Unix()),
+		Difficulty: difficulty,
+		Data:       []byte{},
+	}, nil)
+}
+
+func TestSealBlock(t *testing.T) {
+	c := InitConsensus(1, 1000, 1, 0, nil)
+
+	sealed, err := c.SealBlock(context.Background(), newSealTestBlock(1000))
+	if err != nil {
+		t.Fatalf("SealBlock() error = %v", err)
+	}
+
+	if err := c.VerifySeal(sealed); err != nil {
+		t.Fatalf("VerifySeal() error = %v", err)
+	}
+
+	if sealed.SealHash() != sealed.Hash() {
+		t.Errorf("SealHash() = %v, want block hash %v", sealed.SealHash(), sealed.Hash())
+	}
+
+	// Look for a nonce whose hash misses the target.
+	header := sealed.Header()
+	for {
+		header.Nonce++
+		blk := block.FromHeader(header, nil)
+		if err := c.VerifySeal(blk); err != nil {
+			if !errors.Is(err, ErrInvalidSealHash) {
+				t.Fatalf("VerifySeal() error = %v, want %v", err, ErrInvalidSealHash)
+			}
+			break
+		}
+	}
+}
+
+func TestSealBlock_Aborted(t *testing.T) {
+	c := InitConsensus(1, MaxDifficulty, 1, 0, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	if _, err := c.SealBlock(ctx, newSealTestBlock(MaxDifficulty)); !errors.Is(err, ErrSealAborted) {
+		t.Fatalf("SealBlock() error = %v, want %v", err, ErrSealAborted)
+	}
+}
+
+func TestTarget(t *testing.T) {
+	if _, err := Target(0); !errors.Is(err, ErrInvalidDifficulty) {
+		t.Errorf("Target(0) error = %v, want %v", err, ErrInvalidDifficulty)
+	}
+
+	easy, _ := Target(1)
+	hard, _ := Target(2)
+	if easy.Cmp(hard) <= 0 {
+		t.Errorf("Target(1) = %v is not above Target(2) = %v", easy, hard)
+	}
+}
@@ -0,0 +1,63 @@
+package consensus
+
+import (
+	"bytes"
+	"slices"
+	"sort"
+
+	"github.com/polarysfoundation/polarys-chain/modules/common"
+	"github.com/polarysfoundation/polarys-chain/modules/crypto"
+)
+
+// Validators take turns to propose, height by height, in an order that is
+// reshuffled at every epoch. The order only depends on the validator set
+// and the epoch, so every node agrees on who is in turn.
+
+// epochOrder returns the validators sorted by the hash of the epoch and
+// their address.
+func epochOrder(validators []common.Address, epoch uint64) []common.Address {
+	keys := make(map[common.Address][]byte, len(validators))
+	for _, validator := range validators {
+		keys[validator] = crypto.Pm256(append(common.Uint64ToBytes(epoch), validator.Bytes()...))
+	}
+
+	order := slices.Clone(validators)
+	sort.SliceStable(order, func(i, j int) bool {
+		return bytes.Compare(keys[order[i]], keys[order[j]]) < 0
+	})
+
+	return order
+}
+
+func epochOf(height, epochLength uint64) uint64 {
+	if epochLength == 0 {
+		return 0
+	}
+
+	return height / epochLength
+}
+
+// Proposer returns the in-turn validator at height.
+func Proposer(validators []common.Address, height, epochLength uint64) (common.Address, bool) {
+	if len(validators) == 0 {
+		return common.Address{}, false
+	}
+
+	order := epochOrder(validators, epochOf(height, epochLength))
+	return order[height%uint64(len(order))], true
+}
+
+// Turn returns how many turns validator is away from proposing at height,
+// 0 for the in-turn validator. It reports false when validator is not part
+// of the set.
+func Turn(validators []common.Address, validator common.Address, height, epochLength uint64) (uint64, bool) {
+	order := epochOrder(validators, epochOf(height, epochLength))
+
+	index := slices.Index(order, validator)
+	if index < 0 {
+		return 0, false
+	}
+
+	size := uint64(len(order))
+	return (uint64(index) + size - height%size) % size, true
+}
@@ -0,0 +1,55 @@
+package consensus
+
+import (
+	"testing"
+
+	"github.com/polarysfoundation/polarys-chain/modules/common"
+)
+
+func TestSchedule(t *testing.T) {
+	validators := []common.Address{
+		common.BytesToAddress([]byte("validator_aaaaa")),
+		common.BytesToAddress([]byte("validator_bbbbb")),
+		common.BytesToAddress([]byte("validator_ccccc")),
+	}
+	const epochLength = 30
+
+	for epoch := uint64(0); epoch < 3; epoch++ {
+		proposed := make(map[common.Address]int)
+
+		for height := epoch * epochLength; height < (epoch+1)*epochLength; height++ {
+			proposer, ok := Proposer(validators, height, epochLength)
+			if !ok {
+				t.Fatalf("Proposer(%d) found no validator", height)
+			}
+			proposed[proposer]++
+
+			seen := make(map[uint64]bool)
+			for _, validator := range validators {
+				turn, ok := Turn(validators, validator, height, epochLength)
+				if !ok {
+					t.Fatalf("Turn(%v, %d) reports an unknown validator", validator, height)
+				}
+
+				if (turn == 0) != (validator == proposer) {
+					t.Errorf("Turn(%v, %d) = %d, proposer is %v", validator, height, turn, proposer)
+				}
+
+				if seen[turn] {
+					t.Errorf("two validators share turn %d at height %d", turn, height)
+				}
+				seen[turn] = true
+			}
+		}
+
+		for _, validator := range validators {
+			if proposed[validator] != epochLength/len(validators) {
+				t.Errorf("epoch %d: %v in turn %d times, want %d", epoch, validator, proposed[validator], epochLength/len(validators))
+			}
+		}
+	}
+
+	if _, ok := Turn(validators, common.BytesToAddress([]byte("validator_zzzzz")), 1, epochLength); ok {
+		t.Errorf("Turn() accepted a validator outside the set")
+	}
+}
@@ -7,4 +7,38 @@ var (
 	ErrBlockNotFound       = errors.New("block not found")
 	ErrBlockExists         = errors.New("block already exists")
 	ErrBlockHeight         = errors.New("invalid block height")
+
+	ErrNilTransaction       = errors.New("transaction is nil")
+	ErrOversizedTransaction = errors.New("transaction exceeds max size")
+
+	ErrInvalidTransactionSignature = errors.New("invalid transaction signature")
+	ErrInvalidTransactionValue     = errors.New("invalid transaction value")
+	ErrInvalidNonce                = errors.New("invalid transaction nonce")
+	ErrInvalidGas                  = errors.New("transaction gas does not match its size")
+	ErrInvalidGasUsed              = errors.New("gas used does not match block transactions")
+	ErrInvalidGasTip               = errors.New("gas tip does not match block transactions")
+	ErrInvalidBlockReward          = errors.New("invalid block reward")
+	ErrUnknownParent               = errors.New("unknown parent block")
+	ErrInvalidTxRoot               = errors.New("transactions root does not match block transactions")
+	ErrInvalidStateRoot            = errors.New("state root does not match resulting state")
+	ErrInvalidBlockHash            = errors.New("block hash does not match header")
+	ErrInvalidTotalDifficulty      = errors.New("invalid total difficulty")
+	ErrInvalidDifficulty           = errors.New("difficulty does not follow from the parent block")
+	ErrInvalidTimestamp            = errors.New("block timestamp not after its parent")
+	ErrFutureBlock                 = errors.New("block timestamp too far in the future")
+	ErrFinalizedReorg              = errors.New("reorg would revert a finalized block")
+	ErrInvalidEvidenceRoot         = errors.New("evidence root does not match block evidence")
+
+	ErrNilAttestation      = errors.New("attestation is nil")
+	ErrNotCheckpoint       = errors.New("attested block is not a checkpoint")
+	ErrUnknownCheckpoint   = errors.New("unknown checkpoint block")
+	ErrCheckpointFinalized = errors.New("checkpoint at or below the finalized height")
+	ErrNotValidator        = errors.New("attester is not a validator")
+	ErrAttestationExists   = errors.New("attestation already known")
+
+	ErrNilEvidence         = errors.New("evidence is nil")
+	ErrEvidenceUnsupported = errors.New("consensus engine does not punish double signing")
+	ErrEvidenceExists      = errors.New("evidence already known")
+	ErrEvidenceTooOld      = errors.New("evidence outside the inclusion window")
+	ErrTooMuchEvidence     = errors.New("block carries too much evidence")
 )
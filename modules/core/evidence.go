@@ -0,0 +1,186 @@
+package core
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/polarysfoundation/polarys-chain/modules/common"
+	"github.com/polarysfoundation/polarys-chain/modules/core/block"
+	"github.com/polarysfoundation/polarys-chain/modules/core/consensus"
+	"github.com/sirupsen/logrus"
+)
+
+// A validator that signs two blocks at the same height is caught by the
+// first node that sees both. The two signed headers are the evidence: it
+// is gossiped, included in a block, and the consensus engine punishes the
+// offender when the block is processed.
+const (
+	MaxEvidencePerBlock = 16
+	MaxEvidenceAge      = 256 // blocks after the offence the evidence may be included
+
+	signedHeadersKept = MaxEvidenceAge
+)
+
+// recordHeader remembers the header signed by the validator of blk and
+// turns a second, different header at the same height into evidence. The
+// block signature must have been checked. It must be called with the
+// chain lock held.
+func (bc *Blockchain) recordHeader(blk *block.Block) {
+	if !bc.jailsOffenders() {
+		return
+	}
+
+	headers, ok := bc.signedHeaders[blk.Height()]
+	if !ok {
+		headers = make(map[common.Address]block.Header)
+		bc.signedHeaders[blk.Height()] = headers
+	}
+
+	seen, ok := headers[blk.Validator()]
+	if !ok {
+		headers[blk.Validator()] = blk.Header()
+	} else if seen.Hash() != blk.Hash() {
+		evidence := block.NewEvidence(seen, blk.Header())
+		if err := bc.addEvidence(&evidence); err != nil {
+			bc.logs.WithError(err).Debug("Double signing evidence dropped")
+		}
+	}
+
+	for height := range bc.signedHeaders {
+		if height+signedHeadersKept < blk.Height() {
+			delete(bc.signedHeaders, height)
+		}
+	}
+}
+
+// AddEvidence verifies double signing evidence received from the network
+// and adds it to the pool of evidence to include.
+func (bc *Blockchain) AddEvidence(evidence *block.Evidence) error {
+	if evidence == nil {
+		return ErrNilEvidence
+	}
+
+	bc.lock.Lock()
+	defer bc.lock.Unlock()
+
+	return bc.addEvidence(evidence)
+}
+
+func (bc *Blockchain) addEvidence(evidence *block.Evidence) error {
+	if !bc.jailsOffenders() {
+		return ErrEvidenceUnsupported
+	}
+
+	hash := evidence.Hash()
+	if _, ok := bc.evidencePool[hash]; ok {
+		return ErrEvidenceExists
+	}
+
+	if _, ok := bc.includedEvidence[hash]; ok {
+		return ErrEvidenceExists
+	}
+
+	if evidence.Height()+MaxEvidenceAge < bc.latestBlock.Height() {
+		return ErrEvidenceTooOld
+	}
+
+	if err := evidence.Verify(bc.chainID); err != nil {
+		return err
+	}
+
+	bc.evidencePool[hash] = *evidence
+
+	bc.logs.WithFields(logrus.Fields{
+		"height":    evidence.Height(),
+		"validator": evidence.Offender().CXID(),
+		"first":     evidence.First.Hash().String(),
+		"second":    evidence.Second.Hash().String(),
+	}).Warn("Validator signed two blocks at the same height")
+
+	return nil
+}
+
+// PendingEvidence returns the evidence waiting to be included, oldest
+// offence first, at most MaxEvidencePerBlock of it.
+func (bc *Blockchain) PendingEvidence() []block.Evidence {
+	bc.lock.RLock()
+	defer bc.lock.RUnlock()
+
+	pending := make([]block.Evidence, 0, len(bc.evidencePool))
+	for _, evidence := range bc.evidencePool {
+		if evidence.Height()+MaxEvidenceAge > bc.latestBlock.Height() {
+			pending = append(pending, evidence)
+		}
+	}
+
+	sort.Slice(pending, func(i, j int) bool {
+		if pending[i].Height() != pending[j].Height() {
+			return pending[i].Height() < pending[j].Height()
+		}
+
+		hi, hj := pending[i].Hash(), pending[j].Hash()
+		return bytes.Compare(hi.Bytes(), hj.Bytes()) < 0
+	})
+
+	if len(pending) > MaxEvidencePerBlock {
+		pending = pending[:MaxEvidencePerBlock]
+	}
+
+	return pending
+}
+
+// verifyEvidence checks the evidence carried by blk. Evidence that was
+// already included elsewhere is accepted, punishing an offender twice has
+// no effect.
+func (bc *Blockchain) verifyEvidence(blk *block.Block) error {
+	evidence := blk.Evidence()
+	if len(evidence) > 0 && !bc.jailsOffenders() {
+		return ErrEvidenceUnsupported
+	}
+
+	if len(evidence) > MaxEvidencePerBlock {
+		return ErrTooMuchEvidence
+	}
+
+	seen := make(map[common.Hash]struct{}, len(evidence))
+	for i := range evidence {
+		if evidence[i].Height() >= blk.Height() || evidence[i].Height()+MaxEvidenceAge < blk.Height() {
+			return ErrEvidenceTooOld
+		}
+
+		hash := evidence[i].Hash()
+		if _, ok := seen[hash]; ok {
+			return ErrEvidenceExists
+		}
+		seen[hash] = struct{}{}
+
+		if err := evidence[i].Verify(bc.chainID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// markEvidence moves the evidence included by blk out of the pool. It must
+// be called with the chain lock held.
+func (bc *Blockchain) markEvidence(blk *block.Block) {
+	for _, evidence := range blk.Evidence() {
+		hash := evidence.Hash()
+		delete(bc.evidencePool, hash)
+		bc.includedEvidence[hash] = evidence.Height()
+	}
+
+	for hash, height := range bc.includedEvidence {
+		if height+MaxEvidenceAge < blk.Height() {
+			delete(bc.includedEvidence, hash)
+		}
+	}
+}
+
+// jailsOffenders reports whether the consensus engine punishes the
+// offenders of the evidence included in blocks.
+func (bc *Blockchain) jailsOffenders() bool {
+	_, ok := bc.consensus.(consensus.Jailer)
+	return ok
+}
@@ -0,0 +1,162 @@
+package core
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/polarysfoundation/polarys-chain/modules/common"
+	"github.com/polarysfoundation/polarys-chain/modules/core/block"
+	"github.com/polarysfoundation/polarys-chain/modules/core/consensus/pow"
+	"github.com/polarysfoundation/polarys-chain/modules/core/gaspool"
+	"github.com/polarysfoundation/polarys-chain/modules/core/state"
+	"github.com/polarysfoundation/polarys-chain/modules/params"
+)
+
+// jailingEngine is the test engine marked as punishing offenders, so the
+// chain collects and accepts evidence.
+type jailingEngine struct {
+	*pow.Consensus
+}
+
+func (jailingEngine) JailsOffenders() {}
+
+func TestBlockchain_DoubleSigning(t *testing.T) {
+	engine := jailingEngine{pow.InitConsensus(1, 1, 1, 0, []common.Address{validatorA, validatorB})}
+	bc, db := newTestBlockchainWithEngine(t, params.DefaultConfig, engine)
+
+	genesis, err := bc.GetLatestBlock()
+	if err != nil {
+		t.Fatalf("GetLatestBlock() error = %v", err)
+	}
+	genesisState := state.New(db, genesis)
+
+	a2, a2State := newTestBlock(t, bc, genesis, genesisState, validatorA, genesis.Timestamp()+2)
+	a2b, _ := newTestBlock(t, bc, genesis, genesisState, validatorA, genesis.Timestamp()+3)
+	a2c, _ := newTestBlock(t, bc, genesis, genesisState, validatorA, genesis.Timestamp()+4)
+
+	for _, blk := range []*block.Block{a2, a2b} {
+		if err := bc.AddRemoteBlock(blk); err != nil {
+			t.Fatalf("AddRemoteBlock() error = %v", err)
+		}
+	}
+
+	pending := bc.PendingEvidence()
+	if len(pending) != 1 || pending[0].Offender() != validatorA {
+		t.Fatalf("PendingEvidence() = %v, want one offence of validatorA", pending)
+	}
+
+	forged := block.NewEvidence(a2.Header(), signTestBlockAs(t, a2c, validatorB).Header())
+	tests := []struct {
+		name     string
+		evidence block.Evidence
+		want     error
+	}{
+		{"known", pending[0], ErrEvidenceExists},
+		{"same block", block.Evidence{First: a2.Header(), Second: a2.Header()}, block.ErrNoConflict},
+		{"forged", forged, block.ErrEvidenceSigner},
+	}
+
+	for _, tt := range tests {
+		if err := bc.AddEvidence(&tt.evidence); !errors.Is(err, tt.want) {
+			t.Errorf("%s: AddEvidence() error = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+
+	// The evidence leaves the pool once a block includes it.
+	header := block.Header{
+		Height:       a2.Height() + 1,
+		Prev:         a2.Hash(),
+		Timestamp:    a2.Timestamp() + 5,
+		Validator:    validatorB,
+		Data:         []byte{},
+		EvidenceRoot: block.CalcEvidenceRoot(pending),
+		BaseFee:      gaspool.CalcBaseFee(a2.GasTarget(), a2.GasUsed(), a2.BaseFee()),
+	}
+	setTestDifficulty(bc, &header, a2)
+
+	st, err := bc.processor.Process(block.NewBlock(header, nil), a2State)
+	if err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+
+	header.StateRoot, err = st.Root()
+	if err != nil {
+		t.Fatalf("Root() error = %v", err)
+	}
+
+	blk := block.NewBlock(header, nil)
+	blk = sealTestBlock(t, bc, blk)
+
+	stripped := signTestBlock(t, blk)
+	if err := bc.AddRemoteBlock(stripped); !errors.Is(err, ErrInvalidEvidenceRoot) {
+		t.Fatalf("AddRemoteBlock() without evidence error = %v, want %v", err, ErrInvalidEvidenceRoot)
+	}
+
+	blk.SetEvidence(pending)
+	if err := bc.AddRemoteBlock(signTestBlock(t, blk)); err != nil {
+		t.Fatalf("AddRemoteBlock() error = %v", err)
+	}
+
+	if pending := bc.PendingEvidence(); len(pending) != 0 {
+		t.Errorf("PendingEvidence() = %v after inclusion, want none", pending)
+	}
+}
+
+func TestBlockchain_EvidenceUnsupported(t *testing.T) {
+	bc, db := newTestBlockchain(t)
+
+	genesis, err := bc.GetLatestBlock()
+	if err != nil {
+		t.Fatalf("GetLatestBlock() error = %v", err)
+	}
+	genesisState := state.New(db, genesis)
+
+	a2, a2State := newTestBlock(t, bc, genesis, genesisState, validatorA, genesis.Timestamp()+2)
+	a2b, _ := newTestBlock(t, bc, genesis, genesisState, validatorA, genesis.Timestamp()+3)
+
+	for _, blk := range []*block.Block{a2, a2b} {
+		if err := bc.AddRemoteBlock(blk); err != nil {
+			t.Fatalf("AddRemoteBlock() error = %v", err)
+		}
+	}
+
+	// Proof of work keeps a fixed validator list, the offence goes
+	// unpunished and no evidence is collected.
+	if pending := bc.PendingEvidence(); len(pending) != 0 {
+		t.Fatalf("PendingEvidence() = %v, want none", pending)
+	}
+
+	evidence := block.NewEvidence(a2.Header(), a2b.Header())
+	if err := bc.AddEvidence(&evidence); !errors.Is(err, ErrEvidenceUnsupported) {
+		t.Errorf("AddEvidence() error = %v, want %v", err, ErrEvidenceUnsupported)
+	}
+
+	header := block.Header{
+		Height:       a2.Height() + 1,
+		Prev:         a2.Hash(),
+		Timestamp:    a2.Timestamp() + 5,
+		Validator:    validatorB,
+		Data:         []byte{},
+		EvidenceRoot: block.CalcEvidenceRoot([]block.Evidence{evidence}),
+		BaseFee:      gaspool.CalcBaseFee(a2.GasTarget(), a2.GasUsed(), a2.BaseFee()),
+	}
+	setTestDifficulty(bc, &header, a2)
+
+	st, err := bc.processor.Process(block.NewBlock(header, nil), a2State)
+	if err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+
+	header.StateRoot, err = st.Root()
+	if err != nil {
+		t.Fatalf("Root() error = %v", err)
+	}
+
+	blk := block.NewBlock(header, nil)
+	blk = sealTestBlock(t, bc, blk)
+	blk.SetEvidence([]block.Evidence{evidence})
+
+	if err := bc.AddRemoteBlock(signTestBlock(t, blk)); !errors.Is(err, ErrEvidenceUnsupported) {
+		t.Errorf("AddRemoteBlock() with evidence error = %v, want %v", err, ErrEvidenceUnsupported)
+	}
+}
@@ -0,0 +1,172 @@
+package core
+
+import (
+	"sort"
+
+	"github.com/polarysfoundation/polarys-chain/modules/common"
+	"github.com/polarysfoundation/polarys-chain/modules/core/block"
+	"github.com/polarysfoundation/polarys-chain/modules/core/gaspool"
+	"github.com/polarysfoundation/polarys-chain/modules/core/transaction"
+)
+
+const (
+	maxFeeHistory   = 1024 // blocks a fee history may cover
+	tipSampleBlocks = 20   // recent blocks SuggestGasTip looks at
+	tipPercentile   = 60   // percentile of the sampled tips SuggestGasTip returns
+)
+
+// EstimateGas returns the gas tx uses. It fails when tx does not fit in a
+// block.
+func (bc *Blockchain) EstimateGas(tx *transaction.Transaction) (uint64, error) {
+	estimated, err := tx.CalcGas(gaspool.GasLimit(bc.gasTarget))
+	if err != nil {
+		return 0, err
+	}
+
+	return estimated.Gas(), nil
+}
+
+// SuggestGasTip returns a tip per gas likely to get a transaction into one
+// of the next blocks: a percentile of the average tip per gas paid in the
+// recent blocks, and never less than the pool accepts.
+func (bc *Blockchain) SuggestGasTip() (uint64, error) {
+	bc.lock.RLock()
+	defer bc.lock.RUnlock()
+
+	return bc.suggestGasTip()
+}
+
+// SuggestGasPrice returns a gas price for a Legacy transaction: the base
+// fee of the next block plus the suggested tip.
+func (bc *Blockchain) SuggestGasPrice() (uint64, error) {
+	bc.lock.RLock()
+	defer bc.lock.RUnlock()
+
+	tip, err := bc.suggestGasTip()
+	if err != nil {
+		return 0, err
+	}
+
+	head := bc.latestBlock
+	return gaspool.CalcBaseFee(head.GasTarget(), head.GasUsed(), head.BaseFee()) + tip, nil
+}
+
+// suggestGasTip must be called with the chain lock held.
+func (bc *Blockchain) suggestGasTip() (uint64, error) {
+	floor := max(uint64(gaspool.MinTipPerGas), bc.txPool.Config().MinimalGasTip)
+
+	tips := make([]uint64, 0, tipSampleBlocks)
+	head := bc.latestBlock.Height()
+	for height := head; height > 0 && head-height < tipSampleBlocks; height-- {
+		blk, err := bc.db.GetBlockByHeight(height)
+		if err != nil {
+			return 0, err
+		}
+
+		if blk.GasUsed() > 0 {
+			tips = append(tips, blk.GasTip()/blk.GasUsed())
+		}
+	}
+
+	if len(tips) == 0 {
+		return floor, nil
+	}
+
+	sort.Slice(tips, func(i, j int) bool { return tips[i] < tips[j] })
+
+	return max(tips[(len(tips)-1)*tipPercentile/100], floor), nil
+}
+
+// FeeHistory returns the fees paid in the count canonical blocks ending at
+// newest. For every block it reports the effective tips paid at the given
+// percentiles, weighted by the gas of each transaction. The percentiles
+// must be ascending and between 0 and 100.
+func (bc *Blockchain) FeeHistory(count uint64, newest *block.Block, percentiles []float64) (*gaspool.FeeHistory, error) {
+	for i, p := range percentiles {
+		if p < 0 || p > 100 || (i > 0 && p < percentiles[i-1]) {
+			return nil, gaspool.ErrInvalidPercentile
+		}
+	}
+
+	count = min(count, maxFeeHistory, newest.Height()+1)
+
+	bc.lock.RLock()
+	defer bc.lock.RUnlock()
+
+	// The transactions are only loaded along with the canonical blocks.
+	blocks := make([]*block.Block, 0, count)
+	for height := newest.Height() + 1 - count; height <= newest.Height() && count > 0; height++ {
+		blk, err := getBlockByHashAndHeight(bc.db, common.Hash{}, height)
+		if err != nil {
+			return nil, err
+		}
+		blocks = append(blocks, blk)
+	}
+
+	history := &gaspool.FeeHistory{
+		BaseFees:     make([]uint64, 0, count+1),
+		GasUsedRatio: make([]float64, 0, count),
+	}
+
+	if count == 0 {
+		history.OldestBlock = newest.Height() + 1
+	} else {
+		history.OldestBlock = blocks[0].Height()
+	}
+
+	for _, blk := range blocks {
+		history.BaseFees = append(history.BaseFees, blk.BaseFee())
+
+		ratio := 0.0
+		if limit := gaspool.GasLimit(blk.GasTarget()); limit > 0 {
+			ratio = float64(blk.GasUsed()) / float64(limit)
+		}
+		history.GasUsedRatio = append(history.GasUsedRatio, ratio)
+
+		if len(percentiles) > 0 {
+			history.Rewards = append(history.Rewards, blockRewards(blk, percentiles))
+		}
+	}
+
+	history.BaseFees = append(history.BaseFees, gaspool.CalcBaseFee(newest.GasTarget(), newest.GasUsed(), newest.BaseFee()))
+
+	return history, nil
+}
+
+// blockRewards returns the effective tips paid in blk at percentiles of
+// its gas used.
+func blockRewards(blk *block.Block, percentiles []float64) []uint64 {
+	rewards := make([]uint64, len(percentiles))
+
+	txs := blk.Transactions()
+	if len(txs) == 0 {
+		return rewards
+	}
+
+	type paid struct {
+		gas uint64
+		tip uint64
+	}
+
+	sorted := make([]paid, 0, len(txs))
+	var total uint64
+	for i := range txs {
+		tip, _ := txs[i].EffectiveTip(blk.BaseFee())
+		sorted = append(sorted, paid{gas: txs[i].Gas(), tip: tip})
+		total += txs[i].Gas()
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].tip < sorted[j].tip })
+
+	var i int
+	cumulative := sorted[0].gas
+	for j, p := range percentiles {
+		threshold := uint64(float64(total) * p / 100)
+		for cumulative < threshold && i < len(sorted)-1 {
+			i++
+			cumulative += sorted[i].gas
+		}
+		rewards[j] = sorted[i].tip
+	}
+
+	return rewards
+}
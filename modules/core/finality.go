@@ -0,0 +1,222 @@
+package core
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/polarysfoundation/polarys-chain/modules/common"
+	"github.com/polarysfoundation/polarys-chain/modules/core/block"
+	"github.com/polarysfoundation/polarys-chain/modules/core/consensus"
+	"github.com/polarysfoundation/polarys-chain/modules/prydb"
+	"github.com/sirupsen/logrus"
+)
+
+// Every block whose height is a multiple of the epoch is a checkpoint.
+// Validators attest the checkpoints they see on the canonical chain, and a
+// checkpoint attested by 2/3 of the validator power is finalized: fork
+// choice never reverts it nor any block below it.
+
+// loadFinalized restores the latest finalized checkpoint from the
+// database.
+func (bc *Blockchain) loadFinalized() error {
+	record, err := bc.db.Finalized()
+	if errors.Is(err, prydb.ErrFinalityNotFound) {
+		bc.finalized = &prydb.FinalityRecord{}
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	bc.finalized = record
+	return nil
+}
+
+// FinalizedHeight returns the height of the latest finalized checkpoint,
+// 0 before the first one.
+func (bc *Blockchain) FinalizedHeight() uint64 {
+	bc.lock.RLock()
+	defer bc.lock.RUnlock()
+
+	return bc.finalized.Height
+}
+
+// FinalizedHash returns the hash of the latest finalized checkpoint.
+func (bc *Blockchain) FinalizedHash() common.Hash {
+	bc.lock.RLock()
+	defer bc.lock.RUnlock()
+
+	return bc.finalized.Hash
+}
+
+// IsCheckpoint reports whether the block at height is a checkpoint.
+func (bc *Blockchain) IsCheckpoint(height uint64) bool {
+	return height > 0 && bc.epoch > 0 && height%bc.epoch == 0
+}
+
+// AddAttestation verifies an attestation for a known checkpoint and
+// finalizes the checkpoint once it gathered 2/3 of the validator power.
+func (bc *Blockchain) AddAttestation(att *consensus.Attestation) error {
+	if att == nil {
+		return ErrNilAttestation
+	}
+
+	if !bc.IsCheckpoint(att.Height) {
+		return ErrNotCheckpoint
+	}
+
+	bc.lock.Lock()
+	defer bc.lock.Unlock()
+
+	if att.Height <= bc.finalized.Height {
+		return ErrCheckpointFinalized
+	}
+
+	checkpoint, err := bc.db.GetBlockByHash(att.Hash)
+	if err != nil {
+		return ErrUnknownCheckpoint
+	}
+
+	if checkpoint.Height() != att.Height {
+		return ErrNotCheckpoint
+	}
+
+	if votes, ok := bc.attestations[att.Hash]; ok {
+		if _, ok := votes[att.Validator]; ok {
+			return ErrAttestationExists
+		}
+	}
+
+	validators, err := bc.consensus.Validators(checkpoint)
+	if err != nil {
+		return err
+	}
+
+	if !hasValidator(validators, att.Validator) {
+		return ErrNotValidator
+	}
+
+	if err := att.Verify(bc.chainID); err != nil {
+		return err
+	}
+
+	votes, ok := bc.attestations[att.Hash]
+	if !ok {
+		votes = make(map[common.Address]*consensus.Attestation)
+		bc.attestations[att.Hash] = votes
+	}
+	votes[att.Validator] = att
+
+	bc.logs.WithFields(logrus.Fields{
+		"height":    att.Height,
+		"hash":      att.Hash.String(),
+		"validator": att.Validator.CXID(),
+	}).Debug("Attestation added")
+
+	return bc.tryFinalize(checkpoint, validators)
+}
+
+// Attestations returns the attestations of the checkpoints that are not
+// below the finalized one, so they can be gossiped.
+func (bc *Blockchain) Attestations() []consensus.Attestation {
+	bc.lock.RLock()
+	defer bc.lock.RUnlock()
+
+	atts := make([]consensus.Attestation, 0)
+	for _, votes := range bc.attestations {
+		for _, att := range votes {
+			atts = append(atts, *att)
+		}
+	}
+
+	return atts
+}
+
+// tryFinalize finalizes checkpoint if it is canonical and attested by 2/3
+// of the validator power. It must be called with the chain lock held.
+func (bc *Blockchain) tryFinalize(checkpoint *block.Block, validators []*consensus.Validator) error {
+	if checkpoint.Height() <= bc.finalized.Height || !bc.isCanonical(checkpoint) {
+		return nil
+	}
+
+	total := new(big.Int)
+	attested := new(big.Int)
+	votes := bc.attestations[checkpoint.Hash()]
+	for _, validator := range validators {
+		if validator.Power == nil {
+			continue
+		}
+
+		total.Add(total, validator.Power)
+		if _, ok := votes[validator.Address]; ok {
+			attested.Add(attested, validator.Power)
+		}
+	}
+
+	if total.Sign() == 0 {
+		return nil
+	}
+
+	// attested/total >= 2/3
+	if new(big.Int).Mul(attested, big.NewInt(3)).Cmp(new(big.Int).Mul(total, big.NewInt(2))) < 0 {
+		return nil
+	}
+
+	record := &prydb.FinalityRecord{Height: checkpoint.Height(), Hash: checkpoint.Hash()}
+	if err := bc.db.WriteFinalized(record); err != nil {
+		return err
+	}
+	bc.finalized = record
+
+	for hash, votes := range bc.attestations {
+		for _, att := range votes {
+			if att.Height < record.Height {
+				delete(bc.attestations, hash)
+			}
+			break
+		}
+	}
+
+	bc.logs.WithFields(logrus.Fields{
+		"height":   record.Height,
+		"hash":     record.Hash.String(),
+		"attested": attested.String(),
+		"power":    total.String(),
+	}).Info("Checkpoint finalized")
+
+	return nil
+}
+
+// finalizePending checks again the attested checkpoints, some of them may
+// have become canonical after a reorg. It must be called with the chain
+// lock held.
+func (bc *Blockchain) finalizePending() error {
+	for hash := range bc.attestations {
+		checkpoint, err := bc.db.GetBlockByHash(hash)
+		if err != nil {
+			continue
+		}
+
+		validators, err := bc.consensus.Validators(checkpoint)
+		if err != nil {
+			continue
+		}
+
+		if err := bc.tryFinalize(checkpoint, validators); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func hasValidator(validators []*consensus.Validator, address common.Address) bool {
+	for _, validator := range validators {
+		if validator.Address == address {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,100 @@
+package core
+
+import (
+	"errors"
+	"testing"
+
+	pec256 "github.com/polarysfoundation/pec-256"
+	"github.com/polarysfoundation/polarys-chain/modules/common"
+	"github.com/polarysfoundation/polarys-chain/modules/core/block"
+	"github.com/polarysfoundation/polarys-chain/modules/core/consensus"
+	"github.com/polarysfoundation/polarys-chain/modules/core/state"
+	"github.com/polarysfoundation/polarys-chain/modules/crypto"
+	"github.com/polarysfoundation/polarys-chain/modules/params"
+)
+
+func signTestAttestation(t *testing.T, checkpoint *block.Block, validator, signer common.Address) *consensus.Attestation {
+	t.Helper()
+
+	att := &consensus.Attestation{
+		Height:    checkpoint.Height(),
+		Hash:      checkpoint.Hash(),
+		Validator: validator,
+	}
+
+	key := testKeys[signer]
+	digest := common.BytesToHash(crypto.Pm256(att.SigningData(params.Polarys.ChainID)))
+	r, s, err := crypto.Sign(digest, pec256.BytesToPrivKey(key[0]))
+	if err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+
+	att.Signature = make([]byte, 64, consensus.AttestationSignatureLen)
+	r.FillBytes(att.Signature[:32])
+	s.FillBytes(att.Signature[32:])
+	att.Signature = append(att.Signature, key[1]...)
+
+	return att
+}
+
+func TestBlockchain_Finality(t *testing.T) {
+	bc, db := newTestBlockchain(t)
+	bc.epoch = 2
+
+	genesis, err := bc.GetLatestBlock()
+	if err != nil {
+		t.Fatalf("GetLatestBlock() error = %v", err)
+	}
+	genesisState := state.New(db, genesis)
+
+	a2, _ := newTestBlock(t, bc, genesis, genesisState, validatorA, genesis.Timestamp()+2)
+	b2, b2State := newTestBlock(t, bc, genesis, genesisState, validatorB, genesis.Timestamp()+3)
+	b3, _ := newTestBlock(t, bc, b2, b2State, validatorB, b2.Timestamp()+4)
+
+	if err := bc.AddRemoteBlock(a2); err != nil {
+		t.Fatalf("AddRemoteBlock(a2) error = %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		att       *consensus.Attestation
+		want      error
+		finalized uint64
+	}{
+		{"not a checkpoint", signTestAttestation(t, genesis, validatorA, validatorA), ErrNotCheckpoint, 0},
+		{"stranger", signTestAttestation(t, a2, validatorZ, validatorZ), ErrNotValidator, 0},
+		{"forged", signTestAttestation(t, a2, validatorA, validatorZ), consensus.ErrAttesterMismatch, 0},
+		{"first half", signTestAttestation(t, a2, validatorA, validatorA), nil, 0},
+		{"duplicate", signTestAttestation(t, a2, validatorA, validatorA), ErrAttestationExists, 0},
+		{"two thirds", signTestAttestation(t, a2, validatorB, validatorB), nil, a2.Height()},
+		{"finalized", signTestAttestation(t, a2, validatorB, validatorB), ErrCheckpointFinalized, a2.Height()},
+	}
+
+	for _, tt := range tests {
+		if err := bc.AddAttestation(tt.att); !errors.Is(err, tt.want) {
+			t.Fatalf("%s: AddAttestation() error = %v, want %v", tt.name, err, tt.want)
+		}
+
+		if got := bc.FinalizedHeight(); got != tt.finalized {
+			t.Fatalf("%s: FinalizedHeight() = %d, want %d", tt.name, got, tt.finalized)
+		}
+	}
+
+	record, err := db.Finalized()
+	if err != nil {
+		t.Fatalf("Finalized() error = %v", err)
+	}
+	if record.Hash != a2.Hash() {
+		t.Errorf("persisted checkpoint = %v, want %v", record.Hash, a2.Hash())
+	}
+
+	// A heavier branch forking below the finalized checkpoint is not
+	// adopted.
+	if err := bc.AddRemoteBlock(b2); err != nil {
+		t.Fatalf("AddRemoteBlock(b2) error = %v", err)
+	}
+	if err := bc.AddRemoteBlock(b3); !errors.Is(err, ErrFinalizedReorg) {
+		t.Fatalf("AddRemoteBlock(b3) error = %v, want %v", err, ErrFinalizedReorg)
+	}
+	assertHead(t, bc, a2)
+}
@@ -0,0 +1,271 @@
+package core
+
+import (
+	"time"
+
+	"github.com/polarysfoundation/polarys-chain/modules/common"
+	"github.com/polarysfoundation/polarys-chain/modules/core/block"
+	"github.com/polarysfoundation/polarys-chain/modules/core/consensus"
+	"github.com/polarysfoundation/polarys-chain/modules/core/transaction"
+	"github.com/sirupsen/logrus"
+)
+
+// allowedFutureBlockTime is how far ahead of the local clock a remote block
+// may be stamped. Without the bound a validator could stamp its block late
+// enough to skip the turn timeout of the validators before it.
+const allowedFutureBlockTime = 15 * time.Second
+
+// importBlock stores a block whose parent is known and applies the fork
+// choice rule: the branch with the highest total difficulty is canonical,
+// and on a tie the branch seen first is kept. It must be called with the
+// chain lock held.
+func (bc *Blockchain) importBlock(blk *block.Block) error {
+	if bc.hasBlock(blk.Hash()) {
+		return ErrBlockExists
+	}
+
+	parent, err := bc.db.GetBlockByHash(blk.Prev())
+	if err != nil {
+		return ErrUnknownParent
+	}
+
+	if err := validateBlock(parent, blk); err != nil {
+		return err
+	}
+
+	if blk.Timestamp() > uint64(time.Now().Add(allowedFutureBlockTime).Unix()) {
+		return ErrFutureBlock
+	}
+
+	// A remote block must be signed by the validator it names.
+	if err := bc.consensus.VerifySignature(blk); err != nil {
+		return err
+	}
+
+	// Once signed, a second block at the same height is an offence, even
+	// if the block turns out to be invalid.
+	bc.recordHeader(blk)
+
+	if err := bc.verifyEvidence(blk); err != nil {
+		return err
+	}
+
+	// Without the seal check total difficulty could be claimed for free.
+	if err := bc.consensus.VerifySeal(blk); err != nil {
+		return err
+	}
+
+	if err := bc.consensus.VerifyProposer(parent, blk); err != nil {
+		return err
+	}
+
+	// The seal is only checked against the difficulty the block claims.
+	if ok, err := bc.consensus.DifficultyValidator(blk, parent); err != nil || !ok {
+		return ErrInvalidDifficulty
+	}
+
+	head, err := bc.db.LatestBlock()
+	if err != nil {
+		return err
+	}
+
+	if blk.Prev() == head.Hash() {
+		return bc.insertBlock(blk)
+	}
+
+	if err := bc.db.WriteBlock(blk); err != nil {
+		return err
+	}
+
+	if blk.TotalDifficulty() <= head.TotalDifficulty() {
+		bc.logs.WithFields(logrus.Fields{
+			"height":           blk.Height(),
+			"hash":             blk.Hash().String(),
+			"total_difficulty": blk.TotalDifficulty(),
+		}).Info("Stored side-chain block")
+		return nil
+	}
+
+	return bc.reorg(head, blk)
+}
+
+// validateBlock checks the fields of a block that depend only on its parent.
+func validateBlock(parent, blk *block.Block) error {
+	hash := blk.Hash()
+	if blk.CalcHash() != hash {
+		return ErrInvalidBlockHash
+	}
+
+	if blk.Height() != parent.Height()+1 {
+		return ErrBlockHeight
+	}
+
+	if blk.Timestamp() <= parent.Timestamp() {
+		return ErrInvalidTimestamp
+	}
+
+	if blk.TotalDifficulty() != parent.TotalDifficulty()+blk.Difficulty() {
+		return ErrInvalidTotalDifficulty
+	}
+
+	if err := consensus.VerifyGas(parent, blk); err != nil {
+		return err
+	}
+
+	if !blk.VerifyTxRoot() {
+		return ErrInvalidTxRoot
+	}
+
+	if !blk.VerifyEvidenceRoot() {
+		return ErrInvalidEvidenceRoot
+	}
+
+	return nil
+}
+
+// reorg makes the branch ending at newHead canonical. The blocks above the
+// common ancestor are reverted, the new branch is applied on top of it, and
+// the transactions only present in the old branch go back to the pool. If a
+// block of the new branch fails to apply, the old branch is restored.
+func (bc *Blockchain) reorg(oldHead, newHead *block.Block) error {
+	newChain := []*block.Block{newHead}
+
+	ancestor, err := bc.db.GetBlockByHash(newHead.Prev())
+	if err != nil {
+		return ErrUnknownParent
+	}
+
+	for !bc.isCanonical(ancestor) {
+		newChain = append(newChain, ancestor)
+
+		ancestor, err = bc.db.GetBlockByHash(ancestor.Prev())
+		if err != nil {
+			return ErrUnknownParent
+		}
+	}
+
+	// Whatever its total difficulty, a branch that forks below the
+	// finalized checkpoint is never adopted.
+	if ancestor.Height() < bc.finalized.Height {
+		bc.logs.WithFields(logrus.Fields{
+			"ancestor":  ancestor.Height(),
+			"finalized": bc.finalized.Height,
+			"head":      newHead.Hash().String(),
+		}).Warn("Rejected reorg below the finalized checkpoint")
+		return ErrFinalizedReorg
+	}
+
+	oldChain := make([]*block.Block, 0, oldHead.Height()-ancestor.Height())
+	for height := ancestor.Height() + 1; height <= oldHead.Height(); height++ {
+		blk, err := getBlockByHashAndHeight(bc.db, common.Hash{}, height)
+		if err != nil {
+			return err
+		}
+
+		oldChain = append(oldChain, blk)
+	}
+
+	if err := bc.revertTo(ancestor, oldChain); err != nil {
+		return err
+	}
+
+	for i := len(newChain) - 1; i >= 0; i-- {
+		if err := bc.insertBlock(newChain[i]); err != nil {
+			bc.logs.WithFields(logrus.Fields{
+				"height": newChain[i].Height(),
+				"hash":   newChain[i].Hash().String(),
+			}).WithError(err).Error("Invalid block in new branch, restoring previous chain")
+
+			applied := make([]*block.Block, 0, len(newChain)-1-i)
+			for j := len(newChain) - 1; j > i; j-- {
+				applied = append(applied, newChain[j])
+			}
+
+			if err := bc.revertTo(ancestor, applied); err != nil {
+				return err
+			}
+
+			for _, blk := range oldChain {
+				if err := bc.insertBlock(blk); err != nil {
+					return err
+				}
+			}
+
+			return err
+		}
+	}
+
+	included := make(map[common.Hash]struct{})
+	for _, blk := range newChain {
+		for _, tx := range blk.Transactions() {
+			included[tx.Hash()] = struct{}{}
+		}
+	}
+
+	orphaned := make([]transaction.Transaction, 0)
+	for _, blk := range oldChain {
+		for _, tx := range blk.Transactions() {
+			if _, ok := included[tx.Hash()]; !ok {
+				orphaned = append(orphaned, tx)
+			}
+		}
+	}
+
+	reinjected := 0
+	for _, tx := range orphaned {
+		if err := bc.txPool.AddTransaction(tx); err == nil {
+			reinjected++
+		}
+	}
+
+	bc.logs.WithFields(logrus.Fields{
+		"ancestor":         ancestor.Height(),
+		"dropped":          len(oldChain),
+		"added":            len(newChain),
+		"reinjected_txs":   reinjected,
+		"head":             newHead.Hash().String(),
+		"total_difficulty": newHead.TotalDifficulty(),
+	}).Warn("Chain reorganised")
+
+	// Checkpoints of the new branch may already be attested.
+	return bc.finalizePending()
+}
+
+// revertTo removes blocks from the canonical chain, newest first, and
+// brings the account state back to ancestor.
+func (bc *Blockchain) revertTo(ancestor *block.Block, blocks []*block.Block) error {
+	for i := len(blocks) - 1; i >= 0; i-- {
+		txs := blocks[i].Transactions()
+		for j := range txs {
+			if err := bc.db.DeleteTransaction(&txs[j], blocks[i]); err != nil {
+				return err
+			}
+		}
+
+		if err := bc.db.DeleteCanonicalBlock(blocks[i].Height()); err != nil {
+			return err
+		}
+	}
+
+	if err := bc.db.RevertAccounts(ancestor.Height()); err != nil {
+		return err
+	}
+
+	if err := bc.db.SetLatestBlock(ancestor); err != nil {
+		return err
+	}
+
+	bc.latestBlock = ancestor
+	bc.totalDifficulty = ancestor.TotalDifficulty()
+
+	return bc.txPool.Update(ancestor)
+}
+
+func (bc *Blockchain) isCanonical(blk *block.Block) bool {
+	canonical, err := bc.db.GetBlockByHeight(blk.Height())
+	if err != nil {
+		return false
+	}
+
+	return canonical.Hash() == blk.Hash()
+}
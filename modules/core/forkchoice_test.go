@@ -0,0 +1,346 @@
+package core
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	pec256 "github.com/polarysfoundation/pec-256"
+	"github.com/polarysfoundation/polarys-chain/modules/common"
+	"github.com/polarysfoundation/polarys-chain/modules/core/block"
+	"github.com/polarysfoundation/polarys-chain/modules/core/consensus"
+	"github.com/polarysfoundation/polarys-chain/modules/core/consensus/pow"
+	"github.com/polarysfoundation/polarys-chain/modules/core/gaspool"
+	"github.com/polarysfoundation/polarys-chain/modules/core/state"
+	"github.com/polarysfoundation/polarys-chain/modules/core/transaction"
+	"github.com/polarysfoundation/polarys-chain/modules/crypto"
+	"github.com/polarysfoundation/polarys-chain/modules/params"
+	"github.com/polarysfoundation/polarys-chain/modules/prydb"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	keyA, pubA = crypto.GenerateKey()
+	keyB, pubB = crypto.GenerateKey()
+	keyZ, pubZ = crypto.GenerateKey()
+
+	validatorA = crypto.PubKeyToAddress(pubA)
+	validatorB = crypto.PubKeyToAddress(pubB)
+	validatorZ = crypto.PubKeyToAddress(pubZ)
+
+	testKeys = map[common.Address][2][]byte{
+		validatorA: {keyA.Bytes(), pubA.Bytes()},
+		validatorB: {keyB.Bytes(), pubB.Bytes()},
+		validatorZ: {keyZ.Bytes(), pubZ.Bytes()},
+	}
+)
+
+func newTestBlockchain(t *testing.T) (*Blockchain, *prydb.Database) {
+	return newTestBlockchainWithConfig(t, params.DefaultConfig)
+}
+
+func newTestBlockchainWithConfig(t *testing.T, config *params.Config) (*Blockchain, *prydb.Database) {
+	return newTestBlockchainWithEngine(t, config, pow.InitConsensus(1, 1, 1, 0, []common.Address{validatorA, validatorB}))
+}
+
+func newTestBlockchainWithEngine(t *testing.T, config *params.Config, engine consensus.Engine) (*Blockchain, *prydb.Database) {
+	// The database lives under the home directory.
+	t.Setenv("HOME", t.TempDir())
+
+	db, err := prydb.InitDB()
+	if err != nil {
+		t.Fatalf("InitDB() error = %v", err)
+	}
+
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	bc, err := InitBlockchain(db, config, params.Polarys, engine, nil, log)
+	if err != nil {
+		t.Fatalf("InitBlockchain() error = %v", err)
+	}
+
+	return bc, db
+}
+
+// signTestBlock signs blk with the key of its validator.
+func signTestBlock(t *testing.T, blk *block.Block) *block.Block {
+	t.Helper()
+
+	return signTestBlockAs(t, blk, blk.Validator())
+}
+
+func signTestBlockAs(t *testing.T, blk *block.Block, signer common.Address) *block.Block {
+	t.Helper()
+
+	key, ok := testKeys[signer]
+	if !ok {
+		t.Fatalf("no key for validator %v", signer)
+	}
+
+	digest := common.BytesToHash(crypto.Pm256(blk.SigningData(params.Polarys.ChainID)))
+	r, s, err := crypto.Sign(digest, pec256.BytesToPrivKey(key[0]))
+	if err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+
+	signature := make([]byte, 64, block.SignatureLen)
+	r.FillBytes(signature[:32])
+	s.FillBytes(signature[32:])
+
+	signed, err := blk.SignBlock(append(signature, key[1]...))
+	if err != nil {
+		t.Fatalf("SignBlock() error = %v", err)
+	}
+
+	return signed
+}
+
+// newTestBlock builds an empty block on top of parent, whose state is
+// parentState, fills in the state root and signs it.
+func newTestBlock(t *testing.T, bc *Blockchain, parent *block.Block, parentState *state.StateDB, validator common.Address, timestamp uint64) (*block.Block, *state.StateDB) {
+	return newTestBlockWithTxs(t, bc, parent, parentState, validator, timestamp, nil)
+}
+
+func newTestBlockWithTxs(t *testing.T, bc *Blockchain, parent *block.Block, parentState *state.StateDB, validator common.Address, timestamp uint64, txs []transaction.Transaction) (*block.Block, *state.StateDB) {
+	header := block.Header{
+		Height:    parent.Height() + 1,
+		Prev:      parent.Hash(),
+		Timestamp: timestamp,
+		Validator: validator,
+		Data:      []byte{},
+		TxRoot:    block.CalcTxRoot(txs),
+		GasTarget: bc.GasTarget(),
+		BaseFee:   gaspool.CalcBaseFee(parent.GasTarget(), parent.GasUsed(), parent.BaseFee()),
+	}
+
+	for _, tx := range txs {
+		tip, err := tx.EffectiveTip(header.BaseFee)
+		if err != nil {
+			t.Fatalf("EffectiveTip() error = %v", err)
+		}
+
+		header.GasUsed += tx.Gas()
+		header.GasTip += tx.Gas() * tip
+	}
+	setTestDifficulty(bc, &header, parent)
+
+	st, err := bc.processor.Process(block.NewBlock(header, txs), parentState)
+	if err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+
+	header.StateRoot, err = st.Root()
+	if err != nil {
+		t.Fatalf("Root() error = %v", err)
+	}
+
+	blk := block.NewBlock(header, txs)
+	blk = sealTestBlock(t, bc, blk)
+
+	return signTestBlock(t, blk), st
+}
+
+// sealTestBlock searches the nonce that makes blk meet its difficulty.
+func sealTestBlock(t *testing.T, bc *Blockchain, blk *block.Block) *block.Block {
+	t.Helper()
+
+	sealed, err := bc.consensus.SealBlock(context.Background(), blk)
+	if err != nil {
+		t.Fatalf("SealBlock() error = %v", err)
+	}
+
+	return sealed
+}
+
+// setTestDifficulty fills in the difficulty the engine expects for header
+// on top of parent, and the total difficulty that follows.
+func setTestDifficulty(bc *Blockchain, header *block.Header, parent *block.Block) {
+	header.Difficulty = bc.consensus.AdjustDifficulty(block.NewBlock(*header, nil), parent)
+	header.TotalDifficulty = parent.TotalDifficulty() + header.Difficulty
+}
+
+func assertHead(t *testing.T, bc *Blockchain, want *block.Block) {
+	t.Helper()
+
+	head, err := bc.GetLatestBlock()
+	if err != nil {
+		t.Fatalf("GetLatestBlock() error = %v", err)
+	}
+
+	if head.Hash() != want.Hash() {
+		t.Fatalf("head = %v at height %d, want %v at height %d", head.Hash(), head.Height(), want.Hash(), want.Height())
+	}
+}
+
+func assertBalance(t *testing.T, bc *Blockchain, address common.Address, want uint64) {
+	t.Helper()
+
+	balance, err := bc.BalanceAt(address, nil)
+	if err != nil {
+		t.Fatalf("BalanceAt() error = %v", err)
+	}
+
+	if balance != want {
+		t.Errorf("BalanceAt(%v) = %d, want %d", address, balance, want)
+	}
+}
+
+func TestBlockchain_Reorg(t *testing.T) {
+	bc, db := newTestBlockchain(t)
+	reward := pow.BlockReward.Uint64()
+
+	genesis, err := bc.GetLatestBlock()
+	if err != nil {
+		t.Fatalf("GetLatestBlock() error = %v", err)
+	}
+	genesisState := state.New(db, genesis)
+
+	// Blocks come late enough for both validators to be allowed to propose.
+	a2, a2State := newTestBlock(t, bc, genesis, genesisState, validatorA, genesis.Timestamp()+2)
+	b2, b2State := newTestBlock(t, bc, genesis, genesisState, validatorB, genesis.Timestamp()+3)
+	b3, _ := newTestBlock(t, bc, b2, b2State, validatorB, b2.Timestamp()+4)
+	a3, _ := newTestBlock(t, bc, a2, a2State, validatorA, a2.Timestamp()+5)
+
+	if err := bc.AddRemoteBlock(a2); err != nil {
+		t.Fatalf("AddRemoteBlock(a2) error = %v", err)
+	}
+
+	// Same total difficulty, the first branch seen stays canonical.
+	if err := bc.AddRemoteBlock(b2); err != nil {
+		t.Fatalf("AddRemoteBlock(b2) error = %v", err)
+	}
+	assertHead(t, bc, a2)
+	assertBalance(t, bc, validatorA, reward)
+
+	// The heavier branch wins and the state of a2 is reverted.
+	if err := bc.AddRemoteBlock(b3); err != nil {
+		t.Fatalf("AddRemoteBlock(b3) error = %v", err)
+	}
+	assertHead(t, bc, b3)
+	assertBalance(t, bc, validatorA, 0)
+	assertBalance(t, bc, validatorB, 2*reward)
+
+	canonical, err := bc.GetBlockByHeight(b2.Height())
+	if err != nil {
+		t.Fatalf("GetBlockByHeight() error = %v", err)
+	}
+	if canonical.Hash() != b2.Hash() {
+		t.Errorf("canonical block at height %d = %v, want %v", b2.Height(), canonical.Hash(), b2.Hash())
+	}
+
+	if err := bc.AddRemoteBlock(a3); err != nil {
+		t.Fatalf("AddRemoteBlock(a3) error = %v", err)
+	}
+	assertHead(t, bc, b3)
+
+	// A heavier branch with an invalid state root leaves the chain as it was.
+	header := block.Header{
+		Height:    a3.Height() + 1,
+		Prev:      a3.Hash(),
+		Timestamp: a3.Timestamp() + 6,
+		Validator: validatorA,
+		Data:      []byte{},
+		StateRoot: common.BytesToHash([]byte("invalid state root")),
+		BaseFee:   gaspool.CalcBaseFee(a3.GasTarget(), a3.GasUsed(), a3.BaseFee()),
+	}
+	setTestDifficulty(bc, &header, a3)
+	a4 := block.NewBlock(header, nil)
+	a4 = sealTestBlock(t, bc, a4)
+	a4 = signTestBlock(t, a4)
+
+	if err := bc.AddRemoteBlock(a4); err != ErrInvalidStateRoot {
+		t.Fatalf("AddRemoteBlock(a4) error = %v, want %v", err, ErrInvalidStateRoot)
+	}
+	assertHead(t, bc, b3)
+	assertBalance(t, bc, validatorA, 0)
+	assertBalance(t, bc, validatorB, 2*reward)
+}
+
+func TestBlockchain_AddRemoteBlockValidation(t *testing.T) {
+	// A turn timeout above one second leaves room for a block stamped
+	// after its parent but before its validator's turn.
+	engine := pow.InitConsensus(1, 1, 5, 0, []common.Address{validatorA, validatorB})
+	bc, db := newTestBlockchainWithEngine(t, params.DefaultConfig, engine)
+
+	genesis, err := bc.GetLatestBlock()
+	if err != nil {
+		t.Fatalf("GetLatestBlock() error = %v", err)
+	}
+
+	genesisState := state.New(db, genesis)
+
+	// Only the in-turn validator may propose right after the parent.
+	var inTurn, outOfTurn common.Address
+	if turn, _ := consensus.Turn([]common.Address{validatorA, validatorB}, validatorA, genesis.Height()+1, 1); turn == 0 {
+		inTurn, outOfTurn = validatorA, validatorB
+	} else {
+		inTurn, outOfTurn = validatorB, validatorA
+	}
+
+	early, _ := newTestBlock(t, bc, genesis, genesisState, outOfTurn, genesis.Timestamp()+1)
+	if err := bc.AddRemoteBlock(early); err != pow.ErrOutOfTurn {
+		t.Errorf("AddRemoteBlock() out of turn error = %v, want %v", err, pow.ErrOutOfTurn)
+	}
+
+	// Stamping the block ahead of the clock would skip the turn timeout.
+	future, _ := newTestBlock(t, bc, genesis, genesisState, outOfTurn, uint64(time.Now().Add(24*time.Hour).Unix()))
+	if err := bc.AddRemoteBlock(future); err != ErrFutureBlock {
+		t.Errorf("AddRemoteBlock() future timestamp error = %v, want %v", err, ErrFutureBlock)
+	}
+
+	stale, _ := newTestBlock(t, bc, genesis, genesisState, inTurn, genesis.Timestamp())
+	if err := bc.AddRemoteBlock(stale); err != ErrInvalidTimestamp {
+		t.Errorf("AddRemoteBlock() timestamp of the parent error = %v, want %v", err, ErrInvalidTimestamp)
+	}
+
+	stranger, _ := newTestBlock(t, bc, genesis, genesisState, validatorZ, genesis.Timestamp()+2)
+	if err := bc.AddRemoteBlock(stranger); err != pow.ErrInvalidValidator {
+		t.Errorf("AddRemoteBlock() unknown validator error = %v, want %v", err, pow.ErrInvalidValidator)
+	}
+
+	// A block signed by another key than the one of its validator.
+	forged, _ := newTestBlock(t, bc, genesis, genesisState, inTurn, genesis.Timestamp()+1)
+	forged = signTestBlockAs(t, forged, outOfTurn)
+	if err := bc.AddRemoteBlock(forged); err != pow.ErrSignerMismatch {
+		t.Errorf("AddRemoteBlock() forged signature error = %v, want %v", err, pow.ErrSignerMismatch)
+	}
+
+	unsigned, _ := forged.SignBlock(nil)
+	if err := bc.AddRemoteBlock(unsigned); err != block.ErrInvalidSignature {
+		t.Errorf("AddRemoteBlock() unsigned error = %v, want %v", err, block.ErrInvalidSignature)
+	}
+
+	// A block claiming less difficulty than its parent requires is cheap
+	// to seal.
+	header := forged.Header()
+	header.Difficulty = 1
+	header.TotalDifficulty = genesis.TotalDifficulty() + 1
+	cheap := signTestBlock(t, sealTestBlock(t, bc, block.NewBlock(header, nil)))
+	if err := bc.AddRemoteBlock(cheap); err != ErrInvalidDifficulty {
+		t.Errorf("AddRemoteBlock() low difficulty error = %v, want %v", err, ErrInvalidDifficulty)
+	}
+
+	blk, _ := newTestBlock(t, bc, genesis, genesisState, inTurn, genesis.Timestamp()+1)
+
+	if err := bc.AddRemoteBlock(blk); err != nil {
+		t.Fatalf("AddRemoteBlock() error = %v", err)
+	}
+
+	if err := bc.AddRemoteBlock(blk); err != ErrBlockExists {
+		t.Errorf("AddRemoteBlock() duplicate error = %v, want %v", err, ErrBlockExists)
+	}
+
+	orphan := block.NewBlock(block.Header{Height: 5, Prev: common.BytesToHash([]byte("unknown")), Difficulty: 1}, nil)
+	orphan.CalcHash()
+	if err := bc.AddRemoteBlock(orphan); err != ErrUnknownParent {
+		t.Errorf("AddRemoteBlock() orphan error = %v, want %v", err, ErrUnknownParent)
+	}
+
+	badTD := block.NewBlock(block.Header{Height: blk.Height() + 1, Prev: blk.Hash(), Timestamp: blk.Timestamp() + 1, Difficulty: 1, TotalDifficulty: 1000}, nil)
+	badTD.CalcHash()
+	if err := bc.AddRemoteBlock(badTD); err != ErrInvalidTotalDifficulty {
+		t.Errorf("AddRemoteBlock() total difficulty error = %v, want %v", err, ErrInvalidTotalDifficulty)
+	}
+}
@@ -0,0 +1,8 @@
+package gaspool
+
+import "errors"
+
+var (
+	ErrGasTargetExceeded = errors.New("gas cost exceeds target")
+	ErrInvalidPercentile = errors.New("percentiles must be ascending and between 0 and 100")
+)
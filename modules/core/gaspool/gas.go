@@ -2,28 +2,37 @@ package gaspool
 
 import (
 	"fmt"
-	"time"
+	"math"
+	"math/big"
 )
 
+// GasPool tracks the gas rules of the next block. The base fee only
+// follows from the latest block, so every node agrees on it.
 type GasPool struct {
 	gasTarget    uint64
-	gasPrice     uint64
 	maxGasTarget uint64
-	gasInterval  uint64
-	latestSync   uint64
+	baseFee      uint64
+}
+
+// FeeHistory describes the fees paid in a range of blocks, oldest first.
+type FeeHistory struct {
+	OldestBlock  uint64
+	BaseFees     []uint64   // base fee of every block, and of the block after the newest
+	GasUsedRatio []float64  // gas used over the gas limit of every block
+	Rewards      [][]uint64 // effective tip per gas at each requested percentile of every block
 }
 
 func InitGasPool() *GasPool {
 	return &GasPool{
 		gasTarget:    uint64(DefaultGasTarget),
-		gasInterval:  uint64(DefaultGasInterval),
-		latestSync:   uint64(time.Now().Unix()),
 		maxGasTarget: uint64(DefaultMaxGasTarget),
+		baseFee:      uint64(InitialBaseFee),
 	}
 }
 
-func (gp *GasPool) GasPrice() uint64 {
-	return gp.gasPrice
+// BaseFee returns the base fee per gas of the next block.
+func (gp *GasPool) BaseFee() uint64 {
+	return gp.baseFee
 }
 
 func (gp *GasPool) GasTarget() uint64 {
@@ -34,53 +43,69 @@ func (gp *GasPool) MaxGasTarget() uint64 {
 	return gp.maxGasTarget
 }
 
-func (gp *GasPool) SyncGasPool(latestGasTarget uint64, latestBlock uint64, transactionCount int) error {
-	if latestGasTarget > uint64(gp.maxGasTarget) {
-		return fmt.Errorf("gas target exceeds max value")
-	}
+// SetHead moves the pool on top of a new latest block, given its gas
+// target, gas used and base fee.
+func (gp *GasPool) SetHead(gasTarget uint64, gasUsed uint64, baseFee uint64) {
+	gp.baseFee = CalcBaseFee(gasTarget, gasUsed, baseFee)
+}
 
-	if !gp.expired() {
-		return nil
+// GasLimit returns the gas a block with gasTarget may use.
+func GasLimit(gasTarget uint64) uint64 {
+	if gasTarget > math.MaxUint64/uint64(ElasticityMultiplier) {
+		return math.MaxUint64
 	}
 
-	totalGasTip := latestGasTarget / uint64(GasDivisor) * uint64(GasTipMultiplier)
-
-	gasTargetWithoutTip := latestGasTarget - totalGasTip
-
-	gasTargetRefactor := gasTargetWithoutTip * uint64(GasRefactor) / uint64(GasDivisor)
-
-	gasTarget := gasTargetWithoutTip + gasTargetRefactor
-
-	gasPrice := gasTarget / uint64(GasDivisor) * uint64(MinGasMultiplier)
-
-	maxGasTarget := gasTarget * uint64(MaxGasMultiplier) / uint64(GasDivisor)
+	return gasTarget * uint64(ElasticityMultiplier)
+}
 
-	gp.gasPrice = gasPrice
-	gp.gasTarget = gasTarget
-	gp.maxGasTarget = maxGasTarget
-	gp.latestSync = uint64(time.Now().Unix())
+// CalcBaseFee returns the base fee per gas of the block following a parent
+// with the given gas target, gas used and base fee. The base fee rises
+// when the parent used more gas than its target and falls when it used
+// less, by at most 1/BaseFeeChangeDenominator per block.
+func CalcBaseFee(parentGasTarget uint64, parentGasUsed uint64, parentBaseFee uint64) uint64 {
+	// The genesis block, and the blocks from before the base fee, start
+	// the schedule.
+	if parentGasTarget == 0 || parentBaseFee == 0 {
+		return uint64(InitialBaseFee)
+	}
 
-	return nil
-}
+	if parentGasUsed == parentGasTarget {
+		return parentBaseFee
+	}
 
-func (gp *GasPool) CalcGas(data []byte, payloadLen int, valueLen int, tipGas uint64) (uint64, error) {
-	gasCost, _, err := calcGasCost(gp.gasTarget, gp.gasPrice, len(data), payloadLen, valueLen, tipGas)
-	if err != nil {
-		return 0, err
+	var used big.Int
+	if parentGasUsed > parentGasTarget {
+		used.SetUint64(parentGasUsed - parentGasTarget)
+	} else {
+		used.SetUint64(parentGasTarget - parentGasUsed)
 	}
 
-	return gasCost, nil
-}
+	delta := new(big.Int).SetUint64(parentBaseFee)
+	delta.Mul(delta, &used)
+	delta.Div(delta, new(big.Int).SetUint64(parentGasTarget))
+	delta.Div(delta, big.NewInt(int64(BaseFeeChangeDenominator)))
+
+	baseFee := new(big.Int).SetUint64(parentBaseFee)
+	if parentGasUsed > parentGasTarget {
+		// A full block always raises the base fee, even a small one.
+		if delta.Sign() == 0 {
+			delta.SetUint64(1)
+		}
+		baseFee.Add(baseFee, delta)
+	} else {
+		baseFee.Sub(baseFee, delta)
+	}
 
-func (gp *GasPool) expired() bool {
-	return gp.latestSync <= uint64(time.Now().Unix())-gp.gasInterval
-}
+	if !baseFee.IsUint64() {
+		return math.MaxUint64
+	}
 
-func CalcGas(gasTarget uint64, gasPrice uint64, dataLength int, payloadLen int, valueLength int) (uint64, uint64, error) {
-	return calcGasCost(gasTarget, gasPrice, dataLength, valueLength, payloadLen, 0)
+	return baseFee.Uint64()
 }
 
-func calcGasCost(gasTarget uint64, gasPrice uint64, dataLength int, payloadLen int, valueLen int, tipGas uint64) (uint64, uint64, error) {
+// CalcGas returns the gas used by a transaction with the given sizes, or
+// an error if it does not fit in gasLimit.
+func CalcGas(gasLimit uint64, dataLength int, payloadLen int, valueLen int) (uint64, error) {
 	gasCost := BaseGas
 
 	gasCost += dataLength * BytesCost
@@ -91,29 +116,9 @@ func calcGasCost(gasTarget uint64, gasPrice uint64, dataLength int, payloadLen i
 		gasCost += valueLen * ValueByteCost
 	}
 
-	exactGasTip := calcGasTip(uint64(gasCost))
-
-	if tipGas > uint64(exactGasTip) {
-		return 0, 0, fmt.Errorf("gas tip does not match")
+	if uint64(gasCost) > gasLimit {
+		return 0, fmt.Errorf("%w: %d above %d", ErrGasTargetExceeded, gasCost, gasLimit)
 	}
 
-	if tipGas > 0 && tipGas < exactGasTip {
-		exactGasTip = tipGas
-	}
-
-	gasCost += int(exactGasTip)
-
-	if gasCost > int(gasTarget) {
-		return 0, 0, fmt.Errorf("gas cost exceeds target")
-	}
-
-	if gasCost < int(gasPrice) {
-		gasCost = int(gasPrice)
-	}
-
-	return uint64(gasCost), uint64(exactGasTip), nil
-}
-
-func calcGasTip(gasCost uint64) uint64 {
-	return gasCost * uint64(GasTipMultiplier) / uint64(GasDivisor)
+	return uint64(gasCost), nil
 }
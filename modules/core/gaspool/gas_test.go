@@ -0,0 +1,52 @@
+package gaspool
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCalcBaseFee(t *testing.T) {
+	tests := []struct {
+		name    string
+		target  uint64
+		used    uint64
+		baseFee uint64
+		want    uint64
+	}{
+		{"genesis", 0, 0, 0, uint64(InitialBaseFee)},
+		{"no base fee", 1000, 500, 0, uint64(InitialBaseFee)},
+		{"at target", 1000, 1000, 800, 800},
+		{"full block", 1000, 2000, 800, 900},
+		{"above target", 1000, 1500, 800, 850},
+		{"empty block", 1000, 0, 800, 700},
+		{"below target", 1000, 500, 800, 750},
+		{"small base fee rises", 1000, 1001, 8, 9},
+		{"small base fee stays", 1000, 999, 8, 8},
+		{"saturates", 1000, 2000, math.MaxUint64, math.MaxUint64},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CalcBaseFee(tt.target, tt.used, tt.baseFee); got != tt.want {
+				t.Errorf("CalcBaseFee(%d, %d, %d) = %d, want %d", tt.target, tt.used, tt.baseFee, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGasPool_SetHead(t *testing.T) {
+	gp := InitGasPool()
+	if gp.BaseFee() != uint64(InitialBaseFee) {
+		t.Fatalf("BaseFee() = %d, want %d", gp.BaseFee(), InitialBaseFee)
+	}
+
+	// Every node computes the same base fee from the same head.
+	gp.SetHead(1000, 2000, 800)
+	if gp.BaseFee() != 900 {
+		t.Errorf("BaseFee() after a full block = %d, want 900", gp.BaseFee())
+	}
+
+	if limit := GasLimit(1000); limit != 1000*uint64(ElasticityMultiplier) {
+		t.Errorf("GasLimit(1000) = %d, want %d", limit, 1000*ElasticityMultiplier)
+	}
+}
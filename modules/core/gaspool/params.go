@@ -29,11 +29,12 @@ var (
 	GasDivisor       = 10000 // 100%
 	MaxGasMultiplier = 6000  // 60%
 	MinGasMultiplier = 500   // 5%
-	GasTipMultiplier = 1000  // 10%
-	GasRefactor      = 3000  // 30%
 
 	DefaultGasTarget    = 1000000                                        // 0.000000001 PRY
 	DefaultMinGas       = DefaultGasTarget - MinGasMultiplier*GasDivisor // default_min_gas = default_gas_target - min_gas_multiplier * gas_divisor
 	DefaultMaxGasTarget = DefaultGasTarget + MaxGasMultiplier*GasDivisor // default_max_target = default_gas_target + max_gas_multiplier * gas_divisor
-	DefaultGasInterval  = 60                                             // 60 seconds
+
+	InitialBaseFee           = 10 // 0.00000000000001 PRY per gas
+	BaseFeeChangeDenominator = 8  // the base fee moves by at most 12.5% per block
+	ElasticityMultiplier     = 2  // a block may use up to twice its gas target
 )
@@ -0,0 +1,146 @@
+package state
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/polarysfoundation/polarys-chain/modules/common"
+	"github.com/polarysfoundation/polarys-chain/modules/crypto"
+)
+
+// leafSet holds the leaf of every non-empty account in the order of the
+// state root, with the Merkle tree built over them. It is never modified
+// once built, so it can be shared between states.
+type leafSet struct {
+	addresses []common.Address
+	leaves    []common.Hash
+	index     map[common.Address]int // position in addresses
+	tree      *crypto.MerkleTree
+}
+
+// newLeafSet builds the set of leaves, whose addresses must be sorted.
+func newLeafSet(addresses []common.Address, leaves []common.Hash) *leafSet {
+	index := make(map[common.Address]int, len(addresses))
+	for i, addr := range addresses {
+		index[addr] = i
+	}
+
+	return &leafSet{
+		addresses: addresses,
+		leaves:    leaves,
+		index:     index,
+		tree:      crypto.NewMerkleTree(leaves),
+	}
+}
+
+// apply returns a set with the leaves of changed accounts replaced, a zero
+// hash removing the account. While no account appears or disappears only
+// the paths of the changed leaves are rehashed.
+func (l *leafSet) apply(changed map[common.Address]common.Hash) *leafSet {
+	if len(changed) == 0 {
+		return l
+	}
+
+	reshaped := false
+	for addr, leaf := range changed {
+		if _, ok := l.index[addr]; ok != (leaf != (common.Hash{})) {
+			reshaped = true
+			break
+		}
+	}
+
+	if !reshaped {
+		next := &leafSet{
+			addresses: l.addresses,
+			leaves:    append([]common.Hash(nil), l.leaves...),
+			index:     l.index,
+		}
+
+		updates := make(map[int]common.Hash, len(changed))
+		for addr, leaf := range changed {
+			i := l.index[addr]
+			next.leaves[i] = leaf
+			updates[i] = leaf
+		}
+		next.tree = l.tree.Update(updates)
+
+		return next
+	}
+
+	addresses := append([]common.Address(nil), l.addresses...)
+	leaves := append([]common.Hash(nil), l.leaves...)
+
+	for addr, leaf := range changed {
+		cxid := addr.CXID()
+		i := sort.Search(len(addresses), func(i int) bool {
+			return addresses[i].CXID() >= cxid
+		})
+		found := i < len(addresses) && addresses[i] == addr
+
+		switch {
+		case leaf == (common.Hash{}) && found:
+			addresses = append(addresses[:i], addresses[i+1:]...)
+			leaves = append(leaves[:i], leaves[i+1:]...)
+		case leaf == (common.Hash{}):
+		case found:
+			leaves[i] = leaf
+		default:
+			addresses = append(addresses, common.Address{})
+			copy(addresses[i+1:], addresses[i:])
+			addresses[i] = addr
+
+			leaves = append(leaves, common.Hash{})
+			copy(leaves[i+1:], leaves[i:])
+			leaves[i] = leaf
+		}
+	}
+
+	return newLeafSet(addresses, leaves)
+}
+
+func (l *leafSet) root() common.Hash {
+	return l.tree.Root()
+}
+
+// Cache keeps the account leaves of the last committed state, so the root
+// of the next block only hashes the accounts the block touched instead of
+// reading every account from the database. It is safe for concurrent use.
+type Cache struct {
+	mu     sync.Mutex
+	height uint64
+	hash   common.Hash
+	set    *leafSet
+}
+
+func NewCache() *Cache {
+	return &Cache{}
+}
+
+// get returns the leaves of the state at the given block, if cached.
+func (c *Cache) get(height uint64, hash common.Hash) (*leafSet, bool) {
+	if c == nil {
+		return nil, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.set == nil || c.height != height || c.hash != hash {
+		return nil, false
+	}
+
+	return c.set, true
+}
+
+func (c *Cache) put(height uint64, hash common.Hash, set *leafSet) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.height = height
+	c.hash = hash
+	c.set = set
+}
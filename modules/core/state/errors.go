@@ -0,0 +1,9 @@
+package state
+
+import "errors"
+
+var (
+	ErrInsufficientBalance = errors.New("insufficient balance")
+	ErrBalanceOverflow     = errors.New("balance overflow")
+	ErrNonceOverflow       = errors.New("nonce overflow")
+)
@@ -0,0 +1,301 @@
+package state
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/polarysfoundation/polarys-chain/modules/crypto"
+
+	"github.com/polarysfoundation/polarys-chain/modules/common"
+	"github.com/polarysfoundation/polarys-chain/modules/core/block"
+	"github.com/polarysfoundation/polarys-chain/modules/prydb"
+)
+
+type stateObject struct {
+	nonce   uint64
+	balance uint64
+	code    []byte
+}
+
+func (o *stateObject) empty() bool {
+	return o.nonce == 0 && o.balance == 0 && len(o.code) == 0
+}
+
+func (o *stateObject) hash(address common.Address) common.Hash {
+	buff := make([]byte, common.AddrLen+8+8+common.HashLen)
+	copy(buff, address.Bytes())
+	copy(buff[common.AddrLen:], common.Uint64ToBytes(o.nonce))
+	copy(buff[common.AddrLen+8:], common.Uint64ToBytes(o.balance))
+	copy(buff[common.AddrLen+16:], crypto.Pm256(o.code))
+
+	return common.BytesToHash(crypto.Pm256(buff))
+}
+
+// StateDB is an in-memory view of the account state on top of a committed
+// block. Changes stay in memory until Commit writes them at a new height.
+type StateDB struct {
+	db      *prydb.Database
+	height  uint64
+	hash    common.Hash
+	objects map[common.Address]*stateObject
+	dirty   map[common.Address]struct{}
+	cache   *Cache
+}
+
+// New returns the state as of blk.
+func New(db *prydb.Database, blk *block.Block) *StateDB {
+	return NewWithCache(db, blk, nil)
+}
+
+// NewWithCache returns the state as of blk, sharing the account leaves of
+// the last committed state through cache.
+func NewWithCache(db *prydb.Database, blk *block.Block, cache *Cache) *StateDB {
+	return &StateDB{
+		db:      db,
+		height:  blk.Height(),
+		hash:    blk.Hash(),
+		objects: make(map[common.Address]*stateObject),
+		dirty:   make(map[common.Address]struct{}),
+		cache:   cache,
+	}
+}
+
+// Height returns the height of the block the state was loaded from.
+func (s *StateDB) Height() uint64 {
+	return s.height
+}
+
+func (s *StateDB) getObject(address common.Address) (*stateObject, error) {
+	if obj, ok := s.objects[address]; ok {
+		return obj, nil
+	}
+
+	obj := &stateObject{}
+
+	nonce, balance, code, err := s.db.AccountAt(address, s.height)
+	if err != nil && !errors.Is(err, prydb.ErrAccountNotFound) {
+		return nil, err
+	}
+
+	if err == nil {
+		obj.nonce = nonce
+		obj.balance = balance
+		obj.code = code
+	}
+
+	s.objects[address] = obj
+
+	return obj, nil
+}
+
+func (s *StateDB) GetBalance(address common.Address) (uint64, error) {
+	obj, err := s.getObject(address)
+	if err != nil {
+		return 0, err
+	}
+
+	return obj.balance, nil
+}
+
+func (s *StateDB) GetNonce(address common.Address) (uint64, error) {
+	obj, err := s.getObject(address)
+	if err != nil {
+		return 0, err
+	}
+
+	return obj.nonce, nil
+}
+
+func (s *StateDB) AddBalance(address common.Address, amount uint64) error {
+	obj, err := s.getObject(address)
+	if err != nil {
+		return err
+	}
+
+	if obj.balance+amount < obj.balance {
+		return ErrBalanceOverflow
+	}
+
+	obj.balance += amount
+	s.dirty[address] = struct{}{}
+
+	return nil
+}
+
+func (s *StateDB) SubBalance(address common.Address, amount uint64) error {
+	obj, err := s.getObject(address)
+	if err != nil {
+		return err
+	}
+
+	if obj.balance < amount {
+		return ErrInsufficientBalance
+	}
+
+	obj.balance -= amount
+	s.dirty[address] = struct{}{}
+
+	return nil
+}
+
+func (s *StateDB) SetNonce(address common.Address, nonce uint64) error {
+	obj, err := s.getObject(address)
+	if err != nil {
+		return err
+	}
+
+	obj.nonce = nonce
+	s.dirty[address] = struct{}{}
+
+	return nil
+}
+
+// Copy returns an independent copy of the state, so a block can be applied
+// without touching the parent state on failure.
+func (s *StateDB) Copy() *StateDB {
+	cpy := &StateDB{
+		db:      s.db,
+		height:  s.height,
+		hash:    s.hash,
+		objects: make(map[common.Address]*stateObject, len(s.objects)),
+		dirty:   make(map[common.Address]struct{}, len(s.dirty)),
+		cache:   s.cache,
+	}
+
+	for addr, obj := range s.objects {
+		code := make([]byte, len(obj.code))
+		copy(code, obj.code)
+		cpy.objects[addr] = &stateObject{nonce: obj.nonce, balance: obj.balance, code: code}
+	}
+
+	for addr := range s.dirty {
+		cpy.dirty[addr] = struct{}{}
+	}
+
+	return cpy
+}
+
+// Root returns the Merkle root of every non-empty account, ordered by
+// address. Each leaf hashes the address, nonce, balance and code hash.
+//
+// With a cache holding the tree of the block the state was loaded from,
+// only the modified accounts and their paths are hashed. An account that
+// appears or disappears rebuilds the tree from the cached leaves, without
+// reading the database. On a cache miss every stored account is read once
+// and its leaves are cached for the next state.
+func (s *StateDB) Root() (common.Hash, error) {
+	set, err := s.leaves()
+	if err != nil {
+		return common.Hash{}, err
+	}
+
+	return set.root(), nil
+}
+
+func (s *StateDB) leaves() (*leafSet, error) {
+	base, ok := s.cache.get(s.height, s.hash)
+	if !ok {
+		var err error
+		base, err = s.storedLeaves()
+		if err != nil {
+			return nil, err
+		}
+
+		s.cache.put(s.height, s.hash, base)
+	}
+
+	changed := make(map[common.Address]common.Hash, len(s.dirty))
+	for addr := range s.dirty {
+		obj := s.objects[addr]
+		if obj.empty() {
+			changed[addr] = common.Hash{}
+			continue
+		}
+
+		changed[addr] = obj.hash(addr)
+	}
+
+	return base.apply(changed), nil
+}
+
+// storedLeaves reads the leaves of every account stored as of the state
+// height, ignoring the changes made in memory.
+func (s *StateDB) storedLeaves() (*leafSet, error) {
+	stored, err := s.db.Accounts()
+	if err != nil {
+		return nil, err
+	}
+
+	objects := make(map[common.Address]*stateObject, len(stored))
+	addresses := make([]common.Address, 0, len(stored))
+	for _, addr := range stored {
+		if _, ok := objects[addr]; ok {
+			continue
+		}
+
+		nonce, balance, code, err := s.db.AccountAt(addr, s.height)
+		if errors.Is(err, prydb.ErrAccountNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		obj := &stateObject{nonce: nonce, balance: balance, code: code}
+		if obj.empty() {
+			continue
+		}
+
+		objects[addr] = obj
+		addresses = append(addresses, addr)
+	}
+
+	sort.Slice(addresses, func(i, j int) bool {
+		return addresses[i].CXID() < addresses[j].CXID()
+	})
+
+	leaves := make([]common.Hash, 0, len(addresses))
+	for _, addr := range addresses {
+		leaves = append(leaves, objects[addr].hash(addr))
+	}
+
+	return newLeafSet(addresses, leaves), nil
+}
+
+// Commit writes every modified account at the height of blk. Accounts are
+// written in address order so all nodes produce the same sequence of writes.
+func (s *StateDB) Commit(blk *block.Block) error {
+	var set *leafSet
+	if s.cache != nil {
+		var err error
+		if set, err = s.leaves(); err != nil {
+			return err
+		}
+	}
+
+	addresses := make([]common.Address, 0, len(s.dirty))
+	for addr := range s.dirty {
+		addresses = append(addresses, addr)
+	}
+
+	sort.Slice(addresses, func(i, j int) bool {
+		return addresses[i].CXID() < addresses[j].CXID()
+	})
+
+	for _, addr := range addresses {
+		obj := s.objects[addr]
+		if err := s.db.CommitAccount(addr, obj.nonce, obj.balance, obj.code, blk); err != nil {
+			return err
+		}
+	}
+
+	s.height = blk.Height()
+	s.hash = blk.Hash()
+	s.dirty = make(map[common.Address]struct{})
+
+	if set != nil {
+		s.cache.put(s.height, s.hash, set)
+	}
+
+	return nil
+}
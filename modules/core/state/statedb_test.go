@@ -0,0 +1,130 @@
+package state
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/polarysfoundation/polarys-chain/modules/common"
+	"github.com/polarysfoundation/polarys-chain/modules/core/block"
+	"github.com/polarysfoundation/polarys-chain/modules/prydb"
+)
+
+func newTestDB(tb testing.TB) *prydb.Database {
+	tb.Helper()
+
+	// The database lives under the home directory.
+	tb.Setenv("HOME", tb.TempDir())
+
+	db, err := prydb.InitDB()
+	if err != nil {
+		tb.Fatalf("InitDB() error = %v", err)
+	}
+
+	return db
+}
+
+func newTestStateBlock(height uint64) *block.Block {
+	blk := block.NewBlock(block.Header{Height: height, Data: []byte{}}, nil)
+	blk.CalcHash()
+
+	return blk
+}
+
+func TestStateDB_RootCache(t *testing.T) {
+	db := newTestDB(t)
+	cache := NewCache()
+
+	a := common.BytesToAddress([]byte("account_aaaaaaa"))
+	b := common.BytesToAddress([]byte("account_bbbbbbb"))
+	c := common.BytesToAddress([]byte("account_ccccccc"))
+
+	steps := []func(st *StateDB) error{
+		func(st *StateDB) error { return st.AddBalance(b, 10) },
+		func(st *StateDB) error {
+			if err := st.AddBalance(a, 5); err != nil {
+				return err
+			}
+			return st.AddBalance(c, 7)
+		},
+		// b empties and leaves the tree.
+		func(st *StateDB) error { return st.SubBalance(b, 10) },
+		func(st *StateDB) error { return st.SetNonce(a, 1) },
+	}
+
+	parent := newTestStateBlock(0)
+	for i, step := range steps {
+		cached := NewWithCache(db, parent, cache)
+		uncached := New(db, parent)
+
+		for _, st := range []*StateDB{cached, uncached} {
+			if err := step(st); err != nil {
+				t.Fatalf("step %d error = %v", i, err)
+			}
+		}
+
+		want, err := uncached.Root()
+		if err != nil {
+			t.Fatalf("Root() error = %v", err)
+		}
+
+		if got, err := cached.Root(); err != nil || got != want {
+			t.Fatalf("step %d cached Root() = %v, %v, want %v", i, got, err, want)
+		}
+
+		blk := newTestStateBlock(parent.Height() + 1)
+		if err := cached.Commit(blk); err != nil {
+			t.Fatalf("Commit() error = %v", err)
+		}
+
+		// The committed leaves are those of the new block.
+		if got, err := NewWithCache(db, blk, cache).Root(); err != nil || got != want {
+			t.Errorf("step %d Root() after commit = %v, %v, want %v", i, got, err, want)
+		}
+
+		parent = blk
+	}
+}
+
+// BenchmarkStateDB_Root computes the root after a transfer between two of
+// a growing number of stored accounts. With the cache only the paths of the
+// two leaves are hashed.
+func BenchmarkStateDB_Root(b *testing.B) {
+	for _, accounts := range []int{10, 100, 200} {
+		b.Run(fmt.Sprintf("accounts=%d", accounts), func(b *testing.B) {
+			db := newTestDB(b)
+			cache := NewCache()
+
+			genesis := newTestStateBlock(0)
+			parent := newTestStateBlock(1)
+
+			st := NewWithCache(db, genesis, cache)
+			for i := 0; i < accounts; i++ {
+				if err := st.AddBalance(common.BytesToAddress([]byte(fmt.Sprintf("account_%07d", i))), 100); err != nil {
+					b.Fatalf("AddBalance() error = %v", err)
+				}
+			}
+
+			if err := st.Commit(parent); err != nil {
+				b.Fatalf("Commit() error = %v", err)
+			}
+
+			from := common.BytesToAddress([]byte(fmt.Sprintf("account_%07d", 0)))
+			to := common.BytesToAddress([]byte(fmt.Sprintf("account_%07d", 1)))
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				st := NewWithCache(db, parent, cache)
+				if err := st.SubBalance(from, 1); err != nil {
+					b.Fatalf("SubBalance() error = %v", err)
+				}
+				if err := st.AddBalance(to, 1); err != nil {
+					b.Fatalf("AddBalance() error = %v", err)
+				}
+
+				if _, err := st.Root(); err != nil {
+					b.Fatalf("Root() error = %v", err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,155 @@
+package core
+
+import (
+	"math/big"
+	"math/bits"
+
+	"github.com/polarysfoundation/polarys-chain/modules/core/block"
+	"github.com/polarysfoundation/polarys-chain/modules/core/consensus"
+	"github.com/polarysfoundation/polarys-chain/modules/core/gaspool"
+	"github.com/polarysfoundation/polarys-chain/modules/core/state"
+	"github.com/polarysfoundation/polarys-chain/modules/core/transaction"
+)
+
+// StateProcessor applies the transactions of a block on top of the state
+// of its parent. The result only depends on the block and the parent state,
+// so every node importing the block ends with the same accounts.
+type StateProcessor struct {
+	engine  consensus.Engine
+	chainID uint64
+}
+
+func NewStateProcessor(engine consensus.Engine, chainID uint64) *StateProcessor {
+	return &StateProcessor{engine: engine, chainID: chainID}
+}
+
+// Process applies blk on a copy of parentState and returns the new state.
+// The parent state is left untouched when the block is invalid.
+func (p *StateProcessor) Process(blk *block.Block, parentState *state.StateDB) (*state.StateDB, error) {
+	st := parentState.Copy()
+
+	var gasUsed, gasTip uint64
+	txs := blk.Transactions()
+	gasLimit := gaspool.GasLimit(blk.GasTarget())
+
+	for i := range txs {
+		tip, err := ApplyTransaction(st, &txs[i], p.chainID, blk.BaseFee(), gasLimit)
+		if err != nil {
+			return nil, err
+		}
+
+		gasUsed += txs[i].Gas()
+		gasTip += tip
+	}
+
+	if gasUsed != blk.GasUsed() {
+		return nil, ErrInvalidGasUsed
+	}
+
+	if gasTip != blk.GasTip() {
+		return nil, ErrInvalidGasTip
+	}
+
+	reward := p.engine.BlockReward(blk.Height())
+	if reward == nil {
+		reward = new(big.Int)
+	}
+
+	if !reward.IsUint64() {
+		return nil, ErrInvalidBlockReward
+	}
+
+	// The validator keeps the tips, the base fee is burned.
+	if err := st.AddBalance(blk.Validator(), reward.Uint64()); err != nil {
+		return nil, err
+	}
+
+	if err := st.AddBalance(blk.Validator(), gasTip); err != nil {
+		return nil, err
+	}
+
+	if finalizer, ok := p.engine.(consensus.Finalizer); ok {
+		if err := finalizer.Finalize(blk, st); err != nil {
+			return nil, err
+		}
+	}
+
+	return st, nil
+}
+
+// ApplyTransaction debits value plus gas from the sender, credits the value
+// to the recipient and bumps the sender nonce. Every unit of gas pays
+// baseFee, which is burned, and the effective tip of the transaction, which
+// goes to the validator; ApplyTransaction returns the total tip. The
+// transaction must be signed for chainID and declare the gas it uses in a
+// block with gasLimit.
+func ApplyTransaction(st *state.StateDB, tx *transaction.Transaction, chainID uint64, baseFee uint64, gasLimit uint64) (uint64, error) {
+	if ok, err := tx.Verify(chainID); err != nil || !ok {
+		return 0, ErrInvalidTransactionSignature
+	}
+
+	expected, err := tx.CalcGas(gasLimit)
+	if err != nil {
+		return 0, err
+	}
+
+	if expected.Gas() != tx.Gas() {
+		return 0, ErrInvalidGas
+	}
+
+	if tx.Value() == nil || tx.Value().Sign() < 0 || !tx.Value().IsUint64() {
+		return 0, ErrInvalidTransactionValue
+	}
+
+	tipPerGas, err := tx.EffectiveTip(baseFee)
+	if err != nil {
+		return 0, err
+	}
+
+	nonce, err := st.GetNonce(tx.From())
+	if err != nil {
+		return 0, err
+	}
+
+	if tx.Nonce() != nonce {
+		return 0, ErrInvalidNonce
+	}
+
+	// baseFee+tipPerGas never exceeds the max fee, so only the products
+	// may overflow.
+	fee, overflow := mulUint64(tx.Gas(), baseFee+tipPerGas)
+	if overflow {
+		return 0, state.ErrBalanceOverflow
+	}
+
+	tip, _ := mulUint64(tx.Gas(), tipPerGas)
+
+	value := tx.Value().Uint64()
+	cost := value + fee
+	if cost < value {
+		return 0, state.ErrBalanceOverflow
+	}
+
+	if err := st.SubBalance(tx.From(), cost); err != nil {
+		return 0, err
+	}
+
+	if err := st.AddBalance(tx.To(), value); err != nil {
+		return 0, err
+	}
+
+	if nonce+1 < nonce {
+		return 0, state.ErrNonceOverflow
+	}
+
+	if err := st.SetNonce(tx.From(), nonce+1); err != nil {
+		return 0, err
+	}
+
+	return tip, nil
+}
+
+func mulUint64(a, b uint64) (uint64, bool) {
+	hi, lo := bits.Mul64(a, b)
+	return lo, hi != 0
+}
@@ -0,0 +1,141 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/polarysfoundation/polarys-chain/modules/common"
+	"github.com/polarysfoundation/polarys-chain/modules/core/block"
+	"github.com/polarysfoundation/polarys-chain/modules/core/consensus/pow"
+	"github.com/polarysfoundation/polarys-chain/modules/core/gaspool"
+	"github.com/polarysfoundation/polarys-chain/modules/core/state"
+	"github.com/polarysfoundation/polarys-chain/modules/core/transaction"
+)
+
+func assertStateBalance(t *testing.T, st *state.StateDB, address common.Address, want uint64) {
+	t.Helper()
+
+	balance, err := st.GetBalance(address)
+	if err != nil {
+		t.Fatalf("GetBalance() error = %v", err)
+	}
+
+	if balance != want {
+		t.Errorf("GetBalance(%v) = %d, want %d", address, balance, want)
+	}
+}
+
+func TestApplyTransaction(t *testing.T) {
+	bc, db := newTestBlockchain(t)
+
+	genesis, err := bc.GetLatestBlock()
+	if err != nil {
+		t.Fatalf("GetLatestBlock() error = %v", err)
+	}
+
+	const funds = 1_000_000_000
+	st := state.New(db, genesis)
+	if err := st.AddBalance(validatorA, funds); err != nil {
+		t.Fatalf("AddBalance() error = %v", err)
+	}
+
+	const tip = 3
+	baseFee := bc.gaspool.BaseFee()
+	gasLimit := gaspool.GasLimit(bc.GasTarget())
+
+	tests := []struct {
+		name string
+		tx   *transaction.Transaction
+		want error
+	}{
+		{"nonce ahead", newPricedTestTx(t, bc, validatorA, validatorZ, 1, baseFee+tip), ErrInvalidNonce},
+		{"insufficient balance", newPricedTestTx(t, bc, validatorB, validatorZ, 0, baseFee+tip), state.ErrInsufficientBalance},
+	}
+
+	for _, tt := range tests {
+		if _, err := ApplyTransaction(st.Copy(), tt.tx, bc.chainID, baseFee, gasLimit); err != tt.want {
+			t.Errorf("ApplyTransaction(%s) error = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+
+	tx := newPricedTestTx(t, bc, validatorA, validatorZ, 0, baseFee+tip)
+	got, err := ApplyTransaction(st, tx, bc.chainID, baseFee, gasLimit)
+	if err != nil {
+		t.Fatalf("ApplyTransaction() error = %v", err)
+	}
+
+	if got != tx.Gas()*tip {
+		t.Errorf("ApplyTransaction() tip = %d, want %d", got, tx.Gas()*tip)
+	}
+
+	// The sender pays the base fee and the tip, the recipient only gets
+	// the value.
+	assertStateBalance(t, st, validatorA, funds-tx.Value().Uint64()-tx.Gas()*(baseFee+tip))
+	assertStateBalance(t, st, validatorZ, tx.Value().Uint64())
+
+	if nonce, _ := st.GetNonce(validatorA); nonce != 1 {
+		t.Errorf("GetNonce() = %d, want 1", nonce)
+	}
+
+	// The same transaction cannot be applied twice.
+	if _, err := ApplyTransaction(st, tx, bc.chainID, baseFee, gasLimit); err != ErrInvalidNonce {
+		t.Errorf("ApplyTransaction(replayed) error = %v, want %v", err, ErrInvalidNonce)
+	}
+}
+
+func TestStateProcessor_Process(t *testing.T) {
+	bc, db := newTestBlockchain(t)
+	reward := pow.BlockReward.Uint64()
+
+	genesis, err := bc.GetLatestBlock()
+	if err != nil {
+		t.Fatalf("GetLatestBlock() error = %v", err)
+	}
+
+	const funds = 1_000_000_000
+	parentState := state.New(db, genesis)
+	if err := parentState.AddBalance(validatorA, funds); err != nil {
+		t.Fatalf("AddBalance() error = %v", err)
+	}
+
+	header := block.Header{
+		Height:    genesis.Height() + 1,
+		Prev:      genesis.Hash(),
+		Timestamp: genesis.Timestamp() + 2,
+		Validator: validatorB,
+		Data:      []byte{},
+		GasTarget: bc.GasTarget(),
+		BaseFee:   gaspool.CalcBaseFee(genesis.GasTarget(), genesis.GasUsed(), genesis.BaseFee()),
+	}
+
+	const tip = 2
+	tx := newPricedTestTx(t, bc, validatorA, validatorZ, 0, header.BaseFee+tip)
+	txs := []transaction.Transaction{*tx}
+
+	header.GasUsed = tx.Gas()
+	header.GasTip = tx.Gas() * tip
+
+	wrongGasUsed := header
+	wrongGasUsed.GasUsed++
+	if _, err := bc.processor.Process(block.NewBlock(wrongGasUsed, txs), parentState); err != ErrInvalidGasUsed {
+		t.Errorf("Process(wrong gas used) error = %v, want %v", err, ErrInvalidGasUsed)
+	}
+
+	wrongGasTip := header
+	wrongGasTip.GasTip++
+	if _, err := bc.processor.Process(block.NewBlock(wrongGasTip, txs), parentState); err != ErrInvalidGasTip {
+		t.Errorf("Process(wrong gas tip) error = %v, want %v", err, ErrInvalidGasTip)
+	}
+
+	st, err := bc.processor.Process(block.NewBlock(header, txs), parentState)
+	if err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+
+	// The validator gets the reward and the tips, the base fee is burned.
+	assertStateBalance(t, st, validatorB, reward+tx.Gas()*tip)
+	assertStateBalance(t, st, validatorA, funds-tx.Value().Uint64()-tx.Gas()*(header.BaseFee+tip))
+
+	// The parent state is left untouched.
+	assertStateBalance(t, parentState, validatorA, funds)
+	assertStateBalance(t, parentState, validatorB, 0)
+}
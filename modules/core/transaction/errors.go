@@ -0,0 +1,14 @@
+package transaction
+
+import "errors"
+
+var (
+	ErrInvalidHash      = errors.New("invalid transaction hash")
+	ErrInvalidSignature = errors.New("invalid transaction signature")
+	ErrInvalidSender    = errors.New("public key does not match sender")
+	ErrInvalidValue     = errors.New("invalid transaction value")
+
+	ErrUnsupportedVersion = errors.New("unsupported transaction version")
+
+	ErrFeeCapTooLow = errors.New("max fee below base fee")
+)
@@ -34,13 +34,44 @@ func NewTransaction(from common.Address, to common.Address, value *big.Int, data
 		data: *txData,
 	}
 
+	tx, err := calcGas(gasTarget, tx)
+	if err != nil {
+		return nil, err
+	}
+
 	tx.CalcHash()
 
+	return tx, nil
+}
+
+// NewDynamicFeeTransaction returns a transaction paying, per gas, the base
+// fee of its block plus a tip, at most maxTip, as long as the two stay
+// within maxFee.
+func NewDynamicFeeTransaction(from common.Address, to common.Address, value *big.Int, data []byte, nonce uint64, maxFee uint64, maxTip uint64, payload []byte, gasTarget uint64) (*Transaction, error) {
+	txData := &TxData{
+		From:      from,
+		To:        to,
+		Value:     value,
+		Data:      data,
+		Nonce:     nonce,
+		MaxFee:    maxFee,
+		MaxTip:    maxTip,
+		Version:   DynamicFee,
+		Payload:   payload,
+		Timestamp: uint64(time.Now().Unix()),
+	}
+
+	tx := &Transaction{
+		data: *txData,
+	}
+
 	tx, err := calcGas(gasTarget, tx)
 	if err != nil {
 		return nil, err
 	}
 
+	tx.CalcHash()
+
 	return tx, nil
 }
 
@@ -107,24 +138,88 @@ func (t *Transaction) CalcHash() {
 	t.hash = common.BytesToHash(hash)
 }
 
-func (t *Transaction) SignTransaction(signature []byte) *Transaction {
+func (t *Transaction) SignTransaction(signature []byte, pub pec256.PubKey) *Transaction {
 	auxTx := copyTransaction(t)
 	auxTx.data.Signature = signature
+	auxTx.data.PubKey = pub
 
 	return auxTx
 }
 
-func (t *Transaction) VerifyTx(pub pec256.PubKey) (bool, error) {
+// SigningHash returns the hash signed by the sender for chainID. It
+// covers the chain ID and every field except the signature and the public
+// key, so a transaction signed for one chain is invalid on any other.
+func (t *Transaction) SigningHash(chainID uint64) (common.Hash, error) {
+	var prefix []byte
+
+	switch t.data.Version {
+	case Legacy:
+		prefix = legacySigningPrefix
+	case DynamicFee:
+		prefix = dynamicFeeSigningPrefix
+	default:
+		return common.Hash{}, ErrUnsupportedVersion
+	}
+
 	b, err := t.data.marshal()
+	if err != nil {
+		return common.Hash{}, err
+	}
+
+	preimage := make([]byte, 0, len(prefix)+8+len(b))
+	preimage = append(preimage, prefix...)
+	preimage = append(preimage, common.Uint64ToBytes(chainID)...)
+	preimage = append(preimage, b...)
+
+	return common.BytesToHash(crypto.Pm256(preimage)), nil
+}
+
+func (t *Transaction) VerifyTx(pub pec256.PubKey, chainID uint64) (bool, error) {
+	if len(t.data.Signature) != 64 {
+		return false, ErrInvalidSignature
+	}
+
+	h, err := t.SigningHash(chainID)
 	if err != nil {
 		return false, err
 	}
 
-	h := crypto.Pm256(b)
 	r := new(big.Int).SetBytes(t.data.Signature[:32])
 	s := new(big.Int).SetBytes(t.data.Signature[32:])
 
-	return crypto.Verify(common.BytesToHash(h), r, s, pub)
+	return crypto.Verify(h, r, s, pub)
+}
+
+// Verify checks that the attached public key belongs to the sender and
+// that it signed the transaction for chainID.
+func (t *Transaction) Verify(chainID uint64) (bool, error) {
+	if crypto.PubKeyToAddress(t.data.PubKey) != t.data.From {
+		return false, ErrInvalidSender
+	}
+
+	return t.VerifyTx(t.data.PubKey, chainID)
+}
+
+// DecodeTransaction decodes a JSON encoded signed transaction and checks
+// that the hash it carries matches its content.
+func DecodeTransaction(raw []byte) (*Transaction, error) {
+	tx := &Transaction{}
+	if err := json.Unmarshal(raw, tx); err != nil {
+		return nil, err
+	}
+
+	if tx.data.Value == nil {
+		return nil, ErrInvalidValue
+	}
+
+	hash := tx.hash
+	tx.CalcHash()
+
+	if hash != tx.hash {
+		return nil, ErrInvalidHash
+	}
+
+	return tx, nil
 }
 
 func (t *Transaction) Gas() uint64 {
@@ -163,14 +258,58 @@ func (t *Transaction) Signature() []byte {
 	return t.data.Signature
 }
 
-func (t *Transaction) GasTip() uint64 {
-	return t.data.GasTip
+func (t *Transaction) PubKey() pec256.PubKey {
+	return t.data.PubKey
 }
 
 func (t *Transaction) GasPrice() uint64 {
 	return t.data.GasPrice
 }
 
+// MaxFee returns the most the sender pays per gas, base fee included. A
+// Legacy transaction pays its gas price.
+func (t *Transaction) MaxFee() uint64 {
+	if t.data.Version == Legacy {
+		return t.data.GasPrice
+	}
+
+	return t.data.MaxFee
+}
+
+// MaxTip returns the most the validator gets per gas. A Legacy transaction
+// tips whatever its gas price leaves above the base fee.
+func (t *Transaction) MaxTip() uint64 {
+	if t.data.Version == Legacy {
+		return t.data.GasPrice
+	}
+
+	return t.data.MaxTip
+}
+
+// EffectiveTip returns the tip per gas the validator gets in a block with
+// baseFee.
+func (t *Transaction) EffectiveTip(baseFee uint64) (uint64, error) {
+	maxFee := t.MaxFee()
+	if maxFee < baseFee {
+		return 0, ErrFeeCapTooLow
+	}
+
+	return min(t.MaxTip(), maxFee-baseFee), nil
+}
+
+// Cost returns the most the transaction may take from the sender: its
+// value and its gas at the max fee.
+func (t *Transaction) Cost() *big.Int {
+	cost := new(big.Int).SetUint64(t.data.Gas)
+	cost.Mul(cost, new(big.Int).SetUint64(t.MaxFee()))
+
+	if t.data.Value != nil {
+		cost.Add(cost, t.data.Value)
+	}
+
+	return cost
+}
+
 func (t *Transaction) Version() Version {
 	return t.data.Version
 }
@@ -197,6 +336,7 @@ func (t *Transaction) CalcGas(gasTarget uint64) (*Transaction, error) {
 
 func calcGas(gasTarget uint64, tx *Transaction) (*Transaction, error) {
 	aux := copyTransaction(tx)
+	aux.data.Gas = 0
 
 	payloadLen := uint64(len(aux.data.Payload))
 
@@ -207,13 +347,12 @@ func calcGas(gasTarget uint64, tx *Transaction) (*Transaction, error) {
 
 	dataLen := len(data)
 
-	gasCost, gasTip, err := gaspool.CalcGas(gasTarget, tx.data.GasPrice, dataLen, int(payloadLen), tx.data.Value.BitLen())
+	gas, err := gaspool.CalcGas(gasTarget, dataLen, int(payloadLen), tx.data.Value.BitLen())
 	if err != nil {
 		return nil, err
 	}
 
-	aux.data.Gas = gasCost
-	aux.data.GasTip = gasTip
+	aux.data.Gas = gas
 
 	return aux, err
 }
@@ -0,0 +1,129 @@
+package transaction
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	pec256 "github.com/polarysfoundation/pec-256"
+	"github.com/polarysfoundation/polarys-chain/modules/common"
+	"github.com/polarysfoundation/polarys-chain/modules/crypto"
+)
+
+const testChainID = 7
+
+// signTestTransaction signs tx for chainID with priv.
+func signTestTransaction(t *testing.T, tx *Transaction, chainID uint64, priv pec256.PrivKey, pub pec256.PubKey) *Transaction {
+	t.Helper()
+
+	h, err := tx.SigningHash(chainID)
+	if err != nil {
+		t.Fatalf("SigningHash() error = %v", err)
+	}
+
+	r, s, err := crypto.Sign(h, priv)
+	if err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+
+	signature := make([]byte, 64)
+	r.FillBytes(signature[:32])
+	s.FillBytes(signature[32:])
+
+	return tx.SignTransaction(signature, pub)
+}
+
+func newTestTransaction(t *testing.T, from common.Address) *Transaction {
+	t.Helper()
+
+	to := common.BytesToAddress([]byte("receiver_addres"))
+	tx, err := NewTransaction(from, to, big.NewInt(1000), nil, 0, 1, Legacy, nil, 1000000)
+	if err != nil {
+		t.Fatalf("NewTransaction() error = %v", err)
+	}
+
+	return tx
+}
+
+func TestTransaction_VerifyChainID(t *testing.T) {
+	priv, pub := crypto.GenerateKey()
+	tx := signTestTransaction(t, newTestTransaction(t, crypto.PubKeyToAddress(pub)), testChainID, priv, pub)
+
+	if ok, err := tx.Verify(testChainID); err != nil || !ok {
+		t.Fatalf("Verify(signing chain) = %v, %v, want true", ok, err)
+	}
+
+	if ok, _ := tx.Verify(testChainID + 1); ok {
+		t.Errorf("Verify(other chain) = true, want false")
+	}
+}
+
+func TestTransaction_VerifyTampered(t *testing.T) {
+	priv, pub := crypto.GenerateKey()
+	_, otherPub := crypto.GenerateKey()
+
+	tx := signTestTransaction(t, newTestTransaction(t, crypto.PubKeyToAddress(pub)), testChainID, priv, pub)
+
+	// The key no longer belongs to the sender.
+	wrongFrom := copyTransaction(tx)
+	wrongFrom.data.From = crypto.PubKeyToAddress(otherPub)
+	if _, err := wrongFrom.Verify(testChainID); err != ErrInvalidSender {
+		t.Errorf("Verify() with another sender error = %v, want %v", err, ErrInvalidSender)
+	}
+
+	wrongKey := copyTransaction(tx)
+	wrongKey.data.PubKey = otherPub
+	if _, err := wrongKey.Verify(testChainID); err != ErrInvalidSender {
+		t.Errorf("Verify() with another key error = %v, want %v", err, ErrInvalidSender)
+	}
+
+	// Both replaced consistently, the signature still gives it away.
+	both := copyTransaction(tx)
+	both.data.From = crypto.PubKeyToAddress(otherPub)
+	both.data.PubKey = otherPub
+	if ok, _ := both.Verify(testChainID); ok {
+		t.Errorf("Verify() with another sender and key = true, want false")
+	}
+}
+
+func TestTransaction_SigningHashVersion(t *testing.T) {
+	legacy := newTestTransaction(t, common.BytesToAddress([]byte("sender_address_")))
+
+	dynamic := copyTransaction(legacy)
+	dynamic.data.Version = DynamicFee
+
+	legacyHash, err := legacy.SigningHash(testChainID)
+	if err != nil {
+		t.Fatalf("SigningHash(legacy) error = %v", err)
+	}
+
+	dynamicHash, err := dynamic.SigningHash(testChainID)
+	if err != nil {
+		t.Fatalf("SigningHash(dynamic fee) error = %v", err)
+	}
+
+	if legacyHash == dynamicHash {
+		t.Errorf("legacy and dynamic fee transactions share signing hash %v", legacyHash)
+	}
+
+	// The prefix alone separates the versions.
+	body, err := legacy.data.marshal()
+	if err != nil {
+		t.Fatalf("marshal() error = %v", err)
+	}
+
+	for _, prefix := range [][]byte{legacySigningPrefix, dynamicFeeSigningPrefix} {
+		preimage := append(append(append([]byte{}, prefix...), common.Uint64ToBytes(testChainID)...), body...)
+		h := common.BytesToHash(crypto.Pm256(preimage))
+
+		if want := bytes.Equal(prefix, legacySigningPrefix); (h == legacyHash) != want {
+			t.Errorf("SigningHash(legacy) matches prefix %x = %v, want %v", prefix, h == legacyHash, want)
+		}
+	}
+
+	unknown := copyTransaction(legacy)
+	unknown.data.Version = Version(0xff)
+	if _, err := unknown.SigningHash(testChainID); err != ErrUnsupportedVersion {
+		t.Errorf("SigningHash(unknown version) error = %v, want %v", err, ErrUnsupportedVersion)
+	}
+}
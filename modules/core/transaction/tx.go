@@ -4,13 +4,22 @@ import (
 	"encoding/json"
 	"math/big"
 
+	pec256 "github.com/polarysfoundation/pec-256"
 	"github.com/polarysfoundation/polarys-chain/modules/common"
 )
 
 type Version int
 
 var (
-	Legacy Version = 0
+	Legacy     Version = 0
+	DynamicFee Version = 1
+)
+
+// The signing prefixes separate the signing preimage of each transaction
+// version from the other signed payloads.
+var (
+	legacySigningPrefix     = []byte{0xf7}
+	dynamicFeeSigningPrefix = []byte{0xf8}
 )
 
 type TxData struct {
@@ -20,8 +29,10 @@ type TxData struct {
 	Data      []byte         `json:"data"`
 	Nonce     uint64         `json:"nonce"`
 	Signature []byte         `json:"signature"`
-	GasTip    uint64         `json:"gas_tip"`
+	PubKey    pec256.PubKey  `json:"pub_key"`
 	GasPrice  uint64         `json:"gas_price"`
+	MaxFee    uint64         `json:"max_fee"`
+	MaxTip    uint64         `json:"max_tip"`
 	Gas       uint64         `json:"gas"`
 	Version   Version        `json:"version"`
 	Payload   []byte         `json:"payload"`
@@ -35,8 +46,9 @@ func (t *TxData) marshal() ([]byte, error) {
 		Value     *big.Int       `json:"value"`
 		Data      []byte         `json:"data"`
 		Nonce     uint64         `json:"nonce"`
-		GasTip    uint64         `json:"gas_tip"`
 		GasPrice  uint64         `json:"gas_price"`
+		MaxFee    uint64         `json:"max_fee"`
+		MaxTip    uint64         `json:"max_tip"`
 		Gas       uint64         `json:"gas"`
 		Version   Version        `json:"version"`
 		Payload   []byte         `json:"payload"`
@@ -47,8 +59,9 @@ func (t *TxData) marshal() ([]byte, error) {
 		Value:     t.Value,
 		Data:      t.Data,
 		Nonce:     t.Nonce,
-		GasTip:    t.GasTip,
 		GasPrice:  t.GasPrice,
+		MaxFee:    t.MaxFee,
+		MaxTip:    t.MaxTip,
 		Gas:       t.Gas,
 		Version:   t.Version,
 		Payload:   t.Payload,
@@ -0,0 +1,74 @@
+package txpool
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/polarysfoundation/polarys-chain/modules/params"
+)
+
+// Config holds the limits of the pool.
+type Config struct {
+	MinimalGasTip uint64
+	PriceBump     uint64        // gas price increase, in percent, needed to replace a transaction
+	AccountSlots  uint64        // executable transactions kept per account
+	AccountQueue  uint64        // queued transactions kept per account
+	GlobalSlots   uint64        // transactions kept in the pool, executable or queued
+	GlobalQueue   uint64        // queued transactions kept in the pool
+	Lifetime      time.Duration // how long a transaction may wait in the pool
+	Journal       string        // file keeping the local transactions across restarts, none if empty
+	Rejournal     time.Duration // how often the journal is rewritten
+}
+
+var DefaultConfig = Config{
+	PriceBump:    10,
+	AccountSlots: 16,
+	AccountQueue: 64,
+	GlobalSlots:  4096,
+	GlobalQueue:  1024,
+	Lifetime:     3 * time.Hour,
+	Rejournal:    time.Hour,
+}
+
+// NewConfig reads the pool limits from the node configuration, the unset
+// ones keep their default.
+func NewConfig(config *params.Config) Config {
+	c := DefaultConfig
+	c.MinimalGasTip = uint64(config.MinimalGasTip)
+
+	if config.TxPoolPriceBump > 0 {
+		c.PriceBump = uint64(config.TxPoolPriceBump)
+	}
+	if config.TxPoolAccountSlots > 0 {
+		c.AccountSlots = uint64(config.TxPoolAccountSlots)
+	}
+	if config.TxPoolAccountQueue > 0 {
+		c.AccountQueue = uint64(config.TxPoolAccountQueue)
+	}
+	if config.TxPoolGlobalSlots > 0 {
+		c.GlobalSlots = uint64(config.TxPoolGlobalSlots)
+	}
+	if config.TxPoolGlobalQueue > 0 {
+		c.GlobalQueue = uint64(config.TxPoolGlobalQueue)
+	}
+	if config.TxPoolLifetime > 0 {
+		c.Lifetime = time.Duration(config.TxPoolLifetime) * time.Second
+	}
+	if config.TxPoolRejournal > 0 {
+		c.Rejournal = time.Duration(config.TxPoolRejournal) * time.Second
+	}
+
+	// A relative journal lives in the data directory, next to the database.
+	c.Journal = config.TxPoolJournal
+	if c.Journal != "" && !filepath.IsAbs(c.Journal) {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			c.Journal = ""
+		} else {
+			c.Journal = filepath.Join(home, ".polarys", c.Journal)
+		}
+	}
+
+	return c
+}
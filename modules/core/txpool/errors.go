@@ -1,8 +1,47 @@
 package txpool
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+
+	"github.com/polarysfoundation/polarys-chain/modules/common"
+	"github.com/polarysfoundation/polarys-chain/modules/core/transaction"
+)
 
 var (
 	ErrNotFound     = errors.New("txpool not found")
 	ErrAlreadyExist = errors.New("tx already exist")
+
+	ErrAlreadyKnown       = errors.New("tx already confirmed")
+	ErrInvalidSignature   = errors.New("invalid signature")
+	ErrNonceTooLow        = errors.New("nonce too low")
+	ErrQueueFull          = errors.New("transaction queue full")
+	ErrAccountLimit       = errors.New("too many executable transactions for account")
+	ErrUnderpriced        = errors.New("transaction underpriced for a full pool")
+	ErrReplaceUnderpriced = errors.New("replacement transaction underpriced")
+	ErrJournal            = errors.New("transaction journal failed")
+	ErrInsufficientFunds  = errors.New("insufficient funds for value plus gas")
+	ErrInvalidValue       = errors.New("invalid value")
+	ErrInvalidGas         = errors.New("gas does not match intrinsic cost")
+	ErrFeeCapTooLow       = transaction.ErrFeeCapTooLow
+	ErrTipAboveFeeCap     = errors.New("max tip above max fee")
+	ErrGasTipTooLow       = errors.New("gas tip below pool minimum")
 )
+
+// RejectionError reports why a transaction was not accepted into the pool.
+type RejectionError struct {
+	Hash   common.Hash
+	Reason error
+}
+
+func (e *RejectionError) Error() string {
+	return fmt.Sprintf("transaction %s rejected: %v", e.Hash.CXID(), e.Reason)
+}
+
+func (e *RejectionError) Unwrap() error {
+	return e.Reason
+}
+
+func reject(hash common.Hash, reason error) error {
+	return &RejectionError{Hash: hash, Reason: reason}
+}
@@ -0,0 +1,151 @@
+package txpool
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/polarysfoundation/polarys-chain/modules/core/transaction"
+)
+
+// journal keeps the transactions submitted to this node in a file, one
+// JSON encoded transaction per line, so they survive a restart. New
+// transactions are appended, and the file is periodically rewritten with
+// the ones still in the pool.
+type journal struct {
+	path   string
+	writer *os.File
+}
+
+func newJournal(path string) *journal {
+	return &journal{path: path}
+}
+
+// load decodes the journal and hands every transaction to add. A journal
+// that does not exist yet is empty. It returns how many transactions were
+// read and how many of them add refused.
+func (j *journal) load(add func(tx transaction.Transaction) error) (int, int, error) {
+	file, err := os.Open(j.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, 0, nil
+	}
+
+	if err != nil {
+		return 0, 0, err
+	}
+	defer file.Close()
+
+	var total, dropped int
+
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 1 {
+			total++
+
+			// A line cut by a crash is dropped like an invalid transaction.
+			tx, decodeErr := transaction.DecodeTransaction(line)
+			if decodeErr != nil || add(*tx) != nil {
+				dropped++
+			}
+		}
+
+		if errors.Is(err, io.EOF) {
+			return total, dropped, nil
+		}
+
+		if err != nil {
+			return total, dropped, err
+		}
+	}
+}
+
+// insert appends tx to the journal.
+func (j *journal) insert(tx *transaction.Transaction) error {
+	if j.writer == nil {
+		if err := j.open(); err != nil {
+			return err
+		}
+	}
+
+	b, err := tx.MarshalJSON()
+	if err != nil {
+		return err
+	}
+
+	_, err = j.writer.Write(append(b, '\n'))
+	return err
+}
+
+// rotate replaces the journal with txs.
+func (j *journal) rotate(txs []transaction.Transaction) error {
+	if err := j.close(); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(j.path), os.ModePerm); err != nil {
+		return err
+	}
+
+	tmp := j.path + ".new"
+	file, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+
+	writer := bufio.NewWriter(file)
+	for i := range txs {
+		b, err := txs[i].MarshalJSON()
+		if err != nil {
+			file.Close()
+			return err
+		}
+
+		if _, err := writer.Write(append(b, '\n')); err != nil {
+			file.Close()
+			return err
+		}
+	}
+
+	if err := writer.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp, j.path); err != nil {
+		return err
+	}
+
+	return j.open()
+}
+
+func (j *journal) open() error {
+	if err := os.MkdirAll(filepath.Dir(j.path), os.ModePerm); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(j.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+
+	j.writer = file
+	return nil
+}
+
+func (j *journal) close() error {
+	if j.writer == nil {
+		return nil
+	}
+
+	err := j.writer.Close()
+	j.writer = nil
+
+	return err
+}
@@ -0,0 +1,119 @@
+package txpool
+
+import (
+	"sort"
+
+	"github.com/polarysfoundation/polarys-chain/modules/core/transaction"
+)
+
+// txList holds the transactions of one sender, at most one per nonce,
+// sorted by nonce.
+type txList struct {
+	items  map[uint64]*transaction.Transaction
+	nonces []uint64
+}
+
+func newTxList() *txList {
+	return &txList{items: make(map[uint64]*transaction.Transaction)}
+}
+
+func (l *txList) Len() int {
+	return len(l.nonces)
+}
+
+func (l *txList) Get(nonce uint64) (*transaction.Transaction, bool) {
+	tx, ok := l.items[nonce]
+	return tx, ok
+}
+
+// Put inserts tx and returns the transaction it replaced, if any.
+func (l *txList) Put(tx *transaction.Transaction) *transaction.Transaction {
+	nonce := tx.Nonce()
+	old, ok := l.items[nonce]
+	l.items[nonce] = tx
+	if ok {
+		return old
+	}
+
+	i := sort.Search(len(l.nonces), func(i int) bool { return l.nonces[i] >= nonce })
+	l.nonces = append(l.nonces, 0)
+	copy(l.nonces[i+1:], l.nonces[i:])
+	l.nonces[i] = nonce
+
+	return nil
+}
+
+// Remove deletes the transaction with nonce and reports whether it was
+// there.
+func (l *txList) Remove(nonce uint64) bool {
+	if _, ok := l.items[nonce]; !ok {
+		return false
+	}
+	delete(l.items, nonce)
+
+	i := sort.Search(len(l.nonces), func(i int) bool { return l.nonces[i] >= nonce })
+	l.nonces = append(l.nonces[:i], l.nonces[i+1:]...)
+
+	return true
+}
+
+// Last returns the transaction with the highest nonce.
+func (l *txList) Last() (*transaction.Transaction, bool) {
+	if len(l.nonces) == 0 {
+		return nil, false
+	}
+
+	return l.items[l.nonces[len(l.nonces)-1]], true
+}
+
+// Flatten returns the transactions sorted by nonce.
+func (l *txList) Flatten() []*transaction.Transaction {
+	txs := make([]*transaction.Transaction, 0, len(l.nonces))
+	for _, nonce := range l.nonces {
+		txs = append(txs, l.items[nonce])
+	}
+
+	return txs
+}
+
+// Forward removes and returns the transactions with a nonce below nonce.
+func (l *txList) Forward(nonce uint64) []*transaction.Transaction {
+	return l.Filter(func(tx *transaction.Transaction) bool {
+		return tx.Nonce() < nonce
+	})
+}
+
+// Filter removes and returns the transactions matching fn.
+func (l *txList) Filter(fn func(tx *transaction.Transaction) bool) []*transaction.Transaction {
+	removed := make([]*transaction.Transaction, 0)
+	kept := l.nonces[:0]
+
+	for _, nonce := range l.nonces {
+		if tx := l.items[nonce]; fn(tx) {
+			removed = append(removed, tx)
+			delete(l.items, nonce)
+			continue
+		}
+		kept = append(kept, nonce)
+	}
+	l.nonces = kept
+
+	return removed
+}
+
+// Ready removes and returns the transactions following start without a
+// gap, at most limit of them.
+func (l *txList) Ready(start uint64, limit int) []*transaction.Transaction {
+	ready := make([]*transaction.Transaction, 0)
+	for nonce := start; len(ready) < limit; nonce++ {
+		tx, ok := l.items[nonce]
+		if !ok {
+			break
+		}
+
+		ready = append(ready, tx)
+		l.Remove(nonce)
+	}
+
+	return ready
+}
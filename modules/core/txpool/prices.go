@@ -0,0 +1,153 @@
+package txpool
+
+import (
+	"container/heap"
+
+	"github.com/polarysfoundation/polarys-chain/modules/common"
+	"github.com/polarysfoundation/polarys-chain/modules/core/transaction"
+)
+
+// cheaper reports whether a bids less than b: a lower max fee, or the same
+// max fee and a lower max tip.
+func cheaper(a, b *transaction.Transaction) bool {
+	if a.MaxFee() != b.MaxFee() {
+		return a.MaxFee() < b.MaxFee()
+	}
+
+	return a.MaxTip() < b.MaxTip()
+}
+
+// tips reports whether a pays the validator less than b in a block with
+// baseFee. A transaction that cannot pay the base fee tips nothing.
+func tips(a, b *transaction.Transaction, baseFee uint64) bool {
+	tipA, _ := a.EffectiveTip(baseFee)
+	tipB, _ := b.EffectiveTip(baseFee)
+
+	return tipA < tipB
+}
+
+// priceHeap is a min-heap of transactions, cheapest first.
+type priceHeap []*transaction.Transaction
+
+func (h priceHeap) Len() int           { return len(h) }
+func (h priceHeap) Less(i, j int) bool { return cheaper(h[i], h[j]) }
+func (h priceHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+
+func (h *priceHeap) Push(x any) {
+	*h = append(*h, x.(*transaction.Transaction))
+}
+
+func (h *priceHeap) Pop() any {
+	old := *h
+	n := len(old)
+	tx := old[n-1]
+	old[n-1] = nil
+	*h = old[:n-1]
+
+	return tx
+}
+
+// pricedList orders every transaction of the pool by price so the cheapest
+// can be evicted. Removed transactions are only dropped from the heap when
+// they reach its top, or when too many of them accumulate.
+type pricedList struct {
+	all    map[common.Hash]*transaction.Transaction
+	items  priceHeap
+	stales int
+}
+
+func newPricedList(all map[common.Hash]*transaction.Transaction) *pricedList {
+	return &pricedList{all: all}
+}
+
+func (l *pricedList) Put(tx *transaction.Transaction) {
+	heap.Push(&l.items, tx)
+}
+
+// Removed notes that count transactions left the pool.
+func (l *pricedList) Removed(count int) {
+	l.stales += count
+	if l.stales > len(l.items)/4 {
+		l.reheap()
+	}
+}
+
+// Cheapest returns the cheapest transaction of the pool, nil if it is
+// empty.
+func (l *pricedList) Cheapest() *transaction.Transaction {
+	for len(l.items) > 0 {
+		tx := l.items[0]
+		if l.all[tx.Hash()] == tx {
+			return tx
+		}
+
+		heap.Pop(&l.items)
+		l.stales--
+	}
+
+	return nil
+}
+
+func (l *pricedList) reheap() {
+	l.items = make(priceHeap, 0, len(l.all))
+	for _, tx := range l.all {
+		l.items = append(l.items, tx)
+	}
+	heap.Init(&l.items)
+	l.stales = 0
+}
+
+// byPriceAndNonce orders the transactions of several senders for a block
+// with baseFee: the highest effective tip first, but never a transaction
+// before a lower nonce of the same sender. Each group must be sorted by
+// nonce.
+func byPriceAndNonce(groups [][]*transaction.Transaction, baseFee uint64) []transaction.Transaction {
+	heads := &headHeap{baseFee: baseFee}
+	total := 0
+	for _, group := range groups {
+		if len(group) > 0 {
+			heads.groups = append(heads.groups, group)
+			total += len(group)
+		}
+	}
+	heap.Init(heads)
+
+	txs := make([]transaction.Transaction, 0, total)
+	for heads.Len() > 0 {
+		group := heads.groups[0]
+		txs = append(txs, *group[0])
+
+		if len(group) > 1 {
+			heads.groups[0] = group[1:]
+			heap.Fix(heads, 0)
+		} else {
+			heap.Pop(heads)
+		}
+	}
+
+	return txs
+}
+
+// headHeap is a max-heap of nonce sorted groups keyed by the effective tip
+// of their first transaction.
+type headHeap struct {
+	groups  [][]*transaction.Transaction
+	baseFee uint64
+}
+
+func (h *headHeap) Len() int           { return len(h.groups) }
+func (h *headHeap) Less(i, j int) bool { return tips(h.groups[j][0], h.groups[i][0], h.baseFee) }
+func (h *headHeap) Swap(i, j int)      { h.groups[i], h.groups[j] = h.groups[j], h.groups[i] }
+
+func (h *headHeap) Push(x any) {
+	h.groups = append(h.groups, x.([]*transaction.Transaction))
+}
+
+func (h *headHeap) Pop() any {
+	old := h.groups
+	n := len(old)
+	group := old[n-1]
+	h.groups = old[:n-1]
+
+	return group
+}
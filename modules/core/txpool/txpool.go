@@ -1,6 +1,8 @@
 package txpool
 
 import (
+	"errors"
+	"fmt"
 	"math/big"
 	"sort"
 	"sync"
@@ -14,27 +16,38 @@ import (
 	"github.com/polarysfoundation/polarys-chain/modules/prydb"
 )
 
+// TxPool keeps, for every sender, the executable transactions whose
+// nonces follow the account nonce without a gap, and queues the others
+// until the gap is filled. Every transaction is also ordered by price so
+// the cheapest ones are evicted when the pool is full.
 type TxPool struct {
-	poolAddress         common.Address
-	executor            common.Address
-	poolBalance         uint64
-	timestamp           uint64
-	consensusProof      []byte
-	minimalGasTip       uint64
-	gasProcessed        *big.Int
-	nextEpoch           uint64
-	pendingTransactions []transaction.Transaction
-	sealedTransactions  []transaction.Transaction
-	totalTransactions   uint64
-	gaspool             *gaspool.GasPool
-	latestBlock         *block.Block
-	hash                common.Hash
+	poolAddress       common.Address
+	executor          common.Address
+	poolBalance       uint64
+	timestamp         uint64
+	consensusProof    []byte
+	gasProcessed      *big.Int
+	nextEpoch         uint64
+	totalTransactions uint64
+	gaspool           *gaspool.GasPool
+	latestBlock       *block.Block
+	chainID           uint64
+	hash              common.Hash
+	config            Config
+
+	pending  map[common.Address]*txList // executable transactions
+	queue    map[common.Address]*txList // transactions waiting for a nonce gap to be filled
+	all      map[common.Hash]*transaction.Transaction
+	arrivals map[common.Hash]time.Time // when this node received each transaction
+	priced   *pricedList
+	locals   map[common.Hash]struct{} // transactions submitted to this node
+	journal  *journal
 
 	db    *prydb.Database
 	mutex sync.RWMutex
 }
 
-func InitTxPool(db *prydb.Database, executor common.Address, minimalGasTip uint64, consensusProof []byte, gaspool *gaspool.GasPool, latestBlock *block.Block) (*TxPool, error) {
+func InitTxPool(db *prydb.Database, executor common.Address, config Config, consensusProof []byte, gaspool *gaspool.GasPool, latestBlock *block.Block, chainID uint64) (*TxPool, error) {
 
 	h := crypto.Pm256(executor.Bytes())
 	poolAddress := crypto.CreateAddress(executor, 0, common.BytesToHash(h))
@@ -45,21 +58,25 @@ func InitTxPool(db *prydb.Database, executor common.Address, minimalGasTip uint6
 		}
 
 		pool := &TxPool{
-			poolAddress:         poolAddress,
-			executor:            executor,
-			db:                  db,
-			poolBalance:         balance,
-			timestamp:           timestamp,
-			nextEpoch:           epoch,
-			consensusProof:      consensusProof,
-			minimalGasTip:       minimalGasTip,
-			gaspool:             gaspool,
-			latestBlock:         latestBlock,
-			hash:                hash,
-			pendingTransactions: make([]transaction.Transaction, 0),
-			sealedTransactions:  make([]transaction.Transaction, 0),
-			gasProcessed:        big.NewInt(0),
-			totalTransactions:   0,
+			poolAddress:       poolAddress,
+			executor:          executor,
+			db:                db,
+			poolBalance:       balance,
+			timestamp:         timestamp,
+			nextEpoch:         epoch,
+			consensusProof:    consensusProof,
+			config:            config,
+			gaspool:           gaspool,
+			latestBlock:       latestBlock,
+			chainID:           chainID,
+			hash:              hash,
+			gasProcessed:      big.NewInt(0),
+			totalTransactions: 0,
+		}
+		pool.initLists()
+
+		if err := pool.loadJournal(); err != nil {
+			return nil, err
 		}
 
 		return pool, nil
@@ -75,21 +92,25 @@ func InitTxPool(db *prydb.Database, executor common.Address, minimalGasTip uint6
 	h2 := crypto.Pm256(buff)
 
 	pool := &TxPool{
-		poolAddress:         poolAddress,
-		executor:            executor,
-		db:                  db,
-		poolBalance:         0,
-		timestamp:           uint64(time.Now().Unix()),
-		pendingTransactions: make([]transaction.Transaction, 0),
-		sealedTransactions:  make([]transaction.Transaction, 0),
-		minimalGasTip:       minimalGasTip,
-		gasProcessed:        big.NewInt(0),
-		nextEpoch:           uint64(time.Now().Unix()) + uint64(threeDaysEpoch),
-		consensusProof:      consensusProof,
-		gaspool:             gaspool,
-		hash:                common.BytesToHash(h2),
-		totalTransactions:   0,
-		latestBlock:         latestBlock,
+		poolAddress:       poolAddress,
+		executor:          executor,
+		db:                db,
+		poolBalance:       0,
+		timestamp:         uint64(time.Now().Unix()),
+		config:            config,
+		gasProcessed:      big.NewInt(0),
+		nextEpoch:         uint64(time.Now().Unix()) + uint64(threeDaysEpoch),
+		consensusProof:    consensusProof,
+		gaspool:           gaspool,
+		hash:              common.BytesToHash(h2),
+		totalTransactions: 0,
+		latestBlock:       latestBlock,
+		chainID:           chainID,
+	}
+	pool.initLists()
+
+	if err := pool.loadJournal(); err != nil {
+		return nil, err
 	}
 
 	return pool, nil
@@ -99,107 +120,667 @@ func (t *TxPool) PoolAddress() common.Address {
 	return t.poolAddress
 }
 
+func (t *TxPool) initLists() {
+	t.pending = make(map[common.Address]*txList)
+	t.queue = make(map[common.Address]*txList)
+	t.all = make(map[common.Hash]*transaction.Transaction)
+	t.arrivals = make(map[common.Hash]time.Time)
+	t.priced = newPricedList(t.all)
+	t.locals = make(map[common.Hash]struct{})
+}
+
+// loadJournal replays the journal of local transactions through the
+// normal validation, then rewrites it with the ones accepted.
+func (t *TxPool) loadJournal() error {
+	if t.config.Journal == "" {
+		return nil
+	}
+
+	t.journal = newJournal(t.config.Journal)
+
+	_, _, err := t.journal.load(func(tx transaction.Transaction) error {
+		if err := t.add(tx); err != nil {
+			return err
+		}
+
+		t.locals[tx.Hash()] = struct{}{}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return t.journal.rotate(t.localTransactions())
+}
+
+// AddTransaction validates tx and adds it to the pool. A transaction
+// carrying the next nonce of its account is executable, one with a higher
+// nonce is queued. A transaction for a nonce the pool already holds
+// replaces it if it bids enough more. When the pool is full the cheapest
+// transaction is evicted, provided tx pays more.
 func (t *TxPool) AddTransaction(tx transaction.Transaction) error {
 	t.mutex.Lock()
 	defer t.mutex.Unlock()
 
-	for _, existingTx := range t.pendingTransactions {
-		if existingTx.Hash() == tx.Hash() {
-			return ErrAlreadyExist
-		}
+	return t.add(tx)
+}
+
+// AddLocal adds a transaction submitted to this node and records it in the
+// journal. An error wrapping ErrJournal means the transaction is in the
+// pool but will not survive a restart.
+func (t *TxPool) AddLocal(tx transaction.Transaction) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if err := t.add(tx); err != nil {
+		return err
 	}
 
-	t.pendingTransactions = append(t.pendingTransactions, tx)
+	t.locals[tx.Hash()] = struct{}{}
+
+	if t.journal == nil {
+		return nil
+	}
+
+	if err := t.journal.insert(&tx); err != nil {
+		return fmt.Errorf("%w: %v", ErrJournal, err)
+	}
 
 	return nil
 }
 
-func (t *TxPool) GetTransactions() []transaction.Transaction {
-	t.mutex.RLock()
-	defer t.mutex.RUnlock()
+// Rejournal rewrites the journal with the local transactions still in the
+// pool.
+func (t *TxPool) Rejournal() error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	for hash := range t.locals {
+		if _, ok := t.all[hash]; !ok {
+			delete(t.locals, hash)
+		}
+	}
 
-	return t.sealedTransactions
+	if t.journal == nil {
+		return nil
+	}
+
+	return t.journal.rotate(t.localTransactions())
 }
 
-func (t *TxPool) ProcessTransaction() {
+// Close releases the journal.
+func (t *TxPool) Close() error {
 	t.mutex.Lock()
 	defer t.mutex.Unlock()
 
-	for {
-		seal := make([]byte, 96)
-		h1 := crypto.Pm256(t.poolAddress.Bytes())
-		h2 := crypto.Pm256(t.executor.Bytes())
-		copy(seal[:32], h1)
-		copy(seal[32:], h2)
+	if t.journal == nil {
+		return nil
+	}
+
+	return t.journal.close()
+}
+
+// Config returns the limits of the pool.
+func (t *TxPool) Config() Config {
+	return t.config
+}
+
+// add validates tx and inserts it. It must be called with the pool lock
+// held.
+func (t *TxPool) add(tx transaction.Transaction) error {
+	hash := tx.Hash()
+	if _, ok := t.all[hash]; ok {
+		return reject(hash, ErrAlreadyExist)
+	}
+
+	if err := t.validateTx(&tx); err != nil {
+		return err
+	}
 
-		transactions := t.copyTransactions()
+	// Only checked on entry: a transaction priced out later by a rising
+	// base fee waits in the pool for it to fall again.
+	if tx.MaxFee() < t.gaspool.BaseFee() {
+		return reject(hash, ErrFeeCapTooLow)
+	}
 
-		if len(transactions) == 0 {
-			break
+	from := tx.From()
+	if old, ok := t.lookup(from, tx.Nonce()); ok {
+		if !t.outbids(&tx, old) {
+			return reject(hash, ErrReplaceUnderpriced)
 		}
 
-		sort.Slice(transactions, func(i, j int) bool {
-			return transactions[i].Gas() > transactions[j].Gas()
-		})
+		t.replace(old, &tx)
+		return nil
+	}
 
-		for _, tx := range transactions {
-			copy(seal[64:], tx.Hash().Bytes())
-			sealHash := crypto.Pm256(seal)
-			tx.SealTx(common.BytesToHash(sealHash))
+	next, err := t.nextNonce(from)
+	if err != nil {
+		return err
+	}
 
-			balance, _ := t.db.BalanceAt(tx.From(), t.latestBlock)
-			if balance < tx.Value().Uint64()+tx.Gas() {
-				continue
-			} else {
-				newBalance := balance - tx.Value().Uint64() - tx.GasPrice()
-				t.db.UpdateBalance(tx.From(), newBalance, t.latestBlock)
+	if err := t.checkLimits(&tx, tx.Nonce() == next); err != nil {
+		return err
+	}
+
+	if uint64(len(t.all)) >= t.config.GlobalSlots {
+		cheapest := t.priced.Cheapest()
+		if cheapest == nil || !cheaper(cheapest, &tx) {
+			return reject(hash, ErrUnderpriced)
+		}
+		t.remove(cheapest)
+
+		// The evicted transaction may have opened a gap below tx.
+		if next, err = t.nextNonce(from); err != nil {
+			return err
+		}
+	}
+
+	t.all[hash] = &tx
+	t.arrivals[hash] = time.Now()
+	t.priced.Put(&tx)
+
+	if tx.Nonce() != next {
+		listOf(t.queue, from).Put(&tx)
+		return nil
+	}
 
-				receiverBalance, _ := t.db.BalanceAt(tx.To(), t.latestBlock)
+	listOf(t.pending, from).Put(&tx)
+	t.promote(from)
 
-				if receiverBalance == 0 {
-					t.db.InitAccountState(tx.To(), []byte{}, t.latestBlock)
-				}
+	return nil
+}
+
+// Nonce returns the next nonce of address, counting the executable
+// transactions the pool holds on top of the account state.
+func (t *TxPool) Nonce(address common.Address) (uint64, error) {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
 
-				receiverBalance += tx.Value().Uint64()
-				t.db.UpdateBalance(tx.To(), receiverBalance, t.latestBlock)
+	return t.nextNonce(address)
+}
 
+// GetTransactions returns the sealed transactions ready for a block, the
+// highest effective tip at the base fee of the next block first, and in
+// nonce order for each sender.
+func (t *TxPool) GetTransactions() []transaction.Transaction {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+
+	groups := make([][]*transaction.Transaction, 0, len(t.pending))
+	for _, list := range t.pending {
+		sealed := make([]*transaction.Transaction, 0, list.Len())
+		for _, tx := range list.Flatten() {
+			// Past an unsealed transaction the sender has a gap.
+			if tx.Seal() == (common.Hash{}) {
+				break
 			}
+			sealed = append(sealed, tx)
+		}
 
-			gasCost, err := t.gaspool.CalcGas(tx.Bytes(), len(tx.Payload()), tx.Value().BitLen(), tx.GasTip())
-			if err != nil {
-				continue
+		groups = append(groups, sealed)
+	}
+
+	return byPriceAndNonce(groups, t.gaspool.BaseFee())
+}
+
+// Pending returns every executable transaction held by the pool, sealed or
+// not. Queued transactions are left out.
+func (t *TxPool) Pending() []transaction.Transaction {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+
+	return flatten(t.pending)
+}
+
+// Queued returns the transactions waiting for a nonce gap to be filled.
+func (t *TxPool) Queued() []transaction.Transaction {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+
+	return flatten(t.queue)
+}
+
+func (t *TxPool) Has(hash common.Hash) bool {
+	_, ok := t.Get(hash)
+	return ok
+}
+
+func (t *TxPool) Get(hash common.Hash) (*transaction.Transaction, bool) {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+
+	tx, ok := t.all[hash]
+	if !ok {
+		return nil, false
+	}
+
+	cpy := *tx
+	return &cpy, true
+}
+
+// ProcessTransaction seals the executable transactions that are still
+// valid against the latest block so the worker can include them. An
+// invalid or expired transaction is dropped and the ones of its sender
+// behind it go back to the queue. Balances are only changed when a block
+// is applied by the state processor.
+func (t *TxPool) ProcessTransaction() {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	seal := make([]byte, 96)
+	h1 := crypto.Pm256(t.poolAddress.Bytes())
+	h2 := crypto.Pm256(t.executor.Bytes())
+	copy(seal[:32], h1)
+	copy(seal[32:], h2)
+
+	now := time.Now()
+	for from, list := range t.pending {
+		for _, tx := range list.Flatten() {
+			if t.expired(tx, now) || t.validateTx(tx) != nil {
+				t.remove(tx)
+				break
 			}
 
-			if gasCost != tx.Gas() {
+			if tx.Seal() != (common.Hash{}) {
 				continue
 			}
 
-			t.sealedTransactions = append(t.sealedTransactions, tx)
+			copy(seal[64:], tx.Hash().Bytes())
+			tx.SealTx(common.BytesToHash(crypto.Pm256(seal)))
 		}
 
-		t.pendingTransactions = make([]transaction.Transaction, 0)
+		if list.Len() == 0 {
+			delete(t.pending, from)
+		}
 	}
 }
 
+// Update moves the pool to a new head, after a block was committed or a
+// reorg. The transactions the block included, or whose nonce it used, are
+// dropped, and the remaining ones are checked again against the new state
+// so the worker never picks a confirmed or unpayable transaction.
 func (t *TxPool) Update(latestBlock *block.Block) error {
 	t.mutex.Lock()
 	defer t.mutex.Unlock()
 
 	t.latestBlock = latestBlock
+	t.gaspool.SetHead(latestBlock.GasTarget(), latestBlock.GasUsed(), latestBlock.BaseFee())
+
+	for _, tx := range latestBlock.Transactions() {
+		if pooled, ok := t.all[tx.Hash()]; ok {
+			t.remove(pooled)
+		}
+	}
+
+	return t.reset()
+}
+
+// reset walks the transactions of every account from its nonce at the
+// latest block. Stale and expired ones are dropped, the ones following the
+// account nonce without a gap are executable and the others are queued. A
+// reorg may turn executable transactions into queued ones and the other
+// way round. It must be called with the pool lock held.
+func (t *TxPool) reset() error {
+	senders := make(map[common.Address]struct{}, len(t.pending)+len(t.queue))
+	for from := range t.pending {
+		senders[from] = struct{}{}
+	}
+	for from := range t.queue {
+		senders[from] = struct{}{}
+	}
+
+	now := time.Now()
+	for from := range senders {
+		nonce, err := t.stateNonce(from)
+		if err != nil {
+			return err
+		}
+
+		merged := listOf(t.queue, from)
+		if pending, ok := t.pending[from]; ok {
+			for _, tx := range pending.Flatten() {
+				merged.Put(tx)
+			}
+			delete(t.pending, from)
+		}
+
+		t.drop(merged.Forward(nonce))
+		t.drop(merged.Filter(func(tx *transaction.Transaction) bool {
+			return t.expired(tx, now)
+		}))
+
+		if ready := merged.Ready(nonce, int(t.config.AccountSlots)); len(ready) > 0 {
+			pending := listOf(t.pending, from)
+			for _, tx := range ready {
+				pending.Put(tx)
+			}
+		}
+
+		// Queued transactions are validated again once executable.
+		for _, tx := range merged.Flatten() {
+			tx.SealTx(common.Hash{})
+		}
+
+		if err := t.revalidate(from); err != nil {
+			return err
+		}
+
+		if merged.Len() == 0 {
+			delete(t.queue, from)
+		}
+	}
+
+	return nil
+}
+
+// revalidate checks the transactions of from against the latest state.
+// The first executable transaction that became invalid is dropped, and it
+// and the ones the balance of from can no longer pay for send the
+// transactions behind them back to the queue. Invalid queued transactions
+// are dropped. It must be called with the pool lock held.
+func (t *TxPool) revalidate(from common.Address) error {
+	balance, err := t.db.BalanceAt(from, t.latestBlock)
+	if err != nil && !errors.Is(err, prydb.ErrAccountNotFound) {
+		return err
+	}
+
+	if list, ok := t.pending[from]; ok {
+		available := new(big.Int).SetUint64(balance)
+		for _, tx := range list.Flatten() {
+			invalid, err := t.invalid(tx)
+			if err != nil {
+				return err
+			}
+
+			if invalid {
+				t.remove(tx)
+				break
+			}
+
+			available.Sub(available, tx.Cost())
+			if available.Sign() < 0 {
+				t.demote(from, tx.Nonce())
+				break
+			}
+		}
+	}
+
+	if list, ok := t.queue[from]; ok {
+		var failure error
+		t.drop(list.Filter(func(tx *transaction.Transaction) bool {
+			invalid, err := t.invalid(tx)
+			if err != nil && failure == nil {
+				failure = err
+			}
+			return invalid
+		}))
+
+		if failure != nil {
+			return failure
+		}
+	}
 
 	return nil
 }
 
+// invalid reports whether tx is rejected against the latest state. Errors
+// that are not a rejection are returned.
+func (t *TxPool) invalid(tx *transaction.Transaction) (bool, error) {
+	err := t.validateTx(tx)
+	if err == nil {
+		return false, nil
+	}
+
+	var rejected *RejectionError
+	if errors.As(err, &rejected) {
+		return true, nil
+	}
+
+	return false, err
+}
+
 func (t *TxPool) AddBalance(amount uint64) {
 	t.mutex.Lock()
 	defer t.mutex.Unlock()
 	t.poolBalance += amount
 }
 
-func (t *TxPool) copyTransactions() []transaction.Transaction {
+// lookup returns the transaction of from with nonce, executable or queued.
+func (t *TxPool) lookup(from common.Address, nonce uint64) (*transaction.Transaction, bool) {
+	if list, ok := t.pending[from]; ok {
+		if tx, ok := list.Get(nonce); ok {
+			return tx, true
+		}
+	}
+
+	if list, ok := t.queue[from]; ok {
+		if tx, ok := list.Get(nonce); ok {
+			return tx, true
+		}
+	}
+
+	return nil, false
+}
+
+// outbids reports whether tx raises both the max fee and the max tip of
+// old by at least the configured price bump.
+func (t *TxPool) outbids(tx, old *transaction.Transaction) bool {
+	return t.bumped(tx.MaxFee(), old.MaxFee()) && t.bumped(tx.MaxTip(), old.MaxTip())
+}
+
+func (t *TxPool) bumped(price, old uint64) bool {
+	threshold := new(big.Int).SetUint64(old)
+	threshold.Mul(threshold, new(big.Int).SetUint64(100+t.config.PriceBump))
+	threshold.Div(threshold, big.NewInt(100))
+
+	return price > old && new(big.Int).SetUint64(price).Cmp(threshold) >= 0
+}
+
+// replace puts tx in the slot of old, a transaction of the same sender
+// with the same nonce. It must be called with the pool lock held.
+func (t *TxPool) replace(old, tx *transaction.Transaction) {
+	if list, ok := t.pending[tx.From()]; ok {
+		if _, ok := list.Get(tx.Nonce()); ok {
+			list.Put(tx)
+		}
+	}
+
+	if list, ok := t.queue[tx.From()]; ok {
+		if _, ok := list.Get(tx.Nonce()); ok {
+			list.Put(tx)
+		}
+	}
+
+	delete(t.all, old.Hash())
+	delete(t.arrivals, old.Hash())
+	t.priced.Removed(1)
+
+	t.all[tx.Hash()] = tx
+	t.arrivals[tx.Hash()] = time.Now()
+	t.priced.Put(tx)
+}
+
+// checkLimits rejects tx when the account of its sender, or the queue of
+// the whole pool, has no slot left. It must be called with the pool lock
+// held.
+func (t *TxPool) checkLimits(tx *transaction.Transaction, executable bool) error {
+	if executable {
+		if list, ok := t.pending[tx.From()]; ok && uint64(list.Len()) >= t.config.AccountSlots {
+			return reject(tx.Hash(), ErrAccountLimit)
+		}
+		return nil
+	}
+
+	if list, ok := t.queue[tx.From()]; ok && uint64(list.Len()) >= t.config.AccountQueue {
+		return reject(tx.Hash(), ErrQueueFull)
+	}
+
+	queued := 0
+	for _, list := range t.queue {
+		queued += list.Len()
+	}
 
-	transactions := make([]transaction.Transaction, len(t.pendingTransactions))
-	copy(transactions, t.pendingTransactions)
+	if uint64(queued) >= t.config.GlobalQueue {
+		return reject(tx.Hash(), ErrQueueFull)
+	}
+
+	return nil
+}
+
+// remove drops tx from the pool. When tx was executable, the transactions
+// of its sender behind it go back to the queue. It must be called with the
+// pool lock held.
+func (t *TxPool) remove(tx *transaction.Transaction) {
+	from := tx.From()
+
+	delete(t.all, tx.Hash())
+	delete(t.arrivals, tx.Hash())
+	t.priced.Removed(1)
+
+	if list, ok := t.pending[from]; ok && list.Remove(tx.Nonce()) {
+		if list.Len() == 0 {
+			delete(t.pending, from)
+		}
+		t.demote(from, tx.Nonce()+1)
+		return
+	}
+
+	if list, ok := t.queue[from]; ok && list.Remove(tx.Nonce()) && list.Len() == 0 {
+		delete(t.queue, from)
+	}
+}
+
+// drop forgets txs, already taken out of their list. It must be called
+// with the pool lock held.
+func (t *TxPool) drop(txs []*transaction.Transaction) {
+	for _, tx := range txs {
+		delete(t.all, tx.Hash())
+		delete(t.arrivals, tx.Hash())
+	}
+	t.priced.Removed(len(txs))
+}
+
+// demote moves the executable transactions of from with a nonce from
+// nonce on back to the queue. It must be called with the pool lock held.
+func (t *TxPool) demote(from common.Address, nonce uint64) {
+	list, ok := t.pending[from]
+	if !ok {
+		return
+	}
+
+	demoted := list.Filter(func(tx *transaction.Transaction) bool {
+		return tx.Nonce() >= nonce
+	})
+
+	if len(demoted) > 0 {
+		queue := listOf(t.queue, from)
+		for _, tx := range demoted {
+			tx.SealTx(common.Hash{})
+			queue.Put(tx)
+		}
+	}
+
+	if list.Len() == 0 {
+		delete(t.pending, from)
+	}
+}
+
+// promote moves the queued transactions of from that follow its last
+// executable one without a gap to the executable ones, within the account
+// slots. It must be called with the pool lock held.
+func (t *TxPool) promote(from common.Address) {
+	queue, ok := t.queue[from]
+	if !ok {
+		return
+	}
+
+	pending := listOf(t.pending, from)
+	last, ok := pending.Last()
+	if !ok {
+		return
+	}
+
+	free := int(t.config.AccountSlots) - pending.Len()
+	for _, tx := range queue.Ready(last.Nonce()+1, free) {
+		pending.Put(tx)
+	}
+
+	if queue.Len() == 0 {
+		delete(t.queue, from)
+	}
+}
+
+// nextNonce returns the nonce the next executable transaction of address
+// must carry: its nonce at the latest block plus its executable
+// transactions. It must be called with the pool lock held.
+func (t *TxPool) nextNonce(address common.Address) (uint64, error) {
+	if list, ok := t.pending[address]; ok {
+		if last, ok := list.Last(); ok {
+			return last.Nonce() + 1, nil
+		}
+	}
+
+	return t.stateNonce(address)
+}
+
+func (t *TxPool) stateNonce(address common.Address) (uint64, error) {
+	nonce, err := t.db.NonceAt(address, t.latestBlock)
+	if err != nil && !errors.Is(err, prydb.ErrAccountNotFound) {
+		return 0, err
+	}
+
+	return nonce, nil
+}
+
+// expired reports whether tx waited in the pool longer than its lifetime.
+// The wait counts from when this node received tx: the timestamp the
+// sender sets is not checked by anyone. It must be called with the pool
+// lock held.
+func (t *TxPool) expired(tx *transaction.Transaction, now time.Time) bool {
+	arrived, ok := t.arrivals[tx.Hash()]
+	if !ok {
+		return false
+	}
+
+	return arrived.Add(t.config.Lifetime).Before(now)
+}
+
+// localTransactions returns the local transactions still in the pool, in
+// nonce order so replaying them needs no queue. It must be called with
+// the pool lock held.
+func (t *TxPool) localTransactions() []transaction.Transaction {
+	txs := make([]transaction.Transaction, 0, len(t.locals))
+	for hash := range t.locals {
+		if tx, ok := t.all[hash]; ok {
+			txs = append(txs, *tx)
+		}
+	}
+
+	sort.Slice(txs, func(i, j int) bool {
+		return txs[i].Nonce() < txs[j].Nonce()
+	})
+
+	return txs
+}
+
+// listOf returns the list of from in lists, creating it if needed.
+func listOf(lists map[common.Address]*txList, from common.Address) *txList {
+	list, ok := lists[from]
+	if !ok {
+		list = newTxList()
+		lists[from] = list
+	}
+
+	return list
+}
+
+func flatten(lists map[common.Address]*txList) []transaction.Transaction {
+	txs := make([]transaction.Transaction, 0)
+	for _, list := range lists {
+		for _, tx := range list.Flatten() {
+			txs = append(txs, *tx)
+		}
+	}
 
-	return transactions
+	return txs
 }
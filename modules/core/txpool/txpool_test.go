@@ -0,0 +1,187 @@
+package txpool
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/polarysfoundation/polarys-chain/modules/common"
+	"github.com/polarysfoundation/polarys-chain/modules/core/gaspool"
+	"github.com/polarysfoundation/polarys-chain/modules/core/transaction"
+)
+
+func newTestTx(t *testing.T, from byte, nonce, price uint64) *transaction.Transaction {
+	t.Helper()
+
+	sender := common.BytesToAddress([]byte{from})
+
+	// Pm256 ignores most of inputs whose length is 120 to 127 modulo 128:
+	// pad the data until the encoding avoids those lengths so every
+	// transaction gets its own hash.
+	for data := []byte{}; ; data = append(data, 0) {
+		tx, err := transaction.NewTransaction(sender, common.Address{}, big.NewInt(1), data, nonce, price, transaction.Legacy, nil, gaspool.InitGasPool().MaxGasTarget())
+		if err != nil {
+			t.Fatalf("NewTransaction() error = %v", err)
+		}
+
+		if len(tx.Bytes())%128 < 120 {
+			return tx
+		}
+	}
+}
+
+func TestTxList(t *testing.T) {
+	list := newTxList()
+	for _, nonce := range []uint64{5, 2, 3, 7} {
+		list.Put(newTestTx(t, 1, nonce, 100))
+	}
+
+	if old := list.Put(newTestTx(t, 1, 3, 200)); old == nil || old.GasPrice() != 100 {
+		t.Errorf("Put(nonce 3) replaced %v, want the transaction priced 100", old)
+	}
+
+	nonces := func(txs []*transaction.Transaction) []uint64 {
+		n := make([]uint64, 0, len(txs))
+		for _, tx := range txs {
+			n = append(n, tx.Nonce())
+		}
+		return n
+	}
+
+	if got := nonces(list.Flatten()); len(got) != 4 || got[0] != 2 || got[1] != 3 || got[2] != 5 || got[3] != 7 {
+		t.Errorf("Flatten() nonces = %v, want [2 3 5 7]", got)
+	}
+
+	if got := nonces(list.Forward(3)); len(got) != 1 || got[0] != 2 {
+		t.Errorf("Forward(3) nonces = %v, want [2]", got)
+	}
+
+	if got := nonces(list.Ready(3, 10)); len(got) != 1 || got[0] != 3 {
+		t.Errorf("Ready(3) nonces = %v, want [3]", got)
+	}
+
+	if last, ok := list.Last(); !ok || last.Nonce() != 7 {
+		t.Errorf("Last() = %v, want nonce 7", last)
+	}
+
+	odd := list.Filter(func(tx *transaction.Transaction) bool { return tx.Nonce()%2 == 1 })
+	if got := nonces(odd); len(got) != 2 || got[0] != 5 || got[1] != 7 {
+		t.Errorf("Filter(odd) nonces = %v, want [5 7]", got)
+	}
+
+	if list.Len() != 0 {
+		t.Errorf("Len() = %d, want 0", list.Len())
+	}
+}
+
+func TestByPriceAndNonce(t *testing.T) {
+	// The second transaction of sender 1 pays the most but waits for its
+	// cheap first one.
+	groups := [][]*transaction.Transaction{
+		{newTestTx(t, 1, 0, 100), newTestTx(t, 1, 1, 900)},
+		{newTestTx(t, 2, 0, 500), newTestTx(t, 2, 1, 50)},
+		{newTestTx(t, 3, 4, 300)},
+	}
+
+	want := []struct {
+		price uint64
+		nonce uint64
+	}{
+		{500, 0}, {300, 4}, {100, 0}, {900, 1}, {50, 1},
+	}
+
+	txs := byPriceAndNonce(groups, 0)
+	if len(txs) != len(want) {
+		t.Fatalf("byPriceAndNonce() = %d transactions, want %d", len(txs), len(want))
+	}
+
+	for i, w := range want {
+		if txs[i].GasPrice() != w.price || txs[i].Nonce() != w.nonce {
+			t.Errorf("transaction %d = price %d nonce %d, want price %d nonce %d", i, txs[i].GasPrice(), txs[i].Nonce(), w.price, w.nonce)
+		}
+	}
+}
+
+func TestByPriceAndNonce_EffectiveTip(t *testing.T) {
+	sender := common.BytesToAddress([]byte{1})
+	newTx := func(maxFee, maxTip uint64) *transaction.Transaction {
+		tx, err := transaction.NewDynamicFeeTransaction(sender, common.Address{}, big.NewInt(1), nil, 0, maxFee, maxTip, nil, gaspool.InitGasPool().MaxGasTarget())
+		if err != nil {
+			t.Fatalf("NewDynamicFeeTransaction() error = %v", err)
+		}
+		return tx
+	}
+
+	// At a low base fee generous tips the most, at a high one its max fee
+	// leaves little room for a tip and capped comes first.
+	generous := newTx(60, 60)
+	capped := newTx(100, 50)
+	groups := [][]*transaction.Transaction{{generous}, {capped}}
+
+	tests := []struct {
+		baseFee uint64
+		first   *transaction.Transaction
+	}{
+		{0, generous},
+		{40, capped},
+	}
+
+	for _, tt := range tests {
+		txs := byPriceAndNonce(groups, tt.baseFee)
+		if len(txs) != 2 || txs[0].MaxFee() != tt.first.MaxFee() {
+			t.Errorf("byPriceAndNonce(base fee %d) first max fee = %d, want %d", tt.baseFee, txs[0].MaxFee(), tt.first.MaxFee())
+		}
+	}
+
+	if _, err := capped.EffectiveTip(101); !errors.Is(err, transaction.ErrFeeCapTooLow) {
+		t.Errorf("EffectiveTip(above max fee) error = %v, want %v", err, transaction.ErrFeeCapTooLow)
+	}
+}
+
+func TestPricedList(t *testing.T) {
+	all := make(map[common.Hash]*transaction.Transaction)
+	priced := newPricedList(all)
+
+	cheap := newTestTx(t, 1, 0, 100)
+	mid := newTestTx(t, 2, 0, 200)
+	high := newTestTx(t, 3, 0, 300)
+	for _, tx := range []*transaction.Transaction{high, cheap, mid} {
+		all[tx.Hash()] = tx
+		priced.Put(tx)
+	}
+
+	if got := priced.Cheapest(); got != cheap {
+		t.Errorf("Cheapest() price = %d, want 100", got.GasPrice())
+	}
+
+	delete(all, cheap.Hash())
+	priced.Removed(1)
+
+	if got := priced.Cheapest(); got != mid {
+		t.Errorf("Cheapest() after removal price = %d, want 200", got.GasPrice())
+	}
+}
+
+func TestTxPool_Expired(t *testing.T) {
+	pool := &TxPool{config: DefaultConfig}
+	pool.initLists()
+
+	tx := newTestTx(t, 1, 0, 100)
+	if pool.expired(tx, time.Now().Add(DefaultConfig.Lifetime+time.Second)) {
+		t.Errorf("expired() = true for a transaction never received")
+	}
+
+	// The lifetime counts from the arrival, whatever timestamp the sender
+	// put in the transaction.
+	received := time.Unix(int64(tx.Timestamp()), 0).Add(-10 * DefaultConfig.Lifetime)
+	pool.arrivals[tx.Hash()] = received
+
+	if pool.expired(tx, received.Add(DefaultConfig.Lifetime)) {
+		t.Errorf("expired() = true at the end of the lifetime")
+	}
+
+	if !pool.expired(tx, received.Add(DefaultConfig.Lifetime+time.Second)) {
+		t.Errorf("expired() = false past the lifetime")
+	}
+}
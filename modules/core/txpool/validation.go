@@ -0,0 +1,62 @@
+package txpool
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/polarysfoundation/polarys-chain/modules/core/transaction"
+	"github.com/polarysfoundation/polarys-chain/modules/prydb"
+)
+
+// validateTx checks a transaction against the pool rules and the account
+// state at the latest block. It must be called with the pool lock held.
+func (t *TxPool) validateTx(tx *transaction.Transaction) error {
+	if tx.Value() == nil || tx.Value().Sign() < 0 || !tx.Value().IsUint64() {
+		return reject(tx.Hash(), ErrInvalidValue)
+	}
+
+	if ok, err := tx.Verify(t.chainID); err != nil || !ok {
+		return reject(tx.Hash(), ErrInvalidSignature)
+	}
+
+	if _, err := t.db.GetTransactionByHash(tx.Hash()); err == nil {
+		return reject(tx.Hash(), ErrAlreadyKnown)
+	}
+
+	expected, err := tx.CalcGas(t.gaspool.MaxGasTarget())
+	if err != nil {
+		return reject(tx.Hash(), err)
+	}
+
+	if expected.Gas() != tx.Gas() {
+		return reject(tx.Hash(), ErrInvalidGas)
+	}
+
+	if tx.MaxTip() > tx.MaxFee() {
+		return reject(tx.Hash(), ErrTipAboveFeeCap)
+	}
+
+	if tx.MaxTip() < t.config.MinimalGasTip {
+		return reject(tx.Hash(), ErrGasTipTooLow)
+	}
+
+	nonce, err := t.stateNonce(tx.From())
+	if err != nil {
+		return err
+	}
+
+	if tx.Nonce() < nonce {
+		return reject(tx.Hash(), ErrNonceTooLow)
+	}
+
+	balance, err := t.db.BalanceAt(tx.From(), t.latestBlock)
+	if err != nil && !errors.Is(err, prydb.ErrAccountNotFound) {
+		return err
+	}
+
+	if tx.Cost().Cmp(new(big.Int).SetUint64(balance)) > 0 {
+		return reject(tx.Hash(), ErrInsufficientFunds)
+	}
+
+	return nil
+}
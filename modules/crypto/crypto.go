@@ -1,6 +1,7 @@
 package crypto
 
 import (
+	"errors"
 	"hash"
 	"log"
 	"math/big"
@@ -12,6 +13,8 @@ import (
 
 var c = pec256.PEC256()
 
+var ErrInvalidPubKey = errors.New("invalid public key")
+
 func Pm256(b []byte) []byte {
 	buf := make([]byte, 32)
 	h := pm256.New256()
@@ -52,6 +55,21 @@ func GenerateSharedKey(priv pec256.PrivKey) pec256.SharedKey {
 	return c.SharedKey(priv)
 }
 
+// SharedSecret returns the Diffie-Hellman secret between a private key and
+// the public key of the other party, hashed with pm256.
+func SharedSecret(priv pec256.PrivKey, pub pec256.PubKey) ([]byte, error) {
+	q := pub.BigInt()
+
+	// Reject the element of order two as well as the out-of-range values.
+	if !c.IsValidPubKey(q) || q.Cmp(new(big.Int).Sub(c.PrimeA, big.NewInt(1))) == 0 {
+		return nil, ErrInvalidPubKey
+	}
+
+	secret := new(big.Int).Exp(q, priv.BigInt(), c.PrimeA)
+
+	return Pm256(secret.Bytes()), nil
+}
+
 func Verify(data common.Hash, r, s *big.Int, pub pec256.PubKey) (bool, error) {
 	return c.Verify(data[:], r, s, pub.BigInt())
 }
@@ -0,0 +1,113 @@
+package crypto
+
+import "github.com/polarysfoundation/polarys-chain/modules/common"
+
+const (
+	merkleLeafPrefix = 0x00
+	merkleNodePrefix = 0x01
+)
+
+// MerkleRoot builds a binary Merkle tree over the leaves with Pm256 and
+// returns its root. Leaves and inner nodes are hashed with different
+// prefixes, and an odd node is carried up unchanged instead of being
+// paired with itself. The root of an empty list is the zero hash.
+func MerkleRoot(leaves []common.Hash) common.Hash {
+	return NewMerkleTree(leaves).Root()
+}
+
+// MerkleTree keeps every level of the tree MerkleRoot builds, so changing
+// a leaf only rehashes the nodes on its path to the root. A tree is never
+// modified once built.
+type MerkleTree struct {
+	levels [][]common.Hash // levels[0] holds the hashed leaves
+}
+
+func NewMerkleTree(leaves []common.Hash) *MerkleTree {
+	if len(leaves) == 0 {
+		return &MerkleTree{}
+	}
+
+	level := make([]common.Hash, len(leaves))
+	for i, leaf := range leaves {
+		level[i] = hashLeaf(leaf)
+	}
+
+	t := &MerkleTree{levels: [][]common.Hash{level}}
+	for len(level) > 1 {
+		next := make([]common.Hash, (len(level)+1)/2)
+		for i := range next {
+			next[i] = hashPair(level, 2*i)
+		}
+
+		t.levels = append(t.levels, next)
+		level = next
+	}
+
+	return t
+}
+
+// Root returns the root of the tree, the zero hash when it is empty.
+func (t *MerkleTree) Root() common.Hash {
+	if len(t.levels) == 0 {
+		return common.Hash{}
+	}
+
+	return t.levels[len(t.levels)-1][0]
+}
+
+// Update returns a tree with the leaves at the given positions replaced.
+// The positions must be within the tree.
+func (t *MerkleTree) Update(leaves map[int]common.Hash) *MerkleTree {
+	if len(leaves) == 0 {
+		return t
+	}
+
+	next := &MerkleTree{levels: make([][]common.Hash, len(t.levels))}
+	for i, level := range t.levels {
+		next.levels[i] = append([]common.Hash(nil), level...)
+	}
+
+	dirty := make(map[int]struct{}, len(leaves))
+	for i, leaf := range leaves {
+		next.levels[0][i] = hashLeaf(leaf)
+		dirty[i] = struct{}{}
+	}
+
+	for depth := 1; depth < len(next.levels); depth++ {
+		parents := make(map[int]struct{}, len(dirty))
+		for i := range dirty {
+			parents[i/2] = struct{}{}
+		}
+
+		for i := range parents {
+			next.levels[depth][i] = hashPair(next.levels[depth-1], 2*i)
+		}
+
+		dirty = parents
+	}
+
+	return next
+}
+
+func hashLeaf(leaf common.Hash) common.Hash {
+	buff := make([]byte, 1+common.HashLen)
+	buff[0] = merkleLeafPrefix
+	copy(buff[1:], leaf.Bytes())
+
+	return common.BytesToHash(Pm256(buff))
+}
+
+// hashPair returns the parent of level[i] and level[i+1], or level[i]
+// itself when it has no sibling.
+func hashPair(level []common.Hash, i int) common.Hash {
+	if i+1 == len(level) {
+		return level[i]
+	}
+
+	buff := make([]byte, 1+2*common.HashLen)
+	buff[0] = merkleNodePrefix
+	copy(buff[1:], level[i].Bytes())
+	copy(buff[1+common.HashLen:], level[i+1].Bytes())
+
+	return common.BytesToHash(Pm256(buff))
+}
@@ -0,0 +1,47 @@
+package crypto
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/polarysfoundation/polarys-chain/modules/common"
+)
+
+func newTestLeaves(n, seed int) []common.Hash {
+	leaves := make([]common.Hash, 0, n)
+	for i := 0; i < n; i++ {
+		leaves = append(leaves, common.BytesToHash(Pm256(big.NewInt(int64(seed*1000+i)).Bytes())))
+	}
+
+	return leaves
+}
+
+func TestMerkleTree_Update(t *testing.T) {
+	for _, n := range []int{1, 2, 3, 5, 8, 13} {
+		leaves := newTestLeaves(n, 0)
+		tree := NewMerkleTree(leaves)
+
+		changed := newTestLeaves(n, 1)
+		updates := map[int]common.Hash{0: changed[0], n - 1: changed[n-1], n / 2: changed[n/2]}
+
+		updated := tree.Update(updates)
+
+		want := append([]common.Hash(nil), leaves...)
+		for i, leaf := range updates {
+			want[i] = leaf
+		}
+
+		if got := updated.Root(); got != MerkleRoot(want) {
+			t.Errorf("%d leaves: Update() root = %v, want %v", n, got, MerkleRoot(want))
+		}
+
+		// The original tree is left untouched.
+		if got := tree.Root(); got != MerkleRoot(leaves) {
+			t.Errorf("%d leaves: root after Update() = %v, want %v", n, got, MerkleRoot(leaves))
+		}
+	}
+
+	if root := NewMerkleTree(nil).Root(); root != (common.Hash{}) {
+		t.Errorf("empty tree root = %v, want zero", root)
+	}
+}
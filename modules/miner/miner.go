@@ -4,6 +4,7 @@ import (
 	pec256 "github.com/polarysfoundation/pec-256"
 	"github.com/polarysfoundation/polarys-chain/modules/common"
 	"github.com/polarysfoundation/polarys-chain/modules/core/block"
+	"github.com/polarysfoundation/polarys-chain/modules/core/consensus"
 )
 
 type wallet interface {
@@ -31,27 +32,42 @@ func (m *Miner) PubKey() (pec256.PubKey, error) {
 	return m.wallet.PubKey(m.address)
 }
 
+// SignBlock signs the block hash for chainID and attaches the public key
+// of the miner, so the signer can be checked from the block alone.
 func (m *Miner) SignBlock(block *block.Block, chainID uint64) (*block.Block, error) {
-	prefix := []byte{0xfb}
-
-	b, err := common.Serialize([]interface{}{
-		prefix,
-		block.Nonce(),
-		block.Timestamp(),
-		block.Size(),
-		block.Difficulty(),
-		block.Height(),
-		block.GasTarget(),
-		chainID,
-	})
+	signature, err := m.wallet.Sign(m.address, block.SigningData(chainID))
 	if err != nil {
 		return nil, err
 	}
 
-	signature, err := m.wallet.Sign(m.address, b)
+	pub, err := m.wallet.PubKey(m.address)
 	if err != nil {
 		return nil, err
 	}
 
-	return block.SignBlock(signature)
+	return block.SignBlock(append(signature, pub.Bytes()...))
+}
+
+// SignAttestation signs the attestation of the miner for the checkpoint
+// blk on chainID.
+func (m *Miner) SignAttestation(blk *block.Block, chainID uint64) (*consensus.Attestation, error) {
+	att := &consensus.Attestation{
+		Height:    blk.Height(),
+		Hash:      blk.Hash(),
+		Validator: m.address,
+	}
+
+	signature, err := m.wallet.Sign(m.address, att.SigningData(chainID))
+	if err != nil {
+		return nil, err
+	}
+
+	pub, err := m.wallet.PubKey(m.address)
+	if err != nil {
+		return nil, err
+	}
+
+	att.Signature = append(signature, pub.Bytes()...)
+
+	return att, nil
 }
@@ -2,21 +2,21 @@ package miner
 
 import (
 	"context"
-	"crypto/rand"
-	"encoding/binary"
-	"math/big"
-	"sort"
+	"errors"
 	"sync"
 	"time"
 
 	"github.com/polarysfoundation/polarys-chain/modules/core"
 	"github.com/polarysfoundation/polarys-chain/modules/core/block"
 	"github.com/polarysfoundation/polarys-chain/modules/core/consensus"
+	"github.com/polarysfoundation/polarys-chain/modules/core/gaspool"
 	"github.com/polarysfoundation/polarys-chain/modules/core/transaction"
 	"github.com/polarysfoundation/polarys-chain/modules/params"
 	"github.com/sirupsen/logrus"
 )
 
+const headPollInterval = 500 * time.Millisecond
+
 type Worker struct {
 	miner      *Miner
 	engine     consensus.Engine
@@ -26,6 +26,8 @@ type Worker struct {
 	cancel     context.CancelFunc
 	wg         sync.WaitGroup
 	log        *logrus.Logger
+
+	lastAttested uint64 // Height of the latest checkpoint attested
 }
 
 func NewWorker(miner *Miner, engine consensus.Engine, blockchain *core.Blockchain, config *params.ChainParams, log *logrus.Logger) *Worker {
@@ -47,7 +49,7 @@ func (w *Worker) Run() {
 	w.log.Info("Worker started")
 	go func() {
 		defer w.wg.Done()
-		ticker := time.NewTicker(time.Duration(w.config.PowEngine.Delay) * time.Second)
+		ticker := time.NewTicker(time.Duration(w.config.Delay()) * time.Second)
 		defer ticker.Stop()
 
 		for {
@@ -56,6 +58,7 @@ func (w *Worker) Run() {
 				w.log.Info("Worker stopped by context")
 				return
 			case <-ticker.C:
+				w.attest()
 				w.tryProduceBlock()
 			}
 		}
@@ -77,13 +80,26 @@ func (w *Worker) tryProduceBlock() {
 		return
 	}
 
-	nonce := calcNewNonce(latest.Nonce(), w.log)
-	if nonce == 0 || nonce == latest.Nonce() || nonce == ^latest.Nonce() {
-		w.log.Warn("Invalid nonce generated", "nonce", nonce)
+	// Validators out of turn wait for the ones before them to time out.
+	earliest, err := w.engine.ProposalTime(latest, w.miner.address)
+	if err != nil {
+		w.log.WithError(err).Debug("Not allowed to propose")
+		return
+	}
+
+	if now := uint64(time.Now().Unix()); now < earliest {
+		w.log.WithFields(logrus.Fields{
+			"height":   latest.Height() + 1,
+			"in_turn":  w.engine.SelectValidator(latest.Height() + 1).CXID(),
+			"wait_for": earliest - now,
+		}).Debug("Waiting for our turn")
 		return
 	}
 
-	selectedTxs, gasUsed, gasTip := w.selectTransactions()
+	// The base fee follows from the parent alone, as every validator
+	// checks.
+	baseFee := gaspool.CalcBaseFee(latest.GasTarget(), latest.GasUsed(), latest.BaseFee())
+	selectedTxs, gasUsed, gasTip := w.selectTransactions(baseFee)
 
 	validatorProof, err := w.engine.ValidatorProof()
 	if err != nil {
@@ -97,27 +113,54 @@ func (w *Worker) tryProduceBlock() {
 		return
 	}
 
-	header := w.buildHeader(latest, nonce, gasUsed, gasTip, validatorProof, consensusProof)
-	newBlock := block.NewBlock(header, selectedTxs)
+	evidence := w.blockchain.PendingEvidence()
 
-	newBlock.CalcHash()
+	header := w.buildHeader(latest, baseFee, gasUsed, gasTip, validatorProof, consensusProof)
+	header.TxRoot = block.CalcTxRoot(selectedTxs)
+	header.EvidenceRoot = block.CalcEvidenceRoot(evidence)
 
-	newBlock, err = w.miner.SignBlock(newBlock, w.config.ChainID)
+	candidate := block.NewBlock(header, selectedTxs)
+	candidate.SetEvidence(evidence)
+
+	st, err := w.blockchain.Process(candidate)
 	if err != nil {
-		w.log.Error("Block signing failed ", "err: ", err)
+		w.log.WithError(err).Error("Failed to process block transactions")
 		return
 	}
 
-	if latest.Height() == newBlock.Height() {
+	header.StateRoot, err = st.Root()
+	if err != nil {
+		w.log.WithError(err).Error("Failed to compute state root")
 		return
 	}
 
-	newBlock, err = w.engine.SealBlock(newBlock)
+	newBlock := block.NewBlock(header, selectedTxs)
+	newBlock.SetEvidence(evidence)
+
+	// Give up on this height as soon as another block is imported on top
+	// of the same parent.
+	ctx, cancel := context.WithCancel(w.ctx)
+	defer cancel()
+	go w.watchHead(ctx, cancel, latest)
+
+	newBlock, err = w.engine.SealBlock(ctx, newBlock)
 	if err != nil {
+		if ctx.Err() != nil {
+			w.log.WithField("height", header.Height).Info("Sealing aborted, chain head changed")
+			return
+		}
+
 		w.log.Error("Block sealing failed ", "err: ", err)
 		return
 	}
 
+	// The signature is not part of the hash, sign the sealed block.
+	newBlock, err = w.miner.SignBlock(newBlock, w.config.ChainID)
+	if err != nil {
+		w.log.Error("Block signing failed ", "err: ", err)
+		return
+	}
+
 	w.log.WithField("difficulty", newBlock.Difficulty()).Info("Block produced")
 
 	if ok, err := w.engine.VerifyBlock(w.blockchain, newBlock); !ok || err != nil {
@@ -137,44 +180,144 @@ func (w *Worker) tryProduceBlock() {
 	}
 }
 
-func (w *Worker) selectTransactions() ([]transaction.Transaction, uint64, uint64) {
+// attest signs an attestation for the latest canonical checkpoint, once,
+// and hands it to the blockchain which gossips it.
+func (w *Worker) attest() {
+	latest, err := w.blockchain.GetLatestBlock()
+	if err != nil {
+		return
+	}
+
+	epoch := w.config.Epoch()
+	if epoch == 0 {
+		return
+	}
+
+	height := latest.Height() - latest.Height()%epoch
+	if height == 0 || height <= w.lastAttested || height <= w.blockchain.FinalizedHeight() {
+		return
+	}
+
+	checkpoint, err := w.blockchain.GetBlockByHeight(height)
+	if err != nil {
+		w.log.WithError(err).WithField("height", height).Error("Failed to get checkpoint")
+		return
+	}
+
+	att, err := w.miner.SignAttestation(checkpoint, w.config.ChainID)
+	if err != nil {
+		w.log.WithError(err).Error("Attestation signing failed")
+		return
+	}
+
+	w.lastAttested = height
+
+	if err := w.blockchain.AddAttestation(att); err != nil {
+		if errors.Is(err, core.ErrNotValidator) {
+			w.log.WithField("height", height).Debug("Not a validator of the checkpoint")
+			return
+		}
+
+		w.log.WithError(err).WithField("height", height).Error("Failed to add attestation")
+		return
+	}
+
+	w.log.WithFields(logrus.Fields{
+		"height": height,
+		"hash":   checkpoint.Hash().String(),
+	}).Info("Checkpoint attested")
+}
+
+// watchHead cancels the seal of a block built on parent once the chain
+// head moves away from parent.
+func (w *Worker) watchHead(ctx context.Context, cancel context.CancelFunc, parent *block.Block) {
+	ticker := time.NewTicker(headPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			head, err := w.blockchain.GetLatestBlock()
+			if err != nil {
+				continue
+			}
+
+			if head.Hash() != parent.Hash() {
+				cancel()
+				return
+			}
+		}
+	}
+}
+
+// selectTransactions picks pool transactions, which the pool returns with
+// the highest effective tip first and in nonce order for each sender, and
+// runs them at baseFee on a copy of the latest state, so transactions that
+// would make the block invalid are left out.
+func (w *Worker) selectTransactions(baseFee uint64) ([]transaction.Transaction, uint64, uint64) {
 	txs := w.blockchain.GetTransactions()
 	w.log.Debug("Selecting transactions ", "count: ", len(txs))
-	sort.Slice(txs, func(i, j int) bool {
-		return txs[i].GasPrice() > txs[j].GasPrice()
-	})
 
 	var (
 		selected []transaction.Transaction
 		gasUsed  uint64
 		gasTip   uint64
-		gasLimit = w.blockchain.GasTarget()
+		gasLimit = gaspool.GasLimit(w.blockchain.GasTarget())
 	)
 
-	for _, tx := range txs {
-		if gasUsed+tx.Gas() > gasLimit {
-			continue
+	st, err := w.blockchain.State()
+	if err != nil {
+		w.log.WithError(err).Error("Failed to load latest state")
+		return nil, 0, 0
+	}
+
+	// Transactions of the same sender must run in nonce order, so keep
+	// retrying the remaining ones while some of them apply.
+	for applied := true; applied && len(txs) > 0; {
+		applied = false
+		remaining := txs[:0]
+
+		for _, tx := range txs {
+			if gasUsed+tx.Gas() > gasLimit {
+				continue
+			}
+
+			candidate := st.Copy()
+			tip, err := core.ApplyTransaction(candidate, &tx, w.config.ChainID, baseFee, gasLimit)
+			if err != nil {
+				remaining = append(remaining, tx)
+				continue
+			}
+
+			st = candidate
+			applied = true
+			gasUsed += tx.Gas()
+			gasTip += tip
+			selected = append(selected, tx)
 		}
-		gasUsed += tx.Gas()
-		gasTip += tx.GasTip()
-		selected = append(selected, tx)
+
+		txs = remaining
 	}
+
 	w.log.Debug("Transactions selected", "selected", len(selected), "gasUsed", gasUsed, "gasTip", gasTip)
 	return selected, gasUsed, gasTip
 }
 
-func (w *Worker) buildHeader(prev *block.Block, nonce, gasUsed, gasTip uint64, valProof, consProof []byte) block.Header {
-	w.log.Debug("Building block header", "prevHeight", prev.Height(), "nonce", nonce)
+func (w *Worker) buildHeader(prev *block.Block, baseFee, gasUsed, gasTip uint64, valProof, consProof []byte) block.Header {
+	w.log.Debug("Building block header", "prevHeight", prev.Height())
 
 	// 1) Creamos un header provisional con la dificultad actual (la iremos ajustando)
 	header := block.Header{
 		Height:         prev.Height() + 1,
 		Prev:           prev.Hash(),
-		Timestamp:      uint64(time.Now().Unix()),
-		Nonce:          nonce,
+		Timestamp:      max(uint64(time.Now().Unix()), prev.Timestamp()+1), // peers reject blocks not after prev
+		Nonce:          0,                                                  // found by SealBlock
 		GasTarget:      w.blockchain.GasTarget(),
 		GasTip:         gasTip,
 		GasUsed:        gasUsed,
+		BaseFee:        baseFee,
 		Difficulty:     prev.Difficulty(), // provisional
 		Data:           []byte{},
 		Validator:      w.miner.address,
@@ -189,26 +332,8 @@ func (w *Worker) buildHeader(prev *block.Block, nonce, gasUsed, gasTip uint64, v
 
 	// 3) Asignamos la dificultad ajustada y recalculamos el tamaño
 	header.Difficulty = newDiff
+	header.TotalDifficulty = prev.TotalDifficulty() + newDiff
 	header.CalculateSize()
 
 	return header
 }
-
-func calcNewNonce(prevNonce uint64, log *logrus.Logger) uint64 {
-	var randBytes [8]byte
-	_, err := rand.Read(randBytes[:])
-	if err != nil {
-		log.Warn("Fallback random nonce due to read error", "err", err)
-		return ^prevNonce ^ uint64(time.Now().UnixNano())
-	}
-	randomPart := binary.LittleEndian.Uint64(randBytes[:])
-	timePart := uint64(time.Now().UnixNano())
-	nonce := prevNonce ^ randomPart ^ timePart
-
-	offset, err := rand.Int(rand.Reader, big.NewInt(1<<16))
-	if err == nil {
-		nonce ^= offset.Uint64()
-	}
-	log.Debug("Nonce generated ", "nonce: ", nonce)
-	return nonce
-}
@@ -0,0 +1,99 @@
+package node
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/polarysfoundation/polarys-chain/modules/core"
+	"github.com/polarysfoundation/polarys-chain/modules/core/consensus"
+)
+
+const (
+	attestationInterval       = 2 * time.Second
+	maxAttestationsPerMessage = 256
+)
+
+// propagateAttestations periodically sends the checkpoint attestations
+// known to the chain to every peer that does not have them yet.
+func (n *Node) propagateAttestations() {
+	for {
+		time.Sleep(attestationInterval)
+
+		atts := n.bc.Attestations()
+		if len(atts) == 0 {
+			continue
+		}
+
+		for cxid, peer := range n.peerSnapshot() {
+			unknown := make([]consensus.Attestation, 0)
+			for i := range atts {
+				if !peer.KnowsAttestation(atts[i].ID()) {
+					unknown = append(unknown, atts[i])
+				}
+			}
+
+			for len(unknown) > 0 {
+				batch := unknown[:min(len(unknown), maxAttestationsPerMessage)]
+				unknown = unknown[len(batch):]
+
+				b, err := json.Marshal(batch)
+				if err != nil {
+					n.log.WithField("client_id", cxid).Error(err)
+					break
+				}
+
+				if err := n.sendPayload(ATTESTATION, b, cxid); err != nil {
+					n.log.WithField("client_id", cxid).Error("Error sending attestations: ", err)
+					break
+				}
+
+				for i := range batch {
+					peer.MarkAttestation(batch[i].ID())
+				}
+			}
+		}
+	}
+}
+
+// handleAttestations hands received attestations to the chain. Forged
+// signatures cost the sender, attestations for checkpoints we do not have
+// yet are dropped and will be sent again.
+func (n *Node) handleAttestations(msg *Message, cxid string) {
+	data, ok := n.readPayload(msg, cxid)
+	if !ok {
+		return
+	}
+
+	var atts []consensus.Attestation
+	if err := json.Unmarshal(data, &atts); err != nil {
+		n.log.WithField("client_id", cxid).Error(err)
+		n.penalise(cxid, offenceInvalidMessage)
+		return
+	}
+
+	if len(atts) > maxAttestationsPerMessage {
+		n.log.WithField("client_id", cxid).Error("Too many attestations in message")
+		n.penalise(cxid, offenceInvalidMessage)
+		return
+	}
+
+	peer := n.peerByCXID(cxid)
+	for i := range atts {
+		att := &atts[i]
+
+		err := n.bc.AddAttestation(att)
+		switch {
+		case err == nil, errors.Is(err, core.ErrAttestationExists), errors.Is(err, core.ErrCheckpointFinalized):
+			if peer != nil {
+				peer.MarkAttestation(att.ID())
+			}
+		case errors.Is(err, consensus.ErrInvalidAttestation), errors.Is(err, consensus.ErrAttesterMismatch):
+			n.log.WithField("client_id", cxid).WithField("height", att.Height).Error("Forged attestation: ", err)
+			n.penalise(cxid, offenceInvalidSignature)
+			return
+		default:
+			n.log.WithField("client_id", cxid).WithField("height", att.Height).Debug("Attestation rejected: ", err)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package node
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+)
+
+const (
+	frameHeaderSize = 4       // big-endian uint32 payload length
+	maxFrameSize    = 8 << 20 // largest payload accepted from a peer
+)
+
+// writeFrame writes payload prefixed with its length. The frame goes out in
+// a single Write so concurrent senders on the same connection never
+// interleave.
+func writeFrame(w io.Writer, payload []byte) error {
+	if len(payload) == 0 {
+		return ErrEmptyFrame
+	}
+
+	if len(payload) > maxFrameSize {
+		return ErrFrameTooLarge
+	}
+
+	buf := make([]byte, frameHeaderSize+len(payload))
+	binary.BigEndian.PutUint32(buf, uint32(len(payload)))
+	copy(buf[frameHeaderSize:], payload)
+
+	_, err := w.Write(buf)
+	return err
+}
+
+// frameReader splits the stream sent by a peer into frames, whatever the
+// way the bytes were split by the transport.
+type frameReader struct {
+	r      *bufio.Reader
+	header [frameHeaderSize]byte
+}
+
+func newFrameReader(r io.Reader) *frameReader {
+	return &frameReader{r: bufio.NewReader(r)}
+}
+
+// readFrame returns the next payload. Any error leaves the stream at an
+// unknown position, so the connection must be dropped.
+func (f *frameReader) readFrame() ([]byte, error) {
+	return f.readFrameLimit(maxFrameSize)
+}
+
+// readFrameLimit is readFrame for payloads of at most limit bytes. The
+// limit is checked before the payload is allocated.
+func (f *frameReader) readFrameLimit(limit uint32) ([]byte, error) {
+	if _, err := io.ReadFull(f.r, f.header[:]); err != nil {
+		return nil, err
+	}
+
+	size := binary.BigEndian.Uint32(f.header[:])
+	if size == 0 {
+		return nil, ErrEmptyFrame
+	}
+
+	if size > limit {
+		return nil, ErrFrameTooLarge
+	}
+
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(f.r, payload); err != nil {
+		return nil, err
+	}
+
+	return payload, nil
+}
@@ -0,0 +1,84 @@
+package node
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestFrameReader_Coalesced(t *testing.T) {
+	var buf bytes.Buffer
+
+	// A block sized frame followed by a small one in the same stream.
+	frames := [][]byte{bytes.Repeat([]byte{0xab}, 64*1024), []byte("ping")}
+
+	for _, frame := range frames {
+		if err := writeFrame(&buf, frame); err != nil {
+			t.Fatalf("writeFrame() error = %v", err)
+		}
+	}
+
+	// Deliver the stream a few bytes at a time.
+	reader := newFrameReader(&chunkedReader{data: buf.Bytes(), chunk: 7})
+
+	for _, want := range frames {
+		got, err := reader.readFrame()
+		if err != nil {
+			t.Fatalf("readFrame() error = %v", err)
+		}
+
+		if !bytes.Equal(got, want) {
+			t.Errorf("readFrame() = %d bytes, want %d bytes", len(got), len(want))
+		}
+	}
+
+	if _, err := reader.readFrame(); !errors.Is(err, io.EOF) {
+		t.Errorf("readFrame() at end of stream error = %v, want %v", err, io.EOF)
+	}
+}
+
+func TestFrameReader_Errors(t *testing.T) {
+	tooLarge := make([]byte, frameHeaderSize)
+	binary.BigEndian.PutUint32(tooLarge, maxFrameSize+1)
+
+	tests := []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{"too large", tooLarge, ErrFrameTooLarge},
+		{"empty", make([]byte, frameHeaderSize), ErrEmptyFrame},
+		{"truncated", []byte{0, 0, 0, 10, 1, 2}, io.ErrUnexpectedEOF},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newFrameReader(bytes.NewReader(tt.data)).readFrame()
+			if !errors.Is(err, tt.want) {
+				t.Errorf("readFrame() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	if err := writeFrame(io.Discard, make([]byte, maxFrameSize+1)); !errors.Is(err, ErrFrameTooLarge) {
+		t.Errorf("writeFrame() error = %v, want %v", err, ErrFrameTooLarge)
+	}
+}
+
+type chunkedReader struct {
+	data  []byte
+	chunk int
+}
+
+func (r *chunkedReader) Read(p []byte) (int, error) {
+	if len(r.data) == 0 {
+		return 0, io.EOF
+	}
+
+	n := copy(p[:min(len(p), r.chunk)], r.data)
+	r.data = r.data[n:]
+
+	return n, nil
+}
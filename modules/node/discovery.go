@@ -0,0 +1,363 @@
+package node
+
+import (
+	"encoding/json"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/polarysfoundation/polarys-chain/modules/common"
+	"github.com/polarysfoundation/polarys-chain/modules/prydb"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	discoveryInterval          = 10 * time.Second
+	defaultTargetOutboundPeers = 8
+	maxKnownPeers              = 1024
+	maxPeersPerMessage         = 64
+	maxDialFailures            = 3
+)
+
+// nodeAddr is a node to dial. The id is optional for bootnodes; when set,
+// the node answering on addr must authenticate with it.
+type nodeAddr struct {
+	id   string
+	addr *net.TCPAddr
+}
+
+// knownPeer is an entry of the peer table.
+type knownPeer struct {
+	addr     *net.TCPAddr
+	lastSeen uint64
+	failures int
+}
+
+// peerAddr is an entry of a PEERS message.
+type peerAddr struct {
+	ID   string `json:"id"`
+	Addr string `json:"addr"`
+}
+
+// parseBootnode reads a bootnode given as host:port or id@host:port.
+func parseBootnode(url string) (nodeAddr, error) {
+	var id string
+	hostport := url
+
+	if i := strings.LastIndex(url, "@"); i >= 0 {
+		id, hostport = url[:i], url[i+1:]
+		if !validNodeID(id) {
+			return nodeAddr{}, ErrInvalidBootnode
+		}
+	}
+
+	addr, err := net.ResolveTCPAddr("tcp", hostport)
+	if err != nil || addr.Port == 0 {
+		return nodeAddr{}, ErrInvalidBootnode
+	}
+
+	return nodeAddr{id: id, addr: addr}, nil
+}
+
+func validNodeID(id string) bool {
+	if !strings.HasPrefix(id, "1cx") {
+		return false
+	}
+
+	b := common.DecodeCXID(id)
+	return len(b) == common.HashLen && common.EncodeToCXID(b) == id
+}
+
+// SetBootnodes sets the nodes dialled when the peer table cannot fill the
+// outbound slots.
+func (n *Node) SetBootnodes(urls []string) error {
+	bootnodes := make([]nodeAddr, 0, len(urls))
+	for _, url := range urls {
+		bn, err := parseBootnode(url)
+		if err != nil {
+			return err
+		}
+
+		bootnodes = append(bootnodes, bn)
+	}
+
+	n.mu.Lock()
+	n.bootnodes = bootnodes
+	n.mu.Unlock()
+
+	return nil
+}
+
+// SetTargetPeers sets the number of outbound connections the node keeps.
+func (n *Node) SetTargetPeers(count int) {
+	n.mu.Lock()
+	n.targetPeers = count
+	n.mu.Unlock()
+}
+
+// loadPeers fills the peer table with the peers saved by a previous run.
+func (n *Node) loadPeers() {
+	records, err := n.db.Peers()
+	if err != nil {
+		n.log.Error("Error loading peer table: ", err)
+		return
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	for _, record := range records {
+		addr, err := net.ResolveTCPAddr("tcp", record.Addr)
+		if err != nil || !validNodeID(record.ID) {
+			continue
+		}
+
+		n.knownPeers[record.ID] = &knownPeer{addr: addr, lastSeen: record.LastSeen}
+	}
+
+	n.log.WithField("peers", len(n.knownPeers)).Info("Peer table loaded")
+}
+
+// addKnownPeer records the listening address of a peer. seen is set when
+// the address was confirmed by a connection, gossiped addresses never
+// replace a known one.
+func (n *Node) addKnownPeer(cxid string, addr *net.TCPAddr, seen bool) {
+	if cxid == n.self.CXID() || addr == nil || addr.Port == 0 || addr.IP.IsUnspecified() {
+		return
+	}
+
+	n.mu.Lock()
+	known, ok := n.knownPeers[cxid]
+	switch {
+	case ok && seen:
+		known.addr = addr
+		known.lastSeen = uint64(time.Now().Unix())
+		known.failures = 0
+	case ok:
+		n.mu.Unlock()
+		return
+	case len(n.knownPeers) >= maxKnownPeers:
+		n.mu.Unlock()
+		return
+	default:
+		known = &knownPeer{addr: addr}
+		if seen {
+			known.lastSeen = uint64(time.Now().Unix())
+		}
+		n.knownPeers[cxid] = known
+	}
+
+	record := &prydb.PeerRecord{ID: cxid, Addr: known.addr.String(), LastSeen: known.lastSeen}
+	n.mu.Unlock()
+
+	if err := n.db.WritePeer(record); err != nil {
+		n.log.WithField("client_id", cxid).Error("Error saving peer: ", err)
+	}
+}
+
+// markDialFailure forgets a peer after too many failed dials.
+func (n *Node) markDialFailure(cxid string) {
+	n.mu.Lock()
+	known, ok := n.knownPeers[cxid]
+	if !ok {
+		n.mu.Unlock()
+		return
+	}
+
+	known.failures++
+	if known.failures < maxDialFailures {
+		n.mu.Unlock()
+		return
+	}
+
+	delete(n.knownPeers, cxid)
+	n.mu.Unlock()
+
+	if err := n.db.DeletePeer(cxid); err != nil {
+		n.log.WithField("client_id", cxid).Error("Error removing peer: ", err)
+	}
+}
+
+// maintainPeers keeps the number of outbound connections at the target,
+// dialling the peer table first and the bootnodes when it runs short, and
+// asks the connected peers for more addresses while below the target.
+func (n *Node) maintainPeers() {
+	for {
+		if missing := n.fillOutbound(); missing > 0 {
+			for cxid := range n.peerSnapshot() {
+				n.mu.Lock()
+				n.peersRequested[cxid] = true
+				n.mu.Unlock()
+
+				if err := n.sendPayload(GET_PEERS, nil, cxid); err != nil {
+					n.log.WithField("client_id", cxid).Error("Error requesting peers: ", err)
+				}
+			}
+		}
+
+		time.Sleep(discoveryInterval)
+	}
+}
+
+// fillOutbound dials new peers and returns how many outbound slots are
+// still free.
+func (n *Node) fillOutbound() int {
+	n.mu.RLock()
+	outbound := 0
+	for _, s := range n.peerConnections {
+		if s.initiator {
+			outbound++
+		}
+	}
+
+	missing := n.targetPeers - outbound
+	if missing <= 0 {
+		n.mu.RUnlock()
+		return 0
+	}
+
+	now := uint64(time.Now().Unix())
+
+	candidates := make([]string, 0)
+	for cxid := range n.knownPeers {
+		if _, ok := n.peerConnections[cxid]; !ok && n.bans[cxid] <= now {
+			candidates = append(candidates, cxid)
+		}
+	}
+
+	sortCandidates(candidates, n.knownPeers)
+
+	dials := make([]nodeAddr, 0, missing)
+	for _, cxid := range candidates {
+		if len(dials) >= missing {
+			break
+		}
+		dials = append(dials, nodeAddr{id: cxid, addr: n.knownPeers[cxid].addr})
+	}
+
+	for _, bn := range n.bootnodes {
+		if len(dials) >= missing {
+			break
+		}
+		if _, ok := n.peerConnections[bn.id]; bn.id != "" && (ok || n.bans[bn.id] > now) {
+			continue
+		}
+		dials = append(dials, bn)
+	}
+	n.mu.RUnlock()
+
+	for _, d := range dials {
+		if err := n.connect(d.addr, d.id); err != nil {
+			n.log.WithFields(logrus.Fields{
+				"client_id": d.id,
+				"addr":      d.addr.String(),
+			}).Debug("Dial failed: ", err)
+
+			if d.id != "" {
+				n.markDialFailure(d.id)
+			}
+			continue
+		}
+
+		missing--
+	}
+
+	return missing
+}
+
+// sortCandidates puts the most reliable and most recently seen peers first.
+func sortCandidates(candidates []string, known map[string]*knownPeer) {
+	sort.Slice(candidates, func(i, j int) bool {
+		a, b := known[candidates[i]], known[candidates[j]]
+		if a.failures != b.failures {
+			return a.failures < b.failures
+		}
+		return a.lastSeen > b.lastSeen
+	})
+}
+
+// handleGetPeers answers with the addresses of the peers we have been
+// connected to, most recent first.
+func (n *Node) handleGetPeers(msg *Message, cxid string) {
+	if _, ok := n.readPayload(msg, cxid); !ok {
+		return
+	}
+
+	now := uint64(time.Now().Unix())
+
+	n.mu.RLock()
+	candidates := make([]string, 0, len(n.knownPeers))
+	for id, known := range n.knownPeers {
+		if id != cxid && known.lastSeen > 0 && n.bans[id] <= now {
+			candidates = append(candidates, id)
+		}
+	}
+
+	sortCandidates(candidates, n.knownPeers)
+
+	addrs := make([]peerAddr, 0, min(len(candidates), maxPeersPerMessage))
+	for _, id := range candidates[:min(len(candidates), maxPeersPerMessage)] {
+		addrs = append(addrs, peerAddr{ID: id, Addr: n.knownPeers[id].addr.String()})
+	}
+	n.mu.RUnlock()
+
+	b, err := json.Marshal(addrs)
+	if err != nil {
+		n.log.WithField("client_id", cxid).Error(err)
+		return
+	}
+
+	if err := n.sendPayload(PEERS, b, cxid); err != nil {
+		n.log.WithField("client_id", cxid).Error("Error sending peers: ", err)
+	}
+}
+
+// handlePeers adds the received addresses to the peer table.
+func (n *Node) handlePeers(msg *Message, cxid string) {
+	data, ok := n.readPayload(msg, cxid)
+	if !ok {
+		return
+	}
+
+	n.mu.Lock()
+	requested := n.peersRequested[cxid]
+	delete(n.peersRequested, cxid)
+	n.mu.Unlock()
+
+	if !requested {
+		n.penalise(cxid, offenceUnrequested)
+		return
+	}
+
+	var addrs []peerAddr
+	if err := json.Unmarshal(data, &addrs); err != nil {
+		n.log.WithField("client_id", cxid).Error(err)
+		n.penalise(cxid, offenceInvalidMessage)
+		return
+	}
+
+	if len(addrs) > maxPeersPerMessage {
+		n.log.WithField("client_id", cxid).Error("Too many peers in message")
+		n.penalise(cxid, offenceInvalidMessage)
+		return
+	}
+
+	for _, pa := range addrs {
+		if !validNodeID(pa.ID) || n.isBanned(pa.ID) {
+			continue
+		}
+
+		host, port, err := net.SplitHostPort(pa.Addr)
+		if err != nil || net.ParseIP(host) == nil {
+			continue
+		}
+
+		addr, err := net.ResolveTCPAddr("tcp", net.JoinHostPort(host, port))
+		if err != nil {
+			continue
+		}
+
+		n.addKnownPeer(pa.ID, addr, false)
+	}
+}
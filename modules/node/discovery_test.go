@@ -0,0 +1,92 @@
+package node
+
+import (
+	"errors"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/polarysfoundation/polarys-chain/modules/common"
+	"github.com/polarysfoundation/polarys-chain/modules/crypto"
+	"github.com/polarysfoundation/polarys-chain/modules/prydb"
+	"github.com/sirupsen/logrus"
+)
+
+func TestParseBootnode(t *testing.T) {
+	id := common.EncodeToCXID(crypto.Pm256([]byte("bootnode")))
+
+	tests := []struct {
+		url    string
+		wantID string
+		want   string
+		err    error
+	}{
+		{"127.0.0.1:5865", "", "127.0.0.1:5865", nil},
+		{id + "@10.0.0.1:30303", id, "10.0.0.1:30303", nil},
+		{"1cxzz@10.0.0.1:30303", "", "", ErrInvalidBootnode},
+		{"10.0.0.1", "", "", ErrInvalidBootnode},
+		{"10.0.0.1:0", "", "", ErrInvalidBootnode},
+	}
+
+	for _, tt := range tests {
+		got, err := parseBootnode(tt.url)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("parseBootnode(%q) error = %v, want %v", tt.url, err, tt.err)
+			continue
+		}
+
+		if err == nil && (got.id != tt.wantID || got.addr.String() != tt.want) {
+			t.Errorf("parseBootnode(%q) = %s@%s, want %s@%s", tt.url, got.id, got.addr, tt.wantID, tt.want)
+		}
+	}
+}
+
+func TestPeerTable_Persisted(t *testing.T) {
+	// The database lives under the home directory.
+	t.Setenv("HOME", t.TempDir())
+
+	db, err := prydb.InitDB()
+	if err != nil {
+		t.Fatalf("InitDB() error = %v", err)
+	}
+
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	n := newTestNode()
+	n.db = db
+	n.log = log
+	n.knownPeers = make(map[string]*knownPeer)
+
+	seen := common.EncodeToCXID(crypto.Pm256([]byte("seen")))
+	gossiped := common.EncodeToCXID(crypto.Pm256([]byte("gossiped")))
+	seenAddr := &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 5865}
+
+	n.addKnownPeer(seen, seenAddr, true)
+	n.addKnownPeer(gossiped, &net.TCPAddr{IP: net.ParseIP("10.0.0.2"), Port: 5865}, false)
+
+	// Gossip does not override an address confirmed by a connection.
+	n.addKnownPeer(seen, &net.TCPAddr{IP: net.ParseIP("10.0.0.3"), Port: 5865}, false)
+
+	// Our own address is never stored.
+	n.addKnownPeer(n.self.CXID(), seenAddr, true)
+
+	for i := 0; i < maxDialFailures; i++ {
+		n.markDialFailure(gossiped)
+	}
+
+	restarted := newTestNode()
+	restarted.db = db
+	restarted.log = log
+	restarted.knownPeers = make(map[string]*knownPeer)
+	restarted.loadPeers()
+
+	if len(restarted.knownPeers) != 1 {
+		t.Fatalf("loaded %d peers, want 1", len(restarted.knownPeers))
+	}
+
+	known, ok := restarted.knownPeers[seen]
+	if !ok || known.addr.String() != seenAddr.String() || known.lastSeen == 0 {
+		t.Errorf("loaded peer = %+v, want %v seen", known, seenAddr)
+	}
+}
@@ -0,0 +1,19 @@
+package node
+
+import "errors"
+
+var (
+	ErrInvalidHashList  = errors.New("invalid hash list")
+	ErrFrameTooLarge    = errors.New("frame exceeds maximum size")
+	ErrEmptyFrame       = errors.New("empty frame")
+	ErrInvalidMessage   = errors.New("invalid message")
+	ErrInvalidFrame     = errors.New("frame failed authentication")
+	ErrInvalidHandshake = errors.New("invalid handshake")
+	ErrVersionMismatch  = errors.New("protocol version mismatch")
+	ErrSelfConnection   = errors.New("connection to self")
+	ErrDuplicateSession = errors.New("peer already connected")
+	ErrInvalidBootnode  = errors.New("invalid bootnode address")
+	ErrUnexpectedPeer   = errors.New("peer id does not match the dialled node")
+	ErrPeerKeyMismatch  = errors.New("message key does not match session")
+	ErrPeerBanned       = errors.New("peer is banned")
+)
@@ -0,0 +1,93 @@
+package node
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/polarysfoundation/polarys-chain/modules/core"
+	"github.com/polarysfoundation/polarys-chain/modules/core/block"
+)
+
+const evidenceInterval = 2 * time.Second
+
+// propagateEvidence periodically sends the double signing evidence waiting
+// to be included to every peer that does not have it yet.
+func (n *Node) propagateEvidence() {
+	for {
+		time.Sleep(evidenceInterval)
+
+		pending := n.bc.PendingEvidence()
+		if len(pending) == 0 {
+			continue
+		}
+
+		for cxid, peer := range n.peerSnapshot() {
+			unknown := make([]block.Evidence, 0)
+			for i := range pending {
+				if !peer.KnowsEvidence(pending[i].Hash()) {
+					unknown = append(unknown, pending[i])
+				}
+			}
+
+			if len(unknown) == 0 {
+				continue
+			}
+
+			b, err := json.Marshal(unknown)
+			if err != nil {
+				n.log.WithField("client_id", cxid).Error(err)
+				continue
+			}
+
+			if err := n.sendPayload(EVIDENCE, b, cxid); err != nil {
+				n.log.WithField("client_id", cxid).Error("Error sending evidence: ", err)
+				continue
+			}
+
+			for i := range unknown {
+				peer.MarkEvidence(unknown[i].Hash())
+			}
+		}
+	}
+}
+
+// handleEvidence checks received evidence and adds it to the pool. The
+// sender is penalised for evidence that does not prove an offence.
+func (n *Node) handleEvidence(msg *Message, cxid string) {
+	data, ok := n.readPayload(msg, cxid)
+	if !ok {
+		return
+	}
+
+	var evidence []block.Evidence
+	if err := json.Unmarshal(data, &evidence); err != nil {
+		n.log.WithField("client_id", cxid).Error(err)
+		n.penalise(cxid, offenceInvalidMessage)
+		return
+	}
+
+	if len(evidence) > core.MaxEvidencePerBlock {
+		n.log.WithField("client_id", cxid).Error("Too much evidence in message")
+		n.penalise(cxid, offenceInvalidMessage)
+		return
+	}
+
+	peer := n.peerByCXID(cxid)
+	for i := range evidence {
+		if peer != nil {
+			peer.MarkEvidence(evidence[i].Hash())
+		}
+
+		err := n.bc.AddEvidence(&evidence[i])
+		switch {
+		case err == nil:
+			n.log.WithField("client_id", cxid).WithField("validator", evidence[i].Offender().CXID()).Info("Double signing evidence received")
+		case errors.Is(err, core.ErrEvidenceExists), errors.Is(err, core.ErrEvidenceTooOld):
+		default:
+			n.log.WithField("client_id", cxid).Error("Invalid evidence: ", err)
+			n.penalise(cxid, offenceInvalidMessage)
+			return
+		}
+	}
+}
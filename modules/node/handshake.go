@@ -0,0 +1,372 @@
+package node
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"math/big"
+	"net"
+	"sync"
+	"time"
+
+	pec256 "github.com/polarysfoundation/pec-256"
+	"github.com/polarysfoundation/polarys-chain/modules/common"
+	"github.com/polarysfoundation/polarys-chain/modules/crypto"
+	"github.com/polarysfoundation/polarys-chain/modules/utils"
+	"golang.org/x/crypto/hkdf"
+)
+
+const (
+	handshakeTimeout   = 10 * time.Second
+	handshakeNonceSize = 32
+	sessionKeySize     = 32
+
+	// Handshake messages are read before the peer is authenticated, so
+	// they get a much smaller limit than session frames.
+	maxHandshakeSize = 4 << 10
+)
+
+var (
+	initiatorLabel = []byte("polarys/handshake/initiator")
+	responderLabel = []byte("polarys/handshake/responder")
+	sessionInfo    = []byte("polarys/session")
+)
+
+// handshakeMsg is exchanged in plain frames before the session starts:
+//
+//	initiator -> responder: version, ephemeral key, nonce
+//	responder -> initiator: version, ephemeral key, nonce, static key, signature
+//	initiator -> responder: static key, signature
+//
+// Each side signs the ephemeral keys and nonces of both sides with its
+// static node key, so a recorded handshake cannot be replayed and the
+// session keys are bound to the authenticated identities.
+type handshakeMsg struct {
+	Version   uint32 `json:"version,omitempty"`
+	Ephemeral []byte `json:"ephemeral,omitempty"`
+	Nonce     []byte `json:"nonce,omitempty"`
+	Static    []byte `json:"static,omitempty"`
+	Signature []byte `json:"signature,omitempty"`
+}
+
+// session is an authenticated connection to a peer. Every frame is sealed
+// with AES-GCM under a per-direction key, using the frame sequence number
+// as nonce: a replayed, dropped or reordered frame fails to open and ends
+// the session.
+type session struct {
+	conn      net.Conn
+	reader    *frameReader
+	remote    pec256.PubKey
+	cxid      string
+	initiator bool
+
+	sendAEAD cipher.AEAD
+	recvAEAD cipher.AEAD
+	sendSeq  uint64
+	recvSeq  uint64
+
+	writeMu sync.Mutex
+}
+
+// initiateHandshake runs the handshake on an outbound connection.
+func (n *Node) initiateHandshake(conn net.Conn) (*session, error) {
+	conn.SetDeadline(time.Now().Add(handshakeTimeout))
+	defer conn.SetDeadline(time.Time{})
+
+	reader := newFrameReader(conn)
+	ephPriv, ephPub := crypto.GenerateKey()
+
+	hello := handshakeMsg{
+		Version:   version,
+		Ephemeral: ephPub.Bytes(),
+		Nonce:     utils.SecureNonce(handshakeNonceSize),
+	}
+
+	if err := writeHandshake(conn, &hello); err != nil {
+		return nil, err
+	}
+
+	reply, err := readHandshake(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	if reply.Version != version {
+		return nil, ErrVersionMismatch
+	}
+
+	if len(reply.Ephemeral) != len(ephPub) || len(reply.Nonce) != handshakeNonceSize || len(reply.Static) != len(ephPub) {
+		return nil, ErrInvalidHandshake
+	}
+
+	transcript := handshakeTranscript(hello.Ephemeral, hello.Nonce, reply.Ephemeral, reply.Nonce)
+	remote := pec256.BytesToPubKey(reply.Static)
+
+	if err := n.checkRemoteKey(remote); err != nil {
+		return nil, err
+	}
+
+	if !verifyHandshake(responderLabel, transcript, remote, reply.Signature) {
+		return nil, ErrInvalidHandshake
+	}
+
+	signature, err := n.signHandshake(initiatorLabel, transcript)
+	if err != nil {
+		return nil, err
+	}
+
+	finish := handshakeMsg{
+		Static:    n.pubKey.Bytes(),
+		Signature: signature,
+	}
+
+	if err := writeHandshake(conn, &finish); err != nil {
+		return nil, err
+	}
+
+	return newSession(conn, reader, remote, true, ephPriv, pec256.BytesToPubKey(reply.Ephemeral), transcript)
+}
+
+// acceptHandshake runs the handshake on an inbound connection.
+func (n *Node) acceptHandshake(conn net.Conn) (*session, error) {
+	conn.SetDeadline(time.Now().Add(handshakeTimeout))
+	defer conn.SetDeadline(time.Time{})
+
+	reader := newFrameReader(conn)
+
+	hello, err := readHandshake(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	if hello.Version != version {
+		return nil, ErrVersionMismatch
+	}
+
+	ephPriv, ephPub := crypto.GenerateKey()
+
+	if len(hello.Ephemeral) != len(ephPub) || len(hello.Nonce) != handshakeNonceSize {
+		return nil, ErrInvalidHandshake
+	}
+
+	reply := handshakeMsg{
+		Version:   version,
+		Ephemeral: ephPub.Bytes(),
+		Nonce:     utils.SecureNonce(handshakeNonceSize),
+		Static:    n.pubKey.Bytes(),
+	}
+
+	transcript := handshakeTranscript(hello.Ephemeral, hello.Nonce, reply.Ephemeral, reply.Nonce)
+
+	reply.Signature, err = n.signHandshake(responderLabel, transcript)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := writeHandshake(conn, &reply); err != nil {
+		return nil, err
+	}
+
+	finish, err := readHandshake(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(finish.Static) != len(ephPub) {
+		return nil, ErrInvalidHandshake
+	}
+
+	remote := pec256.BytesToPubKey(finish.Static)
+
+	if err := n.checkRemoteKey(remote); err != nil {
+		return nil, err
+	}
+
+	if !verifyHandshake(initiatorLabel, transcript, remote, finish.Signature) {
+		return nil, ErrInvalidHandshake
+	}
+
+	return newSession(conn, reader, remote, false, ephPriv, pec256.BytesToPubKey(hello.Ephemeral), transcript)
+}
+
+func (n *Node) checkRemoteKey(remote pec256.PubKey) error {
+	if remote == n.pubKey {
+		return ErrSelfConnection
+	}
+
+	return nil
+}
+
+func (n *Node) signHandshake(label, transcript []byte) ([]byte, error) {
+	r, s, err := crypto.Sign(handshakeDigest(label, transcript, n.pubKey), n.privKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return encodeSignature(r, s), nil
+}
+
+func verifyHandshake(label, transcript []byte, remote pec256.PubKey, signature []byte) bool {
+	if len(signature) != 64 {
+		return false
+	}
+
+	r := new(big.Int).SetBytes(signature[:32])
+	s := new(big.Int).SetBytes(signature[32:])
+
+	ok, err := crypto.Verify(handshakeDigest(label, transcript, remote), r, s, remote)
+	return err == nil && ok
+}
+
+func handshakeTranscript(initEph, initNonce, respEph, respNonce []byte) []byte {
+	transcript := make([]byte, 0, len(initEph)+len(initNonce)+len(respEph)+len(respNonce))
+	transcript = append(transcript, initEph...)
+	transcript = append(transcript, initNonce...)
+	transcript = append(transcript, respEph...)
+	transcript = append(transcript, respNonce...)
+
+	return transcript
+}
+
+func handshakeDigest(label, transcript []byte, static pec256.PubKey) common.Hash {
+	b := make([]byte, 0, len(label)+len(transcript)+len(static))
+	b = append(b, label...)
+	b = append(b, transcript...)
+	b = append(b, static.Bytes()...)
+
+	return common.BytesToHash(crypto.Pm256(b))
+}
+
+// newSession derives the two session keys from the ephemeral
+// Diffie-Hellman secret, salted with the handshake transcript.
+func newSession(conn net.Conn, reader *frameReader, remote pec256.PubKey, initiator bool, ephPriv pec256.PrivKey, remoteEph pec256.PubKey, transcript []byte) (*session, error) {
+	secret, err := crypto.SharedSecret(ephPriv, remoteEph)
+	if err != nil {
+		return nil, ErrInvalidHandshake
+	}
+
+	keys := make([]byte, 2*sessionKeySize)
+	if _, err := io.ReadFull(hkdf.New(crypto.NewPM256, secret, transcript, sessionInfo), keys); err != nil {
+		return nil, err
+	}
+
+	// The first key encrypts from initiator to responder, the second one
+	// the other way round.
+	sendKey, recvKey := keys[:sessionKeySize], keys[sessionKeySize:]
+	if !initiator {
+		sendKey, recvKey = recvKey, sendKey
+	}
+
+	sendAEAD, err := newAEAD(sendKey)
+	if err != nil {
+		return nil, err
+	}
+
+	recvAEAD, err := newAEAD(recvKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &session{
+		conn:      conn,
+		reader:    reader,
+		remote:    remote,
+		cxid:      common.EncodeToCXID(crypto.Pm256(remote.Bytes())),
+		initiator: initiator,
+		sendAEAD:  sendAEAD,
+		recvAEAD:  recvAEAD,
+	}, nil
+}
+
+// WriteMessage seals msg and sends it as one frame.
+func (s *session) WriteMessage(msg *Message) error {
+	b, err := msg.Marshal()
+	if err != nil {
+		return err
+	}
+
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	sealed := s.sendAEAD.Seal(nil, sequenceNonce(s.sendAEAD, s.sendSeq), b, nil)
+	s.sendSeq++
+
+	s.conn.SetWriteDeadline(time.Now().Add(writeDeadline))
+	return writeFrame(s.conn, sealed)
+}
+
+// ReadMessage opens the next frame. It must only be called from the
+// connection read loop.
+func (s *session) ReadMessage() (*Message, error) {
+	sealed, err := s.reader.readFrame()
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := s.recvAEAD.Open(nil, sequenceNonce(s.recvAEAD, s.recvSeq), sealed, nil)
+	if err != nil {
+		return nil, ErrInvalidFrame
+	}
+	s.recvSeq++
+
+	msg := &Message{}
+	if err := msg.Unmarshal(b); err != nil {
+		return nil, ErrInvalidMessage
+	}
+
+	return msg, nil
+}
+
+func (s *session) Close() error {
+	return s.conn.Close()
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+func sequenceNonce(aead cipher.AEAD, seq uint64) []byte {
+	nonce := make([]byte, aead.NonceSize())
+	binary.BigEndian.PutUint64(nonce[len(nonce)-8:], seq)
+
+	return nonce
+}
+
+func writeHandshake(conn net.Conn, msg *handshakeMsg) error {
+	b, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	return writeFrame(conn, b)
+}
+
+func readHandshake(reader *frameReader) (*handshakeMsg, error) {
+	b, err := reader.readFrameLimit(maxHandshakeSize)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := &handshakeMsg{}
+	if err := json.Unmarshal(b, msg); err != nil {
+		return nil, ErrInvalidHandshake
+	}
+
+	return msg, nil
+}
+
+// encodeSignature packs r and s as two 32 byte big-endian integers.
+func encodeSignature(r, s *big.Int) []byte {
+	signature := make([]byte, 64)
+	r.FillBytes(signature[:32])
+	s.FillBytes(signature[32:])
+
+	return signature
+}
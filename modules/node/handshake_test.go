@@ -0,0 +1,130 @@
+package node
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/polarysfoundation/polarys-chain/modules/crypto"
+	"github.com/polarysfoundation/polarys-chain/modules/p2p"
+)
+
+func newTestNode() *Node {
+	priv, pub := crypto.GenerateKey()
+
+	return &Node{
+		self:    p2p.NewPeer(&net.TCPAddr{}, version, pub, uint64(time.Now().Unix())),
+		privKey: priv,
+		pubKey:  pub,
+	}
+}
+
+type handshakeResult struct {
+	s   *session
+	err error
+}
+
+// handshake runs both sides of the handshake over an in-memory connection.
+func handshake(t *testing.T, initiator, responder *Node) (*session, *session, error, error) {
+	t.Helper()
+
+	a, b := net.Pipe()
+	t.Cleanup(func() {
+		a.Close()
+		b.Close()
+	})
+
+	done := make(chan handshakeResult, 1)
+	go func() {
+		s, err := responder.acceptHandshake(b)
+		if err != nil {
+			b.Close()
+		}
+		done <- handshakeResult{s, err}
+	}()
+
+	s, err := initiator.initiateHandshake(a)
+	if err != nil {
+		a.Close()
+	}
+	res := <-done
+
+	return s, res.s, err, res.err
+}
+
+func TestHandshake_Session(t *testing.T) {
+	alice, bob := newTestNode(), newTestNode()
+
+	sa, sb, errA, errB := handshake(t, alice, bob)
+	if errA != nil || errB != nil {
+		t.Fatalf("handshake errors = %v, %v", errA, errB)
+	}
+
+	if sa.remote != bob.pubKey || sb.remote != alice.pubKey {
+		t.Fatalf("sessions are not bound to the static keys")
+	}
+
+	msg, err := NewMessage(PING, []byte("ping"), alice.pubKey)
+	if err != nil {
+		t.Fatalf("NewMessage() error = %v", err)
+	}
+
+	send := func() {
+		go func() {
+			if err := sa.WriteMessage(msg); err != nil {
+				t.Errorf("WriteMessage() error = %v", err)
+			}
+		}()
+	}
+
+	send()
+	got, err := sb.ReadMessage()
+	if err != nil {
+		t.Fatalf("ReadMessage() error = %v", err)
+	}
+
+	data, _ := got.DecodeData()
+	if got.Type != PING || !bytes.Equal(data, []byte("ping")) {
+		t.Errorf("ReadMessage() = type %d data %q, want type %d data %q", got.Type, data, PING, "ping")
+	}
+
+	pubKey, _ := got.DecodePubKey()
+	if pubKey != alice.pubKey {
+		t.Errorf("DecodePubKey() = %v, want %v", pubKey, alice.pubKey)
+	}
+
+	// Sending the same frame again with the same sequence number is a
+	// replay and must be rejected.
+	sa.sendSeq = 0
+	send()
+	if _, err := sb.ReadMessage(); !errors.Is(err, ErrInvalidFrame) {
+		t.Errorf("ReadMessage() of a replayed frame error = %v, want %v", err, ErrInvalidFrame)
+	}
+}
+
+func TestHandshake_Rejects(t *testing.T) {
+	alice, bob := newTestNode(), newTestNode()
+
+	// A node cannot connect to itself.
+	if _, _, errA, _ := handshake(t, alice, alice); !errors.Is(errA, ErrSelfConnection) {
+		t.Errorf("self handshake error = %v, want %v", errA, ErrSelfConnection)
+	}
+
+	// A node claiming bob's static key without his private key fails.
+	mallory := newTestNode()
+	mallory.pubKey = bob.pubKey
+	if _, _, errA, _ := handshake(t, alice, mallory); !errors.Is(errA, ErrInvalidHandshake) {
+		t.Errorf("impersonation error = %v, want %v", errA, ErrInvalidHandshake)
+	}
+
+	// An unauthenticated peer cannot make the node allocate a session
+	// sized frame.
+	header := make([]byte, frameHeaderSize)
+	binary.BigEndian.PutUint32(header, maxHandshakeSize+1)
+	if _, err := readHandshake(newFrameReader(bytes.NewReader(header))); !errors.Is(err, ErrFrameTooLarge) {
+		t.Errorf("oversized handshake error = %v, want %v", err, ErrFrameTooLarge)
+	}
+}
@@ -1,11 +1,7 @@
 package node
 
 import (
-	"crypto/aes"
-	"crypto/cipher"
-	"crypto/rand"
 	"encoding/json"
-	"fmt"
 	"time"
 
 	pec256 "github.com/polarysfoundation/pec-256"
@@ -24,6 +20,16 @@ const (
 	ASK
 	DIFF
 	PEER_INFO
+	TX_HASH
+	TX_ASK
+	GET_HEADERS
+	HEADERS
+	GET_BODIES
+	BODIES
+	GET_PEERS
+	PEERS
+	ATTESTATION
+	EVIDENCE
 )
 
 type Message struct {
@@ -32,56 +38,69 @@ type Message struct {
 	Signature []byte `json:"signature"`
 }
 
-func NewMessage(t Type, d []byte, pubKey pec256.PubKey, aesKey []byte) (*Message, error) {
-	buf := make([]byte, len(d)+32+16+8) // Data length + pubkey + nonce + timestamp
+// Message data is laid out as payload | sender public key | nonce |
+// timestamp. Confidentiality comes from the session the message is sent on.
+const (
+	pubKeySize       = 32
+	messageNonceSize = 16
+	timestampSize    = 8
+	trailerSize      = pubKeySize + messageNonceSize + timestampSize
+)
+
+func NewMessage(t Type, d []byte, pubKey pec256.PubKey) (*Message, error) {
+	if len(d)+trailerSize > maxFrameSize {
+		return nil, ErrFrameTooLarge
+	}
+
+	buf := make([]byte, len(d)+trailerSize)
 	copy(buf, d)
 	copy(buf[len(d):], pubKey[:])
 
-	nonce := utils.SecureNonce(16)
+	nonce := utils.SecureNonce(messageNonceSize)
 	now := uint64(time.Now().Unix())
 
-	copy(buf[len(d)+32:], nonce)
+	copy(buf[len(d)+pubKeySize:], nonce)
 	tBytes := common.Uint64ToBytes(now)
-	copy(buf[len(d)+32+16:], tBytes)
-
-	encrypted, err := encryptPayload(aesKey, buf)
-	if err != nil {
-		return nil, err
-	}
+	copy(buf[len(d)+pubKeySize+messageNonceSize:], tBytes)
 
 	return &Message{
 		Type: t,
-		Data: encrypted,
+		Data: buf,
 	}, nil
 }
 
-func (m *Message) DecryptData(aesKey []byte) (*Message, error) {
-	aux := copyMessage(m)
-	d, err := decryptPayload(aesKey, m.Data)
-	if err != nil {
-		return nil, err
+func (m *Message) DecodeNonce() ([]byte, error) {
+	if len(m.Data) < trailerSize {
+		return nil, ErrInvalidMessage
 	}
-	aux.Data = d
-	m = aux
-	return m, nil
-}
 
-func (m *Message) DecodeNonce() ([]byte, error) {
-	return m.Data[len(m.Data)-16 : 8], nil
+	return m.Data[len(m.Data)-messageNonceSize-timestampSize : len(m.Data)-timestampSize], nil
 }
 
 func (m *Message) DecodeTimestamp() (uint64, error) {
-	return common.BytesToUint64(m.Data[len(m.Data)-8:]), nil
+	if len(m.Data) < trailerSize {
+		return 0, ErrInvalidMessage
+	}
+
+	return common.BytesToUint64(m.Data[len(m.Data)-timestampSize:]), nil
 }
 
 func (m *Message) DecodePubKey() (pec256.PubKey, error) {
 	var pubKey pec256.PubKey
-	copy(pubKey[:], m.Data[len(m.Data)-32:])
+	if len(m.Data) < trailerSize {
+		return pubKey, ErrInvalidMessage
+	}
+
+	copy(pubKey[:], m.Data[len(m.Data)-trailerSize:])
 	return pubKey, nil
 }
 
 func (m *Message) DecodeData() ([]byte, error) {
-	return m.Data[:len(m.Data)-32], nil
+	if len(m.Data) < trailerSize {
+		return nil, ErrInvalidMessage
+	}
+
+	return m.Data[:len(m.Data)-trailerSize], nil
 }
 
 func (m *Message) Marshal() ([]byte, error) {
@@ -112,39 +131,3 @@ func copyMessage(m *Message) *Message {
 		Signature: m.Signature,
 	}
 }
-
-func encryptPayload(aesKey, plaintext []byte) ([]byte, error) {
-	block, err := aes.NewCipher(aesKey)
-	if err != nil {
-		return nil, err
-	}
-	gcm, err := cipher.NewGCM(block)
-	if err != nil {
-		return nil, err
-	}
-	nonce := make([]byte, gcm.NonceSize())
-	if _, err := rand.Read(nonce); err != nil {
-		return nil, err
-	}
-	ct := gcm.Seal(nil, nonce, plaintext, nil)
-	// Enviamos nonce|ct para descifrar luego
-	return append(nonce, ct...), nil
-}
-
-// Descifra tras verificar la firma
-func decryptPayload(aesKey, data []byte) ([]byte, error) {
-	block, err := aes.NewCipher(aesKey)
-	if err != nil {
-		return nil, err
-	}
-	gcm, err := cipher.NewGCM(block)
-	if err != nil {
-		return nil, err
-	}
-	nonceSize := gcm.NonceSize()
-	if len(data) < nonceSize {
-		return nil, fmt.Errorf("payload demasiado corto")
-	}
-	nonce, ct := data[:nonceSize], data[nonceSize:]
-	return gcm.Open(nil, nonce, ct, nil)
-}
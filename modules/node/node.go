@@ -1,9 +1,10 @@
 package node
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"math/big"
 	"net"
 	"sync"
@@ -11,12 +12,15 @@ import (
 
 	pec256 "github.com/polarysfoundation/pec-256"
 	"github.com/polarysfoundation/polarys-chain/modules/common"
+	"github.com/polarysfoundation/polarys-chain/modules/core"
 	"github.com/polarysfoundation/polarys-chain/modules/core/block"
+	"github.com/polarysfoundation/polarys-chain/modules/core/consensus"
+	"github.com/polarysfoundation/polarys-chain/modules/core/transaction"
 	"github.com/polarysfoundation/polarys-chain/modules/crypto"
 	"github.com/polarysfoundation/polarys-chain/modules/p2p"
 	"github.com/polarysfoundation/polarys-chain/modules/prydb"
+	chainsync "github.com/polarysfoundation/polarys-chain/modules/sync"
 	"github.com/sirupsen/logrus"
-	"golang.org/x/crypto/hkdf"
 )
 
 type Chain interface {
@@ -27,6 +31,17 @@ type Chain interface {
 	GetLatestBlock() (*block.Block, error)
 	ChainID() uint64
 	ProtocolHash() common.Hash
+
+	AddRemoteTransaction(tx *transaction.Transaction) error
+	HasTransaction(hash common.Hash) bool
+	GetPoolTransaction(hash common.Hash) (*transaction.Transaction, bool)
+	PendingTransactions() []transaction.Transaction
+
+	AddAttestation(att *consensus.Attestation) error
+	Attestations() []consensus.Attestation
+
+	AddEvidence(evidence *block.Evidence) error
+	PendingEvidence() []block.Evidence
 }
 
 const (
@@ -34,29 +49,42 @@ const (
 	readDeadline   = 30 * time.Second
 	writeDeadline  = 30 * time.Second
 	connectTimeout = 10 * time.Second
+
+	blockRequestTimeout = 30 // seconds an ASK stays open for answers
 )
 
 type Node struct {
 	self             *p2p.Peer
 	peers            map[string]*p2p.Peer
-	peerConnections  map[string]net.Conn // Track TCP connections
+	peerConnections  map[string]*session // Authenticated sessions by peer
 	privKey          pec256.PrivKey
 	pubKey           pec256.PubKey
-	secret           []byte
-	aesKey           []byte
 	blocksTransmited map[common.Hash]bool
 	blocksReceived   map[common.Hash]bool
+	txsRequested     map[common.Hash]uint64
+	blocksRequested  map[common.Hash]uint64
+	peersRequested   map[string]bool
+	statusSent       map[string]bool
 
 	trustedPeers map[string]bool
+	knownPeers   map[string]*knownPeer
+	bootnodes    []nodeAddr
+	targetPeers  int
 
-	bc Chain
+	scores      map[string]*peerScore
+	bans        map[string]uint64 // Ban expiry by peer
+	banDuration time.Duration
+
+	bc         Chain
+	engine     consensus.Engine
+	downloader *chainsync.Downloader
 
 	db  *prydb.Database
 	log *logrus.Logger
 	mu  sync.RWMutex
 }
 
-func NewNode(db *prydb.Database, log *logrus.Logger, bc Chain) (*Node, error) {
+func NewNode(db *prydb.Database, log *logrus.Logger, bc Chain, engine consensus.Engine) (*Node, error) {
 	priv, pub := crypto.GenerateKey()
 
 	addr := &net.TCPAddr{
@@ -66,28 +94,33 @@ func NewNode(db *prydb.Database, log *logrus.Logger, bc Chain) (*Node, error) {
 
 	self := p2p.NewPeer(addr, version, pub, uint64(time.Now().Unix()))
 
-	secret := crypto.GenerateSharedKey(priv)
-
-	aesKey, err := deriveAESKey(secret.Bytes())
-	if err != nil {
-		return nil, err
-	}
-
-	return &Node{
+	n := &Node{
 		self:             self,
 		peers:            make(map[string]*p2p.Peer),
-		peerConnections:  make(map[string]net.Conn),
+		peerConnections:  make(map[string]*session),
 		trustedPeers:     make(map[string]bool),
+		knownPeers:       make(map[string]*knownPeer),
+		targetPeers:      defaultTargetOutboundPeers,
 		blocksTransmited: make(map[common.Hash]bool),
 		blocksReceived:   make(map[common.Hash]bool),
+		txsRequested:     make(map[common.Hash]uint64),
+		blocksRequested:  make(map[common.Hash]uint64),
+		peersRequested:   make(map[string]bool),
+		statusSent:       make(map[string]bool),
+		scores:           make(map[string]*peerScore),
+		bans:             make(map[string]uint64),
+		banDuration:      defaultBanDuration,
 		privKey:          priv,
 		pubKey:           pub,
 		db:               db,
 		log:              log,
 		bc:               bc,
-		secret:           secret.Bytes(),
-		aesKey:           aesKey,
-	}, nil
+		engine:           engine,
+	}
+
+	n.downloader = chainsync.NewDownloader(bc, engine, &syncNetwork{node: n}, log)
+
+	return n, nil
 }
 
 func (n *Node) SetPort(port int) {
@@ -110,6 +143,16 @@ func (n *Node) Run() {
 	// Start ping and block propagation in separate goroutines
 	go n.ping()
 	go n.propagateBlock()
+	go n.propagateTransactions()
+	go n.propagateAttestations()
+	go n.propagateEvidence()
+
+	// Reconnect to the peers of the previous run, then keep looking for more
+	n.loadBans()
+	n.loadPeers()
+	go n.maintainPeers()
+
+	n.downloader.Start()
 
 	// Block forever
 	select {}
@@ -127,76 +170,161 @@ func (n *Node) acceptConnections(listener *net.TCPListener) {
 	}
 }
 
-func (nd *Node) handleConnection(conn *net.TCPConn) {
-	defer conn.Close()
+// handleConnection authenticates an inbound connection and serves it.
+func (n *Node) handleConnection(conn net.Conn) {
+	s, err := n.acceptHandshake(conn)
+	if err != nil {
+		n.log.WithField("remote_addr", conn.RemoteAddr().String()).Error("Handshake failed: ", err)
+		conn.Close()
+		return
+	}
+
+	if n.isBanned(s.cxid) {
+		n.log.WithField("client_id", s.cxid).Debug(ErrPeerBanned)
+		s.Close()
+		return
+	}
+
+	if !n.addSession(s) {
+		s.Close()
+		return
+	}
 
-	// Set read deadline to detect dead connections
-	conn.SetReadDeadline(time.Now().Add(readDeadline))
+	n.readLoop(s)
+}
 
-	buf := make([]byte, 1024)
-	for {
-		n, err := conn.Read(buf)
-		if err != nil {
-			nd.log.WithField("remote_addr", conn.RemoteAddr().String()).Error("Error reading from connection: ", err)
-			return
+// dial opens an authenticated session to addr and starts serving it.
+func (n *Node) dial(addr *net.TCPAddr) (*session, error) {
+	conn, err := net.DialTimeout("tcp", addr.String(), connectTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := n.initiateHandshake(conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	if n.isBanned(s.cxid) {
+		s.Close()
+		return nil, ErrPeerBanned
+	}
+
+	if !n.addSession(s) {
+		s.Close()
+		return nil, ErrDuplicateSession
+	}
+
+	go n.readLoop(s)
+
+	return s, nil
+}
+
+// addSession registers the session of a freshly authenticated peer. When
+// both nodes dial each other at the same time, both keep the session
+// opened by the node with the lowest id.
+func (n *Node) addSession(s *session) bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if existing, ok := n.peerConnections[s.cxid]; ok {
+		if !n.preferSession(s, existing) {
+			return false
 		}
 
-		message := buf[:n]
+		existing.Close()
+	}
 
-		msg := &Message{}
-		err = msg.Unmarshal(message)
-		if err != nil {
-			nd.log.WithField("remote_addr", conn.RemoteAddr().String()).Error(err)
+	if peer, ok := n.peers[s.cxid]; ok {
+		peer.SetLastSeen(uint64(time.Now().Unix()))
+	} else {
+		addr, _ := s.conn.RemoteAddr().(*net.TCPAddr)
+		n.peers[s.cxid] = p2p.NewPeer(addr, version, s.remote, uint64(time.Now().Unix()))
+	}
+
+	n.peerConnections[s.cxid] = s
+	delete(n.statusSent, s.cxid)
+
+	n.log.WithField("client_id", s.cxid).Info("Peer connected")
+
+	return true
+}
+
+func (n *Node) preferSession(s, existing *session) bool {
+	if s.initiator == existing.initiator {
+		return true
+	}
+
+	initiatorID := func(s *session) []byte {
+		if s.initiator {
+			return n.self.ID()
+		}
+		return crypto.Pm256(s.remote.Bytes())
+	}
+
+	return bytes.Compare(initiatorID(s), initiatorID(existing)) < 0
+}
+
+func (n *Node) dropSession(s *session) {
+	n.mu.Lock()
+	if n.peerConnections[s.cxid] == s {
+		delete(n.peerConnections, s.cxid)
+	}
+	n.mu.Unlock()
+
+	s.Close()
+}
+
+func (n *Node) readLoop(s *session) {
+	defer n.dropSession(s)
+
+	for {
+		// Set read deadline to detect dead connections
+		s.conn.SetReadDeadline(time.Now().Add(readDeadline))
+
+		msg, err := s.ReadMessage()
+		if errors.Is(err, ErrInvalidMessage) {
+			n.log.WithField("client_id", s.cxid).Error(err)
+			n.penalise(s.cxid, offenceInvalidMessage)
 			continue
 		}
 
-		// Reset read deadline
-		conn.SetReadDeadline(time.Now().Add(readDeadline))
+		if err != nil {
+			n.log.WithField("client_id", s.cxid).Error("Error reading from connection: ", err)
+			if errors.Is(err, ErrFrameTooLarge) || errors.Is(err, ErrEmptyFrame) || errors.Is(err, ErrInvalidFrame) {
+				n.penalise(s.cxid, offenceInvalidMessage)
+			}
+			return
+		}
 
-		// Handle the message
-		nd.handleMessage(msg, conn)
+		n.handleMessage(msg, s)
 	}
 }
 
-func (n *Node) handleMessage(msg *Message, conn net.Conn) {
+func (n *Node) handleMessage(msg *Message, s *session) {
+	cxid := s.cxid
+
 	pubkey, err := msg.DecodePubKey()
 	if err != nil {
-		n.log.WithField("remote_addr", conn.RemoteAddr().String()).Error(err)
+		n.log.WithField("client_id", cxid).Error(err)
+		n.penalise(cxid, offenceInvalidMessage)
 		return
 	}
 
-	id := crypto.Pm256(pubkey.Bytes())
-	cxid := common.EncodeToCXID(id)
-
-	msg, err = msg.DecryptData(n.aesKey)
-	if err != nil {
-		n.log.WithField("client_id", cxid).Error(err)
+	if pubkey != s.remote {
+		n.log.WithField("client_id", cxid).Error(ErrPeerKeyMismatch)
+		n.penalise(cxid, offenceInvalidSignature)
 		return
 	}
 
-	nonce, err := msg.DecodeNonce()
-	if err != nil {
-		n.log.WithField("client_id", cxid).Error(err)
+	if !n.allowMessage(cxid, msg.Type) {
+		n.penalise(cxid, offenceRateLimited)
 		return
 	}
 
 	n.mu.Lock()
-
-	// Update or create peer information
-	if peer, ok := n.peers[cxid]; !ok {
-		addr := conn.RemoteAddr().(*net.TCPAddr)
-		peer = p2p.NewPeer(addr, version, pubkey, uint64(time.Now().Unix()))
-		peer.AddNonce(nonce)
-		validNonce := peer.ValidNonce(nonce)
-		if !validNonce {
-			n.log.WithField("client_id", cxid).Error("Invalid nonce")
-			n.mu.Unlock()
-			return
-		}
-		n.peers[cxid] = peer
-		n.peerConnections[cxid] = conn
-
-	} else {
+	if peer, ok := n.peers[cxid]; ok {
 		peer.SetLastSeen(uint64(time.Now().Unix()))
 	}
 	n.mu.Unlock()
@@ -205,39 +333,60 @@ func (n *Node) handleMessage(msg *Message, conn net.Conn) {
 
 	switch msg.Type {
 	case BLOCK:
-		ok, err := n.verifyMessage(msg)
+		data, ok := n.readPayload(msg, cxid)
+		if !ok {
+			return
+		}
+
+		var blk block.Block
+		err = json.Unmarshal(data, &blk)
 		if err != nil {
 			n.log.WithField("client_id", cxid).Error(err)
+			n.penalise(cxid, offenceInvalidMessage)
 			return
 		}
 
-		if !ok {
-			n.log.WithField("client_id", cxid).Error("Invalid signature")
-			return
+		// Blocks are only sent in answer to an ASK. Requests are kept until
+		// they expire, every peer we asked may answer.
+		n.mu.RLock()
+		_, requested := n.blocksRequested[blk.Hash()]
+		n.mu.RUnlock()
+
+		if !requested {
+			n.penalise(cxid, offenceUnrequested)
 		}
 
-		data, err := msg.DecodeData()
-		if err != nil {
-			n.log.WithField("client_id", cxid).Error(err)
+		err = n.bc.AddRemoteBlock(&blk)
+		if errors.Is(err, core.ErrUnknownParent) {
+			// We are missing blocks in between, fetch them from the peers.
+			// Without its ancestors the block cannot be validated, so the
+			// peer is only trusted to reach it once it is sealed and signed
+			// by a validator: the downloader syncs from the heaviest peer.
+			if n.verifyDetached(&blk) {
+				n.setPeerHead(cxid, &blk)
+			}
+			n.downloader.Trigger()
 			return
 		}
 
-		var blk block.Block
-		err = json.Unmarshal(data, &blk)
-		if err != nil {
-			n.log.WithField("client_id", cxid).Error(err)
+		if errors.Is(err, core.ErrBlockExists) {
+			n.setPeerHead(cxid, &blk)
 			return
 		}
 
-		err = n.bc.AddRemoteBlock(&blk)
 		if err != nil {
 			n.log.WithField("client_id", cxid).Error(err)
+			n.penalise(cxid, offenceInvalidBlock)
 			return
 		}
 
+		n.setPeerHead(cxid, &blk)
+
+		n.mu.Lock()
 		n.blocksReceived[blk.Hash()] = true
+		n.mu.Unlock()
 
-		newMessage, err := NewMessage(HASH, blk.Hash().Bytes(), n.pubKey, n.aesKey)
+		newMessage, err := NewMessage(HASH, blk.Hash().Bytes(), n.pubKey)
 		if err != nil {
 			n.log.WithField("client_id", cxid).Error(err)
 			return
@@ -251,26 +400,23 @@ func (n *Node) handleMessage(msg *Message, conn net.Conn) {
 
 		n.broadcast(newMessage, cxid)
 	case HASH:
-		ok, err := n.verifyMessage(msg)
-		if err != nil {
-			n.log.WithField("client_id", cxid).Error(err)
-			return
-		}
-
+		data, ok := n.readPayload(msg, cxid)
 		if !ok {
-			n.log.WithField("client_id", cxid).Error("Invalid signature")
 			return
 		}
 
-		data, err := msg.DecodeData()
-		if err != nil {
-			n.log.WithField("client_id", cxid).Error(err)
+		if len(data) != common.HashLen {
+			n.penalise(cxid, offenceInvalidMessage)
 			return
 		}
 
 		hashBlock := common.BytesToHash(data)
 		if !n.bc.HasBlock(hashBlock) {
-			newMessage, err := NewMessage(ASK, data, n.pubKey, n.aesKey)
+			n.mu.Lock()
+			n.blocksRequested[hashBlock] = uint64(time.Now().Unix())
+			n.mu.Unlock()
+
+			newMessage, err := NewMessage(ASK, data, n.pubKey)
 			if err != nil {
 				n.log.WithField("client_id", cxid).Error(err)
 				return
@@ -285,20 +431,13 @@ func (n *Node) handleMessage(msg *Message, conn net.Conn) {
 			n.response(newMessage, cxid)
 		}
 	case ASK:
-		ok, err := n.verifyMessage(msg)
-		if err != nil {
-			n.log.WithField("client_id", cxid).Error(err)
-			return
-		}
-
+		data, ok := n.readPayload(msg, cxid)
 		if !ok {
-			n.log.WithField("client_id", cxid).Error("Invalid signature")
 			return
 		}
 
-		data, err := msg.DecodeData()
-		if err != nil {
-			n.log.WithField("client_id", cxid).Error(err)
+		if len(data) != common.HashLen {
+			n.penalise(cxid, offenceInvalidMessage)
 			return
 		}
 
@@ -316,7 +455,7 @@ func (n *Node) handleMessage(msg *Message, conn net.Conn) {
 				return
 			}
 
-			newMessage, err := NewMessage(BLOCK, b, n.pubKey, n.aesKey)
+			newMessage, err := NewMessage(BLOCK, b, n.pubKey)
 			if err != nil {
 				n.log.WithField("client_id", cxid).Error(err)
 				return
@@ -328,158 +467,86 @@ func (n *Node) handleMessage(msg *Message, conn net.Conn) {
 				return
 			}
 
+			n.mu.Lock()
 			n.blocksTransmited[hashBlock] = true
+			n.mu.Unlock()
 
 			n.response(newMessage, cxid)
 		}
 	case PEER_INFO:
-		ok, err := n.verifyMessage(msg)
-		if err != nil {
-			n.log.WithField("client_id", cxid).Error(err)
-			return
-		}
-
-		if !ok {
-			n.log.WithField("client_id", cxid).Error("Invalid signature")
-			return
-		}
-
-		data, err := msg.DecodeData()
-		if err != nil {
-			n.log.WithField("client_id", cxid).Error(err)
-			return
-		}
-
-		peerInfo := struct {
-			ChainID      uint64      `json:"chain_id"`
-			ProtocolHash common.Hash `json:"protocol_hash"`
-			LatestBlock  common.Hash `json:"latest_block"`
-		}{}
-
-		err = json.Unmarshal(data, &peerInfo)
-		if err != nil {
-			n.log.WithField("client_id", cxid).Error(err)
-			return
-		}
-
-		if peerInfo.ChainID != n.bc.ChainID() {
-			n.log.WithField("client_id", cxid).Error("Invalid chain ID")
-			return
-		}
-
-		if peerInfo.ProtocolHash != n.bc.ProtocolHash() {
-			n.log.WithField("client_id", cxid).Error("Invalid protocol hash")
-			return
-		}
-
-		if peerInfo.LatestBlock.IsValid() {
-			latestBlock, err := n.bc.GetLatestBlock()
-			if err != nil {
-				n.log.WithField("client_id", cxid).Error(err)
-				return
-			}
-
-			blk, err := n.bc.GetBlockByHash(peerInfo.LatestBlock)
-			if err != nil {
-				n.log.WithField("client_id", cxid).Error(err)
-				return
-			}
-
-			if blk.Hash() != peerInfo.LatestBlock {
-				n.log.WithField("client_id", cxid).Error("Invalid block")
-				return
-			}
-
-			diffBlocks := blk.Height() - latestBlock.Height()
-			if diffBlocks == 0 {
-				n.log.WithField("client_id", cxid).Error("Node synced")
-				return
-			}
-
-			for i := 1; i < int(diffBlocks); i++ {
-				rBlk, err := n.bc.GetBlockByHeight(blk.Height() + uint64(i))
-				if err != nil {
-					n.log.WithField("client_id", cxid).Error(err)
-					return
-				}
-
-				b, err := json.Marshal(rBlk)
-				if err != nil {
-					n.log.WithField("client_id", cxid).Error(err)
-					return
-				}
-
-				nMsg, err := NewMessage(BLOCK, b, n.pubKey, n.aesKey)
-				if err != nil {
-					n.log.WithField("client_id", cxid).Error(err)
-					return
-				}
-
-				signedMsg, err := n.signMessage(nMsg)
-				if err != nil {
-					n.log.WithField("client_id", cxid).Error(err)
-					return
-				}
-
-				n.response(signedMsg, cxid)
-
-			}
-
-		}
-
+		n.handleStatus(msg, cxid)
+	case TX_HASH:
+		n.handleTxHashes(msg, cxid)
+	case TX_ASK:
+		n.handleTxAsk(msg, cxid)
+	case TRANSACTION:
+		n.handleTransactions(msg, cxid)
+	case GET_HEADERS:
+		n.handleGetHeaders(msg, cxid)
+	case HEADERS:
+		n.handleHeaders(msg, cxid)
+	case GET_BODIES:
+		n.handleGetBodies(msg, cxid)
+	case BODIES:
+		n.handleBodies(msg, cxid)
+	case GET_PEERS:
+		n.handleGetPeers(msg, cxid)
+	case PEERS:
+		n.handlePeers(msg, cxid)
+	case ATTESTATION:
+		n.handleAttestations(msg, cxid)
+	case EVIDENCE:
+		n.handleEvidence(msg, cxid)
+	case PING:
+		// Liveness is recorded above.
+	default:
+		n.penalise(cxid, offenceInvalidMessage)
 	}
 }
 
 // ConnectToPeer establishes a TCP connection to another peer
 func (n *Node) ConnectToPeer(addr *net.TCPAddr) error {
-	conn, err := net.DialTimeout("tcp", addr.String(), connectTimeout)
-	if err != nil {
-		return err
-	}
+	return n.connect(addr, "")
+}
 
-	latestBlock, err := n.bc.GetLatestBlock()
+// connect dials addr and sends our status. When id is set the remote node
+// must authenticate with it.
+func (n *Node) connect(addr *net.TCPAddr, id string) error {
+	s, err := n.dial(addr)
 	if err != nil {
-		conn.Close()
 		return err
 	}
 
-	// Send our peer information immediately after connecting
-	peerInfo := struct {
-		ChainID      uint64      `json:"chain_id"`
-		ProtocolHash common.Hash `json:"protocol_hash"`
-		LatestBlock  common.Hash `json:"latest_block"`
-	}{
-		ChainID:      n.bc.ChainID(),
-		ProtocolHash: n.bc.ProtocolHash(),
-		LatestBlock:  latestBlock.Hash(),
+	if id != "" && s.cxid != id {
+		n.dropSession(s)
+		return ErrUnexpectedPeer
 	}
 
-	data, err := json.Marshal(peerInfo)
+	// Send our peer information immediately after connecting
+	data, err := n.statusPayload()
 	if err != nil {
-		conn.Close()
 		return err
 	}
 
-	msg, err := NewMessage(PEER_INFO, data, n.pubKey, n.aesKey)
+	msg, err := NewMessage(PEER_INFO, data, n.pubKey)
 	if err != nil {
 		return err
 	}
 
 	signedMsg, err := n.signMessage(msg)
 	if err != nil {
-		conn.Close()
 		return err
 	}
 
-	_, err = conn.Write(signedMsg.Bytes())
-	if err != nil {
-		conn.Close()
+	n.mu.Lock()
+	n.statusSent[s.cxid] = true
+	n.mu.Unlock()
+
+	if err := s.WriteMessage(signedMsg); err != nil {
+		n.dropSession(s)
 		return err
 	}
 
-	// Start a goroutine to handle this connection
-	go n.handleConnection(conn.(*net.TCPConn))
-
 	return nil
 }
 
@@ -555,6 +622,7 @@ func (n *Node) RemovePeer(peer *p2p.Peer) error {
 	}
 
 	delete(n.peers, cxid)
+	delete(n.statusSent, cxid)
 
 	return nil
 }
@@ -588,14 +656,18 @@ func (n *Node) propagateBlock() error {
 			continue
 		}
 
-		n.mu.Lock()
+		n.mu.RLock()
+		_, transmited := n.blocksTransmited[latestBlock.Hash()]
+		n.mu.RUnlock()
 
-		for cxid, peer := range n.peers {
+		if transmited {
+			continue
+		}
+
+		// sendMessage takes the lock itself, so work on a copy of the peers.
+		for cxid, peer := range n.peerSnapshot() {
 			if peer.CXID() != n.self.CXID() {
-				if _, ok := n.blocksTransmited[latestBlock.Hash()]; ok {
-					continue
-				}
-				newMessage, err := NewMessage(HASH, latestBlock.Hash().Bytes(), n.pubKey, n.aesKey)
+				newMessage, err := NewMessage(HASH, latestBlock.Hash().Bytes(), n.pubKey)
 				if err != nil {
 					n.log.WithField("client_id", peer.CXID()).Error(err)
 					continue
@@ -615,7 +687,6 @@ func (n *Node) propagateBlock() error {
 				n.log.WithField("client_id", peer.CXID()).Info("Block proposed")
 			}
 		}
-		n.mu.Unlock()
 	}
 }
 
@@ -628,10 +699,7 @@ func (n *Node) response(msg *Message, cxid string) {
 }
 
 func (n *Node) broadcast(msg *Message, senderCXID string) {
-	n.mu.RLock()
-	defer n.mu.RUnlock()
-
-	for cxid := range n.peers {
+	for cxid := range n.peerSnapshot() {
 		if cxid != senderCXID {
 			if err := n.sendMessage(cxid, msg); err != nil {
 				n.log.WithField("client_id", cxid).Error("Error broadcasting message: ", err)
@@ -644,34 +712,30 @@ func (n *Node) broadcast(msg *Message, senderCXID string) {
 
 func (n *Node) sendMessage(cxid string, msg *Message) error {
 	n.mu.RLock()
-	conn, ok := n.peerConnections[cxid]
+	s, ok := n.peerConnections[cxid]
 	n.mu.RUnlock()
 
 	if !ok {
-		// Try to establish a new connection if we don't have one
+		// Try to establish a new session if we don't have one
 		peer, err := n.GetPeerByID(common.DecodeCXID(cxid))
 		if err != nil {
 			return err
 		}
 
-		tcpAddr := peer.Addr()
-		newConn, err := net.DialTCP("tcp", nil, tcpAddr)
+		s, err = n.dial(peer.Addr())
 		if err != nil {
 			return err
 		}
+	}
 
-		n.mu.Lock()
-		n.peerConnections[cxid] = newConn
-		conn = newConn
-		n.mu.Unlock()
-
-		// Start handling the new connection
-		go n.handleConnection(newConn)
+	if err := s.WriteMessage(msg); err != nil {
+		// A partial frame leaves the stream unusable, start over with a new
+		// session next time.
+		n.dropSession(s)
+		return err
 	}
 
-	conn.SetWriteDeadline(time.Now().Add(writeDeadline))
-	_, err := conn.Write(msg.Bytes())
-	return err
+	return nil
 }
 
 func (n *Node) signMessage(msg *Message) (*Message, error) {
@@ -687,21 +751,25 @@ func (n *Node) signMessage(msg *Message) (*Message, error) {
 		return nil, err
 	}
 
-	signature := make([]byte, 64)
-	copy(signature[:32], r.Bytes())
-	copy(signature[32:], s.Bytes())
-
-	return msg.SignMessage(signature), nil
+	return msg.SignMessage(encodeSignature(r, s)), nil
 }
 
 func (n *Node) verifyMessage(msg *Message) (bool, error) {
-	b, err := msg.Marshal()
+	signature := msg.Signature
+	if len(signature) != 64 {
+		return false, nil
+	}
+
+	// The signature covers the message as it was before being signed.
+	unsigned := copyMessage(msg)
+	unsigned.Signature = nil
+
+	b, err := unsigned.Marshal()
 	if err != nil {
 		return false, err
 	}
 
 	h := crypto.Pm256(b)
-	signature := msg.Signature
 
 	pubKey, err := msg.DecodePubKey()
 	if err != nil {
@@ -729,10 +797,23 @@ func (n *Node) ping() {
 					delete(n.peerConnections, cxid)
 				}
 				delete(n.peers, cxid)
-				continue
+				delete(n.statusSent, cxid)
+				delete(n.peersRequested, cxid)
 			}
+		}
+
+		for hash, requested := range n.blocksRequested {
+			if now-int64(requested) >= blockRequestTimeout {
+				delete(n.blocksRequested, hash)
+			}
+		}
 
-			pingMsg, err := NewMessage(PING, []byte(fmt.Sprintf("%d", now)), n.pubKey, n.aesKey)
+		n.mu.Unlock()
+
+		n.pruneReputation()
+
+		for cxid := range n.peerSnapshot() {
+			pingMsg, err := NewMessage(PING, []byte(fmt.Sprintf("%d", now)), n.pubKey)
 			if err != nil {
 				n.log.WithField("client_id", cxid).Error(err)
 				continue
@@ -751,17 +832,37 @@ func (n *Node) ping() {
 
 			n.log.WithField("client_id", cxid).Info("Ping sent")
 		}
+	}
+}
 
-		n.mu.Unlock()
+// verifyDetached checks what can be checked of a block whose parent is
+// unknown: its hash, its seal and that a validator signed it.
+func (n *Node) verifyDetached(blk *block.Block) bool {
+	hash := blk.Hash()
+	if blk.CalcHash() != hash {
+		return false
+	}
+
+	if err := n.engine.VerifySeal(blk); err != nil {
+		return false
 	}
+
+	if err := n.engine.VerifySignature(blk); err != nil {
+		return false
+	}
+
+	return n.engine.ValidatorExists(blk.Validator())
 }
 
-// Deriva clave AES-GCM a partir de un secreto ECDH
-func deriveAESKey(secret []byte) ([]byte, error) {
-	hk := hkdf.New(crypto.NewPM256, secret, nil, nil)
-	key := make([]byte, 32)
-	if _, err := io.ReadFull(hk, key); err != nil {
-		return nil, err
+// setPeerHead records blk as the head of the peer if it is heavier than
+// the one it announced before.
+func (n *Node) setPeerHead(cxid string, blk *block.Block) {
+	peer := n.peerByCXID(cxid)
+	if peer == nil {
+		return
+	}
+
+	if _, _, td := peer.Head(); blk.TotalDifficulty() > td {
+		peer.SetHead(blk.Hash(), blk.Height(), blk.TotalDifficulty())
 	}
-	return key, nil
 }
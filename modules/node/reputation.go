@@ -0,0 +1,246 @@
+package node
+
+import (
+	"time"
+
+	"github.com/polarysfoundation/polarys-chain/modules/prydb"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	banThreshold       = -100
+	scoreRecovery      = 1.0 // points forgiven per minute of good behaviour
+	defaultBanDuration = 24 * time.Hour
+)
+
+// offence is a kind of misbehaviour and what it costs to the peer score.
+type offence struct {
+	reason  string
+	penalty float64
+}
+
+var (
+	offenceInvalidSignature = offence{reason: "invalid signature", penalty: 25}
+	offenceInvalidMessage   = offence{reason: "malformed message", penalty: 10}
+	offenceInvalidBlock     = offence{reason: "invalid block", penalty: 50}
+	offenceWrongChain       = offence{reason: "different chain", penalty: -banThreshold}
+	offenceUnrequested      = offence{reason: "unrequested data", penalty: 5}
+	offenceRateLimited      = offence{reason: "rate limit exceeded", penalty: 2}
+)
+
+// rateLimit is the token bucket refill rate, in messages per second, and
+// size for a message type.
+type rateLimit struct {
+	rate  float64
+	burst float64
+}
+
+var (
+	messageLimits = map[Type]rateLimit{
+		PING:        {rate: 1, burst: 5},
+		PEER_INFO:   {rate: 0.2, burst: 5},
+		BLOCK:       {rate: 10, burst: 50},
+		HASH:        {rate: 10, burst: 50},
+		ASK:         {rate: 10, burst: 50},
+		TX_HASH:     {rate: 10, burst: 50},
+		TX_ASK:      {rate: 10, burst: 50},
+		TRANSACTION: {rate: 10, burst: 50},
+		GET_HEADERS: {rate: 5, burst: 20},
+		HEADERS:     {rate: 5, burst: 20},
+		GET_BODIES:  {rate: 10, burst: 40},
+		BODIES:      {rate: 10, burst: 40},
+		GET_PEERS:   {rate: 0.2, burst: 3},
+		PEERS:       {rate: 0.2, burst: 3},
+		ATTESTATION: {rate: 5, burst: 20},
+		EVIDENCE:    {rate: 1, burst: 5},
+	}
+
+	defaultLimit = rateLimit{rate: 1, burst: 5}
+)
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// take refills the bucket for the time elapsed since the last call and
+// spends one token if there is one.
+func (b *tokenBucket) take(limit rateLimit, now time.Time) bool {
+	b.tokens = min(limit.burst, b.tokens+now.Sub(b.last).Seconds()*limit.rate)
+	b.last = now
+
+	if b.tokens < 1 {
+		return false
+	}
+
+	b.tokens--
+	return true
+}
+
+// peerScore is the reputation of a peer. It is kept by id rather than on
+// the connection, so reconnecting does not clear it.
+type peerScore struct {
+	score   float64
+	updated time.Time
+	buckets map[Type]*tokenBucket
+}
+
+// forgive cancels part of the past offences, the score never goes above
+// zero.
+func (s *peerScore) forgive(now time.Time) {
+	s.score = min(0, s.score+now.Sub(s.updated).Minutes()*scoreRecovery)
+	s.updated = now
+}
+
+// SetBanDuration sets how long a peer stays banned once its score falls
+// below the threshold.
+func (n *Node) SetBanDuration(d time.Duration) {
+	n.mu.Lock()
+	n.banDuration = d
+	n.mu.Unlock()
+}
+
+// loadBans restores the bans that outlived the previous run.
+func (n *Node) loadBans() {
+	records, err := n.db.Bans()
+	if err != nil {
+		n.log.Error("Error loading ban list: ", err)
+		return
+	}
+
+	now := uint64(time.Now().Unix())
+
+	n.mu.Lock()
+	for _, record := range records {
+		if record.Until > now {
+			n.bans[record.ID] = record.Until
+		}
+	}
+	n.mu.Unlock()
+
+	n.log.WithField("bans", len(n.bans)).Info("Ban list loaded")
+}
+
+func (n *Node) isBanned(cxid string) bool {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	return n.bans[cxid] > uint64(time.Now().Unix())
+}
+
+// allowMessage spends a token of the peer bucket for the message type.
+func (n *Node) allowMessage(cxid string, t Type) bool {
+	limit, ok := messageLimits[t]
+	if !ok {
+		limit = defaultLimit
+	}
+
+	now := time.Now()
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	score := n.peerScore(cxid, now)
+
+	bucket, ok := score.buckets[t]
+	if !ok {
+		bucket = &tokenBucket{tokens: limit.burst, last: now}
+		score.buckets[t] = bucket
+	}
+
+	return bucket.take(limit, now)
+}
+
+// penalise lowers the score of a peer and bans it when the score falls
+// below the threshold.
+func (n *Node) penalise(cxid string, o offence) {
+	now := time.Now()
+
+	n.mu.Lock()
+	score := n.peerScore(cxid, now)
+	score.forgive(now)
+	score.score -= o.penalty
+	current := score.score
+	n.mu.Unlock()
+
+	n.log.WithFields(logrus.Fields{
+		"client_id": cxid,
+		"reason":    o.reason,
+		"score":     current,
+	}).Warn("Peer misbehaved")
+
+	if current <= banThreshold {
+		n.ban(cxid, o.reason)
+	}
+}
+
+// peerScore returns the score of a peer, creating it on first use. The
+// caller must hold the node lock.
+func (n *Node) peerScore(cxid string, now time.Time) *peerScore {
+	score, ok := n.scores[cxid]
+	if !ok {
+		score = &peerScore{updated: now, buckets: make(map[Type]*tokenBucket)}
+		n.scores[cxid] = score
+	}
+
+	return score
+}
+
+// ban disconnects a peer and refuses it until the ban expires.
+func (n *Node) ban(cxid string, reason string) {
+	n.mu.Lock()
+	until := uint64(time.Now().Add(n.banDuration).Unix())
+	n.bans[cxid] = until
+	delete(n.scores, cxid)
+
+	if s, ok := n.peerConnections[cxid]; ok {
+		s.Close()
+		delete(n.peerConnections, cxid)
+	}
+	delete(n.peers, cxid)
+	delete(n.statusSent, cxid)
+	delete(n.peersRequested, cxid)
+	n.mu.Unlock()
+
+	n.log.WithFields(logrus.Fields{
+		"client_id": cxid,
+		"reason":    reason,
+		"until":     until,
+	}).Warn("Peer banned")
+
+	if err := n.db.WriteBan(&prydb.BanRecord{ID: cxid, Until: until, Reason: reason}); err != nil {
+		n.log.WithField("client_id", cxid).Error("Error saving ban: ", err)
+	}
+}
+
+// pruneReputation forgets expired bans and the scores of disconnected
+// peers that have fully recovered.
+func (n *Node) pruneReputation() {
+	now := time.Now()
+	expired := make([]string, 0)
+
+	n.mu.Lock()
+	for cxid, until := range n.bans {
+		if until <= uint64(now.Unix()) {
+			delete(n.bans, cxid)
+			expired = append(expired, cxid)
+		}
+	}
+
+	for cxid, score := range n.scores {
+		if _, ok := n.peerConnections[cxid]; ok {
+			continue
+		}
+
+		if score.forgive(now); score.score == 0 {
+			delete(n.scores, cxid)
+		}
+	}
+	n.mu.Unlock()
+
+	for _, cxid := range expired {
+		if err := n.db.DeleteBan(cxid); err != nil {
+			n.log.WithField("client_id", cxid).Error("Error removing ban: ", err)
+		}
+	}
+}
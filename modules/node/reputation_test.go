@@ -0,0 +1,122 @@
+package node
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	"github.com/polarysfoundation/polarys-chain/modules/p2p"
+	"github.com/polarysfoundation/polarys-chain/modules/prydb"
+	"github.com/sirupsen/logrus"
+)
+
+func TestTokenBucket(t *testing.T) {
+	limit := rateLimit{rate: 2, burst: 3}
+	now := time.Now()
+	bucket := &tokenBucket{tokens: limit.burst, last: now}
+
+	for i := 0; i < 3; i++ {
+		if !bucket.take(limit, now) {
+			t.Fatalf("take() #%d = false within the burst", i)
+		}
+	}
+
+	if bucket.take(limit, now) {
+		t.Fatalf("take() = true with an empty bucket")
+	}
+
+	// Two tokens come back every second.
+	now = now.Add(time.Second)
+	if !bucket.take(limit, now) || !bucket.take(limit, now) {
+		t.Fatalf("take() = false after refill")
+	}
+
+	if bucket.take(limit, now) {
+		t.Errorf("take() = true beyond the refill")
+	}
+}
+
+func newReputationNode(t *testing.T, db *prydb.Database) *Node {
+	t.Helper()
+
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	n := newTestNode()
+	n.db = db
+	n.log = log
+	n.peers = make(map[string]*p2p.Peer)
+	n.peerConnections = make(map[string]*session)
+	n.statusSent = make(map[string]bool)
+	n.peersRequested = make(map[string]bool)
+	n.scores = make(map[string]*peerScore)
+	n.bans = make(map[string]uint64)
+	n.banDuration = time.Hour
+
+	return n
+}
+
+func TestPenalise_Ban(t *testing.T) {
+	// The database lives under the home directory.
+	t.Setenv("HOME", t.TempDir())
+
+	db, err := prydb.InitDB()
+	if err != nil {
+		t.Fatalf("InitDB() error = %v", err)
+	}
+
+	n := newReputationNode(t, db)
+	cxid := newTestNode().self.CXID()
+
+	for i := 0; i < 3; i++ {
+		n.penalise(cxid, offenceInvalidSignature)
+	}
+
+	if n.isBanned(cxid) {
+		t.Fatalf("peer banned above the threshold")
+	}
+
+	n.penalise(cxid, offenceInvalidSignature)
+	n.penalise(cxid, offenceInvalidSignature)
+
+	if !n.isBanned(cxid) {
+		t.Fatalf("peer not banned below the threshold")
+	}
+
+	// The ban survives a restart.
+	restarted := newReputationNode(t, db)
+	restarted.loadBans()
+
+	if !restarted.isBanned(cxid) {
+		t.Errorf("ban not restored from the database")
+	}
+
+	// A wrong chain is banned straight away.
+	other := newTestNode().self.CXID()
+	n.penalise(other, offenceWrongChain)
+
+	if !n.isBanned(other) {
+		t.Errorf("peer on another chain not banned")
+	}
+}
+
+func TestAllowMessage(t *testing.T) {
+	n := newReputationNode(t, nil)
+	cxid := newTestNode().self.CXID()
+
+	limit := messageLimits[GET_PEERS]
+	for i := 0; i < int(limit.burst); i++ {
+		if !n.allowMessage(cxid, GET_PEERS) {
+			t.Fatalf("allowMessage() #%d = false within the burst", i)
+		}
+	}
+
+	if n.allowMessage(cxid, GET_PEERS) {
+		t.Errorf("allowMessage() = true beyond the burst")
+	}
+
+	// Every message type has its own bucket.
+	if !n.allowMessage(cxid, PING) {
+		t.Errorf("allowMessage(PING) = false, limited by another type")
+	}
+}
@@ -0,0 +1,299 @@
+package node
+
+import (
+	"encoding/json"
+	"net"
+
+	"github.com/polarysfoundation/polarys-chain/modules/common"
+	"github.com/polarysfoundation/polarys-chain/modules/core/block"
+	"github.com/polarysfoundation/polarys-chain/modules/core/transaction"
+	"github.com/polarysfoundation/polarys-chain/modules/p2p"
+	chainsync "github.com/polarysfoundation/polarys-chain/modules/sync"
+	"github.com/sirupsen/logrus"
+)
+
+// status is the PEER_INFO payload exchanged when a connection is opened.
+type status struct {
+	ChainID         uint64      `json:"chain_id"`
+	ProtocolHash    common.Hash `json:"protocol_hash"`
+	LatestBlock     common.Hash `json:"latest_block"`
+	Height          uint64      `json:"height"`
+	TotalDifficulty uint64      `json:"total_difficulty"`
+	ListenPort      int         `json:"listen_port"`
+}
+
+func (n *Node) statusPayload() ([]byte, error) {
+	latestBlock, err := n.bc.GetLatestBlock()
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(status{
+		ChainID:         n.bc.ChainID(),
+		ProtocolHash:    n.bc.ProtocolHash(),
+		LatestBlock:     latestBlock.Hash(),
+		Height:          latestBlock.Height(),
+		TotalDifficulty: latestBlock.TotalDifficulty(),
+		ListenPort:      n.self.Addr().Port,
+	})
+}
+
+// handleStatus checks that the peer follows the same chain, records its
+// head and answers with our own status the first time. Catching up is left
+// to the downloader, which is woken up when the peer is ahead.
+func (n *Node) handleStatus(msg *Message, cxid string) {
+	data, ok := n.readPayload(msg, cxid)
+	if !ok {
+		return
+	}
+
+	var peerInfo status
+	if err := json.Unmarshal(data, &peerInfo); err != nil {
+		n.log.WithField("client_id", cxid).Error(err)
+		n.penalise(cxid, offenceInvalidMessage)
+		return
+	}
+
+	if peerInfo.ChainID != n.bc.ChainID() {
+		n.log.WithField("client_id", cxid).Error("Invalid chain ID")
+		n.penalise(cxid, offenceWrongChain)
+		return
+	}
+
+	if peerInfo.ProtocolHash != n.bc.ProtocolHash() {
+		n.log.WithField("client_id", cxid).Error("Invalid protocol hash")
+		n.penalise(cxid, offenceWrongChain)
+		return
+	}
+
+	peer := n.peerByCXID(cxid)
+	if peer == nil {
+		return
+	}
+
+	peer.SetHead(peerInfo.LatestBlock, peerInfo.Height, peerInfo.TotalDifficulty)
+
+	// Inbound peers connect from an ephemeral port, remember where they
+	// listen so they can be dialled and shared.
+	if addr := peer.Addr(); addr != nil && peerInfo.ListenPort > 0 && peerInfo.ListenPort <= 65535 {
+		listenAddr := &net.TCPAddr{IP: addr.IP, Port: peerInfo.ListenPort}
+		peer.SetAddr(listenAddr)
+		n.addKnownPeer(cxid, listenAddr, true)
+	}
+
+	n.mu.Lock()
+	sent := n.statusSent[cxid]
+	n.statusSent[cxid] = true
+	n.mu.Unlock()
+
+	if !sent {
+		b, err := n.statusPayload()
+		if err != nil {
+			n.log.WithField("client_id", cxid).Error(err)
+			return
+		}
+
+		if err := n.sendPayload(PEER_INFO, b, cxid); err != nil {
+			n.log.WithField("client_id", cxid).Error("Error sending status: ", err)
+		}
+	}
+
+	latestBlock, err := n.bc.GetLatestBlock()
+	if err != nil {
+		n.log.WithField("client_id", cxid).Error(err)
+		return
+	}
+
+	if peerInfo.TotalDifficulty > latestBlock.TotalDifficulty() {
+		n.log.WithFields(logrus.Fields{
+			"client_id":        cxid,
+			"height":           peerInfo.Height,
+			"total_difficulty": peerInfo.TotalDifficulty,
+		}).Info("Peer is ahead, starting synchronisation")
+
+		n.downloader.Trigger()
+	}
+}
+
+// handleGetHeaders answers with up to MaxHeaderFetch canonical headers.
+func (n *Node) handleGetHeaders(msg *Message, cxid string) {
+	data, ok := n.readPayload(msg, cxid)
+	if !ok {
+		return
+	}
+
+	var req chainsync.HeadersRequest
+	if err := json.Unmarshal(data, &req); err != nil {
+		n.log.WithField("client_id", cxid).Error(err)
+		n.penalise(cxid, offenceInvalidMessage)
+		return
+	}
+
+	amount := min(req.Amount, chainsync.MaxHeaderFetch)
+	headers := make([]block.Header, 0, amount)
+	for height := req.Origin; height < req.Origin+amount; height++ {
+		blk, err := n.bc.GetBlockByHeight(height)
+		if err != nil {
+			break
+		}
+
+		headers = append(headers, blk.Header())
+	}
+
+	b, err := json.Marshal(headers)
+	if err != nil {
+		n.log.WithField("client_id", cxid).Error(err)
+		return
+	}
+
+	if err := n.sendPayload(HEADERS, b, cxid); err != nil {
+		n.log.WithField("client_id", cxid).Error("Error sending headers: ", err)
+	}
+}
+
+func (n *Node) handleHeaders(msg *Message, cxid string) {
+	data, ok := n.readPayload(msg, cxid)
+	if !ok {
+		return
+	}
+
+	var headers []block.Header
+	if err := json.Unmarshal(data, &headers); err != nil {
+		n.log.WithField("client_id", cxid).Error(err)
+		n.penalise(cxid, offenceInvalidMessage)
+		return
+	}
+
+	if !n.downloader.DeliverHeaders(cxid, headers) {
+		n.penalise(cxid, offenceUnrequested)
+	}
+}
+
+// handleGetBodies answers with the transactions of the requested blocks we
+// have, at most MaxBodyFetch of them.
+func (n *Node) handleGetBodies(msg *Message, cxid string) {
+	data, ok := n.readPayload(msg, cxid)
+	if !ok {
+		return
+	}
+
+	hashes, err := decodeHashes(data)
+	if err != nil {
+		n.log.WithField("client_id", cxid).Error(err)
+		n.penalise(cxid, offenceInvalidMessage)
+		return
+	}
+
+	if len(hashes) > chainsync.MaxBodyFetch {
+		hashes = hashes[:chainsync.MaxBodyFetch]
+	}
+
+	bodies := make([]chainsync.Body, 0, len(hashes))
+	for _, hash := range hashes {
+		blk, err := n.bc.GetBlockByHash(hash)
+		if err != nil {
+			continue
+		}
+
+		bodies = append(bodies, chainsync.Body{
+			Hash:         hash,
+			Transactions: blk.Transactions(),
+			Evidence:     blk.Evidence(),
+		})
+	}
+
+	b, err := json.Marshal(bodies)
+	if err != nil {
+		n.log.WithField("client_id", cxid).Error(err)
+		return
+	}
+
+	if err := n.sendPayload(BODIES, b, cxid); err != nil {
+		n.log.WithField("client_id", cxid).Error("Error sending bodies: ", err)
+	}
+}
+
+// handleBodies decodes the received bodies, recomputing every transaction
+// hash so the downloader can check them against the header roots.
+func (n *Node) handleBodies(msg *Message, cxid string) {
+	data, ok := n.readPayload(msg, cxid)
+	if !ok {
+		return
+	}
+
+	var raws []struct {
+		Hash         common.Hash       `json:"hash"`
+		Transactions []json.RawMessage `json:"transactions"`
+		Evidence     []block.Evidence  `json:"evidence"`
+	}
+	if err := json.Unmarshal(data, &raws); err != nil {
+		n.log.WithField("client_id", cxid).Error(err)
+		n.penalise(cxid, offenceInvalidMessage)
+		return
+	}
+
+	bodies := make([]chainsync.Body, 0, len(raws))
+	for _, raw := range raws {
+		txs := make([]transaction.Transaction, 0, len(raw.Transactions))
+		for _, rawTx := range raw.Transactions {
+			tx, err := transaction.DecodeTransaction(rawTx)
+			if err != nil {
+				n.log.WithField("client_id", cxid).Error(err)
+				n.penalise(cxid, offenceInvalidMessage)
+				return
+			}
+
+			txs = append(txs, *tx)
+		}
+
+		bodies = append(bodies, chainsync.Body{Hash: raw.Hash, Transactions: txs, Evidence: raw.Evidence})
+	}
+
+	if !n.downloader.DeliverBodies(cxid, bodies) {
+		n.penalise(cxid, offenceUnrequested)
+	}
+}
+
+// syncNetwork exposes the connected peers to the downloader.
+type syncNetwork struct {
+	node *Node
+}
+
+func (s *syncNetwork) Peers() []chainsync.Peer {
+	peers := make([]chainsync.Peer, 0)
+	for cxid, peer := range s.node.peerSnapshot() {
+		if cxid == s.node.self.CXID() {
+			continue
+		}
+
+		peers = append(peers, &syncPeer{node: s.node, peer: peer})
+	}
+
+	return peers
+}
+
+type syncPeer struct {
+	node *Node
+	peer *p2p.Peer
+}
+
+func (p *syncPeer) ID() string {
+	return p.peer.CXID()
+}
+
+func (p *syncPeer) Head() (common.Hash, uint64, uint64) {
+	return p.peer.Head()
+}
+
+func (p *syncPeer) RequestHeaders(origin uint64, amount uint64) error {
+	b, err := json.Marshal(chainsync.HeadersRequest{Origin: origin, Amount: amount})
+	if err != nil {
+		return err
+	}
+
+	return p.node.sendPayload(GET_HEADERS, b, p.peer.CXID())
+}
+
+func (p *syncPeer) RequestBodies(hashes []common.Hash) error {
+	return p.node.sendPayload(GET_BODIES, encodeHashes(hashes), p.peer.CXID())
+}
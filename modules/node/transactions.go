@@ -0,0 +1,309 @@
+package node
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/polarysfoundation/polarys-chain/modules/common"
+	"github.com/polarysfoundation/polarys-chain/modules/core/transaction"
+	"github.com/polarysfoundation/polarys-chain/modules/core/txpool"
+	"github.com/polarysfoundation/polarys-chain/modules/p2p"
+)
+
+const (
+	txAnnounceInterval = 2 * time.Second
+	txRequestTimeout   = 30 // seconds before a missing tx is requested again
+	maxTxsPerMessage   = 256
+)
+
+// propagateTransactions periodically announces the hashes of pool
+// transactions to every peer that does not know them yet.
+func (n *Node) propagateTransactions() {
+	for {
+		time.Sleep(txAnnounceInterval)
+
+		pending := n.bc.PendingTransactions()
+		if len(pending) == 0 {
+			continue
+		}
+
+		for cxid, peer := range n.peerSnapshot() {
+			hashes := make([]common.Hash, 0)
+			for i := range pending {
+				hash := pending[i].Hash()
+				if peer.KnowsTransaction(hash) {
+					continue
+				}
+				hashes = append(hashes, hash)
+			}
+
+			for len(hashes) > 0 {
+				batch := hashes[:min(len(hashes), maxTxsPerMessage)]
+				hashes = hashes[len(batch):]
+
+				if err := n.sendPayload(TX_HASH, encodeHashes(batch), cxid); err != nil {
+					n.log.WithField("client_id", cxid).Error("Error announcing transactions: ", err)
+					break
+				}
+
+				for _, hash := range batch {
+					peer.MarkTransaction(hash)
+				}
+			}
+		}
+	}
+}
+
+// handleTxHashes answers a transaction announcement by asking the sender
+// for the transactions that are neither local nor already requested.
+func (n *Node) handleTxHashes(msg *Message, cxid string) {
+	data, ok := n.readPayload(msg, cxid)
+	if !ok {
+		return
+	}
+
+	hashes, err := decodeHashes(data)
+	if err != nil {
+		n.log.WithField("client_id", cxid).Error(err)
+		n.penalise(cxid, offenceInvalidMessage)
+		return
+	}
+
+	if len(hashes) > maxTxsPerMessage {
+		n.log.WithField("client_id", cxid).Error("Too many transactions announced in message")
+		n.penalise(cxid, offenceInvalidMessage)
+		return
+	}
+
+	// The pool lookups may read the database, keep them out of the node
+	// lock.
+	peer := n.peerByCXID(cxid)
+	unknown := make([]common.Hash, 0, len(hashes))
+	for _, hash := range hashes {
+		if peer != nil {
+			peer.MarkTransaction(hash)
+		}
+
+		if !n.bc.HasTransaction(hash) {
+			unknown = append(unknown, hash)
+		}
+	}
+
+	now := uint64(time.Now().Unix())
+	missing := make([]common.Hash, 0, len(unknown))
+
+	n.mu.Lock()
+	for _, hash := range unknown {
+		if requested, ok := n.txsRequested[hash]; ok && now-requested < txRequestTimeout {
+			continue
+		}
+
+		n.txsRequested[hash] = now
+		missing = append(missing, hash)
+	}
+
+	for hash, requested := range n.txsRequested {
+		if now-requested >= txRequestTimeout {
+			delete(n.txsRequested, hash)
+		}
+	}
+	n.mu.Unlock()
+
+	if len(missing) == 0 {
+		return
+	}
+
+	if err := n.sendPayload(TX_ASK, encodeHashes(missing), cxid); err != nil {
+		n.log.WithField("client_id", cxid).Error("Error requesting transactions: ", err)
+	}
+}
+
+// handleTxAsk sends back the requested transactions found in the pool.
+func (n *Node) handleTxAsk(msg *Message, cxid string) {
+	data, ok := n.readPayload(msg, cxid)
+	if !ok {
+		return
+	}
+
+	hashes, err := decodeHashes(data)
+	if err != nil {
+		n.log.WithField("client_id", cxid).Error(err)
+		n.penalise(cxid, offenceInvalidMessage)
+		return
+	}
+
+	if len(hashes) > maxTxsPerMessage {
+		hashes = hashes[:maxTxsPerMessage]
+	}
+
+	peer := n.peerByCXID(cxid)
+	txs := make([]*transaction.Transaction, 0, len(hashes))
+	for _, hash := range hashes {
+		tx, ok := n.bc.GetPoolTransaction(hash)
+		if !ok {
+			continue
+		}
+
+		txs = append(txs, tx)
+		if peer != nil {
+			peer.MarkTransaction(hash)
+		}
+	}
+
+	if len(txs) == 0 {
+		return
+	}
+
+	b, err := json.Marshal(txs)
+	if err != nil {
+		n.log.WithField("client_id", cxid).Error(err)
+		return
+	}
+
+	if err := n.sendPayload(TRANSACTION, b, cxid); err != nil {
+		n.log.WithField("client_id", cxid).Error("Error sending transactions: ", err)
+	}
+}
+
+// handleTransactions validates received transactions and inserts them
+// into the local pool. Accepted transactions are announced to the other
+// peers by propagateTransactions.
+func (n *Node) handleTransactions(msg *Message, cxid string) {
+	data, ok := n.readPayload(msg, cxid)
+	if !ok {
+		return
+	}
+
+	var raws []json.RawMessage
+	if err := json.Unmarshal(data, &raws); err != nil {
+		n.log.WithField("client_id", cxid).Error(err)
+		n.penalise(cxid, offenceInvalidMessage)
+		return
+	}
+
+	if len(raws) > maxTxsPerMessage {
+		n.log.WithField("client_id", cxid).Error("Too many transactions in message")
+		n.penalise(cxid, offenceInvalidMessage)
+		return
+	}
+
+	peer := n.peerByCXID(cxid)
+	unrequested := false
+	for _, raw := range raws {
+		tx, err := transaction.DecodeTransaction(raw)
+		if err != nil {
+			n.log.WithField("client_id", cxid).Error(err)
+			n.penalise(cxid, offenceInvalidMessage)
+			return
+		}
+
+		if peer != nil {
+			peer.MarkTransaction(tx.Hash())
+		}
+
+		// Transactions are only sent in answer to a TX_ASK.
+		n.mu.Lock()
+		if _, ok := n.txsRequested[tx.Hash()]; !ok {
+			unrequested = true
+		}
+		delete(n.txsRequested, tx.Hash())
+		n.mu.Unlock()
+
+		if err := n.bc.AddRemoteTransaction(tx); err != nil {
+			if errors.Is(err, txpool.ErrAlreadyExist) || errors.Is(err, txpool.ErrAlreadyKnown) {
+				continue
+			}
+
+			n.log.WithField("client_id", cxid).WithField("hash", tx.Hash().String()).Debug("Remote transaction rejected: ", err)
+			continue
+		}
+
+		n.log.WithField("client_id", cxid).WithField("hash", tx.Hash().String()).Info("Transaction received")
+	}
+
+	if unrequested {
+		n.penalise(cxid, offenceUnrequested)
+	}
+}
+
+// readPayload verifies the message signature and returns its data.
+func (n *Node) readPayload(msg *Message, cxid string) ([]byte, bool) {
+	ok, err := n.verifyMessage(msg)
+	if err != nil {
+		n.log.WithField("client_id", cxid).Error(err)
+		n.penalise(cxid, offenceInvalidMessage)
+		return nil, false
+	}
+
+	if !ok {
+		n.log.WithField("client_id", cxid).Error("Invalid signature")
+		n.penalise(cxid, offenceInvalidSignature)
+		return nil, false
+	}
+
+	data, err := msg.DecodeData()
+	if err != nil {
+		n.log.WithField("client_id", cxid).Error(err)
+		n.penalise(cxid, offenceInvalidMessage)
+		return nil, false
+	}
+
+	return data, true
+}
+
+// sendPayload builds, signs and sends a message to a single peer.
+func (n *Node) sendPayload(t Type, data []byte, cxid string) error {
+	msg, err := NewMessage(t, data, n.pubKey)
+	if err != nil {
+		return err
+	}
+
+	msg, err = n.signMessage(msg)
+	if err != nil {
+		return err
+	}
+
+	return n.sendMessage(cxid, msg)
+}
+
+func (n *Node) peerSnapshot() map[string]*p2p.Peer {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	peers := make(map[string]*p2p.Peer, len(n.peers))
+	for cxid, peer := range n.peers {
+		peers[cxid] = peer
+	}
+
+	return peers
+}
+
+func (n *Node) peerByCXID(cxid string) *p2p.Peer {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	return n.peers[cxid]
+}
+
+func encodeHashes(hashes []common.Hash) []byte {
+	b := make([]byte, 0, len(hashes)*common.HashLen)
+	for _, hash := range hashes {
+		b = append(b, hash.Bytes()...)
+	}
+
+	return b
+}
+
+func decodeHashes(b []byte) ([]common.Hash, error) {
+	if len(b)%common.HashLen != 0 {
+		return nil, ErrInvalidHashList
+	}
+
+	hashes := make([]common.Hash, 0, len(b)/common.HashLen)
+	for i := 0; i < len(b); i += common.HashLen {
+		hashes = append(hashes, common.BytesToHash(b[i:i+common.HashLen]))
+	}
+
+	return hashes, nil
+}
@@ -0,0 +1,209 @@
+package node
+
+import (
+	"encoding/json"
+	"errors"
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/polarysfoundation/polarys-chain/modules/common"
+	"github.com/polarysfoundation/polarys-chain/modules/core/transaction"
+)
+
+// txChain is a Chain that only knows about transactions.
+type txChain struct {
+	Chain
+
+	known map[common.Hash]bool
+	added []common.Hash
+}
+
+func (c *txChain) HasTransaction(hash common.Hash) bool {
+	return c.known[hash]
+}
+
+func (c *txChain) AddRemoteTransaction(tx *transaction.Transaction) error {
+	c.added = append(c.added, tx.Hash())
+	return nil
+}
+
+func newTxNode(t *testing.T) (*Node, *txChain) {
+	t.Helper()
+
+	chain := &txChain{known: make(map[common.Hash]bool)}
+
+	n := newReputationNode(t, nil)
+	n.txsRequested = make(map[common.Hash]uint64)
+	n.bc = chain
+
+	return n, chain
+}
+
+// newTxMessage builds a message of type typ signed by from.
+func newTxMessage(t *testing.T, from *Node, typ Type, data []byte) *Message {
+	t.Helper()
+
+	msg, err := NewMessage(typ, data, from.pubKey)
+	if err != nil {
+		t.Fatalf("NewMessage() error = %v", err)
+	}
+
+	msg, err = from.signMessage(msg)
+	if err != nil {
+		t.Fatalf("signMessage() error = %v", err)
+	}
+
+	return msg
+}
+
+func newTestHashes(n int) []common.Hash {
+	hashes := make([]common.Hash, 0, n)
+	for i := 0; i < n; i++ {
+		hashes = append(hashes, common.BytesToHash(big.NewInt(int64(i+1)).Bytes()))
+	}
+
+	return hashes
+}
+
+func TestHashes_EncodeDecode(t *testing.T) {
+	hashes := newTestHashes(3)
+
+	got, err := decodeHashes(encodeHashes(hashes))
+	if err != nil {
+		t.Fatalf("decodeHashes() error = %v", err)
+	}
+
+	if !reflect.DeepEqual(got, hashes) {
+		t.Errorf("decodeHashes() = %v, want %v", got, hashes)
+	}
+
+	// Decoding does not cap the list, the handlers do.
+	many := newTestHashes(maxTxsPerMessage + 1)
+	if got, err := decodeHashes(encodeHashes(many)); err != nil || len(got) != len(many) {
+		t.Errorf("decodeHashes() of %d hashes = %d, %v", len(many), len(got), err)
+	}
+
+	truncated := encodeHashes(hashes)
+	if _, err := decodeHashes(truncated[:len(truncated)-1]); !errors.Is(err, ErrInvalidHashList) {
+		t.Errorf("decodeHashes() of truncated input error = %v, want %v", err, ErrInvalidHashList)
+	}
+}
+
+func TestHandleTxHashes_AsksUnknown(t *testing.T) {
+	n, chain := newTxNode(t)
+	remote := newTestNode()
+	cxid := remote.self.CXID()
+
+	sr, sn, errR, errN := handshake(t, remote, n)
+	if errR != nil || errN != nil {
+		t.Fatalf("handshake errors = %v, %v", errR, errN)
+	}
+	n.peerConnections[cxid] = sn
+
+	hashes := newTestHashes(4)
+	known, requested, unknown := hashes[0], hashes[1], hashes[2:]
+
+	chain.known[known] = true
+	n.txsRequested[requested] = uint64(time.Now().Unix())
+
+	go n.handleTxHashes(newTxMessage(t, remote, TX_HASH, encodeHashes(hashes)), cxid)
+
+	sr.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	msg, err := sr.ReadMessage()
+	if err != nil {
+		t.Fatalf("ReadMessage() error = %v", err)
+	}
+
+	if msg.Type != TX_ASK {
+		t.Fatalf("message type = %d, want %d", msg.Type, TX_ASK)
+	}
+
+	data, _ := msg.DecodeData()
+	asked, err := decodeHashes(data)
+	if err != nil {
+		t.Fatalf("decodeHashes() error = %v", err)
+	}
+
+	if !reflect.DeepEqual(asked, unknown) {
+		t.Errorf("asked for %v, want %v", asked, unknown)
+	}
+
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	for _, hash := range unknown {
+		if _, ok := n.txsRequested[hash]; !ok {
+			t.Errorf("request for %v not recorded", hash)
+		}
+	}
+}
+
+func TestHandleTxHashes_TooMany(t *testing.T) {
+	n, _ := newTxNode(t)
+	remote := newTestNode()
+	cxid := remote.self.CXID()
+
+	hashes := newTestHashes(maxTxsPerMessage + 1)
+	n.handleTxHashes(newTxMessage(t, remote, TX_HASH, encodeHashes(hashes)), cxid)
+
+	if len(n.txsRequested) != 0 {
+		t.Errorf("%d hashes requested from an oversized announcement", len(n.txsRequested))
+	}
+
+	if score := n.scores[cxid]; score == nil || score.score >= 0 {
+		t.Errorf("peer announcing too many hashes not penalised")
+	}
+}
+
+func TestHandleTransactions(t *testing.T) {
+	n, chain := newTxNode(t)
+	remote := newTestNode()
+	cxid := remote.self.CXID()
+
+	from := common.BytesToAddress([]byte("sender_address_"))
+	to := common.BytesToAddress([]byte("receiver_addres"))
+
+	var txs []*transaction.Transaction
+	for i := 0; i < 2; i++ {
+		tx, err := transaction.NewTransaction(from, to, big.NewInt(1000), nil, uint64(i), 0, transaction.Legacy, nil, 1000000)
+		if err != nil {
+			t.Fatalf("NewTransaction() error = %v", err)
+		}
+		txs = append(txs, tx)
+	}
+
+	send := func(txs ...*transaction.Transaction) {
+		b, err := json.Marshal(txs)
+		if err != nil {
+			t.Fatalf("Marshal() error = %v", err)
+		}
+
+		n.handleTransactions(newTxMessage(t, remote, TRANSACTION, b), cxid)
+	}
+
+	// A requested transaction is accepted and no longer waited for.
+	n.txsRequested[txs[0].Hash()] = uint64(time.Now().Unix())
+	send(txs[0])
+
+	if _, ok := n.txsRequested[txs[0].Hash()]; ok {
+		t.Errorf("request still open after the transaction arrived")
+	}
+
+	if n.scores[cxid] != nil && n.scores[cxid].score < 0 {
+		t.Errorf("peer penalised for a requested transaction")
+	}
+
+	// Nobody asked for the second one.
+	send(txs[1])
+
+	if score := n.scores[cxid]; score == nil || score.score >= 0 {
+		t.Errorf("peer sending an unrequested transaction not penalised")
+	}
+
+	want := []common.Hash{txs[0].Hash(), txs[1].Hash()}
+	if !reflect.DeepEqual(chain.added, want) {
+		t.Errorf("transactions added = %v, want %v", chain.added, want)
+	}
+}
@@ -0,0 +1,37 @@
+package p2p
+
+import "github.com/polarysfoundation/polarys-chain/modules/common"
+
+// knownSet is a bounded set of hashes a peer already has. Once full, the
+// oldest entries are evicted first. It is guarded by the peer lock.
+type knownSet struct {
+	items map[common.Hash]struct{}
+	order []common.Hash // insertion order, used for eviction
+	limit int
+}
+
+func newKnownSet(limit int) *knownSet {
+	return &knownSet{
+		items: make(map[common.Hash]struct{}),
+		limit: limit,
+	}
+}
+
+func (k *knownSet) add(hash common.Hash) {
+	if _, ok := k.items[hash]; ok {
+		return
+	}
+
+	for len(k.order) >= k.limit {
+		delete(k.items, k.order[0])
+		k.order = k.order[1:]
+	}
+
+	k.items[hash] = struct{}{}
+	k.order = append(k.order, hash)
+}
+
+func (k *knownSet) has(hash common.Hash) bool {
+	_, ok := k.items[hash]
+	return ok
+}
@@ -2,12 +2,20 @@ package p2p
 
 import (
 	"net"
+	"sync"
 
 	pec256 "github.com/polarysfoundation/pec-256"
 	"github.com/polarysfoundation/polarys-chain/modules/common"
 	"github.com/polarysfoundation/polarys-chain/modules/crypto"
 )
 
+// Bounds of the sets of hashes remembered per peer.
+const (
+	maxKnownTxs          = 32768
+	maxKnownAttestations = 4096
+	maxKnownEvidence     = 1024
+)
+
 type Peer struct {
 	id       []byte        // ID node
 	addr     *net.TCPAddr  // ip:port
@@ -15,6 +23,15 @@ type Peer struct {
 	pubKey   pec256.PubKey // public key
 	lastSeen uint64        // last seen time
 	nonces   [][]byte
+
+	headHash   common.Hash // latest block announced by the peer
+	headHeight uint64
+	headTD     uint64
+
+	knownTxs      *knownSet // tx hashes the peer already has
+	knownAtts     *knownSet // attestation ids the peer already has
+	knownEvidence *knownSet // evidence hashes the peer already has
+	lock          sync.RWMutex
 }
 
 func NewPeer(addr *net.TCPAddr, version uint32, pubKey pec256.PubKey, lastSeen uint64) *Peer {
@@ -22,12 +39,15 @@ func NewPeer(addr *net.TCPAddr, version uint32, pubKey pec256.PubKey, lastSeen u
 	id := crypto.Pm256(pubKey.Bytes())
 
 	return &Peer{
-		id:       id,
-		addr:     addr,
-		version:  version,
-		pubKey:   pubKey,
-		lastSeen: lastSeen,
-		nonces:   make([][]byte, 0),
+		id:            id,
+		addr:          addr,
+		version:       version,
+		pubKey:        pubKey,
+		lastSeen:      lastSeen,
+		nonces:        make([][]byte, 0),
+		knownTxs:      newKnownSet(maxKnownTxs),
+		knownAtts:     newKnownSet(maxKnownAttestations),
+		knownEvidence: newKnownSet(maxKnownEvidence),
 	}
 }
 
@@ -40,9 +60,21 @@ func (p *Peer) CXID() string {
 }
 
 func (p *Peer) Addr() *net.TCPAddr {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
 	return p.addr
 }
 
+// SetAddr replaces the address of the peer, for example with the listening
+// address it announced once connected.
+func (p *Peer) SetAddr(addr *net.TCPAddr) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.addr = addr
+}
+
 func (p *Peer) Version() uint32 {
 	return p.version
 }
@@ -72,3 +104,67 @@ func (p *Peer) ValidNonce(nonce []byte) bool {
 
 	return false
 }
+
+// MarkTransaction records that the peer knows the transaction, evicting the
+// oldest entries once maxKnownTxs is reached.
+func (p *Peer) MarkTransaction(hash common.Hash) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.knownTxs.add(hash)
+}
+
+func (p *Peer) KnowsTransaction(hash common.Hash) bool {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	return p.knownTxs.has(hash)
+}
+
+// MarkAttestation records that the peer knows the attestation, evicting
+// the oldest entries once maxKnownAttestations is reached.
+func (p *Peer) MarkAttestation(id common.Hash) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.knownAtts.add(id)
+}
+
+func (p *Peer) KnowsAttestation(id common.Hash) bool {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	return p.knownAtts.has(id)
+}
+
+// MarkEvidence records that the peer knows the double-signing evidence.
+func (p *Peer) MarkEvidence(hash common.Hash) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.knownEvidence.add(hash)
+}
+
+func (p *Peer) KnowsEvidence(hash common.Hash) bool {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	return p.knownEvidence.has(hash)
+}
+
+// SetHead records the chain head announced by the peer.
+func (p *Peer) SetHead(hash common.Hash, height uint64, totalDifficulty uint64) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.headHash = hash
+	p.headHeight = height
+	p.headTD = totalDifficulty
+}
+
+func (p *Peer) Head() (common.Hash, uint64, uint64) {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	return p.headHash, p.headHeight, p.headTD
+}
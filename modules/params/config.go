@@ -1,12 +1,28 @@
 package params
 
 type Config struct {
-	MaxProposalSize int64 `mapstructure:"max_proposal_size"`
-	MaxTxSize       int64 `mapstructure:"max_tx_size"`
-	MaxBlockSize    int64 `mapstructure:"max_block_size"`
-	MaxTxPerBlock   int64 `mapstructure:"max_tx_per_block"`
-	MinimalGasTip   int64 `mapstructure:"minimal_gas_tip"`
-	
+	MaxProposalSize int64  `mapstructure:"max_proposal_size"`
+	MaxTxSize       int64  `mapstructure:"max_tx_size"`
+	MaxBlockSize    int64  `mapstructure:"max_block_size"`
+	MaxTxPerBlock   int64  `mapstructure:"max_tx_per_block"`
+	MinimalGasTip   int64  `mapstructure:"minimal_gas_tip"`
+	RPCEnabled      bool   `mapstructure:"rpc_enabled"`
+	RPCAddr         string `mapstructure:"rpc_addr"`
+
+	RPCAllowedOrigins []string `mapstructure:"rpc_allowed_origins"` // browser origins besides the node's own, "*" for any
+
+	TxPoolPriceBump    int64  `mapstructure:"txpool_price_bump"` // percent
+	TxPoolAccountSlots int64  `mapstructure:"txpool_account_slots"`
+	TxPoolAccountQueue int64  `mapstructure:"txpool_account_queue"`
+	TxPoolGlobalSlots  int64  `mapstructure:"txpool_global_slots"`
+	TxPoolGlobalQueue  int64  `mapstructure:"txpool_global_queue"`
+	TxPoolLifetime     int64  `mapstructure:"txpool_lifetime"`  // seconds
+	TxPoolJournal      string `mapstructure:"txpool_journal"`   // relative to the data directory
+	TxPoolRejournal    int64  `mapstructure:"txpool_rejournal"` // seconds
+
+	Bootnodes           []string `mapstructure:"bootnodes"`
+	TargetOutboundPeers int      `mapstructure:"target_outbound_peers"`
+	PeerBanDuration     int64    `mapstructure:"peer_ban_duration"` // seconds
 }
 
 func LoadConfig() *Config {
@@ -14,31 +14,114 @@ var (
 		MaxTxSize:       1024 * 1024,
 		MaxBlockSize:    1024 * 1024,
 		MaxTxPerBlock:   1000,
+		RPCEnabled:      true,
+		RPCAddr:         "127.0.0.1:5866",
+
+		RPCAllowedOrigins: []string{},
+
+		TxPoolPriceBump:    10,
+		TxPoolAccountSlots: 16,
+		TxPoolAccountQueue: 64,
+		TxPoolGlobalSlots:  4096,
+		TxPoolGlobalQueue:  1024,
+		TxPoolLifetime:     3 * 60 * 60,
+		TxPoolJournal:      "txpool/transactions.journal",
+		TxPoolRejournal:    60 * 60,
+
+		Bootnodes:           []string{},
+		TargetOutboundPeers: 8,
+		PeerBanDuration:     24 * 60 * 60,
 	}
 
 	Polarys = &ChainParams{
 		PolarysBlock: big.NewInt(0),
 		ChainID:      0,
+		Engine:       PowEngineName,
 		PowEngine: PowEngine{
 			Epoch:      1000,
 			Difficulty: 100,
 			Delay:      10,
 		},
+		PosEngine: PosEngine{
+			Epoch:    1000,
+			Delay:    10,
+			MinStake: 1000000000000000000,
+		},
+		PoaEngine: PoaEngine{
+			Epoch: 1000,
+			Delay: 10,
+		},
 	}
 )
 
+const (
+	PowEngineName = "pow_engine"
+	PosEngineName = "pos_engine"
+	PoaEngineName = "poa_engine"
+)
+
 type ChainParams struct {
 	PolarysBlock *big.Int
 	ChainID      uint64
+	Engine       string // Name of the consensus engine the chain runs on
 	PowEngine    PowEngine
+	PosEngine    PosEngine
+	PoaEngine    PoaEngine
+}
+
+// Epoch returns the epoch length of the configured engine.
+func (p *ChainParams) Epoch() uint64 {
+	switch p.Engine {
+	case PosEngineName:
+		return p.PosEngine.Epoch
+	case PoaEngineName:
+		return p.PoaEngine.Epoch
+	default:
+		return p.PowEngine.Epoch
+	}
+}
+
+// Delay returns the target block time, in seconds, of the configured
+// engine.
+func (p *ChainParams) Delay() uint64 {
+	switch p.Engine {
+	case PosEngineName:
+		return p.PosEngine.Delay
+	case PoaEngineName:
+		return p.PoaEngine.Delay
+	default:
+		return p.PowEngine.Delay
+	}
 }
 
 type PowEngine struct {
 	Epoch      uint64
 	Difficulty uint64
 	Delay      uint64
+	Validators []common.Address // Validators taking turns to propose
 }
 
 func (c *PowEngine) String() string {
-	return "pow_engine"
+	return PowEngineName
+}
+
+type PosEngine struct {
+	Epoch      uint64
+	Delay      uint64
+	MinStake   uint64           // Stake worth one unit of voting power
+	Validators []common.Address // Validators proposing until someone stakes
+}
+
+func (c *PosEngine) String() string {
+	return PosEngineName
+}
+
+type PoaEngine struct {
+	Epoch   uint64
+	Delay   uint64
+	Signers []common.Address // Signers authorised from genesis
+}
+
+func (c *PoaEngine) String() string {
+	return PoaEngineName
 }
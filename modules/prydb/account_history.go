@@ -0,0 +1,57 @@
+package prydb
+
+import (
+	"sort"
+
+	"github.com/polarysfoundation/polarys-chain/modules/common"
+)
+
+// accountHistory lists, in ascending order, the block heights at which an
+// account record was written.
+type accountHistory struct {
+	Address common.Address `json:"address"`
+	Heights []uint64       `json:"heights"`
+}
+
+// latestAt returns the highest recorded height that is not above height.
+func (h *accountHistory) latestAt(height uint64) (uint64, bool) {
+	i := sort.Search(len(h.Heights), func(i int) bool {
+		return h.Heights[i] > height
+	})
+
+	if i == 0 {
+		return 0, false
+	}
+
+	return h.Heights[i-1], true
+}
+
+// add inserts height keeping the list sorted and reports whether it was
+// missing.
+func (h *accountHistory) add(height uint64) bool {
+	i := sort.Search(len(h.Heights), func(i int) bool {
+		return h.Heights[i] >= height
+	})
+
+	if i < len(h.Heights) && h.Heights[i] == height {
+		return false
+	}
+
+	h.Heights = append(h.Heights, 0)
+	copy(h.Heights[i+1:], h.Heights[i:])
+	h.Heights[i] = height
+
+	return true
+}
+
+// truncate removes the heights above height and returns them.
+func (h *accountHistory) truncate(height uint64) []uint64 {
+	i := sort.Search(len(h.Heights), func(i int) bool {
+		return h.Heights[i] > height
+	})
+
+	dropped := append([]uint64(nil), h.Heights[i:]...)
+	h.Heights = h.Heights[:i]
+
+	return dropped
+}
@@ -0,0 +1,48 @@
+package prydb
+
+import "encoding/json"
+
+// BanRecord is an entry of the persisted ban list: a node id, the unix
+// time the ban ends and why it was banned.
+type BanRecord struct {
+	ID     string `json:"id"`
+	Until  uint64 `json:"until"`
+	Reason string `json:"reason"`
+}
+
+func (db *Database) WriteBan(record *BanRecord) error {
+	return db.db.Write(bans, record.ID, record)
+}
+
+func (db *Database) DeleteBan(id string) error {
+	if _, ok := db.db.Read(bans, id); !ok {
+		return nil
+	}
+
+	return db.db.Delete(bans, id)
+}
+
+// Bans returns every entry of the ban list, expired ones included.
+func (db *Database) Bans() ([]*BanRecord, error) {
+	data, err := db.db.ReadBatch(bans)
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]*BanRecord, 0, len(data))
+	for _, v := range data {
+		var record *BanRecord
+		b, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := json.Unmarshal(b, &record); err != nil {
+			return nil, err
+		}
+
+		records = append(records, record)
+	}
+
+	return records, nil
+}
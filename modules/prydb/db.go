@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"strconv"
+	"sync"
 
 	"github.com/polarysfoundation/polarys-chain/modules/common"
 	"github.com/polarysfoundation/polarys-chain/modules/core/block"
@@ -15,6 +16,8 @@ type Database struct {
 	db             *polarysdb.Database
 	cachedAccounts map[common.Address]*account
 	cachedTxPools  map[common.Address]*txPool
+
+	mu sync.Mutex
 }
 
 func InitDB() (*Database, error) {
@@ -83,12 +86,36 @@ func (db *Database) initialize() error {
 		}
 	}
 
+	if !db.db.Exist(accountHistories) {
+		if err := db.db.Create(accountHistories); err != nil {
+			return err
+		}
+	}
+
 	if !db.db.Exist(transactionsRejecteds) {
 		if err := db.db.Create(transactionsRejecteds); err != nil {
 			return err
 		}
 	}
 
+	if !db.db.Exist(peers) {
+		if err := db.db.Create(peers); err != nil {
+			return err
+		}
+	}
+
+	if !db.db.Exist(bans) {
+		if err := db.db.Create(bans); err != nil {
+			return err
+		}
+	}
+
+	if !db.db.Exist(finality) {
+		if err := db.db.Create(finality); err != nil {
+			return err
+		}
+	}
+
 	return nil
 
 }
@@ -113,6 +140,23 @@ func (db *Database) commitBlock(block *block.Block) error {
 	return nil
 }
 
+// WriteBlock stores a block by hash only. Side-chain blocks are kept this
+// way until a reorganisation makes them canonical.
+func (db *Database) WriteBlock(block *block.Block) error {
+	return db.db.Write(blocksByHash, block.Hash().CXID(), block)
+}
+
+// SetLatestBlock moves the head of the canonical chain.
+func (db *Database) SetLatestBlock(block *block.Block) error {
+	return db.db.Write(blocksLatest, "latest", block)
+}
+
+// DeleteCanonicalBlock removes the canonical entry at height. The block is
+// still reachable by hash.
+func (db *Database) DeleteCanonicalBlock(height uint64) error {
+	return db.db.Delete(blocksByHeight, strconv.FormatUint(height, 10))
+}
+
 func (db *Database) LatestBlock() (*block.Block, error) {
 	data, ok := db.db.Read(blocksLatest, "latest")
 	if !ok {
@@ -176,6 +220,18 @@ func (db *Database) CommitTransaction(transaction *transaction.Transaction, bloc
 }
 
 func (db *Database) commitTransaction(transaction *transaction.Transaction, block *block.Block) error {
+	tables := []string{
+		fmt.Sprintf(transactionsByAccount, transaction.From().CXID()),
+		fmt.Sprintf(transactionsByBlockHash, block.Hash().CXID()),
+		fmt.Sprintf(transactionsByBlockHeight, strconv.FormatUint(block.Height(), 10)),
+	}
+
+	for _, table := range tables {
+		if err := db.ensureTable(table); err != nil {
+			return err
+		}
+	}
+
 	if err := db.db.Write(transactionsByHash, transaction.Hash().CXID(), transaction); err != nil {
 		return err
 	}
@@ -196,6 +252,29 @@ func (db *Database) commitTransaction(transaction *transaction.Transaction, bloc
 
 }
 
+// DeleteTransaction removes the confirmed indexes of a transaction that
+// belonged to a block dropped from the canonical chain. The index by block
+// hash is kept together with the block.
+func (db *Database) DeleteTransaction(transaction *transaction.Transaction, block *block.Block) error {
+	if err := db.db.Delete(transactionsByHash, transaction.Hash().CXID()); err != nil {
+		return err
+	}
+
+	if table := fmt.Sprintf(transactionsByAccount, transaction.From().CXID()); db.db.Exist(table) {
+		if err := db.db.Delete(table, transaction.Hash().CXID()); err != nil {
+			return err
+		}
+	}
+
+	if table := fmt.Sprintf(transactionsByBlockHeight, strconv.FormatUint(block.Height(), 10)); db.db.Exist(table) {
+		if err := db.db.Delete(table, transaction.Hash().CXID()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func (db *Database) BlockHasTransaction(hash common.Address, block *block.Block) (bool, error) {
 	_, ok := db.db.Read(fmt.Sprintf(transactionsByBlockHash, block.Hash().CXID()), hash.CXID())
 	if !ok {
@@ -390,6 +469,9 @@ func (db *Database) InitAccountState(address common.Address, code []byte, block
 }
 
 func (db *Database) BalanceAt(address common.Address, block *block.Block) (uint64, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
 	var err error
 	if block == nil {
 		block, err = db.LatestBlock()
@@ -420,7 +502,35 @@ func (db *Database) BalanceAt(address common.Address, block *block.Block) (uint6
 	return acc.balance, nil
 }
 
+func (db *Database) NonceAt(address common.Address, block *block.Block) (uint64, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	var err error
+	if block == nil {
+		block, err = db.LatestBlock()
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	acc, ok := db.cachedAccounts[address]
+	if !ok || block.Height() != acc.latestUpdate {
+		acc, err = db.getAccount(address, block)
+		if err != nil {
+			return 0, err
+		}
+
+		db.cachedAccounts[address] = acc
+	}
+
+	return acc.nonce, nil
+}
+
 func (db *Database) CodeAt(address common.Address, block *block.Block) ([]byte, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
 	var err error
 	if block == nil {
 		block, err = db.LatestBlock()
@@ -452,6 +562,9 @@ func (db *Database) CodeAt(address common.Address, block *block.Block) ([]byte,
 }
 
 func (db *Database) UpdateBalance(address common.Address, amount uint64, block *block.Block) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
 	var err error
 	if block == nil {
 		block, err = db.LatestBlock()
@@ -486,8 +599,31 @@ func (db *Database) UpdateBalance(address common.Address, amount uint64, block *
 
 }
 
+// getAccount returns the account as of the given block, that is the most
+// recent record written at or below the block height.
 func (db *Database) getAccount(address common.Address, block *block.Block) (*account, error) {
-	data, ok := db.db.Read(fmt.Sprintf(accounts, strconv.FormatUint(block.Height(), 10)), address.CXID())
+	acc, err := db.getAccountAt(address, block.Height())
+	if err != nil {
+		return nil, err
+	}
+
+	db.cachedAccounts[address] = acc
+
+	return acc, nil
+}
+
+func (db *Database) getAccountAt(address common.Address, height uint64) (*account, error) {
+	history, err := db.getAccountHistory(address)
+	if err != nil {
+		return nil, err
+	}
+
+	recordHeight, ok := history.latestAt(height)
+	if !ok {
+		return nil, ErrAccountNotFound
+	}
+
+	data, ok := db.db.Read(fmt.Sprintf(accounts, strconv.FormatUint(recordHeight, 10)), address.CXID())
 	if !ok {
 		return nil, ErrAccountNotFound
 	}
@@ -502,22 +638,163 @@ func (db *Database) getAccount(address common.Address, block *block.Block) (*acc
 		return nil, err
 	}
 
-	db.cachedAccounts[address] = acc
-
 	return acc, nil
 }
 
 func (db *Database) commitAccount(address common.Address, block *block.Block, account *account) error {
-	err := db.db.Write(fmt.Sprintf(accounts, strconv.FormatUint(block.Height(), 10)), address.CXID(), account)
+	table := fmt.Sprintf(accounts, strconv.FormatUint(block.Height(), 10))
+	if err := db.ensureTable(table); err != nil {
+		return err
+	}
+
+	err := db.db.Write(table, address.CXID(), account)
 	if err != nil {
 		return err
 	}
 
+	history, err := db.getAccountHistory(address)
+	if err != nil {
+		if err != ErrAccountNotFound {
+			return err
+		}
+		history = &accountHistory{Address: address}
+	}
+
+	if !history.add(block.Height()) {
+		return nil
+	}
+
+	return db.db.Write(accountHistories, address.CXID(), history)
+}
+
+func (db *Database) getAccountHistory(address common.Address) (*accountHistory, error) {
+	data, ok := db.db.Read(accountHistories, address.CXID())
+	if !ok {
+		return nil, ErrAccountNotFound
+	}
+
+	var history *accountHistory
+	b, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(b, &history); err != nil {
+		return nil, err
+	}
+
+	return history, nil
+}
+
+// RevertAccounts drops every account record written above height, bringing
+// the account state back to the block at that height.
+func (db *Database) RevertAccounts(height uint64) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	addresses, err := db.Accounts()
+	if err != nil {
+		return err
+	}
+
+	for _, address := range addresses {
+		history, err := db.getAccountHistory(address)
+		if err != nil {
+			return err
+		}
+
+		dropped := history.truncate(height)
+		if len(dropped) == 0 {
+			continue
+		}
+
+		for _, h := range dropped {
+			table := fmt.Sprintf(accounts, strconv.FormatUint(h, 10))
+			if !db.db.Exist(table) {
+				continue
+			}
+
+			if err := db.db.Delete(table, address.CXID()); err != nil {
+				return err
+			}
+		}
+
+		if len(history.Heights) == 0 {
+			err = db.db.Delete(accountHistories, address.CXID())
+		} else {
+			err = db.db.Write(accountHistories, address.CXID(), history)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	db.cachedAccounts = make(map[common.Address]*account)
+
 	return nil
+}
+
+// Accounts returns the address of every account ever written.
+func (db *Database) Accounts() ([]common.Address, error) {
+	data, err := db.db.ReadBatch(accountHistories)
+	if err != nil {
+		return nil, err
+	}
+
+	addresses := make([]common.Address, 0, len(data))
+	for _, v := range data {
+		var history *accountHistory
+		b, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := json.Unmarshal(b, &history); err != nil {
+			return nil, err
+		}
+
+		addresses = append(addresses, history.Address)
+	}
+
+	return addresses, nil
+}
+
+// AccountAt returns the nonce, balance and code of an account as of the
+// given block height.
+func (db *Database) AccountAt(address common.Address, height uint64) (uint64, uint64, []byte, error) {
+	acc, err := db.getAccountAt(address, height)
+	if err != nil {
+		return 0, 0, nil, err
+	}
 
+	return acc.nonce, acc.balance, acc.codeHash, nil
+}
+
+// CommitAccount writes the full account record at the block height.
+func (db *Database) CommitAccount(address common.Address, nonce uint64, balance uint64, code []byte, block *block.Block) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	acc := InitAccount(nonce, balance, code, block.Height())
+	db.cachedAccounts[address] = acc
+
+	return db.commitAccount(address, block, acc)
+}
+
+// ensureTable creates tables whose name depends on a block or an account.
+func (db *Database) ensureTable(table string) error {
+	if db.db.Exist(table) {
+		return nil
+	}
+
+	return db.db.Create(table)
 }
 
 func (db *Database) UpdateCode(address common.Address, code []byte, block *block.Block) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
 	var err error
 	if block == nil {
 		block, err = db.LatestBlock()
@@ -552,6 +829,9 @@ func (db *Database) UpdateCode(address common.Address, code []byte, block *block
 }
 
 func (db *Database) TxPoolBalanceAt(address common.Address, block *block.Block) (uint64, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
 	var err error
 	if block == nil {
 		block, err = db.LatestBlock()
@@ -583,6 +863,9 @@ func (db *Database) TxPoolBalanceAt(address common.Address, block *block.Block)
 }
 
 func (db *Database) TxPoolExcutor(address common.Address, block *block.Block) (common.Address, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
 	var err error
 	if block == nil {
 		block, err = db.LatestBlock()
@@ -614,6 +897,9 @@ func (db *Database) TxPoolExcutor(address common.Address, block *block.Block) (c
 }
 
 func (db *Database) TxPoolHash(address common.Address, block *block.Block) (common.Hash, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
 	var err error
 	if block == nil {
 		block, err = db.LatestBlock()
@@ -666,6 +952,9 @@ func (db *Database) getTxPool(address common.Address, block *block.Block) (*txPo
 }
 
 func (db *Database) UpdateTxPoolBalance(address common.Address, amount uint64, block *block.Block) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
 	var err error
 	if block == nil {
 		block, err = db.LatestBlock()
@@ -701,6 +990,9 @@ func (db *Database) UpdateTxPoolBalance(address common.Address, amount uint64, b
 }
 
 func (db *Database) TxPoolExist(address common.Address, block *block.Block) bool {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
 	txpool, err := db.getTxPool(address, block)
 	if err != nil {
 		return false
@@ -719,6 +1011,9 @@ func (db *Database) commitTxPool(address common.Address, block *block.Block, txp
 }
 
 func (db *Database) TxPoolState(address common.Address, block *block.Block) (uint64, uint64, uint64, common.Address, common.Hash, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
 	var err error
 	if block == nil {
 		block, err = db.LatestBlock()
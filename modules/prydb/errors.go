@@ -8,4 +8,5 @@ var (
 	ErrNotTransactionsFound = errors.New("no transactions found")
 	ErrAccountNotFound      = errors.New("account not found")
 	ErrTxPoolNotFound       = errors.New("tx pool not found")
+	ErrFinalityNotFound     = errors.New("no finalized checkpoint")
 )
@@ -0,0 +1,38 @@
+package prydb
+
+import (
+	"encoding/json"
+
+	"github.com/polarysfoundation/polarys-chain/modules/common"
+)
+
+// FinalityRecord is the latest checkpoint finalized by the validators.
+type FinalityRecord struct {
+	Height uint64      `json:"height"`
+	Hash   common.Hash `json:"hash"`
+}
+
+func (db *Database) WriteFinalized(record *FinalityRecord) error {
+	return db.db.Write(finality, "finalized", record)
+}
+
+// Finalized returns the latest finalized checkpoint, or
+// ErrFinalityNotFound before the first one.
+func (db *Database) Finalized() (*FinalityRecord, error) {
+	data, ok := db.db.Read(finality, "finalized")
+	if !ok {
+		return nil, ErrFinalityNotFound
+	}
+
+	var record *FinalityRecord
+	b, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(b, &record); err != nil {
+		return nil, err
+	}
+
+	return record, nil
+}
@@ -0,0 +1,48 @@
+package prydb
+
+import "encoding/json"
+
+// PeerRecord is an entry of the persisted peer table: a node id, the
+// address it listens on and the last time we were connected to it.
+type PeerRecord struct {
+	ID       string `json:"id"`
+	Addr     string `json:"addr"`
+	LastSeen uint64 `json:"last_seen"`
+}
+
+func (db *Database) WritePeer(record *PeerRecord) error {
+	return db.db.Write(peers, record.ID, record)
+}
+
+func (db *Database) DeletePeer(id string) error {
+	if _, ok := db.db.Read(peers, id); !ok {
+		return nil
+	}
+
+	return db.db.Delete(peers, id)
+}
+
+// Peers returns every peer of the table.
+func (db *Database) Peers() ([]*PeerRecord, error) {
+	data, err := db.db.ReadBatch(peers)
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]*PeerRecord, 0, len(data))
+	for _, v := range data {
+		var record *PeerRecord
+		b, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := json.Unmarshal(b, &record); err != nil {
+			return nil, err
+		}
+
+		records = append(records, record)
+	}
+
+	return records, nil
+}
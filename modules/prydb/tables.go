@@ -10,6 +10,10 @@ var (
 	transactionsByBlockHash   = "blocks/hash/%s/transactions/"
 	transactionsByBlockHeight = "blocks/height/%s/transactions/"
 	accounts                  = "accounts/block_%s/"
+	accountHistories          = "accounts/history/"
 	txPools                   = "txpool/block_%s"
 	transactionsByTxPool      = "txpool/%s/transactions/"
+	peers                     = "p2p/peers/"
+	bans                      = "p2p/bans/"
+	finality                  = "chain/finality/"
 )
@@ -0,0 +1,459 @@
+package rpc
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+
+	"github.com/polarysfoundation/polarys-chain/modules/common"
+	"github.com/polarysfoundation/polarys-chain/modules/core/block"
+	"github.com/polarysfoundation/polarys-chain/modules/core/gaspool"
+	"github.com/polarysfoundation/polarys-chain/modules/core/transaction"
+	"github.com/polarysfoundation/polarys-chain/modules/core/txpool"
+	"github.com/polarysfoundation/polarys-chain/modules/prydb"
+)
+
+type Backend interface {
+	ChainID() uint64
+	GetLatestBlock() (*block.Block, error)
+	GetBlockByHeight(height uint64) (*block.Block, error)
+	GetBlockByHash(hash common.Hash) (*block.Block, error)
+	GetTransactionByHash(hash common.Hash) (*transaction.Transaction, error)
+	BalanceAt(address common.Address, blk *block.Block) (uint64, error)
+	NonceAt(address common.Address, blk *block.Block) (uint64, error)
+	PendingNonceAt(address common.Address) (uint64, error)
+	SubmitTransaction(tx *transaction.Transaction) (common.Hash, error)
+	EstimateGas(tx *transaction.Transaction) (uint64, error)
+	SuggestGasTip() (uint64, error)
+	SuggestGasPrice() (uint64, error)
+	FeeHistory(count uint64, newest *block.Block, percentiles []float64) (*gaspool.FeeHistory, error)
+}
+
+func (s *Server) registerAPI() {
+	s.register("pry_chainId", s.chainID)
+	s.register("pry_blockNumber", s.blockNumber)
+	s.register("pry_getBlockByHeight", s.getBlockByHeight)
+	s.register("pry_getBlockByHash", s.getBlockByHash)
+	s.register("pry_getTransactionByHash", s.getTransactionByHash)
+	s.register("pry_getBalance", s.getBalance)
+	s.register("pry_getTransactionCount", s.getTransactionCount)
+	s.register("pry_sendRawTransaction", s.sendRawTransaction)
+	s.register("pry_estimateGas", s.estimateGas)
+	s.register("pry_suggestGasTip", s.suggestGasTip)
+	s.register("pry_gasPrice", s.gasPrice)
+	s.register("pry_feeHistory", s.feeHistory)
+}
+
+func (s *Server) chainID(params json.RawMessage) (any, error) {
+	return encodeUint64(s.backend.ChainID()), nil
+}
+
+func (s *Server) blockNumber(params json.RawMessage) (any, error) {
+	latest, err := s.backend.GetLatestBlock()
+	if err != nil {
+		return nil, err
+	}
+
+	return encodeUint64(latest.Height()), nil
+}
+
+func (s *Server) getBlockByHeight(params json.RawMessage) (any, error) {
+	var (
+		tag    BlockTag
+		fullTx bool
+	)
+
+	if err := decodeParams(params, 1, &tag, &fullTx); err != nil {
+		return nil, err
+	}
+
+	blk, err := s.blockByTag(tag)
+	if err != nil {
+		if errors.Is(err, prydb.ErrBlockNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return newRPCBlock(blk, fullTx), nil
+}
+
+func (s *Server) getBlockByHash(params json.RawMessage) (any, error) {
+	var (
+		hash   string
+		fullTx bool
+	)
+
+	if err := decodeParams(params, 1, &hash, &fullTx); err != nil {
+		return nil, err
+	}
+
+	h, err := parseHash(hash)
+	if err != nil {
+		return nil, invalidParams(err)
+	}
+
+	blk, err := s.backend.GetBlockByHash(h)
+	if err != nil {
+		if errors.Is(err, prydb.ErrBlockNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return newRPCBlock(blk, fullTx), nil
+}
+
+func (s *Server) getTransactionByHash(params json.RawMessage) (any, error) {
+	var hash string
+
+	if err := decodeParams(params, 1, &hash); err != nil {
+		return nil, err
+	}
+
+	h, err := parseHash(hash)
+	if err != nil {
+		return nil, invalidParams(err)
+	}
+
+	tx, err := s.backend.GetTransactionByHash(h)
+	if err != nil {
+		if errors.Is(err, prydb.ErrTransactionNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return newRPCTransaction(tx), nil
+}
+
+func (s *Server) getBalance(params json.RawMessage) (any, error) {
+	var (
+		address string
+		tag     = LatestBlock
+	)
+
+	if err := decodeParams(params, 1, &address, &tag); err != nil {
+		return nil, err
+	}
+
+	addr, err := parseAddress(address)
+	if err != nil {
+		return nil, invalidParams(err)
+	}
+
+	blk, err := s.blockByTag(tag)
+	if err != nil {
+		return nil, err
+	}
+
+	balance, err := s.backend.BalanceAt(addr, blk)
+	if err != nil {
+		return nil, err
+	}
+
+	return encodeUint64(balance), nil
+}
+
+// getTransactionCount returns the next nonce of an account. With the
+// "pending" tag the transactions waiting in the pool are counted too.
+func (s *Server) getTransactionCount(params json.RawMessage) (any, error) {
+	var (
+		address string
+		tag     = LatestBlock
+	)
+
+	if err := decodeParams(params, 1, &address, &tag); err != nil {
+		return nil, err
+	}
+
+	addr, err := parseAddress(address)
+	if err != nil {
+		return nil, invalidParams(err)
+	}
+
+	if tag == PendingBlock {
+		nonce, err := s.backend.PendingNonceAt(addr)
+		if err != nil {
+			return nil, err
+		}
+
+		return encodeUint64(nonce), nil
+	}
+
+	blk, err := s.blockByTag(tag)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce, err := s.backend.NonceAt(addr, blk)
+	if err != nil {
+		return nil, err
+	}
+
+	return encodeUint64(nonce), nil
+}
+
+// sendRawTransaction accepts a 0x-prefixed hex encoding of a signed JSON
+// transaction and returns its hash once the pool accepts it.
+func (s *Server) sendRawTransaction(params json.RawMessage) (any, error) {
+	var raw string
+
+	if err := decodeParams(params, 1, &raw); err != nil {
+		return nil, err
+	}
+
+	b, err := hex.DecodeString(strings.TrimPrefix(raw, "0x"))
+	if err != nil {
+		return nil, invalidParams(err)
+	}
+
+	tx, err := transaction.DecodeTransaction(b)
+	if err != nil {
+		return nil, invalidParams(err)
+	}
+
+	hash, err := s.backend.SubmitTransaction(tx)
+	if err != nil {
+		var rejected *txpool.RejectionError
+		if errors.As(err, &rejected) {
+			return nil, &Error{Code: codeTxRejected, Message: rejected.Reason.Error(), Data: rejected.Hash.CXID()}
+		}
+		return nil, err
+	}
+
+	return hash.CXID(), nil
+}
+
+// estimateGas returns the gas used by the transaction the call arguments
+// describe.
+func (s *Server) estimateGas(params json.RawMessage) (any, error) {
+	var args CallArgs
+
+	if err := decodeParams(params, 1, &args); err != nil {
+		return nil, err
+	}
+
+	tx, err := s.callTransaction(&args)
+	if err != nil {
+		return nil, err
+	}
+
+	gas, err := s.backend.EstimateGas(tx)
+	if err != nil {
+		if errors.Is(err, gaspool.ErrGasTargetExceeded) {
+			return nil, invalidParams(err)
+		}
+		return nil, err
+	}
+
+	return encodeUint64(gas), nil
+}
+
+// suggestGasTip returns a max tip per gas for a DynamicFee transaction.
+func (s *Server) suggestGasTip(params json.RawMessage) (any, error) {
+	tip, err := s.backend.SuggestGasTip()
+	if err != nil {
+		return nil, err
+	}
+
+	return encodeUint64(tip), nil
+}
+
+// gasPrice returns a gas price for a Legacy transaction.
+func (s *Server) gasPrice(params json.RawMessage) (any, error) {
+	price, err := s.backend.SuggestGasPrice()
+	if err != nil {
+		return nil, err
+	}
+
+	return encodeUint64(price), nil
+}
+
+// feeHistory returns the base fees, gas used ratios and, at the requested
+// percentiles, the tips paid in up to blockCount blocks ending at the
+// newest block.
+func (s *Server) feeHistory(params json.RawMessage) (any, error) {
+	var (
+		count       string
+		tag         = LatestBlock
+		percentiles []float64
+	)
+
+	if err := decodeParams(params, 1, &count, &tag, &percentiles); err != nil {
+		return nil, err
+	}
+
+	n, err := decodeUint64(count)
+	if err != nil {
+		return nil, invalidParams(err)
+	}
+
+	newest, err := s.blockByTag(tag)
+	if err != nil {
+		return nil, err
+	}
+
+	history, err := s.backend.FeeHistory(n, newest, percentiles)
+	if err != nil {
+		if errors.Is(err, gaspool.ErrInvalidPercentile) {
+			return nil, invalidParams(err)
+		}
+		return nil, err
+	}
+
+	return newRPCFeeHistory(history), nil
+}
+
+// callTransaction builds the unsigned transaction described by args. A
+// gas price makes it a Legacy transaction, otherwise it is a DynamicFee
+// one. The nonce defaults to the pending nonce of the sender.
+func (s *Server) callTransaction(args *CallArgs) (*transaction.Transaction, error) {
+	var (
+		from, to common.Address
+		value    = new(big.Int)
+		data     []byte
+		payload  []byte
+		nonce    uint64
+		err      error
+	)
+
+	if args.From != "" {
+		if from, err = parseAddress(args.From); err != nil {
+			return nil, invalidParams(err)
+		}
+	}
+
+	if args.To != "" {
+		if to, err = parseAddress(args.To); err != nil {
+			return nil, invalidParams(err)
+		}
+	}
+
+	if args.Value != "" {
+		if value, err = decodeBig(args.Value); err != nil {
+			return nil, invalidParams(err)
+		}
+	}
+
+	// Omitted data stays nil, as in the transactions wallets build: the
+	// encoding, and so the gas, differs from empty data.
+	if args.Data != "" {
+		if data, err = hex.DecodeString(strings.TrimPrefix(args.Data, "0x")); err != nil {
+			return nil, invalidParams(err)
+		}
+	}
+
+	if args.Payload != "" {
+		if payload, err = hex.DecodeString(strings.TrimPrefix(args.Payload, "0x")); err != nil {
+			return nil, invalidParams(err)
+		}
+	}
+
+	if args.Nonce != "" {
+		if nonce, err = decodeUint64(args.Nonce); err != nil {
+			return nil, invalidParams(err)
+		}
+	} else if args.From != "" {
+		if nonce, err = s.backend.PendingNonceAt(from); err != nil {
+			return nil, err
+		}
+	}
+
+	fees := make([]uint64, 3)
+	for i, f := range []string{args.GasPrice, args.MaxFee, args.MaxTip} {
+		if f == "" {
+			continue
+		}
+
+		if fees[i], err = decodeUint64(f); err != nil {
+			return nil, invalidParams(err)
+		}
+	}
+
+	// The backend checks the gas against the limit of a block.
+	var tx *transaction.Transaction
+	if args.GasPrice != "" {
+		tx, err = transaction.NewTransaction(from, to, value, data, nonce, fees[0], transaction.Legacy, payload, math.MaxUint64)
+	} else {
+		tx, err = transaction.NewDynamicFeeTransaction(from, to, value, data, nonce, fees[1], fees[2], payload, math.MaxUint64)
+	}
+	if err != nil {
+		return nil, invalidParams(err)
+	}
+
+	return tx, nil
+}
+
+func (s *Server) blockByTag(tag BlockTag) (*block.Block, error) {
+	switch tag {
+	case LatestBlock, "":
+		return s.backend.GetLatestBlock()
+	case EarliestBlock:
+		return s.backend.GetBlockByHeight(0)
+	}
+
+	height, err := decodeUint64(string(tag))
+	if err != nil {
+		return nil, invalidParams(ErrInvalidBlock)
+	}
+
+	return s.backend.GetBlockByHeight(height)
+}
+
+// decodeParams decodes positional params into args. The first required
+// entries must be present, the rest keep their current value when omitted.
+func decodeParams(raw json.RawMessage, required int, args ...any) error {
+	var params []json.RawMessage
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &params); err != nil {
+			return invalidParams(err)
+		}
+	}
+
+	if len(params) < required {
+		return invalidParams(fmt.Errorf("missing value for required argument %d", len(params)))
+	}
+
+	if len(params) > len(args) {
+		return invalidParams(fmt.Errorf("too many arguments, want at most %d", len(args)))
+	}
+
+	for i, p := range params {
+		if err := json.Unmarshal(p, args[i]); err != nil {
+			return invalidParams(fmt.Errorf("invalid argument %d: %v", i, err))
+		}
+	}
+
+	return nil
+}
+
+func parseHash(s string) (common.Hash, error) {
+	if len(s) != common.HashLen*2+3 || !strings.HasPrefix(s, "1cx") {
+		return common.Hash{}, ErrInvalidHash
+	}
+
+	b := common.DecodeCXID(s)
+	if len(b) != common.HashLen {
+		return common.Hash{}, ErrInvalidHash
+	}
+
+	return common.BytesToHash(b), nil
+}
+
+func parseAddress(s string) (common.Address, error) {
+	if len(s) != common.AddrLen*2+3 || !strings.HasPrefix(s, "1cx") {
+		return common.Address{}, ErrInvalidAddress
+	}
+
+	b := common.DecodeCXID(s)
+	if len(b) != common.AddrLen {
+		return common.Address{}, ErrInvalidAddress
+	}
+
+	return common.BytesToAddress(b), nil
+}
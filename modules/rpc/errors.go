@@ -0,0 +1,27 @@
+package rpc
+
+import "errors"
+
+const (
+	codeParseError     = -32700
+	codeInvalidRequest = -32600
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+	codeInternalError  = -32603
+	codeTxRejected     = -32000
+)
+
+var (
+	ErrServerRunning  = errors.New("rpc server already running")
+	ErrInvalidAddress = errors.New("invalid address")
+	ErrInvalidHash    = errors.New("invalid hash")
+	ErrInvalidBlock   = errors.New("invalid block tag")
+)
+
+func newError(code int, message string) *Error {
+	return &Error{Code: code, Message: message}
+}
+
+func invalidParams(err error) *Error {
+	return newError(codeInvalidParams, err.Error())
+}
@@ -0,0 +1,357 @@
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	maxRequestSize  = 5 * 1024 * 1024
+	shutdownTimeout = 5 * time.Second
+	wsWriteDeadline = 10 * time.Second
+)
+
+type handler func(params json.RawMessage) (any, error)
+
+type Server struct {
+	methods  map[string]handler
+	backend  Backend
+	upgrader websocket.Upgrader
+	http     *http.Server
+	addr     *net.TCPAddr // listen address, nil when stopped
+	origins  []string     // allowed on top of the server's own origin
+
+	log  *logrus.Logger
+	lock sync.Mutex
+}
+
+// NewServer returns a server for backend. Browsers may only call it from
+// pages served by the node itself or from one of allowedOrigins, "*"
+// allowing any page.
+func NewServer(backend Backend, allowedOrigins []string, log *logrus.Logger) *Server {
+	s := &Server{
+		methods: make(map[string]handler),
+		backend: backend,
+		origins: allowedOrigins,
+		log:     log,
+	}
+
+	s.upgrader = websocket.Upgrader{
+		ReadBufferSize:  4096,
+		WriteBufferSize: 4096,
+		CheckOrigin:     s.checkOrigin,
+	}
+
+	s.registerAPI()
+
+	return s
+}
+
+func (s *Server) register(method string, h handler) {
+	s.methods[method] = h
+}
+
+// Start listens on addr and serves HTTP POST requests and WebSocket
+// connections on the same port.
+func (s *Server) Start(addr string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.http != nil {
+		return ErrServerRunning
+	}
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	s.http = &http.Server{
+		Handler:           s,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	s.addr, _ = listener.Addr().(*net.TCPAddr)
+
+	go func(srv *http.Server) {
+		if err := srv.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.log.WithError(err).Error("RPC server stopped")
+		}
+	}(s.http)
+
+	s.log.WithField("addr", listener.Addr().String()).Info("RPC server started")
+
+	return nil
+}
+
+func (s *Server) Stop() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.http == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if err := s.http.Shutdown(ctx); err != nil {
+		s.log.WithError(err).Error("Failed to stop RPC server")
+	}
+
+	s.http = nil
+	s.addr = nil
+	s.log.Info("RPC server stopped")
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// A page could otherwise make the operator's browser send
+	// transactions to a node listening on localhost.
+	if !s.checkOrigin(r) {
+		http.Error(w, "origin not allowed", http.StatusForbidden)
+		return
+	}
+
+	if websocket.IsWebSocketUpgrade(r) {
+		s.serveWebSocket(w, r)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestSize))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	resp := s.handle(body)
+
+	w.Header().Set("Content-Type", "application/json")
+	if resp == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	w.Write(resp)
+}
+
+// checkOrigin accepts requests without an Origin header, which do not come
+// from a web page, and requests from the server's own origin or an allowed
+// one. The own origin is taken from the listen address, never from the Host
+// header: a rebound domain name sends its own name there.
+func (s *Server) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+
+	if s.isOwnOrigin(u) {
+		return true
+	}
+
+	for _, allowed := range s.origins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isOwnOrigin reports whether u points at the listen address. A server
+// listening on every interface only counts its loopback addresses, so a
+// page on another host serving from the same port is not mistaken for it.
+func (s *Server) isOwnOrigin(u *url.URL) bool {
+	s.lock.Lock()
+	addr := s.addr
+	s.lock.Unlock()
+
+	if addr == nil {
+		return false
+	}
+
+	port := u.Port()
+	if port == "" {
+		switch u.Scheme {
+		case "http":
+			port = "80"
+		case "https":
+			port = "443"
+		}
+	}
+
+	if port != strconv.Itoa(addr.Port) {
+		return false
+	}
+
+	host := u.Hostname()
+	if strings.EqualFold(host, "localhost") {
+		return addr.IP.IsLoopback() || addr.IP.IsUnspecified()
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+
+	if addr.IP.IsUnspecified() {
+		return ip.IsLoopback()
+	}
+
+	return ip.Equal(addr.IP)
+}
+
+func (s *Server) serveWebSocket(w http.ResponseWriter, r *http.Request) {
+	conn, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		s.log.WithError(err).Error("WebSocket upgrade failed")
+		return
+	}
+	defer conn.Close()
+
+	conn.SetReadLimit(maxRequestSize)
+
+	for {
+		_, msg, err := conn.ReadMessage()
+		if err != nil {
+			if !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				s.log.WithField("remote_addr", r.RemoteAddr).WithError(err).Debug("WebSocket read failed")
+			}
+			return
+		}
+
+		resp := s.handle(msg)
+		if resp == nil {
+			continue
+		}
+
+		conn.SetWriteDeadline(time.Now().Add(wsWriteDeadline))
+		if err := conn.WriteMessage(websocket.TextMessage, resp); err != nil {
+			s.log.WithField("remote_addr", r.RemoteAddr).WithError(err).Debug("WebSocket write failed")
+			return
+		}
+	}
+}
+
+// handle processes a single request or a batch and returns the encoded
+// response, or nil when only notifications were received.
+func (s *Server) handle(body []byte) []byte {
+	body = bytes.TrimSpace(body)
+
+	if len(body) > 0 && body[0] == '[' {
+		var batch []json.RawMessage
+		if err := json.Unmarshal(body, &batch); err != nil {
+			return encode(errorResponse(nil, newError(codeParseError, err.Error())))
+		}
+
+		if len(batch) == 0 {
+			return encode(errorResponse(nil, newError(codeInvalidRequest, "empty batch")))
+		}
+
+		responses := make([]any, 0, len(batch))
+		for _, raw := range batch {
+			if resp := s.handleRequest(raw); resp != nil {
+				responses = append(responses, resp)
+			}
+		}
+
+		if len(responses) == 0 {
+			return nil
+		}
+
+		return encode(responses)
+	}
+
+	resp := s.handleRequest(body)
+	if resp == nil {
+		return nil
+	}
+
+	return encode(resp)
+}
+
+func (s *Server) handleRequest(raw json.RawMessage) any {
+	var req Request
+	if err := json.Unmarshal(raw, &req); err != nil {
+		return errorResponse(nil, newError(codeParseError, err.Error()))
+	}
+
+	if req.Version != jsonrpcVersion || req.Method == "" {
+		return errorResponse(req.ID, newError(codeInvalidRequest, "invalid request"))
+	}
+
+	h, ok := s.methods[req.Method]
+	if !ok {
+		if req.isNotification() {
+			return nil
+		}
+		return errorResponse(req.ID, newError(codeMethodNotFound, "method "+req.Method+" not found"))
+	}
+
+	result, err := h(req.Params)
+	if req.isNotification() {
+		return nil
+	}
+
+	if err != nil {
+		var rpcErr *Error
+		if !errors.As(err, &rpcErr) {
+			rpcErr = newError(codeInternalError, err.Error())
+		}
+
+		s.log.WithFields(logrus.Fields{
+			"method": req.Method,
+			"code":   rpcErr.Code,
+		}).Debug(rpcErr.Message)
+
+		return errorResponse(req.ID, rpcErr)
+	}
+
+	return &Response{
+		Version: jsonrpcVersion,
+		ID:      req.ID,
+		Result:  result,
+	}
+}
+
+func errorResponse(id json.RawMessage, err *Error) *ErrorResponse {
+	if len(id) == 0 {
+		id = json.RawMessage("null")
+	}
+
+	return &ErrorResponse{
+		Version: jsonrpcVersion,
+		ID:      id,
+		Error:   err,
+	}
+}
+
+func encode(v any) []byte {
+	b, err := json.Marshal(v)
+	if err != nil {
+		b, _ = json.Marshal(errorResponse(nil, newError(codeInternalError, err.Error())))
+	}
+
+	return b
+}
@@ -0,0 +1,446 @@
+package rpc
+
+import (
+	"encoding/json"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/polarysfoundation/polarys-chain/modules/common"
+	"github.com/polarysfoundation/polarys-chain/modules/core/block"
+	"github.com/polarysfoundation/polarys-chain/modules/core/gaspool"
+	"github.com/polarysfoundation/polarys-chain/modules/core/transaction"
+	"github.com/polarysfoundation/polarys-chain/modules/core/txpool"
+	"github.com/polarysfoundation/polarys-chain/modules/crypto"
+	"github.com/polarysfoundation/polarys-chain/modules/prydb"
+	"github.com/sirupsen/logrus"
+)
+
+type testBackend struct {
+	blocks   []*block.Block
+	txs      map[common.Hash]*transaction.Transaction
+	balances map[common.Address]uint64
+	nonces   map[common.Address]uint64
+	pending  map[common.Address]uint64
+}
+
+func newTestBackend() *testBackend {
+	b := &testBackend{
+		txs:      make(map[common.Hash]*transaction.Transaction),
+		balances: make(map[common.Address]uint64),
+		nonces:   make(map[common.Address]uint64),
+		pending:  make(map[common.Address]uint64),
+	}
+
+	var prev common.Hash
+	for i := uint64(0); i < 3; i++ {
+		blk := block.NewBlock(block.Header{Height: i, Prev: prev, Timestamp: 1700000000 + i}, nil)
+		prev = blk.CalcHash()
+		b.blocks = append(b.blocks, blk)
+	}
+
+	return b
+}
+
+func (b *testBackend) ChainID() uint64 { return 7 }
+
+func (b *testBackend) GetLatestBlock() (*block.Block, error) {
+	return b.blocks[len(b.blocks)-1], nil
+}
+
+func (b *testBackend) GetBlockByHeight(height uint64) (*block.Block, error) {
+	if height >= uint64(len(b.blocks)) {
+		return nil, prydb.ErrBlockNotFound
+	}
+	return b.blocks[height], nil
+}
+
+func (b *testBackend) GetBlockByHash(hash common.Hash) (*block.Block, error) {
+	for _, blk := range b.blocks {
+		if blk.Hash() == hash {
+			return blk, nil
+		}
+	}
+	return nil, prydb.ErrBlockNotFound
+}
+
+func (b *testBackend) GetTransactionByHash(hash common.Hash) (*transaction.Transaction, error) {
+	if tx, ok := b.txs[hash]; ok {
+		return tx, nil
+	}
+	return nil, prydb.ErrTransactionNotFound
+}
+
+func (b *testBackend) BalanceAt(address common.Address, blk *block.Block) (uint64, error) {
+	return b.balances[address], nil
+}
+
+func (b *testBackend) NonceAt(address common.Address, blk *block.Block) (uint64, error) {
+	return b.nonces[address], nil
+}
+
+func (b *testBackend) PendingNonceAt(address common.Address) (uint64, error) {
+	return b.pending[address], nil
+}
+
+func (b *testBackend) SubmitTransaction(tx *transaction.Transaction) (common.Hash, error) {
+	if ok, err := tx.Verify(b.ChainID()); err != nil || !ok {
+		return common.Hash{}, &txpool.RejectionError{Hash: tx.Hash(), Reason: txpool.ErrInvalidSignature}
+	}
+
+	b.txs[tx.Hash()] = tx
+	return tx.Hash(), nil
+}
+
+func (b *testBackend) EstimateGas(tx *transaction.Transaction) (uint64, error) {
+	estimated, err := tx.CalcGas(1000000)
+	if err != nil {
+		return 0, err
+	}
+	return estimated.Gas(), nil
+}
+
+func (b *testBackend) SuggestGasTip() (uint64, error) { return 5, nil }
+
+func (b *testBackend) SuggestGasPrice() (uint64, error) { return 15, nil }
+
+func (b *testBackend) FeeHistory(count uint64, newest *block.Block, percentiles []float64) (*gaspool.FeeHistory, error) {
+	history := &gaspool.FeeHistory{OldestBlock: newest.Height() + 1 - count}
+	for i := uint64(0); i < count; i++ {
+		history.BaseFees = append(history.BaseFees, 10)
+		history.GasUsedRatio = append(history.GasUsedRatio, 0.5)
+		history.Rewards = append(history.Rewards, make([]uint64, len(percentiles)))
+	}
+	history.BaseFees = append(history.BaseFees, 10)
+
+	return history, nil
+}
+
+func newTestServer() (*Server, *testBackend) {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	backend := newTestBackend()
+	return NewServer(backend, nil, log), backend
+}
+
+func call(t *testing.T, s *Server, body string) map[string]any {
+	t.Helper()
+
+	var resp map[string]any
+	if err := json.Unmarshal(s.handle([]byte(body)), &resp); err != nil {
+		t.Fatalf("invalid response for %s: %v", body, err)
+	}
+	return resp
+}
+
+func TestServer_ChainIDAndBlockNumber(t *testing.T) {
+	s, _ := newTestServer()
+
+	resp := call(t, s, `{"jsonrpc":"2.0","id":1,"method":"pry_chainId"}`)
+	if resp["result"] != "0x7" {
+		t.Errorf("pry_chainId result = %v, want 0x7", resp["result"])
+	}
+
+	resp = call(t, s, `{"jsonrpc":"2.0","id":2,"method":"pry_blockNumber","params":[]}`)
+	if resp["result"] != "0x2" {
+		t.Errorf("pry_blockNumber result = %v, want 0x2", resp["result"])
+	}
+}
+
+func TestServer_GetBlock(t *testing.T) {
+	s, backend := newTestServer()
+
+	resp := call(t, s, `{"jsonrpc":"2.0","id":1,"method":"pry_getBlockByHeight","params":["0x1"]}`)
+	result, ok := resp["result"].(map[string]any)
+	if !ok {
+		t.Fatalf("pry_getBlockByHeight result = %v, want object", resp["result"])
+	}
+	if result["hash"] != backend.blocks[1].Hash().CXID() {
+		t.Errorf("block hash = %v, want %v", result["hash"], backend.blocks[1].Hash().CXID())
+	}
+
+	body := `{"jsonrpc":"2.0","id":2,"method":"pry_getBlockByHash","params":["` + backend.blocks[2].Hash().CXID() + `", true]}`
+	resp = call(t, s, body)
+	result, ok = resp["result"].(map[string]any)
+	if !ok {
+		t.Fatalf("pry_getBlockByHash result = %v, want object", resp["result"])
+	}
+	if result["height"] != "0x2" {
+		t.Errorf("block height = %v, want 0x2", result["height"])
+	}
+
+	resp = call(t, s, `{"jsonrpc":"2.0","id":3,"method":"pry_getBlockByHeight","params":[99]}`)
+	if v, ok := resp["result"]; !ok || v != nil {
+		t.Errorf("missing block result = %v, want null", v)
+	}
+}
+
+func TestServer_GetBalance(t *testing.T) {
+	s, backend := newTestServer()
+
+	addr := common.BytesToAddress(big.NewInt(42).Bytes())
+	backend.balances[addr] = 1000
+
+	resp := call(t, s, `{"jsonrpc":"2.0","id":1,"method":"pry_getBalance","params":["`+addr.CXID()+`","latest"]}`)
+	if resp["result"] != "0x3e8" {
+		t.Errorf("pry_getBalance result = %v, want 0x3e8", resp["result"])
+	}
+
+	resp = call(t, s, `{"jsonrpc":"2.0","id":2,"method":"pry_getBalance","params":["1cx1234"]}`)
+	rpcErr, ok := resp["error"].(map[string]any)
+	if !ok || rpcErr["code"] != float64(codeInvalidParams) {
+		t.Errorf("invalid address error = %v, want code %d", resp["error"], codeInvalidParams)
+	}
+}
+
+func TestServer_GetTransactionCount(t *testing.T) {
+	s, backend := newTestServer()
+
+	addr := common.BytesToAddress(big.NewInt(42).Bytes())
+	backend.nonces[addr] = 3
+	backend.pending[addr] = 5
+
+	tests := []struct {
+		params string
+		want   string
+	}{
+		{`["` + addr.CXID() + `"]`, "0x3"},
+		{`["` + addr.CXID() + `","latest"]`, "0x3"},
+		{`["` + addr.CXID() + `","pending"]`, "0x5"},
+	}
+
+	for _, tt := range tests {
+		resp := call(t, s, `{"jsonrpc":"2.0","id":1,"method":"pry_getTransactionCount","params":`+tt.params+`}`)
+		if resp["result"] != tt.want {
+			t.Errorf("pry_getTransactionCount(%s) = %v, want %s", tt.params, resp["result"], tt.want)
+		}
+	}
+
+	resp := call(t, s, `{"jsonrpc":"2.0","id":2,"method":"pry_getTransactionCount","params":["`+addr.CXID()+`","0x63"]}`)
+	if resp["error"] == nil {
+		t.Errorf("pry_getTransactionCount at unknown height = %v, want an error", resp["result"])
+	}
+}
+
+func TestServer_GasAPIs(t *testing.T) {
+	s, backend := newTestServer()
+
+	from := common.BytesToAddress(big.NewInt(42).Bytes())
+	to := common.BytesToAddress([]byte("receiver"))
+	backend.pending[from] = 2
+
+	// The estimate is the gas of the transaction a wallet would build.
+	want, err := transaction.NewDynamicFeeTransaction(from, to, big.NewInt(1000), []byte{1, 2}, 2, 20, 5, nil, 1000000)
+	if err != nil {
+		t.Fatalf("NewDynamicFeeTransaction() error = %v", err)
+	}
+
+	args := `{"from":"` + from.CXID() + `","to":"` + to.CXID() + `","value":"0x3e8","data":"0x0102","maxFee":"0x14","maxTip":"0x5"}`
+	resp := call(t, s, `{"jsonrpc":"2.0","id":1,"method":"pry_estimateGas","params":[`+args+`]}`)
+	if resp["result"] != encodeUint64(want.Gas()) {
+		t.Errorf("pry_estimateGas = %v, want %s", resp["result"], encodeUint64(want.Gas()))
+	}
+
+	// A transaction that does not fit in a block is an invalid request.
+	huge := `{"data":"0x` + strings.Repeat("00", 100000) + `"}`
+	resp = call(t, s, `{"jsonrpc":"2.0","id":2,"method":"pry_estimateGas","params":[`+huge+`]}`)
+	if e, ok := resp["error"].(map[string]any); !ok || e["code"] != float64(codeInvalidParams) {
+		t.Errorf("pry_estimateGas(huge) error = %v, want code %d", resp["error"], codeInvalidParams)
+	}
+
+	resp = call(t, s, `{"jsonrpc":"2.0","id":3,"method":"pry_suggestGasTip"}`)
+	if resp["result"] != "0x5" {
+		t.Errorf("pry_suggestGasTip = %v, want 0x5", resp["result"])
+	}
+
+	resp = call(t, s, `{"jsonrpc":"2.0","id":4,"method":"pry_gasPrice"}`)
+	if resp["result"] != "0xf" {
+		t.Errorf("pry_gasPrice = %v, want 0xf", resp["result"])
+	}
+
+	resp = call(t, s, `{"jsonrpc":"2.0","id":5,"method":"pry_feeHistory","params":["0x2","latest",[10,90]]}`)
+	result, ok := resp["result"].(map[string]any)
+	if !ok {
+		t.Fatalf("pry_feeHistory result = %v, want object", resp["result"])
+	}
+	if result["oldestBlock"] != "0x1" {
+		t.Errorf("oldestBlock = %v, want 0x1", result["oldestBlock"])
+	}
+	if fees, _ := result["baseFee"].([]any); len(fees) != 3 {
+		t.Errorf("baseFee = %v, want 3 entries", result["baseFee"])
+	}
+	if rewards, _ := result["reward"].([]any); len(rewards) != 2 {
+		t.Errorf("reward = %v, want 2 entries", result["reward"])
+	}
+}
+
+func newSignedTx(t *testing.T) *transaction.Transaction {
+	t.Helper()
+
+	priv, pub := crypto.GenerateKey()
+	from := crypto.PubKeyToAddress(pub)
+	to := common.BytesToAddress([]byte("receiver"))
+
+	tx, err := transaction.NewTransaction(from, to, big.NewInt(1000), nil, 0, 0, transaction.Legacy, nil, 1000000)
+	if err != nil {
+		t.Fatalf("NewTransaction() error = %v", err)
+	}
+
+	h, err := tx.SigningHash(7)
+	if err != nil {
+		t.Fatalf("SigningHash() error = %v", err)
+	}
+
+	r, sig, err := crypto.Sign(h, priv)
+	if err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+
+	signature := make([]byte, 64)
+	r.FillBytes(signature[:32])
+	sig.FillBytes(signature[32:])
+
+	return tx.SignTransaction(signature, pub)
+}
+
+func TestServer_SendRawTransaction(t *testing.T) {
+	s, backend := newTestServer()
+	tx := newSignedTx(t)
+
+	raw, err := json.Marshal(tx)
+	if err != nil {
+		t.Fatalf("failed to encode transaction: %v", err)
+	}
+
+	resp := call(t, s, `{"jsonrpc":"2.0","id":1,"method":"pry_sendRawTransaction","params":["`+common.EncodeToHex(raw)+`"]}`)
+	if resp["result"] != tx.Hash().CXID() {
+		t.Fatalf("pry_sendRawTransaction result = %v, want %v", resp, tx.Hash().CXID())
+	}
+
+	if _, ok := backend.txs[tx.Hash()]; !ok {
+		t.Errorf("transaction was not submitted to the backend")
+	}
+
+	forged := tx.SignTransaction(make([]byte, 64), tx.PubKey())
+	raw, _ = json.Marshal(forged)
+
+	resp = call(t, s, `{"jsonrpc":"2.0","id":2,"method":"pry_sendRawTransaction","params":["`+common.EncodeToHex(raw)+`"]}`)
+	rpcErr, ok := resp["error"].(map[string]any)
+	if !ok || rpcErr["code"] != float64(codeTxRejected) {
+		t.Errorf("forged transaction error = %v, want code %d", resp["error"], codeTxRejected)
+	}
+}
+
+func TestServer_Errors(t *testing.T) {
+	s, _ := newTestServer()
+
+	tests := []struct {
+		name string
+		body string
+		code int
+	}{
+		{"parse error", `{"jsonrpc":`, codeParseError},
+		{"wrong version", `{"jsonrpc":"1.0","id":1,"method":"pry_chainId"}`, codeInvalidRequest},
+		{"unknown method", `{"jsonrpc":"2.0","id":1,"method":"pry_unknown"}`, codeMethodNotFound},
+		{"missing params", `{"jsonrpc":"2.0","id":1,"method":"pry_getTransactionByHash","params":[]}`, codeInvalidParams},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := call(t, s, tt.body)
+			rpcErr, ok := resp["error"].(map[string]any)
+			if !ok {
+				t.Fatalf("response = %v, want error", resp)
+			}
+			if rpcErr["code"] != float64(tt.code) {
+				t.Errorf("error code = %v, want %d", rpcErr["code"], tt.code)
+			}
+		})
+	}
+}
+
+func TestServer_Batch(t *testing.T) {
+	s, _ := newTestServer()
+
+	out := s.handle([]byte(`[
+		{"jsonrpc":"2.0","id":1,"method":"pry_chainId"},
+		{"jsonrpc":"2.0","method":"pry_blockNumber"},
+		{"jsonrpc":"2.0","id":2,"method":"pry_blockNumber"}
+	]`))
+
+	var resp []map[string]any
+	if err := json.Unmarshal(out, &resp); err != nil {
+		t.Fatalf("invalid batch response: %v", err)
+	}
+
+	if len(resp) != 2 {
+		t.Fatalf("batch returned %d responses, want 2 (notifications get no response)", len(resp))
+	}
+
+	if s.handle([]byte(`{"jsonrpc":"2.0","method":"pry_chainId"}`)) != nil {
+		t.Errorf("notification returned a response")
+	}
+}
+
+func TestServer_CheckOrigin(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	s := NewServer(newTestBackend(), []string{"https://wallet.example"}, log)
+	if err := s.Start("127.0.0.1:0"); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer s.Stop()
+
+	body := `{"jsonrpc":"2.0","id":1,"method":"pry_chainId"}`
+	host := s.addr.String()
+	port := strconv.Itoa(s.addr.Port)
+
+	tests := []struct {
+		origin string
+		host   string
+		want   int
+	}{
+		{"", host, http.StatusOK},
+		{"http://" + host, host, http.StatusOK},
+		{"http://localhost:" + port, "localhost:" + port, http.StatusOK},
+		{"https://wallet.example", host, http.StatusOK},
+		{"https://evil.example", host, http.StatusForbidden},
+		{"http://127.0.0.1:1", host, http.StatusForbidden},
+		// A rebound name matches the Host header the browser sends.
+		{"http://evil.example:" + port, "evil.example:" + port, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "http://"+host, strings.NewReader(body))
+		req.Host = tt.host
+		if tt.origin != "" {
+			req.Header.Set("Origin", tt.origin)
+		}
+
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+		if rec.Code != tt.want {
+			t.Errorf("POST from %q status = %d, want %d", tt.origin, rec.Code, tt.want)
+		}
+
+		header := http.Header{}
+		if tt.origin != "" {
+			header.Set("Origin", tt.origin)
+		}
+
+		conn, _, err := websocket.DefaultDialer.Dial("ws://"+host, header)
+		if ok := err == nil; ok != (tt.want == http.StatusOK) {
+			t.Errorf("WebSocket from %q error = %v", tt.origin, err)
+		}
+		if conn != nil {
+			conn.Close()
+		}
+	}
+}
@@ -0,0 +1,250 @@
+package rpc
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/polarysfoundation/polarys-chain/modules/common"
+	"github.com/polarysfoundation/polarys-chain/modules/core/block"
+	"github.com/polarysfoundation/polarys-chain/modules/core/gaspool"
+	"github.com/polarysfoundation/polarys-chain/modules/core/transaction"
+)
+
+const jsonrpcVersion = "2.0"
+
+type Request struct {
+	Version string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+func (r *Request) isNotification() bool {
+	return len(r.ID) == 0
+}
+
+type Response struct {
+	Version string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result"`
+}
+
+type ErrorResponse struct {
+	Version string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Error   *Error          `json:"error"`
+}
+
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Data    any    `json:"data,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("rpc error %d: %s", e.Code, e.Message)
+}
+
+// BlockTag selects a block by height or by one of the "latest" and
+// "earliest" labels. "pending" is only understood where the pool matters.
+type BlockTag string
+
+const (
+	LatestBlock   BlockTag = "latest"
+	EarliestBlock BlockTag = "earliest"
+	PendingBlock  BlockTag = "pending"
+)
+
+type RPCBlock struct {
+	Height          string `json:"height"`
+	Hash            string `json:"hash"`
+	Prev            string `json:"prev"`
+	Timestamp       string `json:"timestamp"`
+	Nonce           string `json:"nonce"`
+	GasTarget       string `json:"gasTarget"`
+	GasTip          string `json:"gasTip"`
+	BaseFee         string `json:"baseFee"`
+	GasUsed         string `json:"gasUsed"`
+	Difficulty      string `json:"difficulty"`
+	TotalDifficulty string `json:"totalDifficulty"`
+	Validator       string `json:"validator"`
+	TxRoot          string `json:"txRoot"`
+	StateRoot       string `json:"stateRoot"`
+	EvidenceRoot    string `json:"evidenceRoot"`
+	Data            string `json:"data"`
+	Signature       string `json:"signature"`
+	Size            string `json:"size"`
+	Transactions    []any  `json:"transactions"`
+}
+
+type RPCTransaction struct {
+	Hash      string `json:"hash"`
+	From      string `json:"from"`
+	To        string `json:"to"`
+	Value     string `json:"value"`
+	Nonce     string `json:"nonce"`
+	Gas       string `json:"gas"`
+	GasPrice  string `json:"gasPrice"`
+	MaxFee    string `json:"maxFee"`
+	MaxTip    string `json:"maxTip"`
+	Data      string `json:"data"`
+	Payload   string `json:"payload"`
+	Version   string `json:"version"`
+	Timestamp string `json:"timestamp"`
+	Signature string `json:"signature"`
+}
+
+func newRPCBlock(blk *block.Block, fullTx bool) *RPCBlock {
+	txs := blk.Transactions()
+
+	result := &RPCBlock{
+		Height:          encodeUint64(blk.Height()),
+		Hash:            blk.Hash().CXID(),
+		Prev:            blk.Prev().CXID(),
+		Timestamp:       encodeUint64(blk.Timestamp()),
+		Nonce:           encodeUint64(blk.Nonce()),
+		GasTarget:       encodeUint64(blk.GasTarget()),
+		GasTip:          encodeUint64(blk.GasTip()),
+		BaseFee:         encodeUint64(blk.BaseFee()),
+		GasUsed:         encodeUint64(blk.GasUsed()),
+		Difficulty:      encodeUint64(blk.Difficulty()),
+		TotalDifficulty: encodeUint64(blk.TotalDifficulty()),
+		Validator:       blk.Validator().CXID(),
+		TxRoot:          blk.TxRoot().CXID(),
+		StateRoot:       blk.StateRoot().CXID(),
+		EvidenceRoot:    blk.EvidenceRoot().CXID(),
+		Data:            common.EncodeToHex(blk.Data()),
+		Signature:       common.EncodeToHex(blk.Signature()),
+		Size:            encodeUint64(blk.Size()),
+		Transactions:    make([]any, 0, len(txs)),
+	}
+
+	for i := range txs {
+		if fullTx {
+			result.Transactions = append(result.Transactions, newRPCTransaction(&txs[i]))
+		} else {
+			result.Transactions = append(result.Transactions, txs[i].Hash().CXID())
+		}
+	}
+
+	return result
+}
+
+func newRPCTransaction(tx *transaction.Transaction) *RPCTransaction {
+	return &RPCTransaction{
+		Hash:      tx.Hash().CXID(),
+		From:      tx.From().CXID(),
+		To:        tx.To().CXID(),
+		Value:     encodeBig(tx.Value()),
+		Nonce:     encodeUint64(tx.Nonce()),
+		Gas:       encodeUint64(tx.Gas()),
+		GasPrice:  encodeUint64(tx.GasPrice()),
+		MaxFee:    encodeUint64(tx.MaxFee()),
+		MaxTip:    encodeUint64(tx.MaxTip()),
+		Data:      common.EncodeToHex(tx.Data()),
+		Payload:   common.EncodeToHex(tx.Payload()),
+		Version:   encodeUint64(uint64(tx.Version())),
+		Timestamp: encodeUint64(tx.Timestamp()),
+		Signature: common.EncodeToHex(tx.Signature()),
+	}
+}
+
+// CallArgs describes a transaction for pry_estimateGas. Every field is
+// optional.
+type CallArgs struct {
+	From     string `json:"from"`
+	To       string `json:"to"`
+	Value    string `json:"value"`
+	Data     string `json:"data"`
+	Payload  string `json:"payload"`
+	Nonce    string `json:"nonce"`
+	GasPrice string `json:"gasPrice"`
+	MaxFee   string `json:"maxFee"`
+	MaxTip   string `json:"maxTip"`
+}
+
+type RPCFeeHistory struct {
+	OldestBlock  string     `json:"oldestBlock"`
+	BaseFee      []string   `json:"baseFee"`
+	GasUsedRatio []float64  `json:"gasUsedRatio"`
+	Reward       [][]string `json:"reward,omitempty"`
+}
+
+func newRPCFeeHistory(history *gaspool.FeeHistory) *RPCFeeHistory {
+	result := &RPCFeeHistory{
+		OldestBlock:  encodeUint64(history.OldestBlock),
+		BaseFee:      make([]string, 0, len(history.BaseFees)),
+		GasUsedRatio: history.GasUsedRatio,
+	}
+
+	for _, fee := range history.BaseFees {
+		result.BaseFee = append(result.BaseFee, encodeUint64(fee))
+	}
+
+	for _, rewards := range history.Rewards {
+		encoded := make([]string, 0, len(rewards))
+		for _, reward := range rewards {
+			encoded = append(encoded, encodeUint64(reward))
+		}
+		result.Reward = append(result.Reward, encoded)
+	}
+
+	return result
+}
+
+func encodeUint64(n uint64) string {
+	return "0x" + strconv.FormatUint(n, 16)
+}
+
+func encodeBig(n *big.Int) string {
+	if n == nil {
+		return "0x0"
+	}
+
+	return "0x" + n.Text(16)
+}
+
+// decodeUint64 accepts both 0x-prefixed hex quantities and plain decimal
+// strings.
+func decodeUint64(s string) (uint64, error) {
+	if strings.HasPrefix(s, "0x") {
+		return strconv.ParseUint(s[2:], 16, 64)
+	}
+
+	return strconv.ParseUint(s, 10, 64)
+}
+
+// decodeBig accepts both 0x-prefixed hex quantities and plain decimal
+// strings.
+func decodeBig(s string) (*big.Int, error) {
+	base := 10
+	if strings.HasPrefix(s, "0x") {
+		s, base = s[2:], 16
+	}
+
+	n, ok := new(big.Int).SetString(s, base)
+	if !ok || n.Sign() < 0 {
+		return nil, fmt.Errorf("invalid quantity %q", s)
+	}
+
+	return n, nil
+}
+
+func (b *BlockTag) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*b = BlockTag(s)
+		return nil
+	}
+
+	var n uint64
+	if err := json.Unmarshal(data, &n); err != nil {
+		return err
+	}
+
+	*b = BlockTag(strconv.FormatUint(n, 10))
+	return nil
+}
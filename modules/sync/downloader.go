@@ -0,0 +1,499 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	gosync "sync"
+	"time"
+
+	"github.com/polarysfoundation/polarys-chain/modules/common"
+	"github.com/polarysfoundation/polarys-chain/modules/core"
+	"github.com/polarysfoundation/polarys-chain/modules/core/block"
+	"github.com/polarysfoundation/polarys-chain/modules/core/consensus"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	MaxHeaderFetch = 192 // headers per GET_HEADERS request
+	MaxBodyFetch   = 64  // bodies per GET_BODIES request
+
+	requestTimeout = 10 * time.Second
+	syncInterval   = 10 * time.Second
+)
+
+type headerPack struct {
+	peer    string
+	headers []block.Header
+}
+
+type bodyPack struct {
+	peer   string
+	bodies []Body
+}
+
+// Downloader brings the local chain up to the heaviest chain known by the
+// peers. Headers are fetched first from the best peer and verified, then
+// the bodies are fetched in parallel from every peer that has them.
+type Downloader struct {
+	chain    Chain
+	verifier HeaderVerifier
+	network  Network
+
+	headerWaiters map[string]chan headerPack
+	bodyWaiters   map[string]chan bodyPack
+
+	running bool
+	trigger chan struct{}
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      gosync.WaitGroup
+
+	log  *logrus.Logger
+	lock gosync.Mutex
+}
+
+func NewDownloader(chain Chain, verifier HeaderVerifier, network Network, log *logrus.Logger) *Downloader {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &Downloader{
+		chain:         chain,
+		verifier:      verifier,
+		network:       network,
+		headerWaiters: make(map[string]chan headerPack),
+		bodyWaiters:   make(map[string]chan bodyPack),
+		trigger:       make(chan struct{}, 1),
+		ctx:           ctx,
+		cancel:        cancel,
+		log:           log,
+	}
+}
+
+// Start runs Synchronise periodically and whenever Trigger is called.
+func (d *Downloader) Start() {
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+		ticker := time.NewTicker(syncInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-d.ctx.Done():
+				return
+			case <-ticker.C:
+			case <-d.trigger:
+			}
+
+			if err := d.Synchronise(); err != nil && !errors.Is(err, ErrBusy) && !errors.Is(err, ErrCancelled) {
+				d.log.WithError(err).Warn("Synchronisation failed")
+			}
+		}
+	}()
+}
+
+func (d *Downloader) Stop() {
+	d.cancel()
+	d.wg.Wait()
+}
+
+// Trigger asks for a synchronisation round, for example when a peer with a
+// heavier chain connects.
+func (d *Downloader) Trigger() {
+	select {
+	case d.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// DeliverHeaders hands the answer to a GET_HEADERS request to the waiting
+// fetcher. Unsolicited answers are dropped and reported by returning false.
+func (d *Downloader) DeliverHeaders(peer string, headers []block.Header) bool {
+	d.lock.Lock()
+	ch, ok := d.headerWaiters[peer]
+	d.lock.Unlock()
+
+	if !ok {
+		return false
+	}
+
+	select {
+	case ch <- headerPack{peer: peer, headers: headers}:
+		return true
+	default:
+		return false
+	}
+}
+
+// DeliverBodies hands the answer to a GET_BODIES request to the waiting
+// fetcher. Unsolicited answers are dropped and reported by returning false.
+func (d *Downloader) DeliverBodies(peer string, bodies []Body) bool {
+	d.lock.Lock()
+	ch, ok := d.bodyWaiters[peer]
+	d.lock.Unlock()
+
+	if !ok {
+		return false
+	}
+
+	select {
+	case ch <- bodyPack{peer: peer, bodies: bodies}:
+		return true
+	default:
+		return false
+	}
+}
+
+// Synchronise runs one round against the peer with the highest total
+// difficulty. It returns nil when the local chain is already the heaviest.
+func (d *Downloader) Synchronise() error {
+	d.lock.Lock()
+	if d.running {
+		d.lock.Unlock()
+		return ErrBusy
+	}
+	d.running = true
+	d.lock.Unlock()
+
+	defer func() {
+		d.lock.Lock()
+		d.running = false
+		d.lock.Unlock()
+	}()
+
+	head, err := d.chain.GetLatestBlock()
+	if err != nil {
+		return err
+	}
+
+	peer := d.bestPeer()
+	if peer == nil {
+		return nil
+	}
+
+	_, peerHeight, peerTD := peer.Head()
+	if peerTD <= head.TotalDifficulty() {
+		return nil
+	}
+
+	d.log.WithFields(logrus.Fields{
+		"peer":             peer.ID(),
+		"peer_height":      peerHeight,
+		"peer_td":          peerTD,
+		"local_height":     head.Height(),
+		"total_difficulty": head.TotalDifficulty(),
+	}).Info("Synchronising with peer")
+
+	ancestor, err := d.findAncestor(peer, head, peerHeight)
+	if err != nil {
+		return err
+	}
+
+	parent := ancestor
+	imported := 0
+
+	for parent.Height() < peerHeight {
+		amount := min(peerHeight-parent.Height(), MaxHeaderFetch)
+
+		headers, err := d.fetchHeaders(peer, parent.Height()+1, amount)
+		if err != nil {
+			return err
+		}
+
+		blocks, err := d.verifyHeaders(parent, headers)
+		if err != nil {
+			return err
+		}
+
+		if err := d.fetchBodies(peerHeight, blocks); err != nil {
+			return err
+		}
+
+		for _, blk := range blocks {
+			if err := d.chain.AddRemoteBlock(blk); err != nil && !errors.Is(err, core.ErrBlockExists) {
+				return err
+			}
+			imported++
+		}
+
+		parent = blocks[len(blocks)-1]
+	}
+
+	d.log.WithFields(logrus.Fields{
+		"peer":     peer.ID(),
+		"imported": imported,
+		"height":   parent.Height(),
+	}).Info("Synchronisation finished")
+
+	return nil
+}
+
+func (d *Downloader) bestPeer() Peer {
+	var (
+		best   Peer
+		bestTD uint64
+	)
+
+	for _, peer := range d.network.Peers() {
+		if _, _, td := peer.Head(); best == nil || td > bestTD {
+			best = peer
+			bestTD = td
+		}
+	}
+
+	return best
+}
+
+// findAncestor walks the peer's canonical headers backwards from our head
+// until it finds one we already have.
+func (d *Downloader) findAncestor(peer Peer, head *block.Block, peerHeight uint64) (*block.Block, error) {
+	to := min(head.Height(), peerHeight)
+
+	for {
+		from := to - min(to, MaxHeaderFetch-1)
+
+		headers, err := d.fetchHeaders(peer, from, to-from+1)
+		if err != nil {
+			return nil, err
+		}
+
+		for i := len(headers) - 1; i >= 0; i-- {
+			hash := block.FromHeader(headers[i], nil).Hash()
+			if d.chain.HasBlock(hash) {
+				return d.chain.GetBlockByHash(hash)
+			}
+		}
+
+		if from == 0 {
+			return nil, ErrNoCommonAncestor
+		}
+
+		to = from - 1
+	}
+}
+
+// verifyHeaders checks that headers form a chain on top of parent that the
+// consensus engine accepts. When a header can only be verified once the
+// bodies before it are imported, the headers verified so far are returned
+// and the rest is fetched again in the next batch.
+func (d *Downloader) verifyHeaders(parent *block.Block, headers []block.Header) ([]*block.Block, error) {
+	blocks := make([]*block.Block, 0, len(headers))
+
+	for _, header := range headers {
+		blk := block.FromHeader(header, nil)
+		if err := d.verifier.VerifyHeader(parent, blk); err != nil {
+			if errors.Is(err, consensus.ErrMissingBody) && len(blocks) > 0 {
+				break
+			}
+			return nil, errors.Join(ErrInvalidHeaders, err)
+		}
+
+		blocks = append(blocks, blk)
+		parent = blk
+	}
+
+	return blocks, nil
+}
+
+// fetchHeaders requests amount headers starting at origin and checks that
+// the answer is a contiguous run starting at origin.
+func (d *Downloader) fetchHeaders(peer Peer, origin, amount uint64) ([]block.Header, error) {
+	ch := make(chan headerPack, 1)
+
+	d.lock.Lock()
+	d.headerWaiters[peer.ID()] = ch
+	d.lock.Unlock()
+
+	defer func() {
+		d.lock.Lock()
+		delete(d.headerWaiters, peer.ID())
+		d.lock.Unlock()
+	}()
+
+	if err := peer.RequestHeaders(origin, amount); err != nil {
+		return nil, err
+	}
+
+	timeout := time.NewTimer(requestTimeout)
+	defer timeout.Stop()
+
+	select {
+	case <-d.ctx.Done():
+		return nil, ErrCancelled
+	case <-timeout.C:
+		return nil, ErrTimeout
+	case pack := <-ch:
+		if len(pack.headers) == 0 {
+			return nil, ErrEmptyResponse
+		}
+
+		if uint64(len(pack.headers)) > amount {
+			return nil, ErrInvalidHeaders
+		}
+
+		for i, header := range pack.headers {
+			if header.Height != origin+uint64(i) {
+				return nil, ErrInvalidHeaders
+			}
+		}
+
+		return pack.headers, nil
+	}
+}
+
+// fetchBodies fills in the transactions of blocks. Requests of at most
+// MaxBodyFetch hashes are spread over every peer whose chain reaches
+// height, one request in flight per peer. Chunks that fail or come back
+// incomplete are retried on the remaining peers, and the peer that served
+// them is dropped for the rest of the round.
+func (d *Downloader) fetchBodies(height uint64, blocks []*block.Block) error {
+	pending := make(map[common.Hash]*block.Block, len(blocks))
+	queue := make([][]common.Hash, 0, len(blocks)/MaxBodyFetch+1)
+
+	var chunk []common.Hash
+	for _, blk := range blocks {
+		// Empty blocks need no body.
+		if blk.TxRoot() == (common.Hash{}) && blk.EvidenceRoot() == (common.Hash{}) {
+			continue
+		}
+
+		pending[blk.Hash()] = blk
+		chunk = append(chunk, blk.Hash())
+
+		if len(chunk) == MaxBodyFetch {
+			queue = append(queue, chunk)
+			chunk = nil
+		}
+	}
+
+	if len(chunk) > 0 {
+		queue = append(queue, chunk)
+	}
+
+	if len(queue) == 0 {
+		return nil
+	}
+
+	peers := make([]Peer, 0)
+	for _, peer := range d.network.Peers() {
+		if _, peerHeight, _ := peer.Head(); peerHeight >= height {
+			peers = append(peers, peer)
+		}
+	}
+
+	for len(queue) > 0 {
+		if len(peers) == 0 {
+			return ErrNoPeers
+		}
+
+		round := min(len(peers), len(queue))
+
+		type result struct {
+			bodies []Body
+			err    error
+		}
+
+		results := make([]result, round)
+
+		var wg gosync.WaitGroup
+		for i := 0; i < round; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				bodies, err := d.requestBodies(peers[i], queue[i])
+				results[i] = result{bodies: bodies, err: err}
+			}(i)
+		}
+		wg.Wait()
+
+		if d.ctx.Err() != nil {
+			return ErrCancelled
+		}
+
+		retry := queue[round:]
+		healthy := make([]Peer, 0, len(peers))
+		healthy = append(healthy, peers[round:]...)
+
+		for i := 0; i < round; i++ {
+			if results[i].err != nil {
+				d.log.WithField("peer", peers[i].ID()).WithError(results[i].err).Debug("Body request failed")
+				retry = append(retry, queue[i])
+				continue
+			}
+
+			for _, body := range results[i].bodies {
+				blk, ok := pending[body.Hash]
+				if !ok {
+					continue
+				}
+
+				if block.CalcTxRoot(body.Transactions) != blk.TxRoot() || block.CalcEvidenceRoot(body.Evidence) != blk.EvidenceRoot() {
+					d.log.WithFields(logrus.Fields{
+						"peer": peers[i].ID(),
+						"hash": body.Hash.String(),
+					}).Debug(ErrInvalidBody)
+					continue
+				}
+
+				for _, tx := range body.Transactions {
+					blk.AddTransaction(tx)
+				}
+				blk.SetEvidence(body.Evidence)
+				delete(pending, body.Hash)
+			}
+
+			var missing []common.Hash
+			for _, hash := range queue[i] {
+				if _, ok := pending[hash]; ok {
+					missing = append(missing, hash)
+				}
+			}
+
+			// A peer that did not serve the whole chunk is not asked again.
+			if len(missing) > 0 {
+				retry = append(retry, missing)
+				continue
+			}
+
+			healthy = append(healthy, peers[i])
+		}
+
+		queue = retry
+		peers = healthy
+	}
+
+	return nil
+}
+
+func (d *Downloader) requestBodies(peer Peer, hashes []common.Hash) ([]Body, error) {
+	ch := make(chan bodyPack, 1)
+
+	d.lock.Lock()
+	d.bodyWaiters[peer.ID()] = ch
+	d.lock.Unlock()
+
+	defer func() {
+		d.lock.Lock()
+		delete(d.bodyWaiters, peer.ID())
+		d.lock.Unlock()
+	}()
+
+	if err := peer.RequestBodies(hashes); err != nil {
+		return nil, err
+	}
+
+	timeout := time.NewTimer(requestTimeout)
+	defer timeout.Stop()
+
+	select {
+	case <-d.ctx.Done():
+		return nil, ErrCancelled
+	case <-timeout.C:
+		return nil, ErrTimeout
+	case pack := <-ch:
+		if len(pack.bodies) == 0 {
+			return nil, ErrEmptyResponse
+		}
+
+		return pack.bodies, nil
+	}
+}
@@ -0,0 +1,328 @@
+package sync
+
+import (
+	"errors"
+	"io"
+	"math/big"
+	gosync "sync"
+	"testing"
+	"time"
+
+	"github.com/polarysfoundation/polarys-chain/modules/common"
+	"github.com/polarysfoundation/polarys-chain/modules/core/block"
+	"github.com/polarysfoundation/polarys-chain/modules/core/consensus/pos"
+	"github.com/polarysfoundation/polarys-chain/modules/core/gaspool"
+	"github.com/polarysfoundation/polarys-chain/modules/core/transaction"
+	"github.com/polarysfoundation/polarys-chain/modules/crypto"
+	"github.com/polarysfoundation/polarys-chain/modules/prydb"
+	"github.com/sirupsen/logrus"
+)
+
+// testChain is an in-memory canonical chain.
+type testChain struct {
+	blocks []*block.Block
+	byHash map[common.Hash]*block.Block
+	lock   gosync.Mutex
+}
+
+func newTestChain(blocks []*block.Block) *testChain {
+	c := &testChain{byHash: make(map[common.Hash]*block.Block)}
+	for _, blk := range blocks {
+		c.blocks = append(c.blocks, blk)
+		c.byHash[blk.Hash()] = blk
+	}
+	return c
+}
+
+func (c *testChain) GetLatestBlock() (*block.Block, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.blocks[len(c.blocks)-1], nil
+}
+
+func (c *testChain) GetBlockByHash(hash common.Hash) (*block.Block, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	blk, ok := c.byHash[hash]
+	if !ok {
+		return nil, errors.New("unknown block")
+	}
+	return blk, nil
+}
+
+func (c *testChain) HasBlock(hash common.Hash) bool {
+	_, err := c.GetBlockByHash(hash)
+	return err == nil
+}
+
+func (c *testChain) AddRemoteBlock(blk *block.Block) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	parent, ok := c.byHash[blk.Prev()]
+	if !ok {
+		return errors.New("unknown parent")
+	}
+	if !blk.VerifyTxRoot() {
+		return errors.New("invalid tx root")
+	}
+
+	c.blocks = append(c.blocks[:parent.Height()+1], blk)
+	c.byHash[blk.Hash()] = blk
+	return nil
+}
+
+type testVerifier struct{}
+
+func (testVerifier) VerifyHeader(parent, header *block.Block) error {
+	if header.Prev() != parent.Hash() || header.Height() != parent.Height()+1 {
+		return errors.New("not a child")
+	}
+	return nil
+}
+
+// testPeer serves a chain and answers requests asynchronously like a
+// remote node would.
+type testPeer struct {
+	id         string
+	chain      []*block.Block
+	downloader *Downloader
+	silent     bool // never answers body requests
+
+	lock         gosync.Mutex
+	bodyRequests int
+}
+
+func (p *testPeer) ID() string { return p.id }
+
+func (p *testPeer) Head() (common.Hash, uint64, uint64) {
+	head := p.chain[len(p.chain)-1]
+	return head.Hash(), head.Height(), head.TotalDifficulty()
+}
+
+func (p *testPeer) RequestHeaders(origin, amount uint64) error {
+	headers := make([]block.Header, 0, amount)
+	for h := origin; h < origin+amount && h < uint64(len(p.chain)); h++ {
+		headers = append(headers, p.chain[h].Header())
+	}
+	go p.downloader.DeliverHeaders(p.id, headers)
+	return nil
+}
+
+func (p *testPeer) RequestBodies(hashes []common.Hash) error {
+	p.lock.Lock()
+	p.bodyRequests++
+	p.lock.Unlock()
+
+	if p.silent {
+		return errors.New("peer gone")
+	}
+
+	bodies := make([]Body, 0, len(hashes))
+	for _, hash := range hashes {
+		for _, blk := range p.chain {
+			if blk.Hash() == hash {
+				bodies = append(bodies, Body{Hash: hash, Transactions: blk.Transactions()})
+			}
+		}
+	}
+	go p.downloader.DeliverBodies(p.id, bodies)
+	return nil
+}
+
+type testNetwork struct {
+	peers []Peer
+}
+
+func (n *testNetwork) Peers() []Peer { return n.peers }
+
+// makeChain builds length blocks on top of parent. Every block carries one
+// transaction so bodies have to be downloaded.
+func makeChain(parent *block.Block, length int, seed byte) []*block.Block {
+	blocks := make([]*block.Block, 0, length)
+	for i := 0; i < length; i++ {
+		tx, err := transaction.NewTransaction(common.Address{}, common.Address{seed}, big.NewInt(int64(i)), nil, parent.Height(), 0, transaction.Legacy, nil, 1000000)
+		if err != nil {
+			panic(err)
+		}
+		txs := []transaction.Transaction{*tx}
+
+		header := block.Header{
+			Height:          parent.Height() + 1,
+			Prev:            parent.Hash(),
+			Timestamp:       parent.Timestamp() + 1,
+			Difficulty:      1,
+			TotalDifficulty: parent.TotalDifficulty() + 1,
+			Data:            []byte{seed},
+			TxRoot:          block.CalcTxRoot(txs),
+		}
+
+		blk := block.FromHeader(header, txs)
+		blocks = append(blocks, blk)
+		parent = blk
+	}
+	return blocks
+}
+
+func newTestDownloader(chain Chain, network Network) *Downloader {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	return NewDownloader(chain, testVerifier{}, network, log)
+}
+
+func TestDownloader_Synchronise(t *testing.T) {
+	genesis := block.FromHeader(block.Header{Data: []byte{}}, nil)
+	shared := makeChain(genesis, 10, 1)
+	ours := makeChain(shared[len(shared)-1], 3, 2)
+	theirs := makeChain(shared[len(shared)-1], 2*MaxHeaderFetch, 3)
+
+	local := append([]*block.Block{genesis}, shared...)
+	remote := append(append([]*block.Block{}, local...), theirs...)
+	local = append(local, ours...)
+
+	chain := newTestChain(local)
+	network := &testNetwork{}
+	d := newTestDownloader(chain, network)
+
+	silent := &testPeer{id: "silent", chain: remote, downloader: d, silent: true}
+	good := &testPeer{id: "good", chain: remote, downloader: d}
+	short := &testPeer{id: "short", chain: local, downloader: d}
+	network.peers = []Peer{silent, good, short}
+
+	if err := d.Synchronise(); err != nil {
+		t.Fatalf("Synchronise() error = %v", err)
+	}
+
+	head, _ := chain.GetLatestBlock()
+	want := remote[len(remote)-1]
+	if head.Hash() != want.Hash() {
+		t.Fatalf("head = %d, want %d", head.Height(), want.Height())
+	}
+
+	if short.bodyRequests != 0 {
+		t.Errorf("peer behind the target was asked for %d bodies", short.bodyRequests)
+	}
+	// The failing peer is dropped after its first request of each batch.
+	if batches := len(theirs) / MaxHeaderFetch; silent.bodyRequests != batches {
+		t.Errorf("failing peer was asked %d times, want %d", silent.bodyRequests, batches)
+	}
+
+	// Nothing to do once we are on the heaviest chain.
+	if err := d.Synchronise(); err != nil {
+		t.Fatalf("second Synchronise() error = %v", err)
+	}
+}
+
+func TestDownloader_InvalidBody(t *testing.T) {
+	genesis := block.FromHeader(block.Header{Data: []byte{}}, nil)
+	remote := append([]*block.Block{genesis}, makeChain(genesis, 5, 1)...)
+
+	// The lying peer serves the right headers with other transactions.
+	forged := make([]*block.Block, len(remote))
+	copy(forged, remote)
+	forged[3] = block.FromHeader(remote[3].Header(), makeChain(genesis, 1, 9)[0].Transactions())
+
+	chain := newTestChain([]*block.Block{genesis})
+	network := &testNetwork{}
+	d := newTestDownloader(chain, network)
+
+	liar := &testPeer{id: "liar", chain: forged, downloader: d}
+	network.peers = []Peer{liar}
+
+	if err := d.Synchronise(); !errors.Is(err, ErrNoPeers) {
+		t.Fatalf("Synchronise() error = %v, want %v", err, ErrNoPeers)
+	}
+
+	if chain.HasBlock(remote[1].Hash()) {
+		t.Errorf("blocks imported from a batch with an invalid body")
+	}
+}
+
+// dbChain also commits the imported blocks to the database the consensus
+// engine reads.
+type dbChain struct {
+	*testChain
+	db *prydb.Database
+}
+
+func (c *dbChain) AddRemoteBlock(blk *block.Block) error {
+	if err := c.testChain.AddRemoteBlock(blk); err != nil {
+		return err
+	}
+	return c.db.CommitBlock(blk)
+}
+
+func TestDownloader_SynchroniseProofOfStake(t *testing.T) {
+	// The database lives under the home directory.
+	t.Setenv("HOME", t.TempDir())
+
+	db, err := prydb.InitDB()
+	if err != nil {
+		t.Fatalf("InitDB() error = %v", err)
+	}
+
+	key, pub := crypto.GenerateKey()
+	validator := crypto.PubKeyToAddress(pub)
+
+	const chainID = 1
+	engine := pos.InitConsensus(db, 10, 1, chainID, 100, []common.Address{validator})
+
+	genesis := block.FromHeader(block.Header{
+		Timestamp: uint64(time.Now().Unix()) - 100,
+		Data:      []byte{},
+	}, nil)
+	if err := db.CommitBlock(genesis); err != nil {
+		t.Fatalf("CommitBlock() error = %v", err)
+	}
+
+	// The batch crosses epochs, whose validators depend on the bodies of
+	// the previous one.
+	remote := []*block.Block{genesis}
+	for parent := genesis; len(remote) <= 35; parent = remote[len(remote)-1] {
+		header := block.Header{
+			Height:          parent.Height() + 1,
+			Prev:            parent.Hash(),
+			Timestamp:       parent.Timestamp() + 1,
+			Difficulty:      pos.DiffInTurn,
+			TotalDifficulty: parent.TotalDifficulty() + pos.DiffInTurn,
+			Validator:       validator,
+			Data:            []byte{},
+			BaseFee:         gaspool.CalcBaseFee(parent.GasTarget(), parent.GasUsed(), parent.BaseFee()),
+		}
+		blk := block.FromHeader(header, nil)
+
+		digest := common.BytesToHash(crypto.Pm256(blk.SigningData(chainID)))
+		r, s, err := crypto.Sign(digest, key)
+		if err != nil {
+			t.Fatalf("Sign() error = %v", err)
+		}
+
+		signature := make([]byte, 64, block.SignatureLen)
+		r.FillBytes(signature[:32])
+		s.FillBytes(signature[32:])
+
+		signed, err := blk.SignBlock(append(signature, pub.Bytes()...))
+		if err != nil {
+			t.Fatalf("SignBlock() error = %v", err)
+		}
+		remote = append(remote, signed)
+	}
+
+	chain := &dbChain{testChain: newTestChain([]*block.Block{genesis}), db: db}
+	network := &testNetwork{}
+
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	d := NewDownloader(chain, engine, network, log)
+
+	network.peers = []Peer{&testPeer{id: "good", chain: remote, downloader: d}}
+
+	if err := d.Synchronise(); err != nil {
+		t.Fatalf("Synchronise() error = %v", err)
+	}
+
+	head, _ := chain.GetLatestBlock()
+	if want := remote[len(remote)-1]; head.Hash() != want.Hash() {
+		t.Fatalf("head = %d, want %d", head.Height(), want.Height())
+	}
+}
@@ -0,0 +1,14 @@
+package sync
+
+import "errors"
+
+var (
+	ErrBusy             = errors.New("synchronisation already running")
+	ErrNoPeers          = errors.New("no peers available")
+	ErrTimeout          = errors.New("request timed out")
+	ErrCancelled        = errors.New("synchronisation cancelled")
+	ErrEmptyResponse    = errors.New("empty response")
+	ErrInvalidHeaders   = errors.New("invalid header chain")
+	ErrInvalidBody      = errors.New("block body does not match header")
+	ErrNoCommonAncestor = errors.New("no common ancestor")
+)
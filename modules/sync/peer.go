@@ -0,0 +1,50 @@
+package sync
+
+import (
+	"github.com/polarysfoundation/polarys-chain/modules/common"
+	"github.com/polarysfoundation/polarys-chain/modules/core/block"
+	"github.com/polarysfoundation/polarys-chain/modules/core/transaction"
+)
+
+// Peer is a remote node the downloader can fetch from. Requests are
+// asynchronous: answers come back through Downloader.DeliverHeaders and
+// Downloader.DeliverBodies.
+type Peer interface {
+	ID() string
+	Head() (hash common.Hash, height uint64, totalDifficulty uint64)
+	RequestHeaders(origin uint64, amount uint64) error
+	RequestBodies(hashes []common.Hash) error
+}
+
+// Network lists the peers currently connected.
+type Network interface {
+	Peers() []Peer
+}
+
+type Chain interface {
+	GetLatestBlock() (*block.Block, error)
+	GetBlockByHash(hash common.Hash) (*block.Block, error)
+	HasBlock(hash common.Hash) bool
+	AddRemoteBlock(block *block.Block) error
+}
+
+// HeaderVerifier checks a header against its parent. consensus.Engine
+// implements it.
+type HeaderVerifier interface {
+	VerifyHeader(parent *block.Block, header *block.Block) error
+}
+
+// HeadersRequest is the payload of a GET_HEADERS message: amount canonical
+// headers starting at height origin.
+type HeadersRequest struct {
+	Origin uint64 `json:"origin"`
+	Amount uint64 `json:"amount"`
+}
+
+// Body carries the transactions and the evidence of the block with the
+// given hash.
+type Body struct {
+	Hash         common.Hash               `json:"hash"`
+	Transactions []transaction.Transaction `json:"transactions"`
+	Evidence     []block.Evidence          `json:"evidence,omitempty"`
+}